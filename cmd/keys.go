@@ -0,0 +1,152 @@
+package main
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/secretbox"
+	"cruder/internal/token"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+const keygenUsage = `usage: cruder keygen <dir>
+
+Generates an Ed25519 signing key in dir. The newest key signs new access
+tokens; older keys keep verifying until they are removed. Send SIGHUP to
+a running server to reload the directory.
+`
+
+const apikeyUsage = `usage: cruder apikey [flags] <name>
+
+Creates an API key and prints it once; only its hash is stored.
+`
+
+// loadSigningKeys reads JWT_KEYS_DIR and reloads it on SIGHUP. Without a
+// directory it signs with a key that only lives as long as the process.
+func loadSigningKeys() *token.KeySet {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		key, err := token.GenerateKey()
+		if err != nil {
+			log.Fatalf("failed to generate signing key: %v", err)
+		}
+		log.Printf("JWT_KEYS_DIR is not set, using an ephemeral signing key; tokens will not survive a restart")
+		return token.NewKeySet(key)
+	}
+
+	keys, err := token.LoadKeyDir(dir)
+	if err != nil {
+		log.Fatalf("failed to load signing keys: %v", err)
+	}
+	keySet := token.NewKeySet(keys...)
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			keys, err := token.LoadKeyDir(dir)
+			if err != nil {
+				log.Printf("failed to reload signing keys, keeping the current ones: %v", err)
+				continue
+			}
+			keySet.Replace(keys)
+			log.Printf("reloaded %d signing keys", len(keys))
+		}
+	}()
+	return keySet
+}
+
+// loadMFASecrets reads MFA_ENCRYPTION_KEY, 32 bytes in base64. Without it
+// MFA is unavailable, which is fatal if roles require it.
+func loadMFASecrets(requiredRoles []string) *secretbox.Box {
+	encoded := os.Getenv("MFA_ENCRYPTION_KEY")
+	if encoded == "" {
+		if len(requiredRoles) > 0 {
+			log.Fatalf("MFA_REQUIRED_ROLES is set but MFA_ENCRYPTION_KEY is not")
+		}
+		return nil
+	}
+
+	key, err := secretbox.ParseKey(encoded)
+	if err != nil {
+		log.Fatalf("invalid MFA_ENCRYPTION_KEY: %v", err)
+	}
+	box, err := secretbox.New(key)
+	if err != nil {
+		log.Fatalf("invalid MFA_ENCRYPTION_KEY: %v", err)
+	}
+	return box
+}
+
+func runKeygen(args []string) int {
+	flags := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), keygenUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	key, err := token.GenerateKey()
+	if err == nil {
+		var path string
+		if path, err = token.WriteKeyFile(flags.Arg(0), key); err == nil {
+			fmt.Println(path)
+			return 0
+		}
+	}
+	fmt.Fprintf(os.Stderr, "keygen failed: %v\n", err)
+	return 1
+}
+
+func runAPIKey(args []string) int {
+	flags := flag.NewFlagSet("apikey", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), apikeyUsage)
+		flags.PrintDefaults()
+	}
+	roles := flags.String("roles", "", "comma-separated roles granted to the key")
+	organization := flags.String("org", model.DefaultOrganizationSlug, "slug of the organization the key acts in")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	var granted []string
+	for _, role := range strings.Split(*roles, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			granted = append(granted, role)
+		}
+	}
+
+	repositories, services := setup(getDSN())
+	org, err := repositories.Organizations.GetBySlug(*organization)
+	if err == nil && org == nil {
+		err = fmt.Errorf("%w: %s", model.ErrOrganizationNotFound, *organization)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apikey failed: %v\n", err)
+		return 1
+	}
+
+	raw, apiKey, err := services.Tokens.CreateAPIKey(org.ID, flags.Arg(0), granted)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apikey failed: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "created API key %s (%s)\n", apiKey.ID, apiKey.Prefix)
+	fmt.Println(raw)
+	return 0
+}
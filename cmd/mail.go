@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"cruder/internal/mail"
+	"cruder/internal/notify"
+	"log"
+	"os"
+)
+
+// loadNotifier configures account emails from the environment. Messages are
+// queued and delivered in the background.
+func loadNotifier() *notify.Notifier {
+	sender := loadMailSender()
+	if sender == nil {
+		return nil
+	}
+
+	queueConfig := notify.DefaultQueueConfig()
+	queueConfig.Size = getEnvInt("MAIL_QUEUE_SIZE", queueConfig.Size)
+	queueConfig.MaxAttempts = getEnvInt("MAIL_MAX_ATTEMPTS", queueConfig.MaxAttempts)
+	queue := notify.NewQueue(sender, queueConfig)
+	go queue.Run(context.Background())
+
+	notifier, err := notify.NewNotifier(queue, os.Getenv("MAIL_LOCALE"))
+	if err != nil {
+		log.Fatalf("invalid MAIL_LOCALE: %v", err)
+	}
+	return notifier
+}
+
+// loadMailSender delivers over SMTP when SMTP_ADDR is set, or writes .eml
+// files to MAIL_DIR for development. Without either no emails are sent.
+func loadMailSender() mail.Sender {
+	from := getEnv("SMTP_FROM", "cruder@localhost")
+	if dir := os.Getenv("MAIL_DIR"); dir != "" {
+		sender, err := mail.NewFileSender(dir, from)
+		if err != nil {
+			log.Fatalf("invalid MAIL_DIR: %v", err)
+		}
+		log.Printf("writing emails to %s instead of sending them", dir)
+		return sender
+	}
+
+	addr := os.Getenv("SMTP_ADDR")
+	if addr == "" {
+		log.Printf("neither SMTP_ADDR nor MAIL_DIR is set, emails are disabled")
+		return nil
+	}
+
+	tlsMode := mail.TLSMode(os.Getenv("SMTP_TLS"))
+	switch tlsMode {
+	case mail.TLSOpportunistic, mail.TLSStartTLS, mail.TLSImplicit, mail.TLSNone:
+	default:
+		log.Fatalf("invalid SMTP_TLS %q, expected starttls, tls or none", tlsMode)
+	}
+	return mail.NewSMTPSender(mail.SMTPConfig{
+		Addr:     addr,
+		From:     from,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		TLS:      tlsMode,
+		Timeout:  getEnvDuration("SMTP_TIMEOUT", 0),
+	})
+}
@@ -1,33 +1,214 @@
 package main
 
 import (
+	"context"
 	"cruder/internal/controller"
+	"cruder/internal/export"
 	"cruder/internal/handler"
+	"cruder/internal/outbox"
+	"cruder/internal/password"
 	"cruder/internal/repository"
 	"cruder/internal/service"
+	"cruder/internal/stream"
+	"cruder/internal/webhook"
+	"expvar"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import":
+			os.Exit(runImport(os.Args[2:]))
+		case "keygen":
+			os.Exit(runKeygen(os.Args[2:]))
+		case "apikey":
+			os.Exit(runAPIKey(os.Args[2:]))
+		case "org":
+			os.Exit(runOrg(os.Args[2:]))
+		case "oidc-client":
+			os.Exit(runOIDCClient(os.Args[2:]))
+		}
+	}
+
+	dsn := getDSN()
+	repositories, services := setup(dsn)
+
+	relay := outbox.NewRelay(repositories, outbox.DefaultConfig(),
+		outbox.NewLogSink(log.Default()),
+		webhook.NewSink(repositories.Webhooks),
+	)
+	go relay.Run(context.Background())
+
+	dispatcher := webhook.NewDispatcher(repositories.Webhooks, webhook.DefaultConfig())
+	go dispatcher.Run(context.Background())
+
+	go services.Sessions.RunCleanup(context.Background())
+
+	if userCache := repositories.UserCache(); userCache != nil {
+		if err := repository.ListenUserCacheInvalidations(context.Background(), dsn, userCache); err != nil {
+			log.Fatalf("failed to start user cache listener: %v", err)
+		}
+		expvar.Publish("user_cache", expvar.Func(func() any { return userCache.Stats() }))
+	}
+
+	broker := stream.NewBroker(1000)
+	if err := stream.Listen(context.Background(), dsn, broker); err != nil {
+		log.Fatalf("failed to start event listener: %v", err)
+	}
+
+	exportDir := os.Getenv("EXPORT_DIR")
+	if exportDir == "" {
+		exportDir = filepath.Join(os.TempDir(), "cruder-exports")
+	}
+	exportJobs := export.NewJobManager(exportDir, 24*time.Hour)
+
+	controllerConfig := controller.DefaultConfig()
+	controllerConfig.UserCacheControl = getEnv("USER_CACHE_CONTROL", controllerConfig.UserCacheControl)
+	controllerConfig.CollectionCacheControl = getEnv("COLLECTION_CACHE_CONTROL", controllerConfig.CollectionCacheControl)
+
+	controllers := controller.NewController(services, broker, exportJobs, controllerConfig)
+	router := gin.Default()
+	// Client IPs feed rate limits, so X-Forwarded-For is only believed from
+	// the proxies listed here.
+	if err := router.SetTrustedProxies(getEnvList("TRUSTED_PROXIES")); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
+	handler.New(router, controllers)
+	if err := router.Run(); err != nil {
+		log.Fatalf("failed to run server: %v", err)
+	}
+}
+
+func getDSN() string {
 	dsn := os.Getenv("POSTGRES_DSN")
 	if dsn == "" {
 		dsn = "host=localhost port=5432 user=postgres password=postgres dbname=postgres sslmode=disable"
 	}
+	return dsn
+}
 
+func setup(dsn string) (*repository.Repository, *service.Service) {
 	dbConn, err := repository.NewPostgresConnection(dsn)
 	if err != nil {
 		log.Fatalf("failed to connect to database: %v", err)
 	}
 
 	repositories := repository.NewRepository(dbConn.DB())
-	services := service.NewService(repositories)
-	controllers := controller.NewController(services)
-	router := gin.Default()
-	handler.New(router, controllers.Users)
-	if err := router.Run(); err != nil {
-		log.Fatalf("failed to run server: %v", err)
+
+	cacheConfig := repository.DefaultUserCacheConfig()
+	cacheConfig.Enabled = getEnvBool("USER_CACHE_ENABLED", cacheConfig.Enabled)
+	cacheConfig.Size = getEnvInt("USER_CACHE_SIZE", cacheConfig.Size)
+	cacheConfig.TTL = getEnvDuration("USER_CACHE_TTL", cacheConfig.TTL)
+	cacheConfig.NegativeTTL = getEnvDuration("USER_CACHE_NEGATIVE_TTL", cacheConfig.NegativeTTL)
+	if cacheConfig.Enabled {
+		repositories.EnableUserCache(repository.NewUserCache(cacheConfig))
+	}
+
+	serviceConfig := service.DefaultConfig()
+	serviceConfig.MaxBatchSize = getEnvInt("MAX_BATCH_SIZE", serviceConfig.MaxBatchSize)
+	serviceConfig.PasswordParams.Memory = uint32(getEnvInt("ARGON2_MEMORY_KIB", int(serviceConfig.PasswordParams.Memory)))
+	serviceConfig.PasswordParams.Iterations = uint32(getEnvInt("ARGON2_ITERATIONS", int(serviceConfig.PasswordParams.Iterations)))
+	serviceConfig.PasswordParams.Parallelism = uint8(getEnvInt("ARGON2_PARALLELISM", int(serviceConfig.PasswordParams.Parallelism)))
+	serviceConfig.PasswordPolicy.MinLength = getEnvInt("PASSWORD_MIN_LENGTH", serviceConfig.PasswordPolicy.MinLength)
+	serviceConfig.PasswordPolicy.MaxLength = getEnvInt("PASSWORD_MAX_LENGTH", serviceConfig.PasswordPolicy.MaxLength)
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		breached, err := password.OpenBreachedFile(path)
+		if err != nil {
+			log.Fatalf("failed to load breached passwords: %v", err)
+		}
+		serviceConfig.PasswordPolicy.Breached = breached
+	}
+	serviceConfig.TokenIssuer = getEnv("JWT_ISSUER", serviceConfig.TokenIssuer)
+	serviceConfig.AccessTokenTTL = getEnvDuration("ACCESS_TOKEN_TTL", serviceConfig.AccessTokenTTL)
+	serviceConfig.RefreshTokenTTL = getEnvDuration("REFRESH_TOKEN_TTL", serviceConfig.RefreshTokenTTL)
+	serviceConfig.SigningKeys = loadSigningKeys()
+	serviceConfig.Notifier = loadNotifier()
+	serviceConfig.VerificationURL = getEnv("VERIFICATION_URL", serviceConfig.VerificationURL)
+	serviceConfig.VerificationTokenTTL = getEnvDuration("VERIFICATION_TOKEN_TTL", serviceConfig.VerificationTokenTTL)
+	serviceConfig.PasswordResetURL = getEnv("PASSWORD_RESET_URL", serviceConfig.PasswordResetURL)
+	serviceConfig.PasswordResetTokenTTL = getEnvDuration("PASSWORD_RESET_TOKEN_TTL", serviceConfig.PasswordResetTokenTTL)
+	serviceConfig.PasswordResetEmailLimit = getEnvInt("PASSWORD_RESET_EMAIL_LIMIT", serviceConfig.PasswordResetEmailLimit)
+	serviceConfig.PasswordResetIPLimit = getEnvInt("PASSWORD_RESET_IP_LIMIT", serviceConfig.PasswordResetIPLimit)
+	serviceConfig.MFARequiredRoles = getEnvList("MFA_REQUIRED_ROLES")
+	serviceConfig.MFASecrets = loadMFASecrets(serviceConfig.MFARequiredRoles)
+	serviceConfig.MFAChallengeTTL = getEnvDuration("MFA_CHALLENGE_TTL", serviceConfig.MFAChallengeTTL)
+	serviceConfig.AccountThrottle.DelayAfter = getEnvInt("LOGIN_DELAY_AFTER", serviceConfig.AccountThrottle.DelayAfter)
+	serviceConfig.AccountThrottle.LockAfter = getEnvInt("LOGIN_LOCK_AFTER", serviceConfig.AccountThrottle.LockAfter)
+	serviceConfig.AccountThrottle.LockDuration = getEnvDuration("LOGIN_LOCK_DURATION", serviceConfig.AccountThrottle.LockDuration)
+	serviceConfig.IPThrottle.DelayAfter = getEnvInt("LOGIN_IP_DELAY_AFTER", serviceConfig.IPThrottle.DelayAfter)
+	serviceConfig.IPThrottle.LockAfter = getEnvInt("LOGIN_IP_LOCK_AFTER", serviceConfig.IPThrottle.LockAfter)
+	serviceConfig.IPThrottle.LockDuration = getEnvDuration("LOGIN_IP_LOCK_DURATION", serviceConfig.IPThrottle.LockDuration)
+	serviceConfig.SessionRetention = getEnvDuration("SESSION_RETENTION", serviceConfig.SessionRetention)
+	serviceConfig.SessionCleanupInterval = getEnvDuration("SESSION_CLEANUP_INTERVAL", serviceConfig.SessionCleanupInterval)
+	serviceConfig.OIDCIssuer = strings.TrimSuffix(getEnv("OIDC_ISSUER", serviceConfig.OIDCIssuer), "/")
+	serviceConfig.OIDCCodeTTL = getEnvDuration("OIDC_CODE_TTL", serviceConfig.OIDCCodeTTL)
+	serviceConfig.SCIMBaseURL = strings.TrimSuffix(getEnv("SCIM_BASE_URL", serviceConfig.SCIMBaseURL), "/")
+	services := service.NewService(repositories, serviceConfig)
+
+	return repositories, services
+}
+
+func getEnv(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// getEnvList splits a comma-separated variable, nil if unset.
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
+	}
+	return parsed
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
+	}
+	return parsed
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
 	}
+	return parsed
 }
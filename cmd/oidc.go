@@ -0,0 +1,62 @@
+package main
+
+import (
+	"cruder/internal/model"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const oidcClientUsage = `usage: cruder oidc-client [flags] <name>
+
+Registers an application that signs users of the organization in through
+the OpenID Connect provider and prints its client id. Confidential clients
+also get a secret, printed once; only its hash is stored.
+`
+
+func runOIDCClient(args []string) int {
+	flags := flag.NewFlagSet("oidc-client", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), oidcClientUsage)
+		flags.PrintDefaults()
+	}
+	redirectURIs := flags.String("redirect-uris", "", "comma-separated redirect URIs the client may use")
+	public := flags.Bool("public", false, "register a public client without a secret, e.g. a single-page app")
+	organization := flags.String("org", model.DefaultOrganizationSlug, "slug of the organization whose users sign in")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	var uris []string
+	for _, uri := range strings.Split(*redirectURIs, ",") {
+		if uri = strings.TrimSpace(uri); uri != "" {
+			uris = append(uris, uri)
+		}
+	}
+
+	repositories, services := setup(getDSN())
+	org, err := repositories.Organizations.GetBySlug(*organization)
+	if err == nil && org == nil {
+		err = fmt.Errorf("%w: %s", model.ErrOrganizationNotFound, *organization)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "oidc-client failed: %v\n", err)
+		return 1
+	}
+
+	secret, client, err := services.OIDC.CreateClient(org.ID, flags.Arg(0), uris, !*public)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "oidc-client failed: %v\n", err)
+		return 1
+	}
+	fmt.Println(client.ID)
+	if secret != "" {
+		fmt.Println(secret)
+	}
+	return 0
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"cruder/internal/model"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const orgUsage = `usage: cruder org create <slug> <name>
+
+Creates an organization. Users, API keys and logins belong to exactly one
+organization.
+`
+
+func runOrg(args []string) int {
+	flags := flag.NewFlagSet("org", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), orgUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 3 || flags.Arg(0) != "create" {
+		flags.Usage()
+		return 2
+	}
+
+	repositories, _ := setup(getDSN())
+	organization := &model.Organization{Slug: flags.Arg(1), Name: flags.Arg(2)}
+	if err := repositories.Organizations.Create(organization); err != nil {
+		fmt.Fprintf(os.Stderr, "org failed: %v\n", err)
+		return 1
+	}
+	fmt.Println(organization.ID)
+	return 0
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+)
+
+// LRU is a size-bounded least-recently-used cache whose entries expire after a
+// per-entry TTL. It is not safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	size    int
+	items   map[K]*list.Element
+	order   *list.List
+	onEvict func(key K, value V)
+	now     func() time.Time
+}
+
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+// NewLRU creates a cache holding at most size entries. onEvict, if not nil, is
+// called for entries dropped because of capacity or expiry, but not for
+// explicit removals.
+func NewLRU[K comparable, V any](size int, onEvict func(key K, value V)) *LRU[K, V] {
+	return &LRU[K, V]{
+		size:    max(size, 1),
+		items:   make(map[K]*list.Element),
+		order:   list.New(),
+		onEvict: onEvict,
+		now:     time.Now,
+	}
+}
+
+func (lru *LRU[K, V]) Get(key K) (V, bool) {
+	element, ok := lru.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	item := element.Value.(*entry[K, V])
+	if !lru.now().Before(item.expiresAt) {
+		lru.evict(element)
+		var zero V
+		return zero, false
+	}
+
+	lru.order.MoveToFront(element)
+	return item.value, true
+}
+
+func (lru *LRU[K, V]) Add(key K, value V, ttl time.Duration) {
+	expiresAt := lru.now().Add(ttl)
+	if element, ok := lru.items[key]; ok {
+		item := element.Value.(*entry[K, V])
+		item.value = value
+		item.expiresAt = expiresAt
+		lru.order.MoveToFront(element)
+		return
+	}
+
+	lru.items[key] = lru.order.PushFront(&entry[K, V]{key: key, value: value, expiresAt: expiresAt})
+	if lru.order.Len() > lru.size {
+		lru.evict(lru.order.Back())
+	}
+}
+
+// Remove deletes key and returns the value it held, if any.
+func (lru *LRU[K, V]) Remove(key K) (V, bool) {
+	element, ok := lru.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	lru.order.Remove(element)
+	delete(lru.items, key)
+	return element.Value.(*entry[K, V]).value, true
+}
+
+func (lru *LRU[K, V]) Purge() {
+	lru.items = make(map[K]*list.Element)
+	lru.order.Init()
+}
+
+func (lru *LRU[K, V]) Len() int {
+	return lru.order.Len()
+}
+
+func (lru *LRU[K, V]) evict(element *list.Element) {
+	item := element.Value.(*entry[K, V])
+	lru.order.Remove(element)
+	delete(lru.items, item.key)
+	if lru.onEvict != nil {
+		lru.onEvict(item.key, item.value)
+	}
+}
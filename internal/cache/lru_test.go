@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldEvictLeastRecentlyUsedEntry(test *testing.T) {
+	// given
+	var evicted []string
+	lru := NewLRU(2, func(key string, value int) { evicted = append(evicted, key) })
+	lru.Add("a", 1, time.Minute)
+	lru.Add("b", 2, time.Minute)
+	lru.Get("a")
+
+	// when
+	lru.Add("c", 3, time.Minute)
+
+	// then
+	_, found := lru.Get("b")
+	assert.False(test, found)
+	assert.Equal(test, []string{"b"}, evicted)
+	assert.Equal(test, 2, lru.Len())
+}
+
+func TestShouldExpireEntriesAfterTTL(test *testing.T) {
+	// given
+	now := time.Now()
+	lru := NewLRU[string, int](10, nil)
+	lru.now = func() time.Time { return now }
+	lru.Add("short", 1, time.Second)
+	lru.Add("long", 2, time.Hour)
+
+	// when
+	now = now.Add(time.Minute)
+
+	// then
+	_, shortFound := lru.Get("short")
+	long, longFound := lru.Get("long")
+	assert.False(test, shortFound)
+	assert.True(test, longFound)
+	assert.Equal(test, 2, long)
+	assert.Equal(test, 1, lru.Len())
+}
+
+func TestShouldRemoveWithoutEvictCallback(test *testing.T) {
+	// given
+	evictions := 0
+	lru := NewLRU(2, func(key string, value int) { evictions++ })
+	lru.Add("a", 1, time.Minute)
+
+	// when
+	value, removed := lru.Remove("a")
+	_, removedAgain := lru.Remove("a")
+
+	// then
+	assert.True(test, removed)
+	assert.Equal(test, 1, value)
+	assert.False(test, removedAgain)
+	assert.Zero(test, evictions)
+}
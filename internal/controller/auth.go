@@ -0,0 +1,221 @@
+package controller
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthController struct {
+	services     *service.Service
+	tokenService service.TokenService
+}
+
+func NewAuthController(services *service.Service) *AuthController {
+	return &AuthController{services: services, tokenService: services.Tokens}
+}
+
+func (authController *AuthController) handleError(ctx *gin.Context, err error) {
+	var throttled *model.LoginThrottledError
+	if errors.As(err, &throttled) {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+	}
+
+	switch {
+	case errors.Is(err, model.ErrUserNotFound):
+		respond(ctx, http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrWeakPassword):
+		respond(ctx, http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrInvalidCredentials),
+		errors.Is(err, model.ErrInvalidToken),
+		errors.Is(err, model.ErrTokenReused),
+		errors.Is(err, model.ErrInvalidMFACode),
+		errors.Is(err, model.ErrUnauthenticated):
+		respond(ctx, http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrAccountDisabled), errors.Is(err, model.ErrForbidden):
+		respond(ctx, http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrMFAAlreadyEnabled), errors.Is(err, model.ErrMFANotEnrolled):
+		respond(ctx, http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrLoginThrottled), errors.Is(err, model.ErrAccountLocked):
+		respond(ctx, http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrMFAUnavailable):
+		respond(ctx, http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		respond(ctx, http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func (authController *AuthController) Login(ctx *gin.Context) {
+	var request model.LoginRequest
+	if err := bindBody(ctx, &request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	// Unknown organizations fail like unknown users.
+	services, err := authController.services.ForOrganization(request.Organization)
+	if errors.Is(err, model.ErrOrganizationNotFound) {
+		err = model.ErrInvalidCredentials
+	}
+	if err != nil {
+		authController.handleError(ctx, err)
+		return
+	}
+
+	user, err := services.Auth.Login(&request, ctx.ClientIP())
+	if err != nil {
+		authController.handleError(ctx, err)
+		return
+	}
+
+	// Users with a second factor get a challenge to complete at LoginMFA
+	// instead of tokens.
+	challenge, err := services.MFA.Challenge(user)
+	if err != nil {
+		authController.handleError(ctx, err)
+		return
+	}
+	if challenge != nil {
+		ctx.Header("Cache-Control", "no-store")
+		respond(ctx, http.StatusOK, challenge)
+		return
+	}
+
+	authController.issueTokens(ctx, user)
+}
+
+// LoginMFA completes a login challenge with a TOTP or recovery code.
+func (authController *AuthController) LoginMFA(ctx *gin.Context) {
+	var request model.MFALoginRequest
+	if err := bindBody(ctx, &request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	user, err := authController.services.MFA.CompleteChallenge(&request)
+	if err != nil {
+		authController.handleError(ctx, err)
+		return
+	}
+
+	authController.issueTokens(ctx, user)
+}
+
+// EnrollMFA enrolls users whose role requires a second factor they do not
+// have yet, as part of their login.
+func (authController *AuthController) EnrollMFA(ctx *gin.Context) {
+	var request model.MFATokenRequest
+	if err := bindBody(ctx, &request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	enrollment, err := authController.services.MFA.EnrollForChallenge(request.MFAToken)
+	if err != nil {
+		authController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	respond(ctx, http.StatusOK, enrollment)
+}
+
+func (authController *AuthController) issueTokens(ctx *gin.Context, user *model.User) {
+	tokens, err := authController.tokenService.IssueTokens(user, model.SessionClient{UserAgent: ctx.Request.UserAgent(), IPAddress: ctx.ClientIP()})
+	if err != nil {
+		authController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	respond(ctx, http.StatusOK, tokens)
+}
+
+func (authController *AuthController) Refresh(ctx *gin.Context) {
+	var request model.RefreshRequest
+	if err := bindBody(ctx, &request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	tokens, err := authController.tokenService.Refresh(request.RefreshToken)
+	if err != nil {
+		authController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	respond(ctx, http.StatusOK, tokens)
+}
+
+// Logout revokes the session of the refresh token in the body and of the
+// access token the request is authenticated with; at least one is required.
+func (authController *AuthController) Logout(ctx *gin.Context) {
+	var request model.LogoutRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := bindBody(ctx, &request); err != nil {
+			respondBindError(ctx, err)
+			return
+		}
+	}
+
+	sessionID := ""
+	principal, err := authenticateRequest(ctx, authController.tokenService)
+	if err != nil {
+		authController.handleError(ctx, err)
+		return
+	}
+	if principal != nil {
+		sessionID = principal.SessionID
+	}
+	if request.RefreshToken == "" && sessionID == "" {
+		authController.handleError(ctx, model.ErrUnauthenticated)
+		return
+	}
+
+	if err := authController.tokenService.Logout(request.RefreshToken, sessionID); err != nil {
+		authController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// JWKS publishes the public keys access tokens can be verified with,
+// including retired keys whose tokens may still be in use.
+func (authController *AuthController) JWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, authController.tokenService.JWKS())
+}
+
+func (authController *AuthController) ChangePassword(ctx *gin.Context) {
+	var request model.ChangePasswordRequest
+	if err := bindBody(ctx, &request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	authService := authController.services.As(principal(ctx)).Auth
+	if err := authService.ChangePassword(ctx.Param("uuid"), &request); err != nil {
+		authController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// Unlock lifts a lockout after failed logins before it expires.
+func (authController *AuthController) Unlock(ctx *gin.Context) {
+	authService := authController.services.As(principal(ctx)).Auth
+	if err := authService.Unlock(ctx.Param("uuid"), actor(ctx)); err != nil {
+		authController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const principalKey = "principal"
+
+// Authenticate requires either a JWT access token or an API key, sent as
+// "Authorization: Bearer <token>" or in the X-API-Key header.
+func Authenticate(tokenService service.TokenService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		principal, err := authenticateRequest(ctx, tokenService)
+		if err == nil && principal == nil {
+			err = model.ErrUnauthenticated
+		}
+		if err != nil {
+			status := http.StatusUnauthorized
+			challenge := `Bearer realm="cruder"`
+			switch {
+			case errors.Is(err, model.ErrInvalidToken):
+				challenge += `, error="invalid_token"`
+			case !errors.Is(err, model.ErrUnauthenticated):
+				status = http.StatusInternalServerError
+			}
+			if status == http.StatusUnauthorized {
+				ctx.Header("WWW-Authenticate", challenge)
+			}
+			ctx.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.Set(principalKey, principal)
+		ctx.Next()
+	}
+}
+
+// RequirePermission lets only principals holding permission through. Use it
+// after Authenticate for routes without a service to enforce it.
+func RequirePermission(permission model.Permission) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if authorize(ctx, permission) {
+			ctx.Next()
+		}
+	}
+}
+
+// authorize checks that the caller holds permission on every user and
+// otherwise aborts with 401 and a challenge when there is no caller, or 403.
+func authorize(ctx *gin.Context, permission model.Permission) bool {
+	err := service.Authorize(principal(ctx), permission, "")
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, model.ErrUnauthenticated):
+		ctx.Header("WWW-Authenticate", `Bearer realm="cruder"`)
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrForbidden):
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
+
+// authenticateRequest returns nil without an error when the request carries
+// no credentials.
+func authenticateRequest(ctx *gin.Context, tokenService service.TokenService) (*model.Principal, error) {
+	if key := ctx.GetHeader("X-API-Key"); key != "" {
+		return tokenService.AuthenticateAPIKey(key)
+	}
+
+	scheme, credentials, found := strings.Cut(ctx.GetHeader("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return nil, nil
+	}
+	credentials = strings.TrimSpace(credentials)
+	if strings.HasPrefix(credentials, service.APIKeyPrefix) {
+		return tokenService.AuthenticateAPIKey(credentials)
+	}
+	return tokenService.Authenticate(credentials)
+}
+
+func principal(ctx *gin.Context) *model.Principal {
+	if value, ok := ctx.Get(principalKey); ok {
+		return value.(*model.Principal)
+	}
+	return nil
+}
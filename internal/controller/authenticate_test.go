@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"cruder/internal/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldRequirePermissionForMetrics(test *testing.T) {
+	// given
+	gin.SetMode(gin.TestMode)
+	callers := map[string]struct {
+		principal *model.Principal
+		status    int
+	}{
+		"anonymous": {nil, http.StatusUnauthorized},
+		"support":   {&model.Principal{Kind: model.PrincipalUser, ID: "support-uuid", Roles: []string{"support"}}, http.StatusForbidden},
+		"admin":     {&model.Principal{Kind: model.PrincipalAPIKey, ID: "1", Roles: []string{"admin"}}, http.StatusOK},
+	}
+
+	for name, caller := range callers {
+		router := gin.New()
+		router.GET("/debug/vars", func(ctx *gin.Context) {
+			if caller.principal != nil {
+				ctx.Set(principalKey, caller.principal)
+			}
+		}, RequirePermission(model.PermissionReadMetrics), func(ctx *gin.Context) {
+			ctx.Status(http.StatusOK)
+		})
+		recorder := httptest.NewRecorder()
+
+		// when
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+
+		// then
+		assert.Equal(test, caller.status, recorder.Code, name)
+	}
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondConditional renders data with a strong ETag and Last-Modified, and
+// answers 304 instead when the request's validators show the client already
+// holds the same representation. Only the body is hashed, together with the
+// negotiated media type, since each format is a distinct representation.
+// Collections pass a zero lastModified: the newest updated_at of the members
+// does not change when one of them is deleted. What a caller may see depends
+// on its credentials, so responses vary on them and authenticated ones are
+// never cached publicly.
+func respondConditional(ctx *gin.Context, data any, lastModified time.Time, cacheControl string) {
+	format := negotiateFormat(ctx)
+	body, err := json.Marshal(data)
+	if format == "" || err != nil {
+		respond(ctx, http.StatusOK, data)
+		return
+	}
+
+	sum := sha256.Sum256(append([]byte(format+"\n"), body...))
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	header := ctx.Writer.Header()
+	header.Set("ETag", etag)
+	header.Add("Vary", "Accept, Authorization, X-API-Key")
+	if cacheControl != "" {
+		if principal(ctx) != nil {
+			cacheControl = privateCacheControl(cacheControl)
+		}
+		header.Set("Cache-Control", cacheControl)
+	}
+	if !lastModified.IsZero() {
+		header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(ctx.Request, etag, lastModified) {
+		ctx.Status(http.StatusNotModified)
+		ctx.Writer.WriteHeaderNow()
+		return
+	}
+	respond(ctx, http.StatusOK, data)
+}
+
+// privateCacheControl replaces a public directive with private, so shared
+// caches do not serve one caller's response to another.
+func privateCacheControl(cacheControl string) string {
+	directives := strings.Split(cacheControl, ",")
+	for i, directive := range directives {
+		if strings.EqualFold(strings.TrimSpace(directive), "public") {
+			directives[i] = strings.Replace(directive, strings.TrimSpace(directive), "private", 1)
+		}
+	}
+	return strings.Join(directives, ",")
+}
+
+// notModified evaluates If-None-Match, falling back to If-Modified-Since only
+// when no If-None-Match is sent (RFC 9110, section 13.2.2).
+func notModified(request *http.Request, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := request.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	ifModifiedSince := request.Header.Get("If-Modified-Since")
+	if ifModifiedSince == "" || lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(since)
+}
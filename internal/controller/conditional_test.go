@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"cruder/internal/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+var conditionalUpdatedAt = time.Date(2025, 11, 12, 9, 30, 15, 500, time.UTC)
+
+func setupConditionalRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/user", func(ctx *gin.Context) {
+		user := model.User{ID: 1, Username: "jdoe", UpdatedAt: conditionalUpdatedAt}
+		respondConditional(ctx, user, user.UpdatedAt, "public, max-age=60")
+	})
+	router.GET("/me", func(ctx *gin.Context) {
+		ctx.Set(principalKey, &model.Principal{Kind: model.PrincipalUser, ID: "uuid-1"})
+		user := model.User{ID: 1, Username: "jdoe", UpdatedAt: conditionalUpdatedAt}
+		respondConditional(ctx, user, user.UpdatedAt, "public, max-age=60")
+	})
+	router.GET("/users", func(ctx *gin.Context) {
+		respondConditional(ctx, []model.User{{ID: 1, Username: "jdoe", UpdatedAt: conditionalUpdatedAt}}, time.Time{}, "")
+	})
+	return router
+}
+
+func getWithHeaders(router *gin.Engine, path string, headers map[string]string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestShouldSendValidatorsAndCacheControl(test *testing.T) {
+	// given
+	router := setupConditionalRouter()
+
+	// when
+	response := getWithHeaders(router, "/user", nil)
+
+	// then
+	assert.Equal(test, http.StatusOK, response.Code)
+	assert.Regexp(test, `^"[0-9a-f]{32}"$`, response.Header().Get("ETag"))
+	assert.Equal(test, "Wed, 12 Nov 2025 09:30:15 GMT", response.Header().Get("Last-Modified"))
+	assert.Equal(test, "public, max-age=60", response.Header().Get("Cache-Control"))
+	assert.Equal(test, "Accept, Authorization, X-API-Key", response.Header().Get("Vary"))
+	assert.Contains(test, response.Body.String(), `"updated_at":"2025-11-12T09:30:15.0000005Z"`)
+}
+
+func TestShouldNotCacheAuthenticatedResponsesPublicly(test *testing.T) {
+	// given
+	router := setupConditionalRouter()
+
+	// when
+	response := getWithHeaders(router, "/me", nil)
+
+	// then
+	assert.Equal(test, http.StatusOK, response.Code)
+	assert.Equal(test, "private, max-age=60", response.Header().Get("Cache-Control"))
+	assert.Equal(test, "Accept, Authorization, X-API-Key", response.Header().Get("Vary"))
+}
+
+func TestShouldAnswerNotModifiedForMatchingETag(test *testing.T) {
+	// given
+	router := setupConditionalRouter()
+	etag := getWithHeaders(router, "/user", nil).Header().Get("ETag")
+
+	// when
+	matching := getWithHeaders(router, "/user", map[string]string{"If-None-Match": `"other", W/` + etag})
+	otherFormat := getWithHeaders(router, "/user", map[string]string{"If-None-Match": etag, "Accept": "application/yaml"})
+
+	// then
+	assert.Equal(test, http.StatusNotModified, matching.Code)
+	assert.Empty(test, matching.Body.String())
+	assert.Equal(test, etag, matching.Header().Get("ETag"))
+	assert.Equal(test, http.StatusOK, otherFormat.Code)
+	assert.NotEqual(test, etag, otherFormat.Header().Get("ETag"))
+}
+
+func TestShouldEvaluateIfModifiedSince(test *testing.T) {
+	// given
+	router := setupConditionalRouter()
+
+	// when
+	unchanged := getWithHeaders(router, "/user", map[string]string{"If-Modified-Since": "Wed, 12 Nov 2025 09:30:15 GMT"})
+	changed := getWithHeaders(router, "/user", map[string]string{"If-Modified-Since": "Wed, 12 Nov 2025 09:30:14 GMT"})
+	etagWins := getWithHeaders(router, "/user", map[string]string{
+		"If-Modified-Since": "Wed, 12 Nov 2025 09:30:15 GMT",
+		"If-None-Match":     `"stale"`,
+	})
+
+	// then
+	assert.Equal(test, http.StatusNotModified, unchanged.Code)
+	assert.Equal(test, http.StatusOK, changed.Code)
+	assert.Equal(test, http.StatusOK, etagWins.Code)
+}
+
+func TestShouldUseOnlyETagForCollections(test *testing.T) {
+	// given
+	router := setupConditionalRouter()
+	first := getWithHeaders(router, "/users", nil)
+
+	// when
+	second := getWithHeaders(router, "/users", map[string]string{"If-None-Match": first.Header().Get("ETag")})
+
+	// then
+	assert.Empty(test, first.Header().Get("Last-Modified"))
+	assert.Empty(test, first.Header().Get("Cache-Control"))
+	assert.Equal(test, http.StatusNotModified, second.Code)
+}
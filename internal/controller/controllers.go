@@ -1,13 +1,65 @@
 package controller
 
-import "cruder/internal/service"
+import (
+	"cruder/internal/export"
+	"cruder/internal/service"
+	"cruder/internal/stream"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Config struct {
+	// UserCacheControl and CollectionCacheControl are sent as Cache-Control
+	// on single-user and list reads respectively. Empty omits the header.
+	UserCacheControl       string
+	CollectionCacheControl string
+}
+
+func DefaultConfig() Config {
+	return Config{
+		UserCacheControl:       "no-cache",
+		CollectionCacheControl: "no-cache",
+	}
+}
 
 type Controller struct {
-	Users *UserController
+	Users    *UserController
+	Webhooks *WebhookController
+	Events   *EventController
+	Imports  *ImportController
+	Exports  *ExportController
+	Auth     *AuthController
+	Roles    *RoleController
+	Groups   *GroupController
+
+	Verifications  *VerificationController
+	PasswordResets *PasswordResetController
+	MFA            *MFAController
+	Sessions       *SessionController
+	OIDC           *OIDCController
+	SCIM           *SCIMController
+
+	Authenticate gin.HandlerFunc
 }
 
-func NewController(services *service.Service) *Controller {
+func NewController(services *service.Service, broker *stream.Broker, exportJobs *export.JobManager, config Config) *Controller {
 	return &Controller{
-		Users: NewUserController(services.Users),
+		Users:    NewUserController(services, config),
+		Webhooks: NewWebhookController(services),
+		Events:   NewEventController(broker),
+		Imports:  NewImportController(services),
+		Exports:  NewExportController(services, exportJobs),
+		Auth:     NewAuthController(services),
+		Roles:    NewRoleController(services),
+		Groups:   NewGroupController(services),
+
+		Verifications:  NewVerificationController(services),
+		PasswordResets: NewPasswordResetController(services),
+		MFA:            NewMFAController(services),
+		Sessions:       NewSessionController(services),
+		OIDC:           NewOIDCController(services),
+		SCIM:           NewSCIMController(services),
+
+		Authenticate: Authenticate(services.Tokens),
 	}
 }
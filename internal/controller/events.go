@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/stream"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultHeartbeatInterval = 15 * time.Second
+	sseRetryMillis           = 3000
+)
+
+type EventController struct {
+	broker            *stream.Broker
+	heartbeatInterval time.Duration
+}
+
+func NewEventController(broker *stream.Broker) *EventController {
+	return &EventController{broker: broker, heartbeatInterval: defaultHeartbeatInterval}
+}
+
+// StreamUserEvents serves user change events as Server-Sent Events. Clients
+// resume with the Last-Event-ID header (or last_event_id query parameter) and
+// narrow the stream with ?types=user.created,user.deleted. A reset event
+// tells clients whose last event is no longer logged to reload their state.
+func (eventController *EventController) StreamUserEvents(ctx *gin.Context) {
+	if !authorize(ctx, model.PermissionReadUsers) {
+		return
+	}
+
+	lastEventID, err := parseLastEventID(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid last event id"})
+		return
+	}
+
+	filter, err := parseEventFilter(ctx.Query("types"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscription, replay, complete := eventController.broker.Subscribe(lastEventID, filter)
+	defer eventController.broker.Unsubscribe(subscription)
+
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Render(http.StatusOK, sse.Event{Event: "ready", Retry: sseRetryMillis, Data: "ok"})
+	if !complete {
+		_ = sse.Encode(ctx.Writer, sse.Event{Event: "reset", Data: "events since the last event id were missed"})
+	}
+	for _, event := range replay {
+		eventController.writeEvent(ctx, event)
+	}
+	ctx.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventController.heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return
+			}
+			eventController.writeEvent(ctx, event)
+		case <-heartbeat.C:
+			_, _ = ctx.Writer.WriteString(": heartbeat\n\n")
+		}
+		ctx.Writer.Flush()
+	}
+}
+
+// writeEvent skips events about other tenants' users.
+func (eventController *EventController) writeEvent(ctx *gin.Context, event stream.Event) {
+	if event.TenantID != principal(ctx).TenantID {
+		return
+	}
+	_ = sse.Encode(ctx.Writer, sse.Event{
+		Id:    strconv.FormatInt(event.ID, 10),
+		Event: string(event.Type),
+		Data:  []byte(event.Data),
+	})
+}
+
+func parseLastEventID(ctx *gin.Context) (int64, error) {
+	value := ctx.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = ctx.Query("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func parseEventFilter(types string) (stream.Filter, error) {
+	filter := make(stream.Filter)
+	for _, value := range strings.Split(types, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		eventType := model.EventType(value)
+		if !eventType.IsValid() {
+			return nil, model.ErrUnknownEventType
+		}
+		filter[eventType] = true
+	}
+	return filter, nil
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/stream"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldRejectEventStreamWithoutPermission(test *testing.T) {
+	// given
+	gin.SetMode(gin.TestMode)
+	eventController := NewEventController(stream.NewBroker(10))
+	callers := map[string]struct {
+		principal *model.Principal
+		status    int
+		challenge string
+	}{
+		"anonymous": {nil, http.StatusUnauthorized, `Bearer realm="cruder"`},
+		"user":      {&model.Principal{Kind: model.PrincipalUser, ID: "user-uuid"}, http.StatusForbidden, ""},
+	}
+
+	for name, caller := range callers {
+		router := gin.New()
+		router.GET("/events", func(ctx *gin.Context) {
+			if caller.principal != nil {
+				ctx.Set(principalKey, caller.principal)
+			}
+		}, eventController.StreamUserEvents)
+		recorder := httptest.NewRecorder()
+
+		// when
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/events", nil))
+
+		// then
+		assert.Equal(test, caller.status, recorder.Code, name)
+		assert.Equal(test, caller.challenge, recorder.Header().Get("WWW-Authenticate"), name)
+	}
+}
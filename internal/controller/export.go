@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"cruder/internal/export"
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExportController struct {
+	services *service.Service
+	jobs     *export.JobManager
+}
+
+func NewExportController(services *service.Service, jobs *export.JobManager) *ExportController {
+	return &ExportController{services: services, jobs: jobs}
+}
+
+func (exportController *ExportController) handleError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, export.ErrJobNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrForbidden):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, export.ErrJobNotReady):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, export.ErrUnsupportedFormat), errors.Is(err, export.ErrUnknownColumn),
+		errors.Is(err, model.ErrInvalidFilter):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// authorize checks up front that the caller may read every user, since an
+// export is streamed or stored before the service sees a single row.
+func (exportController *ExportController) authorize(ctx *gin.Context) bool {
+	return authorize(ctx, model.PermissionReadUsers)
+}
+
+// ExportUsers streams users matching the list filters straight from the
+// database cursor. With async=true the export is written to local storage
+// instead and a job is returned to poll.
+func (exportController *ExportController) ExportUsers(ctx *gin.Context) {
+	if !exportController.authorize(ctx) {
+		return
+	}
+
+	format, err := export.ParseFormat(ctx.Query("format"))
+	if err != nil {
+		exportController.handleError(ctx, err)
+		return
+	}
+
+	columns, err := export.ParseColumns(ctx.Query("columns"))
+	if err != nil {
+		exportController.handleError(ctx, err)
+		return
+	}
+
+	filter, err := parseUserFilter(ctx)
+	if err != nil {
+		exportController.handleError(ctx, err)
+		return
+	}
+	users := exportController.services.As(principal(ctx)).Users
+	source := func(fn func(user *model.User) error) error {
+		return users.ExportUsers(filter, fn)
+	}
+
+	if async, _ := strconv.ParseBool(ctx.Query("async")); async {
+		job, err := exportController.jobs.Start(principal(ctx), format, columns, source)
+		if err != nil {
+			exportController.handleError(ctx, err)
+			return
+		}
+		ctx.Header("Location", "/api/v1/users/export/jobs/"+job.ID)
+		ctx.JSON(http.StatusAccepted, job)
+		return
+	}
+
+	ctx.Header("Content-Type", format.ContentType())
+	ctx.Header("Content-Disposition", `attachment; filename="users.`+string(format)+`"`)
+	ctx.Status(http.StatusOK)
+
+	writer, err := export.NewWriter(format, ctx.Writer, columns)
+	if err != nil {
+		log.Printf("export: %v", err)
+		return
+	}
+
+	rows := 0
+	err = source(func(user *model.User) error {
+		if err := writer.Write(user); err != nil {
+			return err
+		}
+		rows++
+		if rows%500 == 0 {
+			ctx.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		// Headers are already sent, so the truncated body is all the client sees.
+		log.Printf("export aborted after %d rows: %v", rows, err)
+		return
+	}
+	if err := writer.Close(); err != nil {
+		log.Printf("export: %v", err)
+	}
+}
+
+func (exportController *ExportController) GetExportJob(ctx *gin.Context) {
+	if !exportController.authorize(ctx) {
+		return
+	}
+
+	job, err := exportController.jobs.Get(principal(ctx), ctx.Param("id"))
+	if err != nil {
+		exportController.handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, job)
+}
+
+func (exportController *ExportController) DownloadExportJob(ctx *gin.Context) {
+	if !exportController.authorize(ctx) {
+		return
+	}
+
+	job, err := exportController.jobs.Get(principal(ctx), ctx.Param("id"))
+	if err != nil {
+		exportController.handleError(ctx, err)
+		return
+	}
+
+	path, err := exportController.jobs.Path(principal(ctx), job.ID)
+	if err != nil {
+		exportController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Header("Content-Type", job.Format.ContentType())
+	ctx.FileAttachment(path, "users."+string(job.Format))
+}
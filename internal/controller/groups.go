@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GroupController struct {
+	services *service.Service
+}
+
+func NewGroupController(services *service.Service) *GroupController {
+	return &GroupController{services: services}
+}
+
+// groups returns the group service as seen by the authenticated caller.
+func (groupController *GroupController) groups(ctx *gin.Context) service.GroupService {
+	return groupController.services.As(principal(ctx)).Groups
+}
+
+func (groupController *GroupController) handleError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrGroupNotFound), errors.Is(err, model.ErrUserNotFound),
+		errors.Is(err, model.ErrMemberNotFound), errors.Is(err, model.ErrSubgroupNotFound):
+		respond(ctx, http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrEmptyField), errors.Is(err, model.ErrInvalidGroupRole):
+		respond(ctx, http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrGroupExists), errors.Is(err, model.ErrGroupCycle),
+		errors.Is(err, model.ErrLastGroupOwner):
+		respond(ctx, http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrUnauthenticated):
+		respond(ctx, http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrForbidden):
+		respond(ctx, http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		respond(ctx, http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func parsePage(ctx *gin.Context) model.Page {
+	limit, _ := strconv.Atoi(ctx.Query("limit"))
+	offset, _ := strconv.Atoi(ctx.Query("offset"))
+	return model.Page{Limit: limit, Offset: offset}
+}
+
+func (groupController *GroupController) GetAllGroups(ctx *gin.Context) {
+	page, err := groupController.groups(ctx).ListGroups(parsePage(ctx))
+	if err != nil {
+		groupController.handleError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, page)
+}
+
+func (groupController *GroupController) GetGroup(ctx *gin.Context) {
+	group, err := groupController.groups(ctx).GetGroup(ctx.Param("id"))
+	if err != nil {
+		groupController.handleError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, group)
+}
+
+func (groupController *GroupController) CreateGroup(ctx *gin.Context) {
+	var request model.CreateGroupRequest
+	if err := bindBody(ctx, &request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	group, err := groupController.groups(ctx).CreateGroup(&request)
+	if err != nil {
+		groupController.handleError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusCreated, group)
+}
+
+func (groupController *GroupController) UpdateGroup(ctx *gin.Context) {
+	var request model.UpdateGroupRequest
+	if err := bindBody(ctx, &request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	group, err := groupController.groups(ctx).UpdateGroup(ctx.Param("id"), &request)
+	if err != nil {
+		groupController.handleError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, group)
+}
+
+func (groupController *GroupController) DeleteGroup(ctx *gin.Context) {
+	if err := groupController.groups(ctx).DeleteGroup(ctx.Param("id")); err != nil {
+		groupController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (groupController *GroupController) GetMembers(ctx *gin.Context) {
+	page, err := groupController.groups(ctx).ListMembers(ctx.Param("id"), parsePage(ctx))
+	if err != nil {
+		groupController.handleError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, page)
+}
+
+func (groupController *GroupController) SetMember(ctx *gin.Context) {
+	var request model.MembershipRequest
+	if err := bindBody(ctx, &request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	member, err := groupController.groups(ctx).SetMember(ctx.Param("id"), ctx.Param("uuid"), request.Role)
+	if err != nil {
+		groupController.handleError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, member)
+}
+
+func (groupController *GroupController) RemoveMember(ctx *gin.Context) {
+	if err := groupController.groups(ctx).RemoveMember(ctx.Param("id"), ctx.Param("uuid")); err != nil {
+		groupController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (groupController *GroupController) GetSubgroups(ctx *gin.Context) {
+	page, err := groupController.groups(ctx).ListSubgroups(ctx.Param("id"), parsePage(ctx))
+	if err != nil {
+		groupController.handleError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, page)
+}
+
+func (groupController *GroupController) AddSubgroup(ctx *gin.Context) {
+	if err := groupController.groups(ctx).AddSubgroup(ctx.Param("id"), ctx.Param("child_id")); err != nil {
+		groupController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (groupController *GroupController) RemoveSubgroup(ctx *gin.Context) {
+	if err := groupController.groups(ctx).RemoveSubgroup(ctx.Param("id"), ctx.Param("child_id")); err != nil {
+		groupController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (groupController *GroupController) GetUserGroups(ctx *gin.Context) {
+	page, err := groupController.groups(ctx).ListUserGroups(ctx.Param("uuid"), parsePage(ctx))
+	if err != nil {
+		groupController.handleError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, page)
+}
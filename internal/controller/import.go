@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"cruder/internal/importer"
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImportController struct {
+	services *service.Service
+}
+
+func NewImportController(services *service.Service) *ImportController {
+	return &ImportController{services: services}
+}
+
+// ImportUsers accepts a CSV or NDJSON body, either raw or as the "file" part
+// of a multipart upload, and streams it through the importer. With
+// report=csv the per-row outcome is streamed back as a CSV attachment,
+// otherwise a JSON summary is returned once every row is processed.
+func (importController *ImportController) ImportUsers(ctx *gin.Context) {
+	source, contentType, filename, err := importController.openSource(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format, err := importer.DetectFormat(ctx.Query("format"), contentType, filename)
+	if err != nil {
+		ctx.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	}
+
+	mapping, err := importer.ParseMapping(ctx.Query("map"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(ctx.DefaultQuery("dry_run", "false"))
+	options := model.ImportOptions{DryRun: dryRun, UpsertBy: ctx.Query("upsert")}
+	if err := importer.ValidateOptions(options); err != nil {
+		importController.handleError(ctx, err)
+		return
+	}
+	if err := importController.authorize(ctx, options); err != nil {
+		importController.handleError(ctx, err)
+		return
+	}
+	userImporter := importer.New(importController.services.As(principal(ctx)).Users)
+
+	reader, err := importer.NewRowReader(format, source, mapping)
+	if err != nil {
+		importController.handleError(ctx, err)
+		return
+	}
+
+	if ctx.Query("report") == "csv" {
+		importController.streamReport(ctx, userImporter, reader, options)
+		return
+	}
+
+	summary, err := userImporter.Run(reader, options, nil)
+	if err != nil {
+		importController.handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, summary)
+}
+
+func (importController *ImportController) streamReport(ctx *gin.Context, userImporter *importer.Importer, reader importer.RowReader, options model.ImportOptions) {
+	ctx.Header("Content-Type", "text/csv")
+	ctx.Header("Content-Disposition", `attachment; filename="import-report.csv"`)
+	ctx.Status(http.StatusOK)
+
+	report, err := importer.NewCSVReport(ctx.Writer)
+	if err != nil {
+		log.Printf("import report: %v", err)
+		return
+	}
+
+	rows := 0
+	_, err = userImporter.Run(reader, options, func(result model.ImportRowResult) error {
+		if err := report.Write(result); err != nil {
+			return err
+		}
+		rows++
+		if rows%100 == 0 {
+			if err := report.Flush(); err != nil {
+				return err
+			}
+			ctx.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("import report: %v", err)
+	}
+	if err := report.Flush(); err != nil {
+		log.Printf("import report: %v", err)
+	}
+}
+
+func (importController *ImportController) openSource(ctx *gin.Context) (io.Reader, string, string, error) {
+	mediaType, _, _ := mime.ParseMediaType(ctx.GetHeader("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return ctx.Request.Body, mediaType, "", nil
+	}
+
+	reader, err := ctx.Request.MultipartReader()
+	if err != nil {
+		return nil, "", "", err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, "", "", errors.New("multipart upload has no file part")
+		}
+		if err != nil {
+			return nil, "", "", err
+		}
+		if part.FormName() == "file" {
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			return part, partType, part.FileName(), nil
+		}
+	}
+}
+
+// authorize rejects the whole import up front rather than failing it row by
+// row.
+func (importController *ImportController) authorize(ctx *gin.Context, options model.ImportOptions) error {
+	if err := service.Authorize(principal(ctx), model.PermissionCreateUsers, ""); err != nil {
+		return err
+	}
+	if options.UpsertBy != "" {
+		return service.Authorize(principal(ctx), model.PermissionUpdateUsers, "")
+	}
+	return nil
+}
+
+func (importController *ImportController) handleError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, importer.ErrUnsupportedFormat):
+		ctx.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, importer.ErrMissingColumn), errors.Is(err, importer.ErrInvalidMapping),
+		errors.Is(err, model.ErrInvalidUpsertKey), errors.Is(err, io.EOF):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrForbidden):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
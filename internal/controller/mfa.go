@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MFAController struct {
+	services *service.Service
+}
+
+func NewMFAController(services *service.Service) *MFAController {
+	return &MFAController{services: services}
+}
+
+// mfa returns the MFA service as seen by the authenticated caller.
+func (mfaController *MFAController) mfa(ctx *gin.Context) service.MFAService {
+	return mfaController.services.As(principal(ctx)).MFA
+}
+
+func (mfaController *MFAController) handleError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrUserNotFound):
+		respond(ctx, http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrInvalidMFACode):
+		respond(ctx, http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrMFAAlreadyEnabled), errors.Is(err, model.ErrMFANotEnrolled):
+		respond(ctx, http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrUnauthenticated):
+		respond(ctx, http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrForbidden):
+		respond(ctx, http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrMFAUnavailable):
+		respond(ctx, http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		respond(ctx, http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func (mfaController *MFAController) GetStatus(ctx *gin.Context) {
+	status, err := mfaController.mfa(ctx).GetStatus(ctx.Param("uuid"))
+	if err != nil {
+		mfaController.handleError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, status)
+}
+
+// Enroll returns the secret and recovery codes. They are not shown again.
+func (mfaController *MFAController) Enroll(ctx *gin.Context) {
+	enrollment, err := mfaController.mfa(ctx).Enroll(ctx.Param("uuid"))
+	if err != nil {
+		mfaController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	respond(ctx, http.StatusCreated, enrollment)
+}
+
+func (mfaController *MFAController) ConfirmEnrollment(ctx *gin.Context) {
+	var request model.MFACodeRequest
+	if err := bindBody(ctx, &request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	if err := mfaController.mfa(ctx).ConfirmEnrollment(ctx.Param("uuid"), request.Code); err != nil {
+		mfaController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (mfaController *MFAController) RegenerateRecoveryCodes(ctx *gin.Context) {
+	var request model.MFACodeRequest
+	if err := bindBody(ctx, &request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	codes, err := mfaController.mfa(ctx).RegenerateRecoveryCodes(ctx.Param("uuid"), request.Code)
+	if err != nil {
+		mfaController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	respond(ctx, http.StatusOK, codes)
+}
+
+func (mfaController *MFAController) Disable(ctx *gin.Context) {
+	var request model.MFACodeRequest
+	if err := bindBody(ctx, &request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	if err := mfaController.mfa(ctx).Disable(ctx.Param("uuid"), request.Code); err != nil {
+		mfaController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// Reset removes a user's second factor without a code. With a required
+// role, the user enrolls again on their next login.
+func (mfaController *MFAController) Reset(ctx *gin.Context) {
+	if err := mfaController.mfa(ctx).Reset(ctx.Param("uuid")); err != nil {
+		mfaController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,292 @@
+package controller
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gin-gonic/gin/render"
+)
+
+//go:embed templates/authorize.html
+var templateFS embed.FS
+
+var authorizeTemplate = template.Must(template.ParseFS(templateFS, "templates/authorize.html"))
+
+// scopeDescriptions say on the consent page what each scope releases.
+var scopeDescriptions = map[string]string{
+	service.ScopeOpenID:  "your account ID",
+	service.ScopeProfile: "your username and name",
+	service.ScopeEmail:   "your email address",
+}
+
+type OIDCController struct {
+	services *service.Service
+}
+
+func NewOIDCController(services *service.Service) *OIDCController {
+	return &OIDCController{services: services}
+}
+
+// authorizePage is the data of the login and consent page. With MFAToken
+// set it asks for the second factor instead of the password.
+type authorizePage struct {
+	Client   string
+	Scopes   []string
+	Request  model.AuthorizationRequest
+	Login    string
+	MFAToken string
+	Error    string
+}
+
+// oauthError maps errors to the status and error code of an OAuth 2.0
+// error response.
+func oauthError(err error) (int, string) {
+	switch {
+	case errors.Is(err, model.ErrInvalidClient):
+		return http.StatusUnauthorized, "invalid_client"
+	case errors.Is(err, model.ErrInvalidGrant):
+		return http.StatusBadRequest, "invalid_grant"
+	case errors.Is(err, model.ErrUnsupportedGrantType):
+		return http.StatusBadRequest, "unsupported_grant_type"
+	case errors.Is(err, model.ErrUnsupportedResponseType):
+		return http.StatusBadRequest, "unsupported_response_type"
+	case errors.Is(err, model.ErrInvalidScope):
+		return http.StatusBadRequest, "invalid_scope"
+	case errors.Is(err, model.ErrInvalidOAuthRequest):
+		return http.StatusBadRequest, "invalid_request"
+	case errors.Is(err, model.ErrInvalidToken):
+		return http.StatusUnauthorized, "invalid_token"
+	default:
+		return http.StatusInternalServerError, "server_error"
+	}
+}
+
+func (oidcController *OIDCController) handleError(ctx *gin.Context, err error) {
+	status, code := oauthError(err)
+	switch code {
+	case "invalid_client":
+		ctx.Header("WWW-Authenticate", `Basic realm="cruder"`)
+	case "invalid_token":
+		ctx.Header("WWW-Authenticate", `Bearer realm="cruder", error="invalid_token"`)
+	}
+	ctx.JSON(status, gin.H{"error": code, "error_description": err.Error()})
+}
+
+func (oidcController *OIDCController) Discovery(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, oidcController.services.OIDC.Discovery())
+}
+
+// Authorize shows the login and consent page on GET and handles it on POST.
+// Errors about the client or redirect URI are shown on the page; all others
+// are sent to the client's redirect URI.
+func (oidcController *OIDCController) Authorize(ctx *gin.Context) {
+	var request model.AuthorizationRequest
+	if err := ctx.ShouldBindWith(&request, binding.Form); err != nil {
+		renderAuthorize(ctx, http.StatusBadRequest, authorizePage{Error: err.Error()})
+		return
+	}
+
+	client, err := oidcController.services.OIDC.ValidateAuthorization(&request)
+	if err != nil {
+		if client == nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, model.ErrInvalidClient) || errors.Is(err, model.ErrInvalidRedirectURI) {
+				status = http.StatusBadRequest
+			}
+			renderAuthorize(ctx, status, authorizePage{Error: err.Error()})
+			return
+		}
+		_, code := oauthError(err)
+		redirectToClient(ctx, request, url.Values{"error": {code}, "error_description": {err.Error()}})
+		return
+	}
+
+	page := authorizePage{Client: client.Name, Request: request}
+	for _, scope := range strings.Fields(request.Scope) {
+		if description, ok := scopeDescriptions[scope]; ok {
+			page.Scopes = append(page.Scopes, description)
+		}
+	}
+	if ctx.Request.Method == http.MethodGet {
+		renderAuthorize(ctx, http.StatusOK, page)
+		return
+	}
+
+	if ctx.PostForm("decision") != "allow" {
+		redirectToClient(ctx, request, url.Values{"error": {"access_denied"}, "error_description": {"the user denied the request"}})
+		return
+	}
+
+	user, status := oidcController.authenticate(ctx, client, &page)
+	if user == nil {
+		renderAuthorize(ctx, status, page)
+		return
+	}
+
+	code, err := oidcController.services.OIDC.Authorize(&request, user)
+	if errors.Is(err, model.ErrInvalidCredentials) {
+		page.MFAToken = ""
+		page.Error = err.Error()
+		renderAuthorize(ctx, http.StatusUnauthorized, page)
+		return
+	}
+	if err != nil {
+		redirectToClient(ctx, request, url.Values{"error": {"server_error"}, "error_description": {err.Error()}})
+		return
+	}
+	redirectToClient(ctx, request, url.Values{"code": {code}})
+}
+
+// authenticate logs the user in to the client's organization, asking for
+// the second factor when the user has one. Without a user it returns the
+// status to show page with.
+func (oidcController *OIDCController) authenticate(ctx *gin.Context, client *model.OIDCClient, page *authorizePage) (*model.User, int) {
+	if mfaToken := ctx.PostForm("mfa_token"); mfaToken != "" {
+		page.MFAToken = mfaToken
+		user, err := oidcController.services.MFA.CompleteChallenge(&model.MFALoginRequest{
+			MFAToken: mfaToken,
+			Code:     ctx.PostForm("code"),
+		})
+		if errors.Is(err, model.ErrInvalidToken) {
+			// The challenge expired or ran out of attempts: start over.
+			page.MFAToken = ""
+		}
+		if err != nil {
+			return nil, loginErrorStatus(ctx, page, err)
+		}
+		return user, 0
+	}
+
+	page.Login = ctx.PostForm("login")
+	services := oidcController.services.ForTenant(client.TenantID)
+	user, err := services.Auth.Login(&model.LoginRequest{Login: page.Login, Password: ctx.PostForm("password")}, ctx.ClientIP())
+	if err != nil {
+		return nil, loginErrorStatus(ctx, page, err)
+	}
+
+	challenge, err := services.MFA.Challenge(user)
+	if err != nil {
+		return nil, loginErrorStatus(ctx, page, err)
+	}
+	if challenge == nil {
+		return user, 0
+	}
+	if challenge.EnrollmentRequired {
+		page.Error = "Set up multi-factor authentication for your account before signing in here."
+		return nil, http.StatusForbidden
+	}
+	page.MFAToken = challenge.MFAToken
+	return nil, http.StatusOK
+}
+
+// loginErrorStatus puts err on page and returns the status matching it.
+func loginErrorStatus(ctx *gin.Context, page *authorizePage, err error) int {
+	page.Error = err.Error()
+
+	var throttled *model.LoginThrottledError
+	if errors.As(err, &throttled) {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+	}
+	switch {
+	case errors.Is(err, model.ErrInvalidCredentials),
+		errors.Is(err, model.ErrInvalidMFACode),
+		errors.Is(err, model.ErrInvalidToken):
+		return http.StatusUnauthorized
+	case errors.Is(err, model.ErrAccountDisabled):
+		return http.StatusForbidden
+	case errors.Is(err, model.ErrLoginThrottled), errors.Is(err, model.ErrAccountLocked):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func renderAuthorize(ctx *gin.Context, status int, page authorizePage) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("X-Frame-Options", "DENY")
+	ctx.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	ctx.Render(status, render.HTML{Template: authorizeTemplate, Name: "authorize.html", Data: page})
+}
+
+// redirectToClient sends the browser back to the validated redirect URI
+// with params and the request's state.
+func redirectToClient(ctx *gin.Context, request model.AuthorizationRequest, params url.Values) {
+	target, err := url.Parse(request.RedirectURI)
+	if err != nil {
+		renderAuthorize(ctx, http.StatusBadRequest, authorizePage{Error: model.ErrInvalidRedirectURI.Error()})
+		return
+	}
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if request.State != "" {
+		query.Set("state", request.State)
+	}
+	target.RawQuery = query.Encode()
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Redirect(http.StatusSeeOther, target.String())
+}
+
+// Token exchanges an authorization code. Clients authenticate with HTTP
+// Basic or with client_id and client_secret in the form; public clients
+// send only client_id.
+func (oidcController *OIDCController) Token(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Pragma", "no-cache")
+
+	var request model.OIDCTokenRequest
+	if err := ctx.ShouldBindWith(&request, binding.FormPost); err != nil {
+		oidcController.handleError(ctx, fmt.Errorf("%w: %v", model.ErrInvalidOAuthRequest, err))
+		return
+	}
+	if encodedID, encodedSecret, ok := ctx.Request.BasicAuth(); ok {
+		// Basic credentials are form-encoded before they are joined.
+		clientID, idErr := url.QueryUnescape(encodedID)
+		clientSecret, secretErr := url.QueryUnescape(encodedSecret)
+		if idErr != nil || secretErr != nil || request.ClientSecret != "" ||
+			(request.ClientID != "" && request.ClientID != clientID) {
+			oidcController.handleError(ctx, model.ErrInvalidOAuthRequest)
+			return
+		}
+		request.ClientID, request.ClientSecret = clientID, clientSecret
+	}
+
+	response, err := oidcController.services.OIDC.Exchange(&request)
+	if err != nil {
+		oidcController.handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// UserInfo returns the claims released to the bearer access token.
+func (oidcController *OIDCController) UserInfo(ctx *gin.Context) {
+	scheme, accessToken, found := strings.Cut(ctx.GetHeader("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(accessToken) == "" {
+		ctx.Header("WWW-Authenticate", `Bearer realm="cruder"`)
+		ctx.Status(http.StatusUnauthorized)
+		return
+	}
+
+	info, err := oidcController.services.OIDC.UserInfo(strings.TrimSpace(accessToken))
+	if err != nil {
+		oidcController.handleError(ctx, err)
+		return
+	}
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, info)
+}
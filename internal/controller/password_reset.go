@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PasswordResetController struct {
+	services *service.Service
+}
+
+func NewPasswordResetController(services *service.Service) *PasswordResetController {
+	return &PasswordResetController{services: services}
+}
+
+func (passwordResetController *PasswordResetController) handleError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrInvalidToken), errors.Is(err, model.ErrWeakPassword):
+		respond(ctx, http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrRateLimited):
+		respond(ctx, http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		respond(ctx, http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// RequestReset responds the same whether or not the email or organization
+// exists.
+func (passwordResetController *PasswordResetController) RequestReset(ctx *gin.Context) {
+	var request model.PasswordResetRequest
+	if err := bindBody(ctx, &request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	// Without a tenant no user matches, so unknown organizations still go
+	// through the rate limits like unknown emails.
+	services, err := passwordResetController.services.ForOrganization(request.Organization)
+	if errors.Is(err, model.ErrOrganizationNotFound) {
+		services, err = passwordResetController.services.ForTenant(""), nil
+	}
+	if err != nil {
+		passwordResetController.handleError(ctx, err)
+		return
+	}
+
+	if err := services.PasswordResets.RequestReset(request.Email, ctx.ClientIP()); err != nil {
+		passwordResetController.handleError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusAccepted, gin.H{"message": "if the email belongs to an account, a reset link has been sent"})
+}
+
+// ConfirmReset needs no authentication: the token is the proof.
+func (passwordResetController *PasswordResetController) ConfirmReset(ctx *gin.Context) {
+	var request model.ConfirmPasswordResetRequest
+	if err := bindBody(ctx, &request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	if err := passwordResetController.services.PasswordResets.ResetPassword(request.Token, request.NewPassword); err != nil {
+		passwordResetController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"cruder/internal/model"
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gin-gonic/gin/render"
+	"github.com/ugorji/go/codec"
+)
+
+const MIMECBOR = "application/cbor"
+
+// offeredTypes are the media types user endpoints can produce, in order of
+// preference when the Accept header allows several of them.
+var offeredTypes = []string{
+	binding.MIMEJSON,
+	binding.MIMEMSGPACK2,
+	binding.MIMEMSGPACK,
+	MIMECBOR,
+	binding.MIMEYAML2,
+	binding.MIMEYAML,
+	"text/yaml",
+	binding.MIMEXML,
+	binding.MIMEXML2,
+}
+
+var (
+	errNotAcceptable        = errors.New("none of the requested media types are supported")
+	errUnsupportedMediaType = errors.New("unsupported content type")
+)
+
+var cborHandle = &codec.CborHandle{}
+
+// NegotiateContent rejects requests up front when the response cannot be
+// produced in an accepted type (406) or the body is in an unsupported type
+// (415), so handlers never act on a request whose answer would be unusable.
+func NegotiateContent(ctx *gin.Context) {
+	if ctx.GetHeader("Accept") != "" && ctx.NegotiateFormat(offeredTypes...) == "" {
+		ctx.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"error": errNotAcceptable.Error(), "supported": offeredTypes})
+		return
+	}
+
+	if contentType := ctx.GetHeader("Content-Type"); contentType != "" && ctx.Request.ContentLength != 0 {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || !isOffered(mediaType) {
+			ctx.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{"error": errUnsupportedMediaType.Error(), "supported": offeredTypes})
+			return
+		}
+	}
+
+	ctx.Next()
+}
+
+func isOffered(mediaType string) bool {
+	for _, offered := range offeredTypes {
+		if offered == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// respond renders data in the format negotiated from the Accept header. A
+// request that accepts none of the offered types gets 406 as JSON.
+func respond(ctx *gin.Context, status int, data any) {
+	format := negotiateFormat(ctx)
+	if format == "" {
+		ctx.JSON(http.StatusNotAcceptable, gin.H{"error": errNotAcceptable.Error(), "supported": offeredTypes})
+		return
+	}
+
+	switch format {
+	case binding.MIMEMSGPACK, binding.MIMEMSGPACK2:
+		ctx.Render(status, render.MsgPack{Data: data})
+	case MIMECBOR:
+		ctx.Render(status, cborRender{data: data})
+	case binding.MIMEYAML, binding.MIMEYAML2, "text/yaml":
+		ctx.Render(status, render.YAML{Data: data})
+	case binding.MIMEXML, binding.MIMEXML2:
+		ctx.Render(status, render.XML{Data: xmlValue(data)})
+	default:
+		ctx.JSON(status, data)
+	}
+}
+
+// negotiateFormat returns the offered media type that best matches the
+// Accept header, JSON when there is none, or "" when nothing matches.
+func negotiateFormat(ctx *gin.Context) string {
+	if ctx.GetHeader("Accept") == "" {
+		return binding.MIMEJSON
+	}
+	return ctx.NegotiateFormat(offeredTypes...)
+}
+
+// bindBody decodes the request body according to its Content-Type, which
+// defaults to JSON when absent, and runs the binding validators.
+func bindBody(ctx *gin.Context, target any) error {
+	contentType := ctx.GetHeader("Content-Type")
+	if contentType == "" {
+		return ctx.ShouldBindWith(target, binding.JSON)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return errUnsupportedMediaType
+	}
+
+	switch mediaType {
+	case binding.MIMEJSON:
+		return ctx.ShouldBindWith(target, binding.JSON)
+	case binding.MIMEMSGPACK, binding.MIMEMSGPACK2:
+		return ctx.ShouldBindWith(target, binding.MsgPack)
+	case MIMECBOR:
+		if err := codec.NewDecoder(ctx.Request.Body, cborHandle).Decode(target); err != nil {
+			return err
+		}
+		return binding.Validator.ValidateStruct(target)
+	case binding.MIMEYAML, binding.MIMEYAML2, "text/yaml":
+		return ctx.ShouldBindWith(target, binding.YAML)
+	case binding.MIMEXML, binding.MIMEXML2:
+		return ctx.ShouldBindWith(target, binding.XML)
+	default:
+		return errUnsupportedMediaType
+	}
+}
+
+// respondBindError maps a bindBody failure to 415 or 400.
+func respondBindError(ctx *gin.Context, err error) {
+	if errors.Is(err, errUnsupportedMediaType) {
+		respond(ctx, http.StatusUnsupportedMediaType, gin.H{"error": err.Error(), "supported": offeredTypes})
+		return
+	}
+	respond(ctx, http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// xmlValue wraps user lists in a single root element, since encoding/xml
+// cannot produce a well-formed document from a bare slice.
+func xmlValue(data any) any {
+	switch typed := data.(type) {
+	case []model.User:
+		return model.UserList{Users: typed}
+	case []model.StatusTransition:
+		return model.StatusTransitionList{Transitions: typed}
+	case []model.AuditEvent:
+		return model.AuditEventList{Events: typed}
+	case []model.Session:
+		return model.SessionList{Sessions: typed}
+	}
+	return data
+}
+
+type cborRender struct {
+	data any
+}
+
+func (cbor cborRender) Render(writer http.ResponseWriter) error {
+	cbor.WriteContentType(writer)
+	return codec.NewEncoder(writer, cborHandle).Encode(cbor.data)
+}
+
+func (cbor cborRender) WriteContentType(writer http.ResponseWriter) {
+	writer.Header().Set("Content-Type", MIMECBOR)
+}
@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"bytes"
+	"cruder/internal/model"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/ugorji/go/codec"
+)
+
+type codecFormat struct {
+	mediaType string
+	marshal   func(value any) ([]byte, error)
+	unmarshal func(data []byte, value any) error
+}
+
+func codecMarshal(handle codec.Handle) func(value any) ([]byte, error) {
+	return func(value any) ([]byte, error) {
+		var output []byte
+		err := codec.NewEncoderBytes(&output, handle).Encode(value)
+		return output, err
+	}
+}
+
+func codecUnmarshal(handle codec.Handle) func(data []byte, value any) error {
+	return func(data []byte, value any) error {
+		return codec.NewDecoderBytes(data, handle).Decode(value)
+	}
+}
+
+var codecFormats = []codecFormat{
+	{"application/json", json.Marshal, json.Unmarshal},
+	{"application/msgpack", codecMarshal(&codec.MsgpackHandle{}), codecUnmarshal(&codec.MsgpackHandle{})},
+	{"application/cbor", codecMarshal(&codec.CborHandle{}), codecUnmarshal(&codec.CborHandle{})},
+	{"application/yaml", yaml.Marshal, yaml.Unmarshal},
+	{"application/xml", xml.Marshal, xml.Unmarshal},
+}
+
+func setupRenderRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(NegotiateContent)
+	router.POST("/echo", func(ctx *gin.Context) {
+		var request model.CreateUserRequest
+		if err := bindBody(ctx, &request); err != nil {
+			respondBindError(ctx, err)
+			return
+		}
+		respond(ctx, http.StatusCreated, model.User{ID: 7, UUID: "123e4567-e89b-12d3-a456-426614174000",
+			Username: request.Username, Email: request.Email, FullName: request.FullName})
+	})
+	router.GET("/list", func(ctx *gin.Context) {
+		respond(ctx, http.StatusOK, []model.User{{ID: 1, Username: "jdoe"}, {ID: 2, Username: "asmith"}})
+	})
+	return router
+}
+
+func TestShouldRoundTripUserInEveryFormat(test *testing.T) {
+	router := setupRenderRouter()
+	request := model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "Jöhn \"J\" <Doe> & Co"}
+
+	for _, format := range codecFormats {
+		test.Run(format.mediaType, func(subTest *testing.T) {
+			// given
+			body, err := format.marshal(request)
+			assert.NoError(subTest, err)
+			httpRequest := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(body))
+			httpRequest.Header.Set("Content-Type", format.mediaType)
+			httpRequest.Header.Set("Accept", format.mediaType)
+			recorder := httptest.NewRecorder()
+
+			// when
+			router.ServeHTTP(recorder, httpRequest)
+
+			// then
+			assert.Equal(subTest, http.StatusCreated, recorder.Code, recorder.Body.String())
+			assert.Contains(subTest, recorder.Header().Get("Content-Type"), format.mediaType)
+
+			var user model.User
+			assert.NoError(subTest, format.unmarshal(recorder.Body.Bytes(), &user))
+			assert.Equal(subTest, model.User{ID: 7, UUID: "123e4567-e89b-12d3-a456-426614174000",
+				Username: request.Username, Email: request.Email, FullName: request.FullName}, user)
+		})
+	}
+}
+
+func TestShouldWrapUserListInXMLRootElement(test *testing.T) {
+	// given
+	router := setupRenderRouter()
+	request := httptest.NewRequest(http.MethodGet, "/list", nil)
+	request.Header.Set("Accept", "text/xml")
+	recorder := httptest.NewRecorder()
+
+	// when
+	router.ServeHTTP(recorder, request)
+
+	// then
+	var list model.UserList
+	assert.NoError(test, xml.Unmarshal(recorder.Body.Bytes(), &list))
+	assert.Equal(test, "users", list.XMLName.Local)
+	assert.Len(test, list.Users, 2)
+	assert.Equal(test, "asmith", list.Users[1].Username)
+}
+
+func TestShouldDefaultToJSONForWildcardOrMissingAccept(test *testing.T) {
+	for _, accept := range []string{"", "*/*", "application/*"} {
+		// given
+		router := setupRenderRouter()
+		request := httptest.NewRequest(http.MethodGet, "/list", nil)
+		request.Header.Set("Accept", accept)
+		recorder := httptest.NewRecorder()
+
+		// when
+		router.ServeHTTP(recorder, request)
+
+		// then
+		assert.Equal(test, http.StatusOK, recorder.Code)
+		assert.Contains(test, recorder.Header().Get("Content-Type"), "application/json", accept)
+	}
+}
+
+func TestShouldRejectUnsupportedAcceptWith406(test *testing.T) {
+	// given
+	router := setupRenderRouter()
+	request := httptest.NewRequest(http.MethodGet, "/list", nil)
+	request.Header.Set("Accept", "application/pdf")
+	recorder := httptest.NewRecorder()
+
+	// when
+	router.ServeHTTP(recorder, request)
+
+	// then
+	assert.Equal(test, http.StatusNotAcceptable, recorder.Code)
+}
+
+func TestShouldRejectUnsupportedContentTypeWith415(test *testing.T) {
+	// given
+	router := setupRenderRouter()
+	request := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader([]byte("username=jdoe")))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+
+	// when
+	router.ServeHTTP(recorder, request)
+
+	// then
+	assert.Equal(test, http.StatusUnsupportedMediaType, recorder.Code)
+}
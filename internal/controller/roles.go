@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleController struct {
+	services *service.Service
+}
+
+func NewRoleController(services *service.Service) *RoleController {
+	return &RoleController{services: services}
+}
+
+// roles returns the role service as seen by the authenticated caller.
+func (roleController *RoleController) roles(ctx *gin.Context) service.RoleService {
+	return roleController.services.As(principal(ctx)).Roles
+}
+
+func (roleController *RoleController) handleError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrUserNotFound):
+		respond(ctx, http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrInvalidRole):
+		respond(ctx, http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrUnauthenticated):
+		respond(ctx, http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrForbidden):
+		respond(ctx, http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		respond(ctx, http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func (roleController *RoleController) GetRoles(ctx *gin.Context) {
+	roles, err := roleController.roles(ctx).GetRoles(ctx.Param("uuid"))
+	if err != nil {
+		roleController.handleError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, roles)
+}
+
+func (roleController *RoleController) SetRoles(ctx *gin.Context) {
+	var request model.RolesRequest
+	if err := bindBody(ctx, &request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	roles, err := roleController.roles(ctx).SetRoles(ctx.Param("uuid"), request.Roles)
+	if err != nil {
+		roleController.handleError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, roles)
+}
@@ -0,0 +1,246 @@
+package controller
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const scimContentType = "application/scim+json"
+
+type SCIMController struct {
+	services *service.Service
+}
+
+func NewSCIMController(services *service.Service) *SCIMController {
+	return &SCIMController{services: services}
+}
+
+// scim returns the SCIM service as seen by the authenticated caller.
+func (scimController *SCIMController) scim(ctx *gin.Context) service.SCIMService {
+	return scimController.services.As(principal(ctx)).SCIM
+}
+
+// scimError maps errors to the status and scimType of a SCIM error response.
+func scimError(err error) (int, string) {
+	switch {
+	case errors.Is(err, model.ErrUserNotFound):
+		return http.StatusNotFound, ""
+	case errors.Is(err, model.ErrUnauthenticated), errors.Is(err, model.ErrInvalidToken):
+		return http.StatusUnauthorized, ""
+	case errors.Is(err, model.ErrForbidden):
+		return http.StatusForbidden, ""
+	case errors.Is(err, model.ErrUserAlreadyExists):
+		return http.StatusConflict, "uniqueness"
+	case errors.Is(err, model.ErrInvalidFilter):
+		return http.StatusBadRequest, "invalidFilter"
+	case errors.Is(err, model.ErrInvalidSCIMPath):
+		return http.StatusBadRequest, "invalidPath"
+	case errors.Is(err, model.ErrSCIMNoTarget):
+		return http.StatusBadRequest, "noTarget"
+	case errors.Is(err, model.ErrSCIMMutability), errors.Is(err, model.ErrIllegalStatusTransition):
+		return http.StatusBadRequest, "mutability"
+	case errors.Is(err, model.ErrInvalidSCIMSyntax):
+		return http.StatusBadRequest, "invalidSyntax"
+	case errors.Is(err, model.ErrInvalidSCIMValue), errors.Is(err, model.ErrEmptyField),
+		errors.Is(err, model.ErrInvalidEmail), errors.Is(err, model.ErrWeakPassword):
+		return http.StatusBadRequest, "invalidValue"
+	default:
+		return http.StatusInternalServerError, ""
+	}
+}
+
+func (scimController *SCIMController) handleError(ctx *gin.Context, err error) {
+	status, scimType := scimError(err)
+	respondSCIMError(ctx, status, scimType, err.Error())
+}
+
+func respondSCIMError(ctx *gin.Context, status int, scimType, detail string) {
+	if status == http.StatusUnauthorized {
+		ctx.Header("WWW-Authenticate", `Bearer realm="cruder"`)
+	}
+	respondSCIM(ctx, status, model.SCIMError{
+		Schemas:  []string{model.SCIMSchemaError},
+		Status:   strconv.Itoa(status),
+		SCIMType: scimType,
+		Detail:   detail,
+	})
+}
+
+func respondSCIM(ctx *gin.Context, status int, data any) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		ctx.Status(http.StatusInternalServerError)
+		return
+	}
+	ctx.Data(status, scimContentType, body)
+}
+
+// bindSCIM decodes a JSON body, sent as application/scim+json or
+// application/json.
+func bindSCIM(ctx *gin.Context, target any) error {
+	if err := json.NewDecoder(ctx.Request.Body).Decode(target); err != nil {
+		return fmt.Errorf("%w: %v", model.ErrInvalidSCIMSyntax, err)
+	}
+	return nil
+}
+
+// Authenticate works like the API's Authenticate but answers with SCIM
+// errors. Identity providers use an API key as bearer token.
+func (scimController *SCIMController) Authenticate(ctx *gin.Context) {
+	principal, err := authenticateRequest(ctx, scimController.services.Tokens)
+	if err == nil && principal == nil {
+		err = model.ErrUnauthenticated
+	}
+	if err != nil {
+		scimController.handleError(ctx, err)
+		ctx.Abort()
+		return
+	}
+
+	ctx.Set(principalKey, principal)
+	ctx.Next()
+}
+
+func (scimController *SCIMController) ServiceProviderConfig(ctx *gin.Context) {
+	respondSCIM(ctx, http.StatusOK, scimController.services.SCIM.ServiceProviderConfig())
+}
+
+func (scimController *SCIMController) GetSchemas(ctx *gin.Context) {
+	schemas := scimController.services.SCIM.Schemas()
+	respondSCIM(ctx, http.StatusOK, scimList(schemas, len(schemas)))
+}
+
+func (scimController *SCIMController) GetSchema(ctx *gin.Context) {
+	for _, schema := range scimController.services.SCIM.Schemas() {
+		if schema.ID == ctx.Param("id") {
+			respondSCIM(ctx, http.StatusOK, schema)
+			return
+		}
+	}
+	respondSCIMError(ctx, http.StatusNotFound, "", "schema not found")
+}
+
+func (scimController *SCIMController) GetResourceTypes(ctx *gin.Context) {
+	resourceTypes := scimController.services.SCIM.ResourceTypes()
+	respondSCIM(ctx, http.StatusOK, scimList(resourceTypes, len(resourceTypes)))
+}
+
+func (scimController *SCIMController) GetResourceType(ctx *gin.Context) {
+	for _, resourceType := range scimController.services.SCIM.ResourceTypes() {
+		if resourceType.ID == ctx.Param("id") {
+			respondSCIM(ctx, http.StatusOK, resourceType)
+			return
+		}
+	}
+	respondSCIMError(ctx, http.StatusNotFound, "", "resource type not found")
+}
+
+func scimList(resources any, total int) model.SCIMListResponse {
+	return model.SCIMListResponse{
+		Schemas:      []string{model.SCIMSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   1,
+		ItemsPerPage: total,
+		Resources:    resources,
+	}
+}
+
+func (scimController *SCIMController) ListUsers(ctx *gin.Context) {
+	query := model.SCIMListQuery{Filter: ctx.Query("filter"), StartIndex: 1, Count: service.SCIMMaxResults}
+	params := []struct {
+		name   string
+		target *int
+	}{
+		{"startIndex", &query.StartIndex},
+		{"count", &query.Count},
+	}
+	for _, param := range params {
+		value := ctx.Query(param.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			scimController.handleError(ctx, fmt.Errorf("%w: %s must be an integer", model.ErrInvalidSCIMValue, param.name))
+			return
+		}
+		*param.target = parsed
+	}
+
+	list, err := scimController.scim(ctx).ListUsers(query)
+	if err != nil {
+		scimController.handleError(ctx, err)
+		return
+	}
+	respondSCIM(ctx, http.StatusOK, list)
+}
+
+func (scimController *SCIMController) GetUser(ctx *gin.Context) {
+	user, err := scimController.scim(ctx).GetUser(ctx.Param("id"))
+	if err != nil {
+		scimController.handleError(ctx, err)
+		return
+	}
+	respondSCIM(ctx, http.StatusOK, user)
+}
+
+func (scimController *SCIMController) CreateUser(ctx *gin.Context) {
+	var resource model.SCIMUser
+	if err := bindSCIM(ctx, &resource); err != nil {
+		scimController.handleError(ctx, err)
+		return
+	}
+
+	user, err := scimController.scim(ctx).CreateUser(&resource, actor(ctx))
+	if err != nil {
+		scimController.handleError(ctx, err)
+		return
+	}
+	ctx.Header("Location", user.Meta.Location)
+	respondSCIM(ctx, http.StatusCreated, user)
+}
+
+func (scimController *SCIMController) ReplaceUser(ctx *gin.Context) {
+	var resource model.SCIMUser
+	if err := bindSCIM(ctx, &resource); err != nil {
+		scimController.handleError(ctx, err)
+		return
+	}
+
+	user, err := scimController.scim(ctx).ReplaceUser(ctx.Param("id"), &resource, actor(ctx))
+	if err != nil {
+		scimController.handleError(ctx, err)
+		return
+	}
+	respondSCIM(ctx, http.StatusOK, user)
+}
+
+func (scimController *SCIMController) PatchUser(ctx *gin.Context) {
+	var patch model.SCIMPatchRequest
+	if err := bindSCIM(ctx, &patch); err != nil {
+		scimController.handleError(ctx, err)
+		return
+	}
+
+	user, err := scimController.scim(ctx).PatchUser(ctx.Param("id"), &patch, actor(ctx))
+	if err != nil {
+		scimController.handleError(ctx, err)
+		return
+	}
+	respondSCIM(ctx, http.StatusOK, user)
+}
+
+func (scimController *SCIMController) DeleteUser(ctx *gin.Context) {
+	if err := scimController.scim(ctx).DeleteUser(ctx.Param("id")); err != nil {
+		scimController.handleError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"cruder/internal/service"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupSCIMRouter serves the SCIM routes that answer without a database.
+func setupSCIMRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	scimController := NewSCIMController(service.NewService(repository.NewRepository(nil), service.DefaultConfig()))
+	router.GET("/scim/v2/ServiceProviderConfig", scimController.ServiceProviderConfig)
+	router.GET("/scim/v2/Schemas/:id", scimController.GetSchema)
+	router.GET("/scim/v2/ResourceTypes", scimController.GetResourceTypes)
+	router.GET("/scim/v2/Users", scimController.Authenticate, scimController.ListUsers)
+	return router
+}
+
+func TestShouldAnswerUnauthenticatedSCIMRequestWithSCIMError(test *testing.T) {
+	// given
+	router := setupSCIMRouter()
+	recorder := httptest.NewRecorder()
+
+	// when
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, `/scim/v2/Users?filter=userName+eq+"bjensen"`, nil))
+
+	// then
+	assert.Equal(test, http.StatusUnauthorized, recorder.Code)
+	assert.Equal(test, scimContentType, recorder.Header().Get("Content-Type"))
+	assert.Equal(test, `Bearer realm="cruder"`, recorder.Header().Get("WWW-Authenticate"))
+	var body model.SCIMError
+	require.NoError(test, json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Equal(test, []string{model.SCIMSchemaError}, body.Schemas)
+	assert.Equal(test, "401", body.Status)
+}
+
+func TestShouldServeSCIMDiscoveryWithoutAuthentication(test *testing.T) {
+	// given
+	router := setupSCIMRouter()
+	paths := map[string]int{
+		"/scim/v2/ServiceProviderConfig":                               http.StatusOK,
+		"/scim/v2/ResourceTypes":                                       http.StatusOK,
+		"/scim/v2/Schemas/urn:ietf:params:scim:schemas:core:2.0:User":  http.StatusOK,
+		"/scim/v2/Schemas/urn:ietf:params:scim:schemas:core:2.0:Group": http.StatusNotFound,
+	}
+
+	for path, status := range paths {
+		recorder := httptest.NewRecorder()
+
+		// when
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+
+		// then
+		assert.Equal(test, status, recorder.Code, path)
+		assert.Equal(test, scimContentType, recorder.Header().Get("Content-Type"), path)
+	}
+}
+
+func TestShouldMapErrorsToSCIMTypes(test *testing.T) {
+	// given
+	errs := map[error]struct {
+		status   int
+		scimType string
+	}{
+		model.ErrUserNotFound:                           {http.StatusNotFound, ""},
+		model.ErrForbidden:                              {http.StatusForbidden, ""},
+		model.ErrUserAlreadyExists:                      {http.StatusConflict, "uniqueness"},
+		fmt.Errorf("%w: bad", model.ErrInvalidFilter):   {http.StatusBadRequest, "invalidFilter"},
+		model.ErrInvalidSCIMPath:                        {http.StatusBadRequest, "invalidPath"},
+		model.ErrSCIMNoTarget:                           {http.StatusBadRequest, "noTarget"},
+		model.ErrIllegalStatusTransition:                {http.StatusBadRequest, "mutability"},
+		model.ErrInvalidSCIMSyntax:                      {http.StatusBadRequest, "invalidSyntax"},
+		fmt.Errorf("userName: %w", model.ErrEmptyField): {http.StatusBadRequest, "invalidValue"},
+		errors.New("unexpected database failure"):       {http.StatusInternalServerError, ""},
+	}
+
+	for err, expected := range errs {
+		// when
+		status, scimType := scimError(err)
+
+		// then
+		assert.Equal(test, expected.status, status, err.Error())
+		assert.Equal(test, expected.scimType, scimType, err.Error())
+	}
+}
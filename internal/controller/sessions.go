@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionController struct {
+	services *service.Service
+}
+
+func NewSessionController(services *service.Service) *SessionController {
+	return &SessionController{services: services}
+}
+
+// sessions returns the session service as seen by the authenticated caller.
+func (sessionController *SessionController) sessions(ctx *gin.Context) service.SessionService {
+	return sessionController.services.As(principal(ctx)).Sessions
+}
+
+func (sessionController *SessionController) handleError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrUserNotFound), errors.Is(err, model.ErrSessionNotFound):
+		respond(ctx, http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrUnauthenticated):
+		respond(ctx, http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrForbidden):
+		respond(ctx, http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		respond(ctx, http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// ownSession returns the caller's session id when the caller is the user
+// uuid, so it can be marked current or kept.
+func ownSession(ctx *gin.Context, uuid string) string {
+	if caller := principal(ctx); caller != nil && caller.Kind == model.PrincipalUser && caller.ID == uuid {
+		return caller.SessionID
+	}
+	return ""
+}
+
+func (sessionController *SessionController) ListSessions(ctx *gin.Context) {
+	uuid := ctx.Param("uuid")
+	sessions, err := sessionController.sessions(ctx).ListSessions(uuid, ownSession(ctx, uuid))
+	if err != nil {
+		sessionController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	respond(ctx, http.StatusOK, sessions)
+}
+
+func (sessionController *SessionController) RevokeSession(ctx *gin.Context) {
+	err := sessionController.sessions(ctx).RevokeSession(ctx.Param("uuid"), ctx.Param("id"), actor(ctx))
+	if err != nil {
+		sessionController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// RevokeOtherSessions signs the user out everywhere but the caller's own
+// session. An admin revoking another user's sessions revokes all of them.
+func (sessionController *SessionController) RevokeOtherSessions(ctx *gin.Context) {
+	uuid := ctx.Param("uuid")
+	err := sessionController.sessions(ctx).RevokeOtherSessions(uuid, ownSession(ctx, uuid), actor(ctx))
+	if err != nil {
+		sessionController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -4,108 +4,201 @@ import (
 	"cruder/internal/model"
 	"cruder/internal/service"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 type UserController struct {
-	userService service.UserService
+	services *service.Service
+	config   Config
 }
 
-func NewUserController(userService service.UserService) *UserController {
-	return &UserController{userService: userService}
+func NewUserController(services *service.Service, config Config) *UserController {
+	return &UserController{services: services, config: config}
 }
 
-func (userController *UserController) handleError(ctx *gin.Context, err error) {
+// users returns the user service as seen by the authenticated caller.
+func (userController *UserController) users(ctx *gin.Context) service.UserService {
+	return userController.services.As(principal(ctx)).Users
+}
+
+func (userController *UserController) errorStatus(err error) int {
 	switch {
 	case errors.Is(err, model.ErrUserNotFound):
-		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-	case errors.Is(err, model.ErrEmptyField), errors.Is(err, model.ErrInvalidEmail):
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return http.StatusNotFound
+	case errors.Is(err, model.ErrUnauthenticated):
+		return http.StatusUnauthorized
+	case errors.Is(err, model.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, model.ErrEmptyField), errors.Is(err, model.ErrInvalidEmail),
+		errors.Is(err, model.ErrUnknownBatchOp), errors.Is(err, model.ErrInvalidBatchData),
+		errors.Is(err, model.ErrInvalidFilter), errors.Is(err, model.ErrWeakPassword):
+		return http.StatusBadRequest
+	case errors.Is(err, model.ErrUserAlreadyExists), errors.Is(err, model.ErrIllegalStatusTransition):
+		return http.StatusConflict
+	case errors.Is(err, model.ErrBatchTooLarge):
+		return http.StatusRequestEntityTooLarge
 	default:
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return http.StatusInternalServerError
+	}
+}
+
+func (userController *UserController) handleError(ctx *gin.Context, err error) {
+	respond(ctx, userController.errorStatus(err), gin.H{"error": err.Error()})
+}
+
+func parseUserFilter(ctx *gin.Context) (model.UserFilter, error) {
+	filter := model.UserFilter{
+		Username: ctx.Query("username"),
+		Email:    ctx.Query("email"),
+		FullName: ctx.Query("full_name"),
+		Status:   model.UserStatus(ctx.Query("status")),
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return model.UserFilter{}, fmt.Errorf("%w: unknown status %q", model.ErrInvalidFilter, filter.Status)
+	}
+
+	bounds := []struct {
+		param  string
+		target *time.Time
+	}{
+		{"created_after", &filter.CreatedAfter},
+		{"created_before", &filter.CreatedBefore},
+		{"updated_after", &filter.UpdatedAfter},
+		{"updated_before", &filter.UpdatedBefore},
 	}
+	for _, bound := range bounds {
+		value := ctx.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return model.UserFilter{}, fmt.Errorf("%w: %s must be an RFC 3339 timestamp", model.ErrInvalidFilter, bound.param)
+		}
+		*bound.target = parsed
+	}
+
+	sort, err := model.ParseUserSort(ctx.Query("sort"))
+	if err != nil {
+		return model.UserFilter{}, err
+	}
+	filter.Sort = sort
+	return filter, nil
 }
 
 func (userController *UserController) GetAllUsers(ctx *gin.Context) {
-	users, err := userController.userService.GetAllUsers()
+	filter, err := parseUserFilter(ctx)
+	if err != nil {
+		userController.handleError(ctx, err)
+		return
+	}
+
+	users, err := userController.users(ctx).GetAllUsers(filter)
+	if err != nil {
+		userController.handleError(ctx, err)
+		return
+	}
+
+	respondConditional(ctx, users, time.Time{}, userController.config.CollectionCacheControl)
+}
+
+func (userController *UserController) SearchUsers(ctx *gin.Context) {
+	pagination := parsePage(ctx)
+
+	page, err := userController.users(ctx).SearchUsers(model.SearchQuery{Query: ctx.Query("q"), Limit: pagination.Limit, Offset: pagination.Offset})
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		userController.handleError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, users)
+	respondConditional(ctx, page, time.Time{}, userController.config.CollectionCacheControl)
 }
 
 func (userController *UserController) GetUserByUsername(ctx *gin.Context) {
 	username := ctx.Param("username")
 
-	user, err := userController.userService.GetUserByUsername(username)
+	user, err := userController.users(ctx).GetUserByUsername(username)
 	if err != nil {
 		userController.handleError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, user)
+	respondConditional(ctx, user, user.UpdatedAt, userController.config.UserCacheControl)
 }
 
 func (userController *UserController) GetUserByID(ctx *gin.Context) {
 	idStr := ctx.Param("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		respond(ctx, http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
 
-	user, err := userController.userService.GetUserByID(id)
+	user, err := userController.users(ctx).GetUserByID(id)
+	if err != nil {
+		userController.handleError(ctx, err)
+		return
+	}
+
+	respondConditional(ctx, user, user.UpdatedAt, userController.config.UserCacheControl)
+}
+
+func (userController *UserController) GetUserByUUID(ctx *gin.Context) {
+	uuid := ctx.Param("uuid")
+
+	user, err := userController.users(ctx).GetUserByUUID(uuid)
 	if err != nil {
 		userController.handleError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, user)
+	respondConditional(ctx, user, user.UpdatedAt, userController.config.UserCacheControl)
 }
 
 func (userController *UserController) CreateUser(ctx *gin.Context) {
 	var request model.CreateUserRequest
-	if err := ctx.ShouldBindJSON(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindBody(ctx, &request); err != nil {
+		respondBindError(ctx, err)
 		return
 	}
 
-	user, err := userController.userService.CreateUser(&request)
+	user, err := userController.users(ctx).CreateUser(&request)
 	if err != nil {
 		userController.handleError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, user)
+	respond(ctx, http.StatusCreated, user)
 }
 
 func (userController *UserController) UpdateUser(ctx *gin.Context) {
 	uuid := ctx.Param("uuid")
 
 	var request model.UpdateUserRequest
-	if err := ctx.ShouldBindJSON(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindBody(ctx, &request); err != nil {
+		respondBindError(ctx, err)
 		return
 	}
 
-	user, err := userController.userService.UpdateUser(uuid, &request)
+	user, err := userController.users(ctx).UpdateUser(uuid, &request)
 	if err != nil {
 		userController.handleError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, user)
+	respond(ctx, http.StatusOK, user)
 }
 
 func (userController *UserController) DeleteUser(ctx *gin.Context) {
 	uuid := ctx.Param("uuid")
 
-	err := userController.userService.DeleteUser(uuid)
+	err := userController.users(ctx).DeleteUser(uuid)
 	if err != nil {
 		userController.handleError(ctx, err)
 		return
@@ -113,3 +206,119 @@ func (userController *UserController) DeleteUser(ctx *gin.Context) {
 
 	ctx.Status(http.StatusNoContent)
 }
+
+// actor identifies who made a change for audit records.
+func actor(ctx *gin.Context) string {
+	if principal := principal(ctx); principal != nil {
+		return principal.String()
+	}
+	return "anonymous"
+}
+
+func (userController *UserController) ActivateUser(ctx *gin.Context) {
+	userController.transitionStatus(ctx, model.UserStatusActive)
+}
+
+func (userController *UserController) SuspendUser(ctx *gin.Context) {
+	userController.transitionStatus(ctx, model.UserStatusSuspended)
+}
+
+func (userController *UserController) DeactivateUser(ctx *gin.Context) {
+	userController.transitionStatus(ctx, model.UserStatusDeactivated)
+}
+
+func (userController *UserController) transitionStatus(ctx *gin.Context, target model.UserStatus) {
+	var request model.StatusTransitionRequest
+	if err := bindBody(ctx, &request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	user, err := userController.users(ctx).TransitionUserStatus(ctx.Param("uuid"), target, request.Reason, actor(ctx))
+	if err != nil {
+		userController.handleError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, user)
+}
+
+func (userController *UserController) GetStatusTransitions(ctx *gin.Context) {
+	transitions, err := userController.users(ctx).GetStatusTransitions(ctx.Param("uuid"))
+	if err != nil {
+		userController.handleError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, transitions)
+}
+
+func (userController *UserController) GetAuditEvents(ctx *gin.Context) {
+	events, err := userController.users(ctx).GetAuditEvents(ctx.Param("uuid"))
+	if err != nil {
+		userController.handleError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, events)
+}
+
+func (userController *UserController) ExecuteBatch(ctx *gin.Context) {
+	// Operation data is kept as raw JSON until each operation is decoded, so
+	// batches are only accepted as JSON. The response is still negotiated.
+	if contentType := ctx.ContentType(); contentType != "" && contentType != binding.MIMEJSON {
+		respond(ctx, http.StatusUnsupportedMediaType, gin.H{"error": errUnsupportedMediaType.Error()})
+		return
+	}
+
+	var request model.BatchRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		respond(ctx, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := userController.users(ctx).ExecuteBatch(&request)
+	if err != nil {
+		userController.handleError(ctx, err)
+		return
+	}
+
+	response := model.BatchResponse{Atomic: request.Atomic, Results: make([]model.BatchItemResponse, 0, len(results))}
+	for _, result := range results {
+		item := model.BatchItemResponse{Index: result.Index, Op: result.Op, User: result.User}
+		switch {
+		case result.RolledBack:
+			item.Status = http.StatusFailedDependency
+			item.Error = "operation rolled back because another operation in the atomic batch failed"
+		case result.Err != nil:
+			item.Status = userController.errorStatus(result.Err)
+			item.Error = result.Err.Error()
+		default:
+			item.Status = batchSuccessStatus(result.Op)
+		}
+
+		if item.Error == "" {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+		response.Results = append(response.Results, item)
+	}
+
+	status := http.StatusOK
+	if response.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	respond(ctx, status, response)
+}
+
+func batchSuccessStatus(op model.BatchOp) int {
+	switch op {
+	case model.BatchOpCreate:
+		return http.StatusCreated
+	case model.BatchOpDelete:
+		return http.StatusNoContent
+	default:
+		return http.StatusOK
+	}
+}
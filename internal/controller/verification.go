@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VerificationController struct {
+	services *service.Service
+}
+
+func NewVerificationController(services *service.Service) *VerificationController {
+	return &VerificationController{services: services}
+}
+
+func (verificationController *VerificationController) handleError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrInvalidToken):
+		respond(ctx, http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrUserNotFound):
+		respond(ctx, http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrEmailAlreadyVerified):
+		respond(ctx, http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrRateLimited):
+		respond(ctx, http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrUnauthenticated):
+		respond(ctx, http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrForbidden):
+		respond(ctx, http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		respond(ctx, http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// VerifyEmail needs no authentication: the token is the proof.
+func (verificationController *VerificationController) VerifyEmail(ctx *gin.Context) {
+	var request model.VerifyEmailRequest
+	if err := bindBody(ctx, &request); err != nil {
+		respondBindError(ctx, err)
+		return
+	}
+
+	user, err := verificationController.services.Verifications.VerifyEmail(request.Token)
+	if err != nil {
+		verificationController.handleError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, user)
+}
+
+func (verificationController *VerificationController) ResendVerification(ctx *gin.Context) {
+	verifications := verificationController.services.As(principal(ctx)).Verifications
+	if err := verifications.ResendVerification(ctx.Param("uuid")); err != nil {
+		verificationController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusAccepted)
+}
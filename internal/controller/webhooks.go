@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookController struct {
+	services *service.Service
+}
+
+func NewWebhookController(services *service.Service) *WebhookController {
+	return &WebhookController{services: services}
+}
+
+// webhooks returns the webhook service as seen by the authenticated caller.
+func (webhookController *WebhookController) webhooks(ctx *gin.Context) service.WebhookService {
+	return webhookController.services.As(principal(ctx)).Webhooks
+}
+
+func (webhookController *WebhookController) handleError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrWebhookNotFound), errors.Is(err, model.ErrDeliveryNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrEmptyField), errors.Is(err, model.ErrInvalidWebhookURL), errors.Is(err, model.ErrUnknownEventType):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrUnauthenticated):
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrForbidden):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func (webhookController *WebhookController) parseID(ctx *gin.Context, param string) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param(param), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param})
+		return 0, false
+	}
+	return id, true
+}
+
+func (webhookController *WebhookController) GetAllWebhooks(ctx *gin.Context) {
+	webhooks, err := webhookController.webhooks(ctx).GetAllWebhooks()
+	if err != nil {
+		webhookController.handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, webhooks)
+}
+
+func (webhookController *WebhookController) GetWebhook(ctx *gin.Context) {
+	id, ok := webhookController.parseID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	webhook, err := webhookController.webhooks(ctx).GetWebhook(id)
+	if err != nil {
+		webhookController.handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, webhook)
+}
+
+func (webhookController *WebhookController) CreateWebhook(ctx *gin.Context) {
+	var request model.CreateWebhookRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	webhook, err := webhookController.webhooks(ctx).CreateWebhook(&request)
+	if err != nil {
+		webhookController.handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, webhook)
+}
+
+func (webhookController *WebhookController) EnableWebhook(ctx *gin.Context) {
+	id, ok := webhookController.parseID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	webhook, err := webhookController.webhooks(ctx).EnableWebhook(id)
+	if err != nil {
+		webhookController.handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, webhook)
+}
+
+func (webhookController *WebhookController) DeleteWebhook(ctx *gin.Context) {
+	id, ok := webhookController.parseID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if err := webhookController.webhooks(ctx).DeleteWebhook(id); err != nil {
+		webhookController.handleError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (webhookController *WebhookController) GetDeliveries(ctx *gin.Context) {
+	id, ok := webhookController.parseID(ctx, "id")
+	if !ok {
+		return
+	}
+
+	deliveries, err := webhookController.webhooks(ctx).GetDeliveries(id)
+	if err != nil {
+		webhookController.handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, deliveries)
+}
+
+func (webhookController *WebhookController) Redeliver(ctx *gin.Context) {
+	id, ok := webhookController.parseID(ctx, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := webhookController.parseID(ctx, "delivery_id")
+	if !ok {
+		return
+	}
+
+	delivery, err := webhookController.webhooks(ctx).Redeliver(id, deliveryID)
+	if err != nil {
+		webhookController.handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, delivery)
+}
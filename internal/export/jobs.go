@@ -0,0 +1,186 @@
+package export
+
+import (
+	"cruder/internal/model"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type JobStatus string
+
+const (
+	JobStatusRunning   JobStatus = "running"
+	JobStatusCompleted JobStatus = "completed"
+	JobStatusFailed    JobStatus = "failed"
+)
+
+var (
+	ErrJobNotFound = errors.New("export job not found")
+	ErrJobNotReady = errors.New("export job has not completed")
+)
+
+type Job struct {
+	ID          string     `json:"id"`
+	Status      JobStatus  `json:"status"`
+	Format      Format     `json:"format"`
+	Rows        int        `json:"rows"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	path        string
+	// tenantID and owner are those of the principal that started the job;
+	// no one else can see it.
+	tenantID string
+	owner    string
+}
+
+// Source streams the users to export into fn.
+type Source func(fn func(user *model.User) error) error
+
+// JobManager runs exports in the background and keeps the results in a local
+// directory for retention. Jobs are tracked in memory, so a job can only be
+// fetched from the replica that started it, and only by the principal that
+// started it. With several replicas, polling and downloads need sticky
+// sessions; jobs are lost on restart.
+type JobManager struct {
+	directory string
+	retention time.Duration
+	mutex     sync.Mutex
+	jobs      map[string]*Job
+}
+
+func NewJobManager(directory string, retention time.Duration) *JobManager {
+	return &JobManager{
+		directory: directory,
+		retention: retention,
+		jobs:      make(map[string]*Job),
+	}
+}
+
+func (manager *JobManager) Start(principal *model.Principal, format Format, columns []string, source Source) (Job, error) {
+	manager.removeExpired()
+
+	if err := os.MkdirAll(manager.directory, 0o750); err != nil {
+		return Job{}, fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, err
+	}
+
+	job := &Job{
+		ID:        id,
+		Status:    JobStatusRunning,
+		Format:    format,
+		CreatedAt: time.Now().UTC(),
+		path:      filepath.Join(manager.directory, id+"."+string(format)),
+		tenantID:  principal.TenantID,
+		owner:     principal.String(),
+	}
+
+	manager.mutex.Lock()
+	manager.jobs[id] = job
+	snapshot := *job
+	manager.mutex.Unlock()
+
+	go manager.run(job, columns, source)
+	return snapshot, nil
+}
+
+// Get returns the job of principal with the given id. Jobs of other
+// principals are reported as not found.
+func (manager *JobManager) Get(principal *model.Principal, id string) (Job, error) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	job, ok := manager.jobs[id]
+	if !ok || job.tenantID != principal.TenantID || job.owner != principal.String() {
+		return Job{}, ErrJobNotFound
+	}
+	return *job, nil
+}
+
+// Path returns the file of a completed job of principal.
+func (manager *JobManager) Path(principal *model.Principal, id string) (string, error) {
+	job, err := manager.Get(principal, id)
+	if err != nil {
+		return "", err
+	}
+	if job.Status != JobStatusCompleted {
+		return "", ErrJobNotReady
+	}
+	return job.path, nil
+}
+
+func (manager *JobManager) run(job *Job, columns []string, source Source) {
+	rows, err := manager.write(job.path, job.Format, columns, source)
+
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	completedAt := time.Now().UTC()
+	job.CompletedAt = &completedAt
+	job.Rows = rows
+	if err != nil {
+		job.Status = JobStatusFailed
+		job.Error = err.Error()
+		_ = os.Remove(job.path)
+		log.Printf("export job %s failed: %v", job.ID, err)
+		return
+	}
+	job.Status = JobStatusCompleted
+}
+
+func (manager *JobManager) write(path string, format Format, columns []string, source Source) (int, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600) // #nosec G304 -- path is built from a generated job id
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	writer, err := NewWriter(format, file, columns)
+	if err != nil {
+		return 0, err
+	}
+
+	rows := 0
+	err = source(func(user *model.User) error {
+		rows++
+		return writer.Write(user)
+	})
+	if err != nil {
+		return rows, err
+	}
+	if err := writer.Close(); err != nil {
+		return rows, err
+	}
+	return rows, file.Close()
+}
+
+func (manager *JobManager) removeExpired() {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	for id, job := range manager.jobs {
+		if job.CompletedAt != nil && time.Since(*job.CompletedAt) > manager.retention {
+			_ = os.Remove(job.path)
+			delete(manager.jobs, id)
+		}
+	}
+}
+
+func newJobID() (string, error) {
+	buffer := make([]byte, 16)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", fmt.Errorf("failed to generate job id: %w", err)
+	}
+	return hex.EncodeToString(buffer), nil
+}
@@ -0,0 +1,176 @@
+package export
+
+import (
+	"cruder/internal/model"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+	FormatXLSX   Format = "xlsx"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported export format, expected csv, ndjson or xlsx")
+	ErrUnknownColumn     = errors.New("unknown export column")
+)
+
+// Columns lists the exportable user columns in their default order.
+var Columns = []string{"id", "uuid", "username", "email", "full_name", "status", "created_at", "updated_at"}
+
+func ParseFormat(value string) (Format, error) {
+	switch Format(value) {
+	case FormatCSV, FormatNDJSON, FormatXLSX:
+		return Format(value), nil
+	case "":
+		return FormatCSV, nil
+	default:
+		return "", ErrUnsupportedFormat
+	}
+}
+
+func (format Format) ContentType() string {
+	switch format {
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "text/csv"
+	}
+}
+
+func ParseColumns(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return Columns, nil
+	}
+
+	var columns []string
+	for _, column := range strings.Split(value, ",") {
+		column = strings.TrimSpace(column)
+		if columnValue(&model.User{}, column) == nil {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownColumn, column)
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+func columnValue(user *model.User, column string) any {
+	switch column {
+	case "id":
+		return user.ID
+	case "uuid":
+		return user.UUID
+	case "username":
+		return user.Username
+	case "email":
+		return user.Email
+	case "full_name":
+		return user.FullName
+	case "status":
+		return string(user.Status)
+	case "created_at":
+		return user.CreatedAt
+	case "updated_at":
+		return user.UpdatedAt
+	default:
+		return nil
+	}
+}
+
+func stringValue(value any) string {
+	switch typed := value.(type) {
+	case string:
+		return typed
+	case int:
+		return strconv.Itoa(typed)
+	case time.Time:
+		return typed.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(typed)
+	}
+}
+
+// Writer encodes users one at a time. Close must be called to finish the
+// document; it does not close the underlying io.Writer.
+type Writer interface {
+	Write(user *model.User) error
+	Close() error
+}
+
+func NewWriter(format Format, destination io.Writer, columns []string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(destination, columns)
+	case FormatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(destination), columns: columns}, nil
+	case FormatXLSX:
+		return newXLSXWriter(destination, columns)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+type csvWriter struct {
+	writer  *csv.Writer
+	columns []string
+	record  []string
+}
+
+func newCSVWriter(destination io.Writer, columns []string) (*csvWriter, error) {
+	writer := csv.NewWriter(destination)
+	if err := writer.Write(columns); err != nil {
+		return nil, err
+	}
+	return &csvWriter{writer: writer, columns: columns, record: make([]string, len(columns))}, nil
+}
+
+func (writer *csvWriter) Write(user *model.User) error {
+	for i, column := range writer.columns {
+		writer.record[i] = neutralizeFormula(stringValue(columnValue(user, column)))
+	}
+	return writer.writer.Write(writer.record)
+}
+
+// neutralizeFormula prefixes cells that spreadsheets would evaluate as a
+// formula with a quote, so user-controlled fields cannot run one when the
+// export is opened.
+func neutralizeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+func (writer *csvWriter) Close() error {
+	writer.writer.Flush()
+	return writer.writer.Error()
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+	columns []string
+}
+
+func (writer *ndjsonWriter) Write(user *model.User) error {
+	object := make(map[string]any, len(writer.columns))
+	for _, column := range writer.columns {
+		object[column] = columnValue(user, column)
+	}
+	return writer.encoder.Encode(object)
+}
+
+func (writer *ndjsonWriter) Close() error {
+	return nil
+}
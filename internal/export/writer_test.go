@@ -0,0 +1,172 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"cruder/internal/model"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var exportUsers = []model.User{
+	{ID: 1, UUID: "uuid-1", Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"},
+	{ID: 2, UUID: "uuid-2", Username: "asmith", Email: "asmith@example.com", FullName: `Alice "Al" <Smith> & Co`},
+}
+
+func writeUsers(test *testing.T, format Format, columns []string) []byte {
+	var output bytes.Buffer
+	writer, err := NewWriter(format, &output, columns)
+	assert.NoError(test, err)
+	for i := range exportUsers {
+		assert.NoError(test, writer.Write(&exportUsers[i]))
+	}
+	assert.NoError(test, writer.Close())
+	return output.Bytes()
+}
+
+func usersSource(fn func(user *model.User) error) error {
+	for i := range exportUsers {
+		if err := fn(&exportUsers[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestShouldExportSelectedColumnsAsCSV(test *testing.T) {
+	// given
+	columns, err := ParseColumns("username, email")
+	assert.NoError(test, err)
+
+	// when
+	output := writeUsers(test, FormatCSV, columns)
+
+	// then
+	assert.Equal(test, "username,email\njdoe,jdoe@example.com\nasmith,asmith@example.com\n", string(output))
+}
+
+func TestShouldNeutralizeFormulasInCSV(test *testing.T) {
+	// given
+	names := []string{`=HYPERLINK("http://evil.example")`, "+1", "-1", "@SUM(A1)", "\tTab", "\rReturn", "Jane = Doe"}
+	var output bytes.Buffer
+	writer, err := NewWriter(FormatCSV, &output, []string{"full_name"})
+	assert.NoError(test, err)
+
+	// when
+	for _, name := range names {
+		assert.NoError(test, writer.Write(&model.User{FullName: name}))
+	}
+	assert.NoError(test, writer.Close())
+
+	// then
+	expected := "full_name\n\"'=HYPERLINK(\"\"http://evil.example\"\")\"\n'+1\n'-1\n'@SUM(A1)\n'\tTab\n\"'\rReturn\"\nJane = Doe\n"
+	assert.Equal(test, expected, output.String())
+}
+
+func TestShouldRejectUnknownColumn(test *testing.T) {
+	// when
+	_, err := ParseColumns("username,password")
+
+	// then
+	assert.ErrorIs(test, err, ErrUnknownColumn)
+}
+
+func TestShouldExportUsersAsNDJSON(test *testing.T) {
+	// when
+	output := writeUsers(test, FormatNDJSON, []string{"id", "username"})
+
+	// then
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	assert.Len(test, lines, 2)
+	assert.JSONEq(test, `{"id":1,"username":"jdoe"}`, lines[0])
+}
+
+func TestShouldExportUsersAsXLSXWorkbook(test *testing.T) {
+	// when
+	output := writeUsers(test, FormatXLSX, Columns)
+
+	// then
+	archive, err := zip.NewReader(bytes.NewReader(output), int64(len(output)))
+	assert.NoError(test, err)
+
+	var sheet []byte
+	for _, file := range archive.File {
+		if file.Name == "xl/worksheets/sheet1.xml" {
+			reader, err := file.Open()
+			assert.NoError(test, err)
+			sheet, _ = io.ReadAll(reader)
+		}
+	}
+	assert.Contains(test, string(sheet), `<row r="3">`)
+	assert.Contains(test, string(sheet), `<c t="n"><v>2</v></c>`)
+	assert.Contains(test, string(sheet), `Alice &#34;Al&#34; &lt;Smith&gt; &amp; Co`)
+	assert.Len(test, archive.File, 5)
+}
+
+var jobOwner = &model.Principal{Kind: model.PrincipalUser, ID: "uuid-1", TenantID: "tenant-a"}
+
+func TestShouldWriteAsyncExportToLocalStorage(test *testing.T) {
+	// given
+	manager := NewJobManager(test.TempDir(), time.Hour)
+
+	// when
+	job, err := manager.Start(jobOwner, FormatCSV, []string{"uuid"}, usersSource)
+
+	// then
+	assert.NoError(test, err)
+	assert.Eventually(test, func() bool {
+		current, _ := manager.Get(jobOwner, job.ID)
+		return current.Status == JobStatusCompleted
+	}, time.Second, 10*time.Millisecond)
+
+	path, err := manager.Path(jobOwner, job.ID)
+	assert.NoError(test, err)
+	content, err := os.ReadFile(path)
+	assert.NoError(test, err)
+	assert.Equal(test, "uuid\nuuid-1\nuuid-2\n", string(content))
+
+	completed, _ := manager.Get(jobOwner, job.ID)
+	assert.Equal(test, 2, completed.Rows)
+}
+
+func TestShouldReportUnknownExportJob(test *testing.T) {
+	// given
+	manager := NewJobManager(test.TempDir(), time.Hour)
+
+	// when
+	_, err := manager.Path(jobOwner, "missing")
+
+	// then
+	assert.ErrorIs(test, err, ErrJobNotFound)
+}
+
+func TestShouldHideExportJobsFromOtherPrincipals(test *testing.T) {
+	// given
+	manager := NewJobManager(test.TempDir(), time.Hour)
+	job, err := manager.Start(jobOwner, FormatCSV, []string{"uuid"}, usersSource)
+	assert.NoError(test, err)
+	assert.Eventually(test, func() bool {
+		current, _ := manager.Get(jobOwner, job.ID)
+		return current.Status == JobStatusCompleted
+	}, time.Second, 10*time.Millisecond)
+	others := []*model.Principal{
+		{Kind: model.PrincipalUser, ID: "uuid-2", TenantID: "tenant-a"},
+		{Kind: model.PrincipalUser, ID: "uuid-1", TenantID: "tenant-b"},
+		{Kind: model.PrincipalAPIKey, ID: "uuid-1", TenantID: "tenant-a"},
+	}
+
+	for _, other := range others {
+		// when
+		_, getErr := manager.Get(other, job.ID)
+		_, pathErr := manager.Path(other, job.ID)
+
+		// then
+		assert.ErrorIs(test, getErr, ErrJobNotFound)
+		assert.ErrorIs(test, pathErr, ErrJobNotFound)
+	}
+}
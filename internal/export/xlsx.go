@@ -0,0 +1,110 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"cruder/internal/model"
+	"encoding/xml"
+	"io"
+	"strconv"
+)
+
+// xlsxWriter streams a single-sheet workbook. Cells are written as inline
+// strings or numbers so no shared string table has to be held in memory.
+type xlsxWriter struct {
+	archive *zip.Writer
+	sheet   *bufio.Writer
+	columns []string
+	row     int
+}
+
+var xlsxStaticParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="users" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+func newXLSXWriter(destination io.Writer, columns []string) (*xlsxWriter, error) {
+	archive := zip.NewWriter(destination)
+	for _, part := range xlsxStaticParts {
+		file, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(file, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	writer := &xlsxWriter{archive: archive, sheet: bufio.NewWriter(file), columns: columns}
+	_, _ = writer.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	header := make([]any, len(columns))
+	for i, column := range columns {
+		header[i] = column
+	}
+	if err := writer.writeRow(header); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (writer *xlsxWriter) Write(user *model.User) error {
+	values := make([]any, len(writer.columns))
+	for i, column := range writer.columns {
+		values[i] = columnValue(user, column)
+	}
+	return writer.writeRow(values)
+}
+
+func (writer *xlsxWriter) writeRow(values []any) error {
+	writer.row++
+	_, _ = writer.sheet.WriteString(`<row r="` + strconv.Itoa(writer.row) + `">`)
+	for _, value := range values {
+		if number, ok := value.(int); ok {
+			_, _ = writer.sheet.WriteString(`<c t="n"><v>` + strconv.Itoa(number) + `</v></c>`)
+			continue
+		}
+		_, _ = writer.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+		if err := xml.EscapeText(writer.sheet, []byte(stringValue(value))); err != nil {
+			return err
+		}
+		_, _ = writer.sheet.WriteString(`</t></is></c>`)
+	}
+	_, err := writer.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (writer *xlsxWriter) Close() error {
+	if _, err := writer.sheet.WriteString(`</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	if err := writer.sheet.Flush(); err != nil {
+		return err
+	}
+	return writer.archive.Close()
+}
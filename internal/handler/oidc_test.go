@@ -0,0 +1,291 @@
+package handler
+
+import (
+	"cruder/internal/controller"
+	"cruder/internal/export"
+	"cruder/internal/model"
+	"cruder/internal/password"
+	"cruder/internal/repository"
+	"cruder/internal/service"
+	"cruder/internal/stream"
+	"cruder/internal/token"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	oidcTestRedirectURI = "https://wiki.example.com/callback"
+	oidcTestVerifier    = "a-code-verifier-of-at-least-forty-three-characters"
+)
+
+// oidcProvider is a running server acting as OpenID Connect provider for
+// one confidential client and one user.
+type oidcProvider struct {
+	server       *httptest.Server
+	clientID     string
+	clientSecret string
+	user         *model.User
+}
+
+// setupOIDCProvider needs a migrated database named by TEST_POSTGRES_DSN.
+func setupOIDCProvider(test *testing.T) *oidcProvider {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		test.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	connection, err := repository.NewPostgresConnection(dsn)
+	require.NoError(test, err)
+	test.Cleanup(func() { _ = connection.DB().Close() })
+	repos := repository.NewRepository(connection.DB())
+
+	organization := &model.Organization{Slug: fmt.Sprintf("oidc-%d", time.Now().UnixNano()), Name: "OIDC"}
+	require.NoError(test, repos.Organizations.Create(organization))
+	test.Cleanup(func() {
+		_, _ = connection.DB().Exec(`DELETE FROM users WHERE tenant_id = $1`, organization.ID)
+		_, _ = connection.DB().Exec(`DELETE FROM organizations WHERE id = $1`, organization.ID)
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	server := httptest.NewServer(router)
+	test.Cleanup(server.Close)
+
+	key, err := token.GenerateKey()
+	require.NoError(test, err)
+	config := service.DefaultConfig()
+	config.PasswordParams = password.Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	config.SigningKeys = token.NewKeySet(key)
+	config.OIDCIssuer = server.URL
+	services := service.NewService(repos, config)
+	controllers := controller.NewController(services, stream.NewBroker(10),
+		export.NewJobManager(test.TempDir(), time.Hour), controller.DefaultConfig())
+	New(router, controllers)
+
+	user, err := services.ForTenant(organization.ID).Users.CreateUser(&model.CreateUserRequest{
+		Username: "jdoe", Email: "jdoe@example.com", FullName: "Jane Doe", Password: "correct horse battery",
+	})
+	require.NoError(test, err)
+	secret, client, err := services.OIDC.CreateClient(organization.ID, "Wiki", []string{oidcTestRedirectURI}, true)
+	require.NoError(test, err)
+	return &oidcProvider{server: server, clientID: client.ID, clientSecret: secret, user: user}
+}
+
+// browser does not follow redirects, so tests can read the Location the
+// provider sends the user agent to.
+var browser = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+func (provider *oidcProvider) authorizationParams() url.Values {
+	challenge := sha256.Sum256([]byte(oidcTestVerifier))
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {provider.clientID},
+		"redirect_uri":          {oidcTestRedirectURI},
+		"scope":                 {"openid profile email"},
+		"state":                 {"af0ifjsldkj"},
+		"nonce":                 {"n-0S6_WzA2Mj"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+// login submits the login page and returns where the browser is sent.
+func (provider *oidcProvider) login(test *testing.T, decision string) *url.URL {
+	form := provider.authorizationParams()
+	form.Set("login", "jdoe")
+	form.Set("password", "correct horse battery")
+	form.Set("decision", decision)
+	response, err := browser.PostForm(provider.server.URL+"/oauth2/authorize", form)
+	require.NoError(test, err)
+	defer response.Body.Close()
+	require.Equal(test, http.StatusSeeOther, response.StatusCode)
+
+	location, err := url.Parse(response.Header.Get("Location"))
+	require.NoError(test, err)
+	return location
+}
+
+func (provider *oidcProvider) exchange(test *testing.T, code string) (*http.Response, map[string]any) {
+	request, err := http.NewRequest(http.MethodPost, provider.server.URL+"/oauth2/token", strings.NewReader(url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {oidcTestRedirectURI},
+		"code_verifier": {oidcTestVerifier},
+	}.Encode()))
+	require.NoError(test, err)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.SetBasicAuth(url.QueryEscape(provider.clientID), url.QueryEscape(provider.clientSecret))
+	return doJSON(test, request)
+}
+
+func (provider *oidcProvider) userInfo(test *testing.T, accessToken string) (*http.Response, map[string]any) {
+	request, err := http.NewRequest(http.MethodGet, provider.server.URL+"/oauth2/userinfo", nil)
+	require.NoError(test, err)
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	return doJSON(test, request)
+}
+
+func doJSON(test *testing.T, request *http.Request) (*http.Response, map[string]any) {
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(test, err)
+	defer response.Body.Close()
+	body := map[string]any{}
+	if response.Header.Get("Content-Type") != "" {
+		require.NoError(test, json.NewDecoder(response.Body).Decode(&body))
+	}
+	return response, body
+}
+
+// verifyIDToken checks an ID token the way a relying party does: against
+// the keys published at jwks_uri.
+func verifyIDToken(test *testing.T, jwksURI, raw string) map[string]any {
+	response, err := http.Get(jwksURI)
+	require.NoError(test, err)
+	defer response.Body.Close()
+	var jwks token.JWKS
+	require.NoError(test, json.NewDecoder(response.Body).Decode(&jwks))
+
+	parts := strings.Split(raw, ".")
+	require.Len(test, parts, 3)
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+	decodeSegment(test, parts[0], &header)
+	assert.Equal(test, "EdDSA", header.Algorithm)
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(test, err)
+	verified := false
+	for _, key := range jwks.Keys {
+		if key.KeyID == header.KeyID {
+			publicKey, err := base64.RawURLEncoding.DecodeString(key.X)
+			require.NoError(test, err)
+			verified = ed25519.Verify(publicKey, []byte(parts[0]+"."+parts[1]), signature)
+		}
+	}
+	require.True(test, verified, "ID token signature does not verify against the JWKS")
+
+	claims := map[string]any{}
+	decodeSegment(test, parts[1], &claims)
+	return claims
+}
+
+func decodeSegment(test *testing.T, segment string, target any) {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	require.NoError(test, err)
+	require.NoError(test, json.Unmarshal(data, target))
+}
+
+func TestShouldPublishProviderMetadata(test *testing.T) {
+	// given
+	provider := setupOIDCProvider(test)
+	request, err := http.NewRequest(http.MethodGet, provider.server.URL+"/.well-known/openid-configuration", nil)
+	require.NoError(test, err)
+
+	// when
+	response, metadata := doJSON(test, request)
+
+	// then
+	assert.Equal(test, http.StatusOK, response.StatusCode)
+	assert.Equal(test, provider.server.URL, metadata["issuer"])
+	assert.Equal(test, provider.server.URL+"/oauth2/authorize", metadata["authorization_endpoint"])
+	assert.Equal(test, provider.server.URL+"/oauth2/token", metadata["token_endpoint"])
+	assert.Equal(test, provider.server.URL+"/oauth2/userinfo", metadata["userinfo_endpoint"])
+	assert.Equal(test, provider.server.URL+"/.well-known/jwks.json", metadata["jwks_uri"])
+	assert.Equal(test, []any{"code"}, metadata["response_types_supported"])
+	assert.Equal(test, []any{"S256"}, metadata["code_challenge_methods_supported"])
+	assert.Contains(test, metadata["scopes_supported"], "openid")
+}
+
+func TestShouldSignUserInToRelyingParty(test *testing.T) {
+	// given
+	provider := setupOIDCProvider(test)
+	page, err := browser.Get(provider.server.URL + "/oauth2/authorize?" + provider.authorizationParams().Encode())
+	require.NoError(test, err)
+	_ = page.Body.Close()
+	require.Equal(test, http.StatusOK, page.StatusCode)
+	assert.Contains(test, page.Header.Get("Content-Type"), "text/html")
+
+	// when
+	callback := provider.login(test, "allow")
+	response, tokens := provider.exchange(test, callback.Query().Get("code"))
+
+	// then
+	assert.Equal(test, oidcTestRedirectURI, callback.Scheme+"://"+callback.Host+callback.Path)
+	assert.Equal(test, "af0ifjsldkj", callback.Query().Get("state"))
+	require.Equal(test, http.StatusOK, response.StatusCode, tokens)
+	assert.Equal(test, "Bearer", tokens["token_type"])
+
+	claims := verifyIDToken(test, provider.server.URL+"/.well-known/jwks.json", tokens["id_token"].(string))
+	assert.Equal(test, provider.server.URL, claims["iss"])
+	assert.Equal(test, provider.clientID, claims["aud"])
+	assert.Equal(test, provider.user.UUID, claims["sub"])
+	assert.Equal(test, "n-0S6_WzA2Mj", claims["nonce"])
+	assert.Equal(test, "jdoe", claims["preferred_username"])
+	assert.Equal(test, "jdoe@example.com", claims["email"])
+
+	infoResponse, info := provider.userInfo(test, tokens["access_token"].(string))
+	assert.Equal(test, http.StatusOK, infoResponse.StatusCode)
+	assert.Equal(test, provider.user.UUID, info["sub"])
+	assert.Equal(test, "Jane Doe", info["name"])
+}
+
+func TestShouldRevokeTokensWhenCodeIsReplayed(test *testing.T) {
+	// given
+	provider := setupOIDCProvider(test)
+	code := provider.login(test, "allow").Query().Get("code")
+	_, tokens := provider.exchange(test, code)
+	require.NotEmpty(test, tokens["access_token"])
+
+	// when
+	response, body := provider.exchange(test, code)
+
+	// then
+	assert.Equal(test, http.StatusBadRequest, response.StatusCode)
+	assert.Equal(test, "invalid_grant", body["error"])
+	infoResponse, _ := provider.userInfo(test, tokens["access_token"].(string))
+	assert.Equal(test, http.StatusUnauthorized, infoResponse.StatusCode)
+}
+
+func TestShouldRedirectDeniedRequestWithError(test *testing.T) {
+	// given
+	provider := setupOIDCProvider(test)
+
+	// when
+	callback := provider.login(test, "deny")
+
+	// then
+	assert.Equal(test, "access_denied", callback.Query().Get("error"))
+	assert.Equal(test, "af0ifjsldkj", callback.Query().Get("state"))
+	assert.Empty(test, callback.Query().Get("code"))
+}
+
+func TestShouldNotRedirectToUnregisteredURI(test *testing.T) {
+	// given
+	provider := setupOIDCProvider(test)
+	params := provider.authorizationParams()
+	params.Set("redirect_uri", "https://attacker.example.com/callback")
+
+	// when
+	response, err := browser.Get(provider.server.URL + "/oauth2/authorize?" + params.Encode())
+	require.NoError(test, err)
+	_ = response.Body.Close()
+
+	// then
+	assert.Equal(test, http.StatusBadRequest, response.StatusCode)
+	assert.Empty(test, response.Header.Get("Location"))
+}
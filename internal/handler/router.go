@@ -2,21 +2,142 @@ package handler
 
 import (
 	"cruder/internal/controller"
+	"cruder/internal/model"
+	"expvar"
 
 	"github.com/gin-gonic/gin"
 )
 
-func New(router *gin.Engine, userController *controller.UserController) *gin.Engine {
+func New(router *gin.Engine, controllers *controller.Controller) *gin.Engine {
+	userController := controllers.Users
+	webhookController := controllers.Webhooks
+	eventController := controllers.Events
+	importController := controllers.Imports
+	exportController := controllers.Exports
+	authController := controllers.Auth
+	roleController := controllers.Roles
+	groupController := controllers.Groups
+	verificationController := controllers.Verifications
+	passwordResetController := controllers.PasswordResets
+	mfaController := controllers.MFA
+	sessionController := controllers.Sessions
+	oidcController := controllers.OIDC
+	scimController := controllers.SCIM
+
+	router.GET("/debug/vars", controllers.Authenticate, controller.RequirePermission(model.PermissionReadMetrics),
+		gin.WrapH(expvar.Handler()))
+	router.GET("/.well-known/jwks.json", authController.JWKS)
+	router.GET("/.well-known/openid-configuration", oidcController.Discovery)
+
+	oauthGroup := router.Group("/oauth2")
+	{
+		oauthGroup.GET("/authorize", oidcController.Authorize)
+		oauthGroup.POST("/authorize", oidcController.Authorize)
+		oauthGroup.POST("/token", oidcController.Token)
+		oauthGroup.GET("/userinfo", oidcController.UserInfo)
+		oauthGroup.POST("/userinfo", oidcController.UserInfo)
+	}
+
+	scimGroup := router.Group("/scim/v2")
+	{
+		scimGroup.GET("/ServiceProviderConfig", scimController.ServiceProviderConfig)
+		scimGroup.GET("/Schemas", scimController.GetSchemas)
+		scimGroup.GET("/Schemas/:id", scimController.GetSchema)
+		scimGroup.GET("/ResourceTypes", scimController.GetResourceTypes)
+		scimGroup.GET("/ResourceTypes/:id", scimController.GetResourceType)
+
+		scimUserGroup := scimGroup.Group("/Users", scimController.Authenticate)
+		{
+			scimUserGroup.GET("", scimController.ListUsers)
+			scimUserGroup.POST("", scimController.CreateUser)
+			scimUserGroup.GET("/:id", scimController.GetUser)
+			scimUserGroup.PUT("/:id", scimController.ReplaceUser)
+			scimUserGroup.PATCH("/:id", scimController.PatchUser)
+			scimUserGroup.DELETE("/:id", scimController.DeleteUser)
+		}
+	}
+
 	v1 := router.Group("/api/v1")
 	{
-		userGroup := v1.Group("/users")
+		userGroup := v1.Group("/users", controllers.Authenticate)
+		{
+			userGroup.GET("/events", eventController.StreamUserEvents)
+			userGroup.GET("/export", exportController.ExportUsers)
+			userGroup.GET("/export/jobs/:id", exportController.GetExportJob)
+			userGroup.GET("/export/jobs/:id/download", exportController.DownloadExportJob)
+			userGroup.POST("/import", importController.ImportUsers)
+		}
+
+		userResourceGroup := v1.Group("/users", controllers.Authenticate, controller.NegotiateContent)
+		{
+			userResourceGroup.GET("/", userController.GetAllUsers)
+			userResourceGroup.GET("/search", userController.SearchUsers)
+			userResourceGroup.GET("/username/:username", userController.GetUserByUsername)
+			userResourceGroup.GET("/id/:id", userController.GetUserByID)
+			userResourceGroup.GET("/uuid/:uuid", userController.GetUserByUUID)
+			userResourceGroup.POST("/", userController.CreateUser)
+			userResourceGroup.POST("/batch", userController.ExecuteBatch)
+			userResourceGroup.PATCH("/:uuid", userController.UpdateUser)
+			userResourceGroup.DELETE("/:uuid", userController.DeleteUser)
+			userResourceGroup.POST("/:uuid/activate", userController.ActivateUser)
+			userResourceGroup.POST("/:uuid/suspend", userController.SuspendUser)
+			userResourceGroup.POST("/:uuid/deactivate", userController.DeactivateUser)
+			userResourceGroup.GET("/:uuid/transitions", userController.GetStatusTransitions)
+			userResourceGroup.GET("/:uuid/audit-events", userController.GetAuditEvents)
+			userResourceGroup.POST("/:uuid/password", authController.ChangePassword)
+			userResourceGroup.POST("/:uuid/unlock", authController.Unlock)
+			userResourceGroup.GET("/:uuid/roles", roleController.GetRoles)
+			userResourceGroup.PUT("/:uuid/roles", roleController.SetRoles)
+			userResourceGroup.GET("/:uuid/groups", groupController.GetUserGroups)
+			userResourceGroup.POST("/:uuid/verify-email/resend", verificationController.ResendVerification)
+			userResourceGroup.GET("/:uuid/mfa", mfaController.GetStatus)
+			userResourceGroup.POST("/:uuid/mfa", mfaController.Enroll)
+			userResourceGroup.DELETE("/:uuid/mfa", mfaController.Reset)
+			userResourceGroup.POST("/:uuid/mfa/confirm", mfaController.ConfirmEnrollment)
+			userResourceGroup.POST("/:uuid/mfa/recovery-codes", mfaController.RegenerateRecoveryCodes)
+			userResourceGroup.POST("/:uuid/mfa/disable", mfaController.Disable)
+			userResourceGroup.GET("/:uuid/sessions", sessionController.ListSessions)
+			userResourceGroup.DELETE("/:uuid/sessions", sessionController.RevokeOtherSessions)
+			userResourceGroup.DELETE("/:uuid/sessions/:id", sessionController.RevokeSession)
+		}
+
+		v1.POST("/users/verify-email", controller.NegotiateContent, verificationController.VerifyEmail)
+
+		groupGroup := v1.Group("/groups", controllers.Authenticate, controller.NegotiateContent)
+		{
+			groupGroup.GET("/", groupController.GetAllGroups)
+			groupGroup.POST("/", groupController.CreateGroup)
+			groupGroup.GET("/:id", groupController.GetGroup)
+			groupGroup.PATCH("/:id", groupController.UpdateGroup)
+			groupGroup.DELETE("/:id", groupController.DeleteGroup)
+			groupGroup.GET("/:id/members", groupController.GetMembers)
+			groupGroup.PUT("/:id/members/:uuid", groupController.SetMember)
+			groupGroup.DELETE("/:id/members/:uuid", groupController.RemoveMember)
+			groupGroup.GET("/:id/subgroups", groupController.GetSubgroups)
+			groupGroup.PUT("/:id/subgroups/:child_id", groupController.AddSubgroup)
+			groupGroup.DELETE("/:id/subgroups/:child_id", groupController.RemoveSubgroup)
+		}
+
+		authGroup := v1.Group("/auth", controller.NegotiateContent)
+		{
+			authGroup.POST("/login", authController.Login)
+			authGroup.POST("/login/mfa", authController.LoginMFA)
+			authGroup.POST("/login/mfa/enroll", authController.EnrollMFA)
+			authGroup.POST("/refresh", authController.Refresh)
+			authGroup.POST("/logout", authController.Logout)
+			authGroup.POST("/password-reset", passwordResetController.RequestReset)
+			authGroup.POST("/password-reset/confirm", passwordResetController.ConfirmReset)
+		}
+
+		webhookGroup := v1.Group("/webhooks", controllers.Authenticate)
 		{
-			userGroup.GET("/", userController.GetAllUsers)
-			userGroup.GET("/username/:username", userController.GetUserByUsername)
-			userGroup.GET("/id/:id", userController.GetUserByID)
-			userGroup.POST("/", userController.CreateUser)
-			userGroup.PATCH("/:uuid", userController.UpdateUser)
-			userGroup.DELETE("/:uuid", userController.DeleteUser)
+			webhookGroup.GET("/", webhookController.GetAllWebhooks)
+			webhookGroup.POST("/", webhookController.CreateWebhook)
+			webhookGroup.GET("/:id", webhookController.GetWebhook)
+			webhookGroup.DELETE("/:id", webhookController.DeleteWebhook)
+			webhookGroup.POST("/:id/enable", webhookController.EnableWebhook)
+			webhookGroup.GET("/:id/deliveries", webhookController.GetDeliveries)
+			webhookGroup.POST("/:id/deliveries/:delivery_id/redeliver", webhookController.Redeliver)
 		}
 	}
 	return router
@@ -0,0 +1,92 @@
+package importer
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"errors"
+	"io"
+)
+
+// maxSummaryErrors bounds how many failed rows are kept in the summary so
+// that huge imports stay in constant memory; the streamed report has them all.
+const maxSummaryErrors = 1000
+
+// ReportFunc receives the outcome of every row as soon as it is processed.
+type ReportFunc func(result model.ImportRowResult) error
+
+type Importer struct {
+	userService service.UserService
+}
+
+func New(userService service.UserService) *Importer {
+	return &Importer{userService: userService}
+}
+
+// ValidateOptions rejects options that would fail every row, so callers can
+// report them before streaming a response.
+func ValidateOptions(options model.ImportOptions) error {
+	if options.UpsertBy != "" && options.UpsertBy != model.UpsertByEmail && options.UpsertBy != model.UpsertByUsername {
+		return model.ErrInvalidUpsertKey
+	}
+	return nil
+}
+
+// Run streams rows from reader through UserService.ImportUser one at a time.
+// Row level failures are recorded and the import continues; only read errors
+// on the source itself or a failing report abort it.
+func (importer *Importer) Run(reader RowReader, options model.ImportOptions, report ReportFunc) (*model.ImportSummary, error) {
+	if err := ValidateOptions(options); err != nil {
+		return nil, err
+	}
+
+	summary := &model.ImportSummary{DryRun: options.DryRun, Errors: []model.ImportRowResult{}}
+	for {
+		request, err := reader.Next()
+		if err == io.EOF {
+			return summary, nil
+		}
+
+		result := model.ImportRowResult{Row: reader.Row()}
+		var rowErr *RowError
+		switch {
+		case errors.As(err, &rowErr):
+			result.Action, result.Error = model.ImportActionFailed, rowErr.Error()
+		case err != nil:
+			return summary, err
+		default:
+			action, user, importErr := importer.userService.ImportUser(request, options)
+			result.Action = action
+			if importErr != nil {
+				result.Error = importErr.Error()
+			} else if user != nil {
+				result.UUID = user.UUID
+			}
+		}
+
+		importer.record(summary, result)
+		if report != nil {
+			if err := report(result); err != nil {
+				return summary, err
+			}
+		}
+	}
+}
+
+func (importer *Importer) record(summary *model.ImportSummary, result model.ImportRowResult) {
+	summary.Total++
+	switch result.Action {
+	case model.ImportActionCreated:
+		summary.Created++
+	case model.ImportActionUpdated:
+		summary.Updated++
+	case model.ImportActionUnchanged:
+		summary.Unchanged++
+	default:
+		summary.Failed++
+		if len(summary.Errors) < maxSummaryErrors {
+			summary.Errors = append(summary.Errors, result)
+		} else {
+			summary.ErrorsTruncated = true
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package importer
+
+import (
+	"bytes"
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeUserService struct {
+	service.UserService
+	requests []model.CreateUserRequest
+	options  []model.ImportOptions
+}
+
+func (userService *fakeUserService) ImportUser(request *model.CreateUserRequest, options model.ImportOptions) (model.ImportAction, *model.User, error) {
+	userService.requests = append(userService.requests, *request)
+	userService.options = append(userService.options, options)
+	if request.Email == "" {
+		return model.ImportActionFailed, nil, model.ErrEmptyField
+	}
+	return model.ImportActionCreated, &model.User{UUID: "uuid-" + request.Username}, nil
+}
+
+func runImport(test *testing.T, format Format, input string, mapping Mapping, report ReportFunc) (*fakeUserService, *model.ImportSummary) {
+	userService := &fakeUserService{}
+	reader, err := NewRowReader(format, strings.NewReader(input), mapping)
+	assert.NoError(test, err)
+
+	summary, err := New(userService).Run(reader, model.ImportOptions{DryRun: true, UpsertBy: model.UpsertByEmail}, report)
+	assert.NoError(test, err)
+	return userService, summary
+}
+
+func TestShouldImportCSVWithMappedColumns(test *testing.T) {
+	// given
+	input := "Login,E-Mail,Name\njdoe,jdoe@example.com,John Doe\nasmith, ,Alice Smith\n"
+	mapping, err := ParseMapping("username:Login,email:e-mail,full_name:Name")
+	assert.NoError(test, err)
+
+	// when
+	userService, summary := runImport(test, FormatCSV, input, mapping, nil)
+
+	// then
+	assert.Equal(test, model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"}, userService.requests[0])
+	assert.Equal(test, model.ImportOptions{DryRun: true, UpsertBy: model.UpsertByEmail}, userService.options[0])
+	assert.Equal(test, 2, summary.Total)
+	assert.Equal(test, 1, summary.Created)
+	assert.Equal(test, 1, summary.Failed)
+	assert.Equal(test, 3, summary.Errors[0].Row)
+}
+
+func TestShouldRejectCSVWithoutRequiredColumns(test *testing.T) {
+	// given
+	input := "username,full_name\njdoe,John Doe\n"
+
+	// when
+	_, err := NewRowReader(FormatCSV, strings.NewReader(input), nil)
+
+	// then
+	assert.ErrorIs(test, err, ErrMissingColumn)
+}
+
+func TestShouldImportNDJSONAndReportInvalidLines(test *testing.T) {
+	// given
+	input := `{"username":"jdoe","email":"jdoe@example.com"}` + "\n\n" +
+		`{not json}` + "\n" +
+		`{"login":"asmith","email":"asmith@example.com","full_name":"Alice Smith"}` + "\n"
+	mapping := Mapping{"username": "login"}
+
+	// when
+	userService, summary := runImport(test, FormatNDJSON, input, mapping, nil)
+
+	// then
+	assert.Len(test, userService.requests, 2)
+	assert.Equal(test, "", userService.requests[0].Username)
+	assert.Equal(test, "asmith", userService.requests[1].Username)
+	assert.Equal(test, 3, summary.Total)
+	assert.Equal(test, 1, summary.Failed)
+	assert.Equal(test, 3, summary.Errors[0].Row)
+}
+
+func TestShouldWritePerRowCSVReport(test *testing.T) {
+	// given
+	var output bytes.Buffer
+	report, err := NewCSVReport(&output)
+	assert.NoError(test, err)
+	input := "username,email\njdoe,jdoe@example.com\nasmith,\n"
+
+	// when
+	runImport(test, FormatCSV, input, nil, report.Write)
+	assert.NoError(test, report.Flush())
+
+	// then
+	assert.Equal(test, "row,action,uuid,error\n2,created,uuid-jdoe,\n3,failed,,required field is empty\n", output.String())
+}
+
+func TestShouldDetectFormat(test *testing.T) {
+	tests := []struct {
+		name        string
+		explicit    string
+		contentType string
+		filename    string
+		want        Format
+		wantErr     error
+	}{
+		{name: "Explicit format wins", explicit: "ndjson", contentType: "text/csv", want: FormatNDJSON},
+		{name: "Content type", contentType: "text/csv", want: FormatCSV},
+		{name: "File extension", filename: "users.jsonl", want: FormatNDJSON},
+		{name: "Unknown", filename: "users.xlsx", wantErr: ErrUnsupportedFormat},
+	}
+
+	for _, tt := range tests {
+		test.Run(tt.name, func(subTest *testing.T) {
+			// when
+			format, err := DetectFormat(tt.explicit, tt.contentType, tt.filename)
+
+			// then
+			assert.ErrorIs(subTest, err, tt.wantErr)
+			assert.Equal(subTest, tt.want, format)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"cruder/internal/model"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported import format, expected csv or ndjson")
+	ErrInvalidMapping    = errors.New("invalid column mapping, expected field:column pairs")
+	ErrMissingColumn     = errors.New("required column missing from header")
+)
+
+// Fields are the CreateUserRequest fields that can be imported.
+var Fields = []string{"username", "email", "full_name"}
+
+// Mapping maps a CreateUserRequest field to the source column or key name.
+// Unmapped fields are read from a column with the field's own name.
+type Mapping map[string]string
+
+func ParseMapping(value string) (Mapping, error) {
+	mapping := make(Mapping)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		field, column, ok := strings.Cut(pair, ":")
+		field, column = strings.TrimSpace(field), strings.TrimSpace(column)
+		if !ok || column == "" || !isField(field) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidMapping, pair)
+		}
+		mapping[field] = column
+	}
+	return mapping, nil
+}
+
+func (mapping Mapping) column(field string) string {
+	if column, ok := mapping[field]; ok {
+		return column
+	}
+	return field
+}
+
+func isField(name string) bool {
+	for _, field := range Fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// RowReader yields one CreateUserRequest per source row. A row that cannot be
+// decoded returns a *RowError so the import can continue with the next row.
+type RowReader interface {
+	Next() (*model.CreateUserRequest, error)
+	Row() int
+}
+
+type RowError struct {
+	Err error
+}
+
+func (rowError *RowError) Error() string { return rowError.Err.Error() }
+func (rowError *RowError) Unwrap() error { return rowError.Err }
+
+func NewRowReader(format Format, source io.Reader, mapping Mapping) (RowReader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(source, mapping)
+	case FormatNDJSON:
+		return newNDJSONReader(source, mapping), nil
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+type csvReader struct {
+	reader  *csv.Reader
+	indexes map[string]int
+	row     int
+}
+
+func newCSVReader(source io.Reader, mapping Mapping) (*csvReader, error) {
+	reader := csv.NewReader(source)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	positions := make(map[string]int, len(header))
+	for i, column := range header {
+		positions[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))] = i
+	}
+
+	indexes := make(map[string]int, len(Fields))
+	for _, field := range Fields {
+		if i, ok := positions[strings.ToLower(mapping.column(field))]; ok {
+			indexes[field] = i
+		}
+	}
+	for _, required := range []string{"username", "email"} {
+		if _, ok := indexes[required]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingColumn, mapping.column(required))
+		}
+	}
+
+	return &csvReader{reader: reader, indexes: indexes, row: 1}, nil
+}
+
+func (reader *csvReader) Row() int { return reader.row }
+
+func (reader *csvReader) Next() (*model.CreateUserRequest, error) {
+	record, err := reader.reader.Read()
+	reader.row++
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, &RowError{Err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	value := func(field string) string {
+		i, ok := reader.indexes[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	return &model.CreateUserRequest{
+		Username: value("username"),
+		Email:    value("email"),
+		FullName: value("full_name"),
+	}, nil
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	mapping Mapping
+	row     int
+}
+
+func newNDJSONReader(source io.Reader, mapping Mapping) *ndjsonReader {
+	scanner := bufio.NewScanner(source)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &ndjsonReader{scanner: scanner, mapping: mapping}
+}
+
+func (reader *ndjsonReader) Row() int { return reader.row }
+
+func (reader *ndjsonReader) Next() (*model.CreateUserRequest, error) {
+	for reader.scanner.Scan() {
+		reader.row++
+		line := bytes.TrimSpace(reader.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var object map[string]any
+		if err := json.Unmarshal(line, &object); err != nil {
+			return nil, &RowError{Err: fmt.Errorf("invalid json: %w", err)}
+		}
+
+		value := func(field string) string {
+			switch typed := object[reader.mapping.column(field)].(type) {
+			case string:
+				return strings.TrimSpace(typed)
+			case nil:
+				return ""
+			default:
+				return fmt.Sprint(typed)
+			}
+		}
+		return &model.CreateUserRequest{
+			Username: value("username"),
+			Email:    value("email"),
+			FullName: value("full_name"),
+		}, nil
+	}
+
+	if err := reader.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
@@ -0,0 +1,65 @@
+package importer
+
+import (
+	"cruder/internal/model"
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// CSVReport writes one line per imported row so callers can download the
+// outcome of a large import and fix the failed rows in their spreadsheet.
+type CSVReport struct {
+	writer *csv.Writer
+}
+
+func NewCSVReport(destination io.Writer) (*CSVReport, error) {
+	writer := csv.NewWriter(destination)
+	if err := writer.Write([]string{"row", "action", "uuid", "error"}); err != nil {
+		return nil, err
+	}
+	return &CSVReport{writer: writer}, nil
+}
+
+func (report *CSVReport) Write(result model.ImportRowResult) error {
+	return report.writer.Write([]string{strconv.Itoa(result.Row), string(result.Action), result.UUID, result.Error})
+}
+
+func (report *CSVReport) Flush() error {
+	report.writer.Flush()
+	return report.writer.Error()
+}
+
+// DetectFormat picks the import format from an explicit value, falling back
+// to the content type and then the file extension.
+func DetectFormat(explicit, contentType, filename string) (Format, error) {
+	switch {
+	case explicit != "":
+		return parseFormat(explicit)
+	case contentType == "text/csv":
+		return FormatCSV, nil
+	case contentType == "application/x-ndjson" || contentType == "application/jsonl":
+		return FormatNDJSON, nil
+	}
+
+	for _, extension := range []struct {
+		suffix string
+		format Format
+	}{{".csv", FormatCSV}, {".ndjson", FormatNDJSON}, {".jsonl", FormatNDJSON}} {
+		if len(filename) > len(extension.suffix) && filename[len(filename)-len(extension.suffix):] == extension.suffix {
+			return extension.format, nil
+		}
+	}
+	return "", ErrUnsupportedFormat
+}
+
+func parseFormat(value string) (Format, error) {
+	switch Format(value) {
+	case FormatCSV, FormatNDJSON:
+		return Format(value), nil
+	case "jsonl":
+		return FormatNDJSON, nil
+	default:
+		return "", ErrUnsupportedFormat
+	}
+}
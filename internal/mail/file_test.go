@@ -0,0 +1,37 @@
+package mail
+
+import (
+	"context"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldDropMessagesAsFiles(test *testing.T) {
+	// given
+	dir := filepath.Join(test.TempDir(), "outgoing")
+	sender, err := NewFileSender(dir, "noreply@example.com")
+	require.NoError(test, err)
+
+	// when
+	firstErr := sender.Send(context.Background(), Message{To: "jdoe@example.com", Subject: "First", Text: "one"})
+	secondErr := sender.Send(context.Background(), Message{To: "jdoe@example.com", Subject: "Second", Text: "two"})
+
+	// then
+	assert.NoError(test, firstErr)
+	assert.NoError(test, secondErr)
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(test, err)
+	require.Len(test, files, 2)
+	data, err := os.Open(files[0])
+	require.NoError(test, err)
+	defer data.Close()
+	message, err := netmail.ReadMessage(data)
+	require.NoError(test, err)
+	assert.Equal(test, "First", message.Header.Get("Subject"))
+	assert.Equal(test, "jdoe@example.com", message.Header.Get("To"))
+}
@@ -0,0 +1,117 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+var errInvalidHeader = errors.New("mail header must not contain line breaks")
+
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	// HTML is optional. With it the message carries both bodies as
+	// multipart/alternative.
+	HTML string
+}
+
+func (message Message) validate() error {
+	if strings.ContainsAny(message.To, "\r\n") || strings.ContainsAny(message.Subject, "\r\n") {
+		return errInvalidHeader
+	}
+	return nil
+}
+
+// Sender delivers messages. Implementations must be safe for concurrent use.
+type Sender interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// IsPermanent reports whether sending failed in a way retrying cannot fix:
+// the message is malformed or the server rejected it with a 5xx reply.
+func IsPermanent(err error) bool {
+	var protocolErr *textproto.Error
+	if errors.As(err, &protocolErr) {
+		return protocolErr.Code >= 500
+	}
+	return errors.Is(err, errInvalidHeader)
+}
+
+// compose renders message as an RFC 5322 message from from.
+func compose(from string, message Message) ([]byte, error) {
+	if err := message.validate(); err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	header := func(name, value string) { fmt.Fprintf(&buffer, "%s: %s\r\n", name, value) }
+	header("From", from)
+	header("To", message.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", message.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID(from))
+	header("MIME-Version", "1.0")
+
+	if message.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buffer.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buffer, message.Text); err != nil {
+			return nil, err
+		}
+		return buffer.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buffer)
+	header("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	buffer.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", message.Text},
+		{"text/html; charset=utf-8", message.HTML},
+	} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(writer, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func writeQuotedPrintable(target interface{ Write([]byte) (int, error) }, body string) error {
+	writer := quotedprintable.NewWriter(target)
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	if _, err := writer.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+func messageID(from string) string {
+	random := make([]byte, 16)
+	_, _ = rand.Read(random)
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = strings.Trim(from[at+1:], "<> ")
+	}
+	return "<" + hex.EncodeToString(random) + "@" + domain + ">"
+}
@@ -0,0 +1,33 @@
+package mail
+
+import (
+	"context"
+	"sync"
+)
+
+// MemorySender keeps sent messages in memory instead of delivering them.
+type MemorySender struct {
+	mutex    sync.Mutex
+	messages []Message
+}
+
+func NewMemorySender() *MemorySender {
+	return &MemorySender{}
+}
+
+func (memorySender *MemorySender) Send(_ context.Context, message Message) error {
+	if err := message.validate(); err != nil {
+		return err
+	}
+	memorySender.mutex.Lock()
+	defer memorySender.mutex.Unlock()
+	memorySender.messages = append(memorySender.messages, message)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first.
+func (memorySender *MemorySender) Messages() []Message {
+	memorySender.mutex.Lock()
+	defer memorySender.mutex.Unlock()
+	return append([]Message(nil), memorySender.messages...)
+}
@@ -0,0 +1,135 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+type TLSMode string
+
+const (
+	// TLSOpportunistic upgrades with STARTTLS when the server offers it.
+	TLSOpportunistic TLSMode = ""
+	// TLSStartTLS requires STARTTLS.
+	TLSStartTLS TLSMode = "starttls"
+	// TLSImplicit connects with TLS from the start, usually on port 465.
+	TLSImplicit TLSMode = "tls"
+	// TLSNone never encrypts, for local development servers.
+	TLSNone TLSMode = "none"
+)
+
+type SMTPConfig struct {
+	// Addr is the host:port of the SMTP server.
+	Addr string
+	From string
+	// Username and Password enable PLAIN authentication, which net/smtp
+	// only performs over TLS or to localhost.
+	Username string
+	Password string
+	TLS      TLSMode
+	// TLSConfig overrides the TLS settings, e.g. to trust a private CA.
+	TLSConfig *tls.Config
+	Timeout   time.Duration
+}
+
+type SMTPSender struct {
+	config SMTPConfig
+}
+
+func NewSMTPSender(config SMTPConfig) *SMTPSender {
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+	return &SMTPSender{config: config}
+}
+
+func (smtpSender *SMTPSender) Send(ctx context.Context, message Message) error {
+	data, err := compose(smtpSender.config.From, message)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, smtpSender.config.Timeout)
+	defer cancel()
+	host, _, err := net.SplitHostPort(smtpSender.config.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP address %q: %w", smtpSender.config.Addr, err)
+	}
+	conn, err := smtpSender.dial(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", smtpSender.config.Addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if err := smtpSender.deliver(client, host, message.To, data); err != nil {
+		return fmt.Errorf("failed to send mail to %s: %w", message.To, err)
+	}
+	return client.Quit()
+}
+
+func (smtpSender *SMTPSender) tlsConfig(host string) *tls.Config {
+	if smtpSender.config.TLSConfig != nil {
+		config := smtpSender.config.TLSConfig.Clone()
+		if config.ServerName == "" {
+			config.ServerName = host
+		}
+		return config
+	}
+	return &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+}
+
+func (smtpSender *SMTPSender) dial(ctx context.Context, host string) (net.Conn, error) {
+	if smtpSender.config.TLS == TLSImplicit {
+		dialer := tls.Dialer{Config: smtpSender.tlsConfig(host)}
+		return dialer.DialContext(ctx, "tcp", smtpSender.config.Addr)
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", smtpSender.config.Addr)
+}
+
+func (smtpSender *SMTPSender) deliver(client *smtp.Client, host, to string, data []byte) error {
+	switch smtpSender.config.TLS {
+	case TLSOpportunistic, TLSStartTLS:
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(smtpSender.tlsConfig(host)); err != nil {
+				return err
+			}
+		} else if smtpSender.config.TLS == TLSStartTLS {
+			return fmt.Errorf("server %s does not support STARTTLS", host)
+		}
+	}
+	if smtpSender.config.Username != "" {
+		auth := smtp.PlainAuth("", smtpSender.config.Username, smtpSender.config.Password, host)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(smtpSender.config.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(data); err != nil {
+		_ = writer.Close()
+		return err
+	}
+	return writer.Close()
+}
@@ -0,0 +1,294 @@
+package mail
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type receivedMail struct {
+	from, to  string
+	data      []byte
+	encrypted bool
+	auth      string
+}
+
+// fakeSMTPServer speaks just enough SMTP for net/smtp on a local port.
+type fakeSMTPServer struct {
+	listener    net.Listener
+	tlsConfig   *tls.Config
+	implicitTLS bool
+	// rcptReply overrides the reply to RCPT TO, e.g. "550 no such user".
+	rcptReply string
+
+	mutex    sync.Mutex
+	received []receivedMail
+}
+
+func startFakeSMTPServer(test *testing.T, tlsConfig *tls.Config, implicitTLS bool) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(test, err)
+	if implicitTLS {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	server := &fakeSMTPServer{listener: listener, tlsConfig: tlsConfig, implicitTLS: implicitTLS}
+	test.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (server *fakeSMTPServer) addr() string {
+	return server.listener.Addr().String()
+}
+
+func (server *fakeSMTPServer) mails() []receivedMail {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]receivedMail(nil), server.received...)
+}
+
+func (server *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	encrypted := server.implicitTLS
+	text := textproto.NewConn(conn)
+	reply := func(lines ...string) {
+		for _, line := range lines {
+			_ = text.PrintfLine("%s", line)
+		}
+	}
+
+	var current receivedMail
+	reply("220 localhost ESMTP fake")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command, argument, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(command) {
+		case "EHLO", "HELO":
+			lines := []string{"250-localhost"}
+			if server.tlsConfig != nil && !encrypted {
+				lines = append(lines, "250-STARTTLS")
+			}
+			reply(append(lines, "250 AUTH PLAIN")...)
+		case "STARTTLS":
+			reply("220 ready to start TLS")
+			tlsConn := tls.Server(conn, server.tlsConfig)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn, encrypted = tlsConn, true
+			text = textproto.NewConn(tlsConn)
+		case "AUTH":
+			_, initial, _ := strings.Cut(argument, " ")
+			credentials, _ := base64.StdEncoding.DecodeString(initial)
+			current.auth = string(credentials)
+			reply("235 authenticated")
+		case "MAIL":
+			current.from = strings.TrimPrefix(argument, "FROM:")
+			current.encrypted = encrypted
+			reply("250 ok")
+		case "RCPT":
+			if server.rcptReply != "" {
+				reply(server.rcptReply)
+				continue
+			}
+			current.to = strings.TrimPrefix(argument, "TO:")
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			current.data = data
+			server.mutex.Lock()
+			server.received = append(server.received, current)
+			server.mutex.Unlock()
+			current = receivedMail{}
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+// selfSignedTLS returns a server config for 127.0.0.1 and a client config
+// trusting it.
+func selfSignedTLS(test *testing.T) (*tls.Config, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(test, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fake smtp"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(test, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(test, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(certificate)
+	server := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	return server, &tls.Config{RootCAs: roots}
+}
+
+// readParts parses a received multipart/alternative message into its
+// decoded bodies by content type.
+func readParts(test *testing.T, data []byte) (*netmail.Message, map[string]string) {
+	message, err := netmail.ReadMessage(strings.NewReader(string(data)))
+	require.NoError(test, err)
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	require.NoError(test, err)
+	require.Equal(test, "multipart/alternative", mediaType)
+
+	parts := make(map[string]string)
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			return message, parts
+		}
+		require.NoError(test, err)
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		require.NoError(test, err)
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[contentType] = string(body)
+	}
+}
+
+func TestShouldSendOverSTARTTLSWithAuthentication(test *testing.T) {
+	// given
+	serverTLS, clientTLS := selfSignedTLS(test)
+	server := startFakeSMTPServer(test, serverTLS, false)
+	sender := NewSMTPSender(SMTPConfig{
+		Addr: server.addr(), From: "noreply@example.com", Username: "mailer", Password: "secret",
+		TLS: TLSStartTLS, TLSConfig: clientTLS, Timeout: 5 * time.Second,
+	})
+
+	// when
+	err := sender.Send(context.Background(), Message{
+		To:      "jdoe@example.com",
+		Subject: "Grüße",
+		Text:    "Hello jdoe,\nwelcome!",
+		HTML:    "<p>Hello <strong>jdoe</strong></p>",
+	})
+
+	// then
+	require.NoError(test, err)
+	mails := server.mails()
+	require.Len(test, mails, 1)
+	assert.True(test, mails[0].encrypted)
+	assert.Equal(test, "\x00mailer\x00secret", mails[0].auth)
+	assert.Equal(test, "<noreply@example.com>", mails[0].from)
+	assert.Equal(test, "<jdoe@example.com>", mails[0].to)
+	message, parts := readParts(test, mails[0].data)
+	subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	assert.NoError(test, err)
+	assert.Equal(test, "Grüße", subject)
+	assert.Equal(test, "Hello jdoe,\nwelcome!", parts["text/plain"])
+	assert.Equal(test, "<p>Hello <strong>jdoe</strong></p>", parts["text/html"])
+}
+
+func TestShouldSendOverImplicitTLS(test *testing.T) {
+	// given
+	serverTLS, clientTLS := selfSignedTLS(test)
+	server := startFakeSMTPServer(test, serverTLS, true)
+	sender := NewSMTPSender(SMTPConfig{
+		Addr: server.addr(), From: "noreply@example.com", TLS: TLSImplicit, TLSConfig: clientTLS, Timeout: 5 * time.Second,
+	})
+
+	// when
+	err := sender.Send(context.Background(), Message{To: "jdoe@example.com", Subject: "Hi", Text: "plain"})
+
+	// then
+	require.NoError(test, err)
+	mails := server.mails()
+	require.Len(test, mails, 1)
+	assert.True(test, mails[0].encrypted)
+	assert.Contains(test, string(mails[0].data), "Content-Type: text/plain; charset=utf-8")
+}
+
+func TestShouldRefuseUnencryptedServerWhenSTARTTLSIsRequired(test *testing.T) {
+	// given
+	server := startFakeSMTPServer(test, nil, false)
+	sender := NewSMTPSender(SMTPConfig{Addr: server.addr(), From: "noreply@example.com", TLS: TLSStartTLS, Timeout: 5 * time.Second})
+
+	// when
+	err := sender.Send(context.Background(), Message{To: "jdoe@example.com", Subject: "Hi", Text: "plain"})
+
+	// then
+	assert.ErrorContains(test, err, "does not support STARTTLS")
+	assert.False(test, IsPermanent(err))
+	assert.Empty(test, server.mails())
+}
+
+func TestShouldClassifyServerRejections(test *testing.T) {
+	// given
+	rejecting := startFakeSMTPServer(test, nil, false)
+	rejecting.rcptReply = "550 no such user"
+	throttling := startFakeSMTPServer(test, nil, false)
+	throttling.rcptReply = "451 try again later"
+	message := Message{To: "jdoe@example.com", Subject: "Hi", Text: "plain"}
+
+	// when
+	rejectErr := NewSMTPSender(SMTPConfig{Addr: rejecting.addr(), From: "noreply@example.com", TLS: TLSNone}).
+		Send(context.Background(), message)
+	throttleErr := NewSMTPSender(SMTPConfig{Addr: throttling.addr(), From: "noreply@example.com", TLS: TLSNone}).
+		Send(context.Background(), message)
+
+	// then
+	assert.True(test, IsPermanent(rejectErr))
+	assert.Error(test, throttleErr)
+	assert.False(test, IsPermanent(throttleErr))
+}
+
+func TestShouldRejectHeaderInjection(test *testing.T) {
+	// given
+	server := startFakeSMTPServer(test, nil, false)
+	sender := NewSMTPSender(SMTPConfig{Addr: server.addr(), From: "noreply@example.com", TLS: TLSNone})
+
+	// when
+	err := sender.Send(context.Background(), Message{To: "jdoe@example.com", Subject: "Hi\r\nBcc: everyone@example.com"})
+
+	// then
+	assert.True(test, IsPermanent(err))
+	assert.Empty(test, server.mails())
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditLoginLocked   AuditAction = "login.locked"
+	AuditLoginUnlocked AuditAction = "login.unlocked"
+	AuditIPLocked      AuditAction = "login.ip_locked"
+)
+
+// AuditEvent records a security-relevant event. UserUUID is empty for
+// events about a client IP.
+type AuditEvent struct {
+	ID        int64       `json:"id" xml:"id"`
+	UserUUID  string      `json:"user_uuid,omitempty" xml:"user_uuid,omitempty"`
+	Action    AuditAction `json:"action" xml:"action"`
+	Detail    string      `json:"detail" xml:"detail"`
+	Actor     string      `json:"actor" xml:"actor"`
+	IPAddress string      `json:"ip_address,omitempty" xml:"ip_address,omitempty"`
+	CreatedAt time.Time   `json:"created_at" xml:"created_at"`
+}
+
+// AuditEventList is the XML representation of a user's audit events.
+type AuditEventList struct {
+	XMLName xml.Name     `json:"-" xml:"audit_events"`
+	Events  []AuditEvent `json:"events" xml:"event"`
+}
@@ -0,0 +1,136 @@
+package model
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// LoginRequest authenticates with either the username or the email address
+// in Login. Organization is the organization's slug and defaults to
+// DefaultOrganizationSlug.
+type LoginRequest struct {
+	Organization string `json:"organization" xml:"organization"`
+	Login        string `json:"login" xml:"login" binding:"required"`
+	Password     string `json:"password" xml:"password" binding:"required"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" xml:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" xml:"new_password" binding:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" xml:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token" xml:"refresh_token"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token" xml:"access_token"`
+	TokenType    string `json:"token_type" xml:"token_type"`
+	ExpiresIn    int    `json:"expires_in" xml:"expires_in"`
+	RefreshToken string `json:"refresh_token" xml:"refresh_token"`
+	User         *User  `json:"user,omitempty" xml:"user,omitempty"`
+}
+
+type PrincipalKind string
+
+const (
+	PrincipalUser   PrincipalKind = "user"
+	PrincipalAPIKey PrincipalKind = "api_key"
+)
+
+// Principal is the authenticated caller. ID is the user UUID for users and
+// the key id for API keys.
+type Principal struct {
+	Kind      PrincipalKind
+	ID        string
+	TenantID  string
+	SessionID string
+	Roles     []string
+}
+
+func (principal *Principal) String() string {
+	return string(principal.Kind) + ":" + principal.ID
+}
+
+// Session is one refresh token family: every refresh token rotated from the
+// same login. Revoking it invalidates all of them and the access tokens
+// issued alongside.
+type Session struct {
+	ID         string     `json:"id" xml:"id"`
+	UserUUID   string     `json:"user_uuid" xml:"user_uuid"`
+	TenantID   string     `json:"tenant_id" xml:"tenant_id"`
+	UserAgent  string     `json:"user_agent" xml:"user_agent"`
+	IPAddress  string     `json:"ip_address" xml:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at" xml:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at" xml:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at" xml:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" xml:"revoked_at,omitempty"`
+	// Current marks the session the listing was requested with.
+	Current bool `json:"current" xml:"current"`
+}
+
+func (session *Session) Active(now time.Time) bool {
+	return session.RevokedAt == nil && now.Before(session.ExpiresAt)
+}
+
+// SessionList is the XML representation of a user's sessions.
+type SessionList struct {
+	XMLName  xml.Name  `json:"-" xml:"sessions"`
+	Sessions []Session `json:"sessions" xml:"session"`
+}
+
+// SessionClient describes the device a login comes from.
+type SessionClient struct {
+	UserAgent string
+	IPAddress string
+}
+
+type RefreshToken struct {
+	ID        int64
+	SessionID string
+	Hash      []byte
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	TenantID   string     `json:"tenant_id"`
+	Prefix     string     `json:"prefix"`
+	Roles      []string   `json:"roles"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// LoginThrottle counts the failed logins of one account or client IP.
+type LoginThrottle struct {
+	Key          string
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+}
+
+// LoginThrottledError rejects a login attempt without checking the
+// password. It unwraps to ErrAccountLocked during a lockout and to
+// ErrLoginThrottled during the delay between attempts.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (err *LoginThrottledError) Error() string {
+	return err.Unwrap().Error()
+}
+
+func (err *LoginThrottledError) Unwrap() error {
+	if err.Locked {
+		return ErrAccountLocked
+	}
+	return ErrLoginThrottled
+}
@@ -0,0 +1,48 @@
+package model
+
+import "encoding/json"
+
+type BatchOp string
+
+const (
+	BatchOpCreate BatchOp = "create"
+	BatchOpUpdate BatchOp = "update"
+	BatchOpDelete BatchOp = "delete"
+)
+
+type BatchOperation struct {
+	Op   BatchOp         `json:"op" binding:"required"`
+	UUID string          `json:"uuid"`
+	Data json.RawMessage `json:"data"`
+}
+
+type BatchRequest struct {
+	Atomic     bool             `json:"atomic"`
+	Operations []BatchOperation `json:"operations" binding:"required"`
+}
+
+// BatchItemResult is the outcome of one operation. Err is mapped to an HTTP
+// status by the controller; RolledBack marks atomic batch items that succeeded
+// but were undone because another item failed.
+type BatchItemResult struct {
+	Index      int
+	Op         BatchOp
+	User       *User
+	Err        error
+	RolledBack bool
+}
+
+type BatchItemResponse struct {
+	Index  int     `json:"index"`
+	Op     BatchOp `json:"op"`
+	Status int     `json:"status"`
+	User   *User   `json:"user,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Atomic    bool                `json:"atomic"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+	Results   []BatchItemResponse `json:"results"`
+}
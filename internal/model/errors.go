@@ -6,4 +6,72 @@ var (
 	ErrUserNotFound = errors.New("user not found")
 	ErrInvalidEmail = errors.New("invalid email format")
 	ErrEmptyField   = errors.New("required field is empty")
+
+	ErrUserAlreadyExists = errors.New("user already exists")
+	ErrBatchTooLarge     = errors.New("batch exceeds maximum size")
+	ErrUnknownBatchOp    = errors.New("unknown batch operation")
+	ErrInvalidBatchData  = errors.New("invalid batch operation data")
+	ErrInvalidUpsertKey  = errors.New("upsert must be email or username")
+	ErrInvalidFilter     = errors.New("invalid filter")
+
+	ErrIllegalStatusTransition = errors.New("illegal status transition")
+
+	ErrWeakPassword         = errors.New("password does not meet the password policy")
+	ErrInvalidCredentials   = errors.New("invalid credentials")
+	ErrAccountDisabled      = errors.New("account is disabled")
+	ErrInvalidToken         = errors.New("invalid or expired token")
+	ErrTokenReused          = errors.New("refresh token reuse detected; session revoked")
+	ErrUnauthenticated      = errors.New("authentication required")
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+	ErrRateLimited          = errors.New("too many requests; try again later")
+	ErrSessionNotFound      = errors.New("session not found")
+	ErrLoginThrottled       = errors.New("too many failed logins; try again later")
+	ErrAccountLocked        = errors.New("login is temporarily locked after too many failed attempts")
+
+	ErrForbidden   = errors.New("insufficient permissions")
+	ErrInvalidRole = errors.New("invalid role")
+
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrOrganizationExists   = errors.New("organization already exists")
+
+	ErrGroupNotFound    = errors.New("group not found")
+	ErrGroupExists      = errors.New("group already exists")
+	ErrInvalidGroupRole = errors.New("group role must be owner or member")
+	ErrMemberNotFound   = errors.New("user is not a member of the group")
+	ErrGroupCycle       = errors.New("nesting would create a group cycle")
+	ErrSubgroupNotFound = errors.New("group is not a subgroup")
+	ErrLastGroupOwner   = errors.New("group must keep at least one owner")
+
+	ErrInvalidMFACode    = errors.New("invalid one-time code")
+	ErrMFAAlreadyEnabled = errors.New("multi-factor authentication is already enabled")
+	ErrMFANotEnrolled    = errors.New("multi-factor authentication is not enabled")
+	ErrMFAUnavailable    = errors.New("multi-factor authentication is not configured")
+)
+
+var (
+	ErrWebhookNotFound   = errors.New("webhook not found")
+	ErrDeliveryNotFound  = errors.New("webhook delivery not found")
+	ErrInvalidWebhookURL = errors.New("webhook url must be an absolute http or https url")
+	ErrUnknownEventType  = errors.New("unknown event type")
+)
+
+// OpenID Connect errors. The provider reports them with the OAuth 2.0 error
+// code they are named after.
+var (
+	ErrInvalidClient           = errors.New("unknown client or wrong client credentials")
+	ErrInvalidRedirectURI      = errors.New("redirect_uri is not registered for the client")
+	ErrInvalidOAuthRequest     = errors.New("invalid request")
+	ErrUnsupportedResponseType = errors.New("only the code response type is supported")
+	ErrInvalidScope            = errors.New("scope must include openid")
+	ErrInvalidGrant            = errors.New("authorization code is invalid, expired or already used")
+	ErrUnsupportedGrantType    = errors.New("only the authorization_code grant type is supported")
+)
+
+// SCIM errors. They are reported with the scimType they are named after.
+var (
+	ErrInvalidSCIMSyntax = errors.New("request body does not follow the SCIM schema")
+	ErrInvalidSCIMPath   = errors.New("unsupported or malformed attribute path")
+	ErrInvalidSCIMValue  = errors.New("invalid attribute value")
+	ErrSCIMMutability    = errors.New("attribute cannot be changed")
+	ErrSCIMNoTarget      = errors.New("operation has no target")
 )
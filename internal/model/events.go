@@ -0,0 +1,67 @@
+package model
+
+import "time"
+
+type EventType string
+
+const (
+	EventUserCreated EventType = "user.created"
+	EventUserUpdated EventType = "user.updated"
+	EventUserDeleted EventType = "user.deleted"
+)
+
+func (eventType EventType) IsValid() bool {
+	switch eventType {
+	case EventUserCreated, EventUserUpdated, EventUserDeleted:
+		return true
+	}
+	return false
+}
+
+type DomainEvent interface {
+	EventType() EventType
+	AggregateID() string
+}
+
+type UserCreated struct {
+	User User `json:"user"`
+}
+
+func (event UserCreated) EventType() EventType { return EventUserCreated }
+func (event UserCreated) AggregateID() string  { return event.User.UUID }
+
+type UserUpdated struct {
+	User          User     `json:"user"`
+	ChangedFields []string `json:"changed_fields"`
+}
+
+func (event UserUpdated) EventType() EventType { return EventUserUpdated }
+func (event UserUpdated) AggregateID() string  { return event.User.UUID }
+
+type UserDeleted struct {
+	UUID     string `json:"uuid"`
+	TenantID string `json:"tenant_id"`
+}
+
+func (event UserDeleted) EventType() EventType { return EventUserDeleted }
+func (event UserDeleted) AggregateID() string  { return event.UUID }
+
+type OutboxStatus string
+
+const (
+	OutboxStatusPending   OutboxStatus = "pending"
+	OutboxStatusPublished OutboxStatus = "published"
+	OutboxStatusDead      OutboxStatus = "dead"
+)
+
+type OutboxMessage struct {
+	ID          int64        `json:"id"`
+	AggregateID string       `json:"aggregate_id"`
+	EventType   EventType    `json:"event_type"`
+	Payload     []byte       `json:"payload"`
+	Status      OutboxStatus `json:"status"`
+	Attempts    int          `json:"attempts"`
+	LastError   string       `json:"last_error,omitempty"`
+	AvailableAt time.Time    `json:"available_at"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
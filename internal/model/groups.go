@@ -0,0 +1,89 @@
+package model
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+type Group struct {
+	ID          string `json:"id" xml:"id"`
+	TenantID    string `json:"tenant_id" xml:"tenant_id"`
+	Name        string `json:"name" xml:"name"`
+	Description string `json:"description" xml:"description"`
+
+	CreatedAt time.Time `json:"created_at" xml:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" xml:"updated_at"`
+}
+
+type GroupRole string
+
+const (
+	GroupRoleOwner  GroupRole = "owner"
+	GroupRoleMember GroupRole = "member"
+)
+
+func (role GroupRole) IsValid() bool {
+	return role == GroupRoleOwner || role == GroupRoleMember
+}
+
+type CreateGroupRequest struct {
+	Name        string `json:"name" xml:"name" binding:"required"`
+	Description string `json:"description" xml:"description"`
+	// ParentID optionally nests the new group under an existing one.
+	ParentID string `json:"parent_id,omitempty" xml:"parent_id,omitempty"`
+	// OwnerUUID optionally names the group's first owner.
+	OwnerUUID string `json:"owner_uuid,omitempty" xml:"owner_uuid,omitempty"`
+}
+
+type UpdateGroupRequest struct {
+	Name        string `json:"name" xml:"name"`
+	Description string `json:"description" xml:"description"`
+}
+
+type MembershipRequest struct {
+	Role GroupRole `json:"role" xml:"role" binding:"required"`
+}
+
+// GroupMember is a user's direct membership of a group.
+type GroupMember struct {
+	User     User      `json:"user" xml:"user"`
+	Role     GroupRole `json:"role" xml:"role"`
+	JoinedAt time.Time `json:"joined_at" xml:"joined_at"`
+}
+
+// UserGroup is a group a user belongs to. Inherited memberships come from
+// belonging to one of the group's subgroups and always have the member role.
+type UserGroup struct {
+	Group     Group     `json:"group" xml:"group"`
+	Role      GroupRole `json:"role" xml:"role"`
+	Inherited bool      `json:"inherited" xml:"inherited"`
+}
+
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+type GroupPage struct {
+	XMLName xml.Name `json:"-" xml:"groups"`
+	Total   int      `json:"total" xml:"total"`
+	Limit   int      `json:"limit" xml:"limit"`
+	Offset  int      `json:"offset" xml:"offset"`
+	Groups  []Group  `json:"groups" xml:"group"`
+}
+
+type GroupMemberPage struct {
+	XMLName xml.Name      `json:"-" xml:"members"`
+	Total   int           `json:"total" xml:"total"`
+	Limit   int           `json:"limit" xml:"limit"`
+	Offset  int           `json:"offset" xml:"offset"`
+	Members []GroupMember `json:"members" xml:"member"`
+}
+
+type UserGroupPage struct {
+	XMLName xml.Name    `json:"-" xml:"groups"`
+	Total   int         `json:"total" xml:"total"`
+	Limit   int         `json:"limit" xml:"limit"`
+	Offset  int         `json:"offset" xml:"offset"`
+	Groups  []UserGroup `json:"groups" xml:"group"`
+}
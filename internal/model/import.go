@@ -0,0 +1,38 @@
+package model
+
+type ImportAction string
+
+const (
+	ImportActionCreated   ImportAction = "created"
+	ImportActionUpdated   ImportAction = "updated"
+	ImportActionUnchanged ImportAction = "unchanged"
+	ImportActionFailed    ImportAction = "failed"
+)
+
+const (
+	UpsertByEmail    = "email"
+	UpsertByUsername = "username"
+)
+
+type ImportOptions struct {
+	DryRun   bool
+	UpsertBy string
+}
+
+type ImportRowResult struct {
+	Row    int          `json:"row"`
+	Action ImportAction `json:"action"`
+	UUID   string       `json:"uuid,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+type ImportSummary struct {
+	DryRun          bool              `json:"dry_run"`
+	Total           int               `json:"total"`
+	Created         int               `json:"created"`
+	Updated         int               `json:"updated"`
+	Unchanged       int               `json:"unchanged"`
+	Failed          int               `json:"failed"`
+	Errors          []ImportRowResult `json:"errors"`
+	ErrorsTruncated bool              `json:"errors_truncated,omitempty"`
+}
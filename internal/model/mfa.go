@@ -0,0 +1,83 @@
+package model
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// UserMFA is a user's TOTP second factor. It is pending until EnabledAt is
+// set by confirming a first code.
+type UserMFA struct {
+	UserUUID string
+	// Secret is encrypted, bound to the user's UUID.
+	Secret    []byte
+	CreatedAt time.Time
+	EnabledAt *time.Time
+	// LastCounter is the time step of the last accepted code; codes of it
+	// and earlier steps are rejected as replays.
+	LastCounter *int64
+}
+
+// MFAEnrollment is shown once: only the encrypted secret and the recovery
+// code hashes are stored.
+type MFAEnrollment struct {
+	XMLName       xml.Name `json:"-" xml:"mfa_enrollment"`
+	Secret        string   `json:"secret" xml:"secret"`
+	URI           string   `json:"otpauth_uri" xml:"otpauth_uri"`
+	QRCode        string   `json:"qr_code" xml:"qr_code"`
+	RecoveryCodes []string `json:"recovery_codes" xml:"recovery_codes>code"`
+}
+
+type MFAStatus struct {
+	XMLName                xml.Name   `json:"-" xml:"mfa"`
+	Enabled                bool       `json:"enabled" xml:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at,omitempty" xml:"enabled_at,omitempty"`
+	Required               bool       `json:"required" xml:"required"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining" xml:"recovery_codes_remaining"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" xml:"code" binding:"required"`
+}
+
+type RecoveryCodes struct {
+	XMLName       xml.Name `json:"-" xml:"recovery_codes"`
+	RecoveryCodes []string `json:"recovery_codes" xml:"code"`
+}
+
+// MFAChallenge is a login that passed the password check and waits for the
+// second factor.
+type MFAChallenge struct {
+	ID        int64
+	UserUUID  string
+	ExpiresAt time.Time
+	Attempts  int
+	UsedAt    *time.Time
+}
+
+// Usable reports whether the challenge can still be completed at now.
+func (challenge *MFAChallenge) Usable(now time.Time, maxAttempts int) bool {
+	return challenge.UsedAt == nil && now.Before(challenge.ExpiresAt) && challenge.Attempts < maxAttempts
+}
+
+// MFAChallengeResponse answers a login that needs a second factor instead
+// of a TokenResponse. With EnrollmentRequired the user has to enroll first.
+type MFAChallengeResponse struct {
+	XMLName            xml.Name `json:"-" xml:"mfa_challenge"`
+	MFARequired        bool     `json:"mfa_required" xml:"mfa_required"`
+	MFAToken           string   `json:"mfa_token" xml:"mfa_token"`
+	EnrollmentRequired bool     `json:"enrollment_required" xml:"enrollment_required"`
+	ExpiresIn          int      `json:"expires_in" xml:"expires_in"`
+}
+
+type MFATokenRequest struct {
+	MFAToken string `json:"mfa_token" xml:"mfa_token" binding:"required"`
+}
+
+// MFALoginRequest completes a challenge with either a TOTP code or a
+// recovery code.
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfa_token" xml:"mfa_token" binding:"required"`
+	Code         string `json:"code" xml:"code"`
+	RecoveryCode string `json:"recovery_code" xml:"recovery_code"`
+}
@@ -0,0 +1,111 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+// OIDCClient is an application that signs its users in through the built-in
+// OpenID Connect provider. Users of its tenant can sign in to it.
+type OIDCClient struct {
+	ID           string    `json:"client_id"`
+	TenantID     string    `json:"tenant_id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	CreatedAt    time.Time `json:"created_at"`
+	// SecretHash is empty for public clients, which rely on PKCE alone.
+	SecretHash []byte `json:"-"`
+}
+
+func (client *OIDCClient) Public() bool {
+	return len(client.SecretHash) == 0
+}
+
+// AllowsRedirect reports whether uri is one of the registered redirect URIs.
+// Only exact matches count.
+func (client *OIDCClient) AllowsRedirect(uri string) bool {
+	return slices.Contains(client.RedirectURIs, uri)
+}
+
+// AuthorizationRequest is the query of the authorization endpoint. The login
+// page posts it back with the credentials.
+type AuthorizationRequest struct {
+	ResponseType        string `form:"response_type"`
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	Nonce               string `form:"nonce"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+}
+
+// AuthorizationCode is handed to the client after login and exchanged once
+// for tokens.
+type AuthorizationCode struct {
+	ID            int64
+	ClientID      string
+	UserUUID      string
+	RedirectURI   string
+	Scope         string
+	Nonce         string
+	CodeChallenge string
+	AuthTime      time.Time
+	ExpiresAt     time.Time
+	UsedAt        *time.Time
+}
+
+// OIDCAccessToken grants access to the userinfo endpoint. CodeID is the
+// code it was exchanged for, so a replayed code can revoke it.
+type OIDCAccessToken struct {
+	CodeID    int64
+	ClientID  string
+	UserUUID  string
+	Scope     string
+	ExpiresAt time.Time
+}
+
+type OIDCTokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	CodeVerifier string `form:"code_verifier"`
+}
+
+type OIDCTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	IDToken     string `json:"id_token"`
+	Scope       string `json:"scope"`
+}
+
+// UserInfo holds the claims about a user that the granted scopes release:
+// profile adds the username and name, email the email address.
+type UserInfo struct {
+	Subject           string `json:"sub"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Name              string `json:"name,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+}
+
+// OIDCDiscovery is the provider metadata served at
+// /.well-known/openid-configuration.
+type OIDCDiscovery struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// DefaultOrganizationSlug names the organization that users created before
+// multi-tenancy belong to.
+const DefaultOrganizationSlug = "default"
+
+type Organization struct {
+	ID        string    `json:"id" xml:"id"`
+	Slug      string    `json:"slug" xml:"slug"`
+	Name      string    `json:"name" xml:"name"`
+	CreatedAt time.Time `json:"created_at" xml:"created_at"`
+}
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+type PasswordResetToken struct {
+	ID        int64
+	UserUUID  string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// Usable reports whether the token can still reset a password at now.
+func (token *PasswordResetToken) Usable(now time.Time) bool {
+	return token.UsedAt == nil && now.Before(token.ExpiresAt)
+}
+
+// PasswordResetRequest asks for a reset link. Organization is the
+// organization's slug and defaults to DefaultOrganizationSlug.
+type PasswordResetRequest struct {
+	Organization string `json:"organization" xml:"organization"`
+	Email        string `json:"email" xml:"email" binding:"required"`
+}
+
+type ConfirmPasswordResetRequest struct {
+	Token       string `json:"token" xml:"token" binding:"required"`
+	NewPassword string `json:"new_password" xml:"new_password" binding:"required"`
+}
@@ -0,0 +1,56 @@
+package model
+
+import "encoding/xml"
+
+type Role string
+
+const (
+	RoleAdmin   Role = "admin"
+	RoleSupport Role = "support"
+	// RoleSelf is never assigned; every user holds it towards their own record.
+	RoleSelf Role = "self"
+)
+
+func (role Role) IsAssignable() bool {
+	return role == RoleAdmin || role == RoleSupport
+}
+
+type Permission string
+
+const (
+	PermissionReadUsers      Permission = "users:read"
+	PermissionCreateUsers    Permission = "users:create"
+	PermissionUpdateUsers    Permission = "users:update"
+	PermissionDeleteUsers    Permission = "users:delete"
+	PermissionChangeStatus   Permission = "users:status"
+	PermissionChangePassword Permission = "users:password"
+	PermissionManageRoles    Permission = "roles:manage"
+	PermissionReadGroups     Permission = "groups:read"
+	PermissionManageGroups   Permission = "groups:manage"
+	// PermissionEnrollMFA covers setting up and removing one's own second
+	// factor, which involves seeing its secret.
+	PermissionEnrollMFA Permission = "mfa:enroll"
+	// PermissionResetMFA removes another user's second factor, for users who
+	// lost their device.
+	PermissionResetMFA Permission = "mfa:reset"
+	// PermissionManageSessions covers listing and revoking a user's sessions.
+	PermissionManageSessions Permission = "sessions:manage"
+	// PermissionUnlockLogins lifts a lockout after failed logins.
+	PermissionUnlockLogins Permission = "logins:unlock"
+	// PermissionManageWebhooks covers webhook subscriptions and their
+	// deliveries, which carry every user event.
+	PermissionManageWebhooks Permission = "webhooks:manage"
+	// PermissionReadMetrics covers the runtime metrics at /debug/vars, which
+	// include the command line.
+	PermissionReadMetrics Permission = "metrics:read"
+)
+
+type RolesRequest struct {
+	Roles []Role `json:"roles" xml:"role" binding:"required"`
+}
+
+type UserRoles struct {
+	XMLName  xml.Name `json:"-" xml:"roles"`
+	UserUUID string   `json:"user_uuid" xml:"user_uuid,attr"`
+	Roles    []Role   `json:"roles" xml:"role"`
+}
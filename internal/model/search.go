@@ -0,0 +1,21 @@
+package model
+
+type SearchQuery struct {
+	Query  string
+	Limit  int
+	Offset int
+}
+
+type SearchResult struct {
+	User       User              `json:"user" xml:"user"`
+	Score      float64           `json:"score" xml:"score"`
+	Highlights map[string]string `json:"highlights,omitempty" xml:"-"`
+}
+
+type SearchPage struct {
+	Query   string         `json:"query" xml:"query"`
+	Total   int            `json:"total" xml:"total"`
+	Limit   int            `json:"limit" xml:"limit"`
+	Offset  int            `json:"offset" xml:"offset"`
+	Results []SearchResult `json:"results" xml:"result"`
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"encoding/xml"
+	"slices"
+	"time"
+)
+
+type UserStatus string
+
+const (
+	UserStatusPending     UserStatus = "pending"
+	UserStatusActive      UserStatus = "active"
+	UserStatusSuspended   UserStatus = "suspended"
+	UserStatusDeactivated UserStatus = "deactivated"
+)
+
+// userStatusTransitions is the account lifecycle: pending -> active,
+// active <-> suspended and active -> deactivated, which is final.
+var userStatusTransitions = map[UserStatus][]UserStatus{
+	UserStatusPending:   {UserStatusActive},
+	UserStatusActive:    {UserStatusSuspended, UserStatusDeactivated},
+	UserStatusSuspended: {UserStatusActive},
+}
+
+func (status UserStatus) IsValid() bool {
+	switch status {
+	case UserStatusPending, UserStatusActive, UserStatusSuspended, UserStatusDeactivated:
+		return true
+	}
+	return false
+}
+
+func (status UserStatus) CanTransitionTo(target UserStatus) bool {
+	return slices.Contains(userStatusTransitions[status], target)
+}
+
+type StatusTransitionRequest struct {
+	Reason string `json:"reason" xml:"reason"`
+}
+
+// StatusTransition records who moved a user between statuses and why.
+type StatusTransition struct {
+	ID        int64      `json:"id" xml:"id"`
+	UserUUID  string     `json:"user_uuid" xml:"user_uuid"`
+	From      UserStatus `json:"from" xml:"from"`
+	To        UserStatus `json:"to" xml:"to"`
+	Reason    string     `json:"reason" xml:"reason"`
+	Actor     string     `json:"actor" xml:"actor"`
+	CreatedAt time.Time  `json:"created_at" xml:"created_at"`
+}
+
+// StatusTransitionList is the XML representation of a transition history.
+type StatusTransitionList struct {
+	XMLName     xml.Name           `json:"-" xml:"transitions"`
+	Transitions []StatusTransition `json:"transitions" xml:"transition"`
+}
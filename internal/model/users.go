@@ -1,21 +1,88 @@
 package model
 
+import (
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
 type User struct {
-	ID       int    `json:"id"`
-	UUID     string `json:"uuid"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	FullName string `json:"full_name"`
+	ID       int        `json:"id" xml:"id"`
+	UUID     string     `json:"uuid" xml:"uuid"`
+	Username string     `json:"username" xml:"username"`
+	Email    string     `json:"email" xml:"email"`
+	FullName string     `json:"full_name" xml:"full_name"`
+	Status   UserStatus `json:"status" xml:"status"`
+	TenantID string     `json:"tenant_id" xml:"tenant_id"`
+
+	CreatedAt time.Time `json:"created_at" xml:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" xml:"updated_at"`
+	// EmailVerifiedAt is nil until the user proves they own their current
+	// email. Changing the email resets it.
+	EmailVerifiedAt *time.Time `json:"email_verified_at" xml:"email_verified_at,omitempty"`
+
+	// PasswordHash is only set on users about to be created. It is never
+	// read back with the user and never serialised.
+	PasswordHash string `json:"-" xml:"-"`
+}
+
+// UserList is the XML representation of a list of users.
+type UserList struct {
+	XMLName xml.Name `json:"-" xml:"users"`
+	Users   []User   `json:"users" xml:"user"`
+}
+
+// UserFilter narrows list and export queries. Empty fields are ignored;
+// FullName matches case-insensitively anywhere in the name. The timestamp
+// bounds are exclusive.
+type UserFilter struct {
+	Username string
+	Email    string
+	FullName string
+	Status   UserStatus
+
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+
+	Sort UserSort
+}
+
+// UserSort orders list and export results. The zero value sorts by id.
+type UserSort struct {
+	Field      string
+	Descending bool
+}
+
+var userSortFields = []string{"id", "username", "created_at", "updated_at"}
+
+// ParseUserSort parses a sort parameter such as "created_at" or
+// "-updated_at", where the leading "-" sorts in descending order.
+func ParseUserSort(value string) (UserSort, error) {
+	if value == "" {
+		return UserSort{}, nil
+	}
+
+	sort := UserSort{Field: strings.TrimPrefix(value, "-"), Descending: strings.HasPrefix(value, "-")}
+	if !slices.Contains(userSortFields, sort.Field) {
+		return UserSort{}, fmt.Errorf("%w: sort must be one of %s", ErrInvalidFilter, strings.Join(userSortFields, ", "))
+	}
+	return sort, nil
 }
 
 type CreateUserRequest struct {
-	Username string `json:"username" binding:"required"`
-	Email    string `json:"email" binding:"required,email"`
-	FullName string `json:"full_name"`
+	Username string `json:"username" xml:"username" binding:"required"`
+	Email    string `json:"email" xml:"email" binding:"required,email"`
+	FullName string `json:"full_name" xml:"full_name"`
+	// Password is optional; users without one cannot log in.
+	Password string `json:"password,omitempty" xml:"password,omitempty"`
 }
 
 type UpdateUserRequest struct {
-	Username string `json:"username"`
-	Email    string `json:"email" binding:"omitempty,email"`
-	FullName string `json:"full_name"`
+	Username string `json:"username" xml:"username"`
+	Email    string `json:"email" xml:"email" binding:"omitempty,email"`
+	FullName string `json:"full_name" xml:"full_name"`
 }
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+type EmailVerificationToken struct {
+	ID        int64
+	UserUUID  string
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// Usable reports whether the token can still verify an email at now.
+func (token *EmailVerificationToken) Usable(now time.Time) bool {
+	return token.UsedAt == nil && now.Before(token.ExpiresAt)
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" xml:"token" binding:"required"`
+}
@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+const WebhookEventWildcard = "*"
+
+type Webhook struct {
+	ID                  int64     `json:"id"`
+	TenantID            string    `json:"tenant_id"`
+	URL                 string    `json:"url"`
+	Events              []string  `json:"events"`
+	Secret              string    `json:"secret,omitempty"`
+	Active              bool      `json:"active"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+func (webhook *Webhook) Subscribes(eventType EventType) bool {
+	for _, event := range webhook.Events {
+		if event == WebhookEventWildcard || event == string(eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required"`
+	Events []string `json:"events" binding:"required"`
+	Secret string   `json:"secret"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryStatusPending   DeliveryStatus = "pending"
+	DeliveryStatusSucceeded DeliveryStatus = "succeeded"
+	DeliveryStatusFailed    DeliveryStatus = "failed"
+)
+
+type WebhookDelivery struct {
+	ID              int64          `json:"id"`
+	WebhookID       int64          `json:"webhook_id"`
+	TenantID        string         `json:"-"`
+	OutboxID        int64          `json:"-"`
+	EventType       EventType      `json:"event_type"`
+	Payload         []byte         `json:"-"`
+	Status          DeliveryStatus `json:"status"`
+	Attempts        int            `json:"attempts"`
+	ResponseStatus  int            `json:"response_status,omitempty"`
+	RequestSnippet  string         `json:"request_snippet,omitempty"`
+	ResponseSnippet string         `json:"response_snippet,omitempty"`
+	LastError       string         `json:"last_error,omitempty"`
+	NextAttemptAt   time.Time      `json:"next_attempt_at"`
+	CreatedAt       time.Time      `json:"created_at"`
+}
@@ -0,0 +1,93 @@
+package notify
+
+import (
+	"context"
+	"cruder/internal/mail"
+	"cruder/internal/model"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Kind string
+
+const (
+	KindVerifyEmail   Kind = "verify_email"
+	KindWelcome       Kind = "welcome"
+	KindEmailChanged  Kind = "email_changed"
+	KindSuspended     Kind = "suspended"
+	KindDeleted       Kind = "deleted"
+	KindPasswordReset Kind = "password_reset"
+)
+
+var kinds = []Kind{KindVerifyEmail, KindWelcome, KindEmailChanged, KindSuspended, KindDeleted, KindPasswordReset}
+
+const DefaultLocale = "en"
+
+// Data is available to the templates. Fields a kind does not use stay empty.
+type Data struct {
+	User model.User
+	// PreviousEmail is the address the user had before an email change.
+	PreviousEmail string
+	// Reason explains a suspension.
+	Reason string
+	// Link and ExpiresIn describe a verification or password reset link.
+	Link      string
+	ExpiresIn time.Duration
+}
+
+// Notifier renders account notifications and hands them to a sender.
+type Notifier struct {
+	sender mail.Sender
+	locale string
+}
+
+// NewNotifier renders notifications in the given locale, e.g. "de" or
+// "de-DE". An empty locale means DefaultLocale.
+func NewNotifier(sender mail.Sender, locale string) (*Notifier, error) {
+	normalized := normalizeLocale(locale)
+	if _, ok := catalog[normalized]; !ok {
+		return nil, fmt.Errorf("unsupported locale %q, expected one of %s", locale, strings.Join(Locales(), ", "))
+	}
+	return &Notifier{sender: sender, locale: normalized}, nil
+}
+
+// Locales lists the locales templates exist for.
+func Locales() []string {
+	locales := make([]string, 0, len(catalog))
+	for locale := range catalog {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+func normalizeLocale(locale string) string {
+	if locale == "" {
+		return DefaultLocale
+	}
+	language, _, _ := strings.Cut(strings.ToLower(locale), "-")
+	language, _, _ = strings.Cut(language, "_")
+	return language
+}
+
+func (notifier *Notifier) Notify(ctx context.Context, kind Kind, to string, data Data) error {
+	message, err := notifier.render(kind, to, data)
+	if err != nil {
+		return err
+	}
+	return notifier.sender.Send(ctx, message)
+}
+
+func (notifier *Notifier) render(kind Kind, to string, data Data) (mail.Message, error) {
+	set, ok := catalog[notifier.locale][kind]
+	if !ok {
+		return mail.Message{}, fmt.Errorf("unknown notification kind %q", kind)
+	}
+	subject, text, html, err := set.render(data)
+	if err != nil {
+		return mail.Message{}, fmt.Errorf("failed to render %s notification: %w", kind, err)
+	}
+	return mail.Message{To: to, Subject: subject, Text: text, HTML: html}, nil
+}
@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"context"
+	"cruder/internal/mail"
+	"cruder/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldRenderEveryKindInEveryLocale(test *testing.T) {
+	for _, locale := range Locales() {
+		notifier, err := NewNotifier(mail.NewMemorySender(), locale)
+		require.NoError(test, err)
+		for _, kind := range kinds {
+			// when
+			message, err := notifier.render(kind, "jdoe@example.com", Data{User: model.User{Username: "jdoe"}})
+
+			// then
+			assert.NoError(test, err, "%s/%s", locale, kind)
+			assert.NotEmpty(test, message.Subject, "%s/%s", locale, kind)
+			assert.Contains(test, message.Text, "jdoe", "%s/%s", locale, kind)
+			assert.Contains(test, message.HTML, "<title>"+message.Subject+"</title>", "%s/%s", locale, kind)
+		}
+	}
+}
+
+func TestShouldSendLocalizedNotification(test *testing.T) {
+	// given
+	sender := mail.NewMemorySender()
+	notifier, err := NewNotifier(sender, "de-DE")
+	require.NoError(test, err)
+
+	// when
+	err = notifier.Notify(context.Background(), KindVerifyEmail, "jdoe@example.com", Data{
+		User:      model.User{Username: "jdoe"},
+		Link:      "https://example.com/verify?token=ev_abc",
+		ExpiresIn: 48 * time.Hour,
+	})
+
+	// then
+	assert.NoError(test, err)
+	messages := sender.Messages()
+	require.Len(test, messages, 1)
+	assert.Equal(test, "Bestätige deine E-Mail-Adresse", messages[0].Subject)
+	assert.Contains(test, messages[0].Text, "https://example.com/verify?token=ev_abc")
+	assert.Contains(test, messages[0].Text, "48 Stunden")
+	assert.Contains(test, messages[0].HTML, `href="https://example.com/verify?token=ev_abc"`)
+}
+
+func TestShouldEscapeUserInputInHTML(test *testing.T) {
+	// given
+	notifier, _ := NewNotifier(mail.NewMemorySender(), "")
+
+	// when
+	message, err := notifier.render(KindSuspended, "jdoe@example.com", Data{
+		User:   model.User{Username: "jdoe"},
+		Reason: `<script>alert("x")</script>`,
+	})
+
+	// then
+	assert.NoError(test, err)
+	assert.NotContains(test, message.HTML, "<script>")
+	assert.Contains(test, message.HTML, "&lt;script&gt;")
+	assert.Contains(test, message.Text, `Reason: <script>alert("x")</script>`)
+}
+
+func TestShouldRejectUnsupportedLocale(test *testing.T) {
+	// when
+	_, err := NewNotifier(mail.NewMemorySender(), "xx")
+
+	// then
+	assert.ErrorContains(test, err, "unsupported locale")
+}
@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"context"
+	"cruder/internal/mail"
+	"cruder/internal/outbox"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+var ErrQueueFull = errors.New("mail queue is full")
+
+type QueueConfig struct {
+	// Size is how many messages may wait for delivery.
+	Size    int
+	Workers int
+	// MaxAttempts bounds the delivery attempts per message. Permanent
+	// failures are not retried.
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+func DefaultQueueConfig() QueueConfig {
+	return QueueConfig{
+		Size:        1000,
+		Workers:     2,
+		MaxAttempts: 5,
+		BaseBackoff: 2 * time.Second,
+		MaxBackoff:  5 * time.Minute,
+	}
+}
+
+// Queue is a mail.Sender that delivers in the background, so callers do
+// not wait for the mail server. Messages still queued at shutdown are lost.
+type Queue struct {
+	sender mail.Sender
+	config QueueConfig
+	jobs   chan mail.Message
+}
+
+func NewQueue(sender mail.Sender, config QueueConfig) *Queue {
+	return &Queue{sender: sender, config: config, jobs: make(chan mail.Message, config.Size)}
+}
+
+// Send enqueues the message without blocking.
+func (queue *Queue) Send(_ context.Context, message mail.Message) error {
+	select {
+	case queue.jobs <- message:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run delivers queued messages until ctx is cancelled.
+func (queue *Queue) Run(ctx context.Context) {
+	var workers sync.WaitGroup
+	for range max(queue.config.Workers, 1) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case message := <-queue.jobs:
+					queue.deliver(ctx, message)
+				}
+			}
+		}()
+	}
+	workers.Wait()
+}
+
+func (queue *Queue) deliver(ctx context.Context, message mail.Message) {
+	for attempt := 1; ; attempt++ {
+		err := queue.sender.Send(ctx, message)
+		if err == nil {
+			return
+		}
+		if mail.IsPermanent(err) || attempt >= queue.config.MaxAttempts {
+			log.Printf("giving up on mail %q to %s after %d attempts: %v", message.Subject, message.To, attempt, err)
+			return
+		}
+		log.Printf("failed to send mail %q to %s (attempt %d): %v", message.Subject, message.To, attempt, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(outbox.Backoff(queue.config.BaseBackoff, queue.config.MaxBackoff, attempt)):
+		}
+	}
+}
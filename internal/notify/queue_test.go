@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"context"
+	"cruder/internal/mail"
+	"errors"
+	"net/textproto"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakySender fails with the queued errors before it delivers.
+type flakySender struct {
+	mutex     sync.Mutex
+	failures  []error
+	attempts  int
+	delivered []mail.Message
+	done      chan struct{}
+}
+
+func newFlakySender(failures ...error) *flakySender {
+	return &flakySender{failures: failures, done: make(chan struct{}, 10)}
+}
+
+func (flakySender *flakySender) Send(_ context.Context, message mail.Message) error {
+	flakySender.mutex.Lock()
+	defer flakySender.mutex.Unlock()
+	flakySender.attempts++
+	defer func() { flakySender.done <- struct{}{} }()
+	if len(flakySender.failures) > 0 {
+		err := flakySender.failures[0]
+		flakySender.failures = flakySender.failures[1:]
+		return err
+	}
+	flakySender.delivered = append(flakySender.delivered, message)
+	return nil
+}
+
+func (flakySender *flakySender) waitForAttempts(test *testing.T, count int) {
+	for range count {
+		select {
+		case <-flakySender.done:
+		case <-time.After(5 * time.Second):
+			test.Fatal("timed out waiting for delivery attempt")
+		}
+	}
+}
+
+func testQueueConfig() QueueConfig {
+	return QueueConfig{Size: 2, Workers: 1, MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+}
+
+func startQueue(test *testing.T, queue *Queue) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		queue.Run(ctx)
+		close(stopped)
+	}()
+	test.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+}
+
+func TestShouldRetryTransientFailures(test *testing.T) {
+	// given
+	sender := newFlakySender(errors.New("connection refused"), &textproto.Error{Code: 451, Msg: "try again"})
+	queue := NewQueue(sender, testQueueConfig())
+	startQueue(test, queue)
+
+	// when
+	err := queue.Send(context.Background(), mail.Message{To: "jdoe@example.com", Subject: "Hi"})
+	sender.waitForAttempts(test, 3)
+
+	// then
+	assert.NoError(test, err)
+	sender.mutex.Lock()
+	defer sender.mutex.Unlock()
+	assert.Equal(test, 3, sender.attempts)
+	assert.Len(test, sender.delivered, 1)
+}
+
+func TestShouldGiveUpAfterMaxAttempts(test *testing.T) {
+	// given
+	failure := errors.New("connection refused")
+	sender := newFlakySender(failure, failure, failure, failure)
+	queue := NewQueue(sender, testQueueConfig())
+	startQueue(test, queue)
+
+	// when
+	_ = queue.Send(context.Background(), mail.Message{To: "jdoe@example.com", Subject: "First"})
+	_ = queue.Send(context.Background(), mail.Message{To: "jdoe@example.com", Subject: "Second"})
+	sender.waitForAttempts(test, 4)
+
+	// then
+	sender.mutex.Lock()
+	defer sender.mutex.Unlock()
+	assert.Equal(test, 4, sender.attempts)
+	assert.Empty(test, sender.delivered)
+}
+
+func TestShouldNotRetryPermanentFailures(test *testing.T) {
+	// given
+	sender := newFlakySender(&textproto.Error{Code: 550, Msg: "no such user"})
+	queue := NewQueue(sender, testQueueConfig())
+	startQueue(test, queue)
+
+	// when
+	_ = queue.Send(context.Background(), mail.Message{To: "nobody@example.com", Subject: "First"})
+	_ = queue.Send(context.Background(), mail.Message{To: "jdoe@example.com", Subject: "Second"})
+	sender.waitForAttempts(test, 2)
+
+	// then
+	sender.mutex.Lock()
+	defer sender.mutex.Unlock()
+	assert.Equal(test, 2, sender.attempts)
+	assert.Len(test, sender.delivered, 1)
+	assert.Equal(test, "Second", sender.delivered[0].Subject)
+}
+
+func TestShouldRejectMessagesWhenQueueIsFull(test *testing.T) {
+	// given
+	queue := NewQueue(newFlakySender(), testQueueConfig())
+	message := mail.Message{To: "jdoe@example.com", Subject: "Hi"}
+
+	// when
+	firstErr := queue.Send(context.Background(), message)
+	secondErr := queue.Send(context.Background(), message)
+	thirdErr := queue.Send(context.Background(), message)
+
+	// then
+	assert.NoError(test, firstErr)
+	assert.NoError(test, secondErr)
+	assert.ErrorIs(test, thirdErr, ErrQueueFull)
+}
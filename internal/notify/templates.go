@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// Each locale directory holds, per kind, a <kind>.txt defining the
+// "subject" and "text" templates and a <kind>.html defining "content",
+// which layout.html wraps.
+type templateSet struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var functions = map[string]any{
+	"hours":   func(duration time.Duration) int { return int(duration.Round(time.Hour).Hours()) },
+	"minutes": func(duration time.Duration) int { return int(duration.Round(time.Minute).Minutes()) },
+}
+
+var catalog = mustLoadCatalog()
+
+func mustLoadCatalog() map[string]map[Kind]templateSet {
+	locales, err := fs.ReadDir(templateFS, "templates")
+	if err != nil {
+		panic(err)
+	}
+
+	loaded := make(map[string]map[Kind]templateSet, len(locales))
+	for _, locale := range locales {
+		sets := make(map[Kind]templateSet, len(kinds))
+		for _, kind := range kinds {
+			set, err := loadTemplateSet(locale.Name(), kind)
+			if err != nil {
+				panic(fmt.Sprintf("notify: failed to load %s templates for %s: %v", kind, locale.Name(), err))
+			}
+			sets[kind] = set
+		}
+		loaded[locale.Name()] = sets
+	}
+	return loaded
+}
+
+func loadTemplateSet(locale string, kind Kind) (templateSet, error) {
+	dir := path.Join("templates", locale)
+	text, err := texttemplate.New(string(kind)).Funcs(functions).ParseFS(templateFS, path.Join(dir, string(kind)+".txt"))
+	if err != nil {
+		return templateSet{}, err
+	}
+	html, err := htmltemplate.New(string(kind)).Funcs(functions).
+		ParseFS(templateFS, path.Join(dir, "layout.html"), path.Join(dir, string(kind)+".html"))
+	if err != nil {
+		return templateSet{}, err
+	}
+	return templateSet{text: text, html: html}, nil
+}
+
+// view is what the templates see: the caller's data plus the rendered subject.
+type view struct {
+	Data
+	Subject string
+}
+
+func (set templateSet) render(data Data) (subject, text, html string, err error) {
+	var buffer bytes.Buffer
+	if err := set.text.ExecuteTemplate(&buffer, "subject", data); err != nil {
+		return "", "", "", err
+	}
+	subject = strings.TrimSpace(buffer.String())
+
+	buffer.Reset()
+	if err := set.text.ExecuteTemplate(&buffer, "text", data); err != nil {
+		return "", "", "", err
+	}
+	text = buffer.String()
+
+	buffer.Reset()
+	if err := set.html.ExecuteTemplate(&buffer, "layout", view{Data: data, Subject: subject}); err != nil {
+		return "", "", "", err
+	}
+	return subject, text, buffer.String(), nil
+}
@@ -0,0 +1,143 @@
+package outbox
+
+import (
+	"context"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"fmt"
+	"log"
+	"time"
+)
+
+type Config struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		PollInterval: time.Second,
+		BatchSize:    100,
+		MaxAttempts:  10,
+		BaseBackoff:  time.Second,
+		MaxBackoff:   5 * time.Minute,
+	}
+}
+
+// Relay publishes pending outbox messages to every sink in insertion order.
+// A message that fails is retried with exponential backoff and blocks the
+// messages behind it until it is published or moved to the dead state. Each
+// batch is claimed in a transaction, so only one replica relays at a time.
+type Relay struct {
+	transactor repository.Transactor
+	sinks      []Sink
+	config     Config
+	now        func() time.Time
+}
+
+func NewRelay(transactor repository.Transactor, config Config, sinks ...Sink) *Relay {
+	return &Relay{
+		transactor: transactor,
+		sinks:      sinks,
+		config:     config,
+		now:        time.Now,
+	}
+}
+
+func (relay *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(relay.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := relay.ProcessBatch(ctx); err != nil {
+			log.Printf("outbox relay: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessBatch publishes up to BatchSize pending messages and returns how many
+// were delivered.
+func (relay *Relay) ProcessBatch(ctx context.Context) (int, error) {
+	published := 0
+	err := relay.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		messages, err := repos.Outbox.ClaimPending(relay.config.BatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to claim pending messages: %w", err)
+		}
+
+		for _, message := range messages {
+			if message.AvailableAt.After(relay.now()) {
+				break
+			}
+
+			if err := relay.publish(ctx, message); err != nil {
+				blocked, markErr := relay.handleFailure(repos.Outbox, message, err)
+				if markErr != nil {
+					return markErr
+				}
+				if blocked {
+					break
+				}
+				continue
+			}
+
+			if err := repos.Outbox.MarkPublished(message.ID); err != nil {
+				return fmt.Errorf("failed to mark message %d as published: %w", message.ID, err)
+			}
+			published++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return published, nil
+}
+
+func (relay *Relay) publish(ctx context.Context, message model.OutboxMessage) error {
+	for _, sink := range relay.sinks {
+		if err := sink.Publish(ctx, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (relay *Relay) handleFailure(outbox repository.OutboxRepository, message model.OutboxMessage, publishErr error) (bool, error) {
+	attempts := message.Attempts + 1
+	if attempts >= relay.config.MaxAttempts {
+		if err := outbox.MarkDead(message.ID, attempts, publishErr.Error()); err != nil {
+			return false, fmt.Errorf("failed to mark message %d as dead: %w", message.ID, err)
+		}
+		return false, nil
+	}
+
+	availableAt := relay.now().Add(Backoff(relay.config.BaseBackoff, relay.config.MaxBackoff, attempts))
+	if err := outbox.MarkRetry(message.ID, attempts, availableAt, publishErr.Error()); err != nil {
+		return false, fmt.Errorf("failed to schedule retry for message %d: %w", message.ID, err)
+	}
+	return true, nil
+}
+
+// Backoff returns the exponential delay before the given attempt, starting at
+// base and doubling until it reaches max.
+func Backoff(base, max time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	return delay
+}
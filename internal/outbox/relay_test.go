@@ -0,0 +1,179 @@
+package outbox
+
+import (
+	"context"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryOutbox struct {
+	messages []*model.OutboxMessage
+}
+
+func (outbox *memoryOutbox) add(id int64, eventType model.EventType) {
+	outbox.messages = append(outbox.messages, &model.OutboxMessage{
+		ID:        id,
+		EventType: eventType,
+		Status:    model.OutboxStatusPending,
+	})
+}
+
+func (outbox *memoryOutbox) find(id int64) *model.OutboxMessage {
+	for _, message := range outbox.messages {
+		if message.ID == id {
+			return message
+		}
+	}
+	return nil
+}
+
+func (outbox *memoryOutbox) Append(event model.DomainEvent) error {
+	return nil
+}
+
+func (outbox *memoryOutbox) WithinTransaction(fn func(repos *repository.Repository) error) error {
+	return fn(&repository.Repository{Outbox: outbox})
+}
+
+func (outbox *memoryOutbox) ClaimPending(limit int) ([]model.OutboxMessage, error) {
+	var pending []model.OutboxMessage
+	for _, message := range outbox.messages {
+		if message.Status == model.OutboxStatusPending && len(pending) < limit {
+			pending = append(pending, *message)
+		}
+	}
+	return pending, nil
+}
+
+func (outbox *memoryOutbox) MarkPublished(id int64) error {
+	outbox.find(id).Status = model.OutboxStatusPublished
+	return nil
+}
+
+func (outbox *memoryOutbox) MarkRetry(id int64, attempts int, availableAt time.Time, lastError string) error {
+	message := outbox.find(id)
+	message.Attempts = attempts
+	message.AvailableAt = availableAt
+	message.LastError = lastError
+	return nil
+}
+
+func (outbox *memoryOutbox) MarkDead(id int64, attempts int, lastError string) error {
+	message := outbox.find(id)
+	message.Status = model.OutboxStatusDead
+	message.Attempts = attempts
+	message.LastError = lastError
+	return nil
+}
+
+func setupRelay(sinks ...Sink) (*memoryOutbox, *Relay, *time.Time) {
+	store := &memoryOutbox{}
+	now := time.Date(2025, 11, 5, 9, 0, 0, 0, time.UTC)
+	config := Config{PollInterval: time.Second, BatchSize: 10, MaxAttempts: 3, BaseBackoff: time.Second, MaxBackoff: time.Minute}
+	relay := NewRelay(store, config, sinks...)
+	relay.now = func() time.Time { return now }
+	return store, relay, &now
+}
+
+func TestShouldPublishPendingMessagesInOrder(test *testing.T) {
+	// given
+	var published []int64
+	sink := SinkFunc(func(_ context.Context, message model.OutboxMessage) error {
+		published = append(published, message.ID)
+		return nil
+	})
+	store, relay, _ := setupRelay(sink)
+	store.add(1, model.EventUserCreated)
+	store.add(2, model.EventUserUpdated)
+	store.add(3, model.EventUserDeleted)
+
+	// when
+	count, err := relay.ProcessBatch(context.Background())
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, 3, count)
+	assert.Equal(test, []int64{1, 2, 3}, published)
+	assert.Equal(test, model.OutboxStatusPublished, store.find(3).Status)
+}
+
+func TestShouldRetryWithBackoffAndBlockLaterMessages(test *testing.T) {
+	// given
+	var published []int64
+	failing := true
+	sink := SinkFunc(func(_ context.Context, message model.OutboxMessage) error {
+		if failing && message.ID == 1 {
+			return errors.New("sink unavailable")
+		}
+		published = append(published, message.ID)
+		return nil
+	})
+	store, relay, now := setupRelay(sink)
+	store.add(1, model.EventUserCreated)
+	store.add(2, model.EventUserUpdated)
+
+	// when
+	count, err := relay.ProcessBatch(context.Background())
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, 0, count)
+	assert.Empty(test, published)
+	assert.Equal(test, 1, store.find(1).Attempts)
+	assert.Equal(test, now.Add(time.Second), store.find(1).AvailableAt)
+	assert.Equal(test, "sink unavailable", store.find(1).LastError)
+
+	// when
+	failing = false
+	*now = now.Add(time.Second)
+	count, err = relay.ProcessBatch(context.Background())
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, 2, count)
+	assert.Equal(test, []int64{1, 2}, published)
+}
+
+func TestShouldMoveMessageToDeadLetterAfterMaxAttempts(test *testing.T) {
+	// given
+	var published []int64
+	sink := SinkFunc(func(_ context.Context, message model.OutboxMessage) error {
+		if message.ID == 1 {
+			return errors.New("rejected")
+		}
+		published = append(published, message.ID)
+		return nil
+	})
+	store, relay, _ := setupRelay(sink)
+	store.add(1, model.EventUserCreated)
+	store.add(2, model.EventUserUpdated)
+	store.find(1).Attempts = 2
+
+	// when
+	count, err := relay.ProcessBatch(context.Background())
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, 1, count)
+	assert.Equal(test, model.OutboxStatusDead, store.find(1).Status)
+	assert.Equal(test, 3, store.find(1).Attempts)
+	assert.Equal(test, []int64{2}, published)
+}
+
+func TestShouldCapBackoffAtMaximum(test *testing.T) {
+	// given
+	base, max := time.Second, time.Minute
+
+	// when
+	delays := []time.Duration{Backoff(base, max, 1), Backoff(base, max, 2), Backoff(base, max, 3), Backoff(base, max, 20)}
+
+	// then
+	assert.Equal(test, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, time.Minute}, delays)
+}
+
+var _ repository.OutboxRepository = (*memoryOutbox)(nil)
@@ -0,0 +1,30 @@
+package outbox
+
+import (
+	"context"
+	"cruder/internal/model"
+	"log"
+)
+
+type Sink interface {
+	Publish(ctx context.Context, message model.OutboxMessage) error
+}
+
+type SinkFunc func(ctx context.Context, message model.OutboxMessage) error
+
+func (sinkFunc SinkFunc) Publish(ctx context.Context, message model.OutboxMessage) error {
+	return sinkFunc(ctx, message)
+}
+
+type LogSink struct {
+	logger *log.Logger
+}
+
+func NewLogSink(logger *log.Logger) *LogSink {
+	return &LogSink{logger: logger}
+}
+
+func (logSink *LogSink) Publish(_ context.Context, message model.OutboxMessage) error {
+	logSink.logger.Printf("outbox event %d %s for %s: %s", message.ID, message.EventType, message.AggregateID, message.Payload)
+	return nil
+}
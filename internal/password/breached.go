@@ -0,0 +1,116 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+const rangePrefixLength = 5
+
+// BreachedFile looks passwords up in a local copy of the Pwned Passwords
+// SHA-1 list ordered by hash, one "HASH:COUNT" per line. A lookup binary
+// searches for the 5-character hash prefix and scans only that range, the
+// same k-anonymity split the online range API uses, so the file is never
+// loaded into memory.
+type BreachedFile struct {
+	file *os.File
+	size int64
+}
+
+func OpenBreachedFile(path string) (*BreachedFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to stat breached password file: %w", err)
+	}
+	return &BreachedFile{file: file, size: info.Size()}, nil
+}
+
+func (breachedFile *BreachedFile) Close() error {
+	return breachedFile.file.Close()
+}
+
+func (breachedFile *BreachedFile) Breached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix := hash[:rangePrefixLength]
+
+	var searchErr error
+	offset := sort.Search(int(breachedFile.size), func(offset int) bool {
+		line, err := breachedFile.lineFrom(int64(offset))
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return line == "" || lineHash(line) >= prefix
+	})
+	if searchErr != nil {
+		return false, searchErr
+	}
+
+	start, err := breachedFile.lineStart(int64(offset))
+	if err != nil {
+		return false, err
+	}
+	reader := bufio.NewReader(io.NewSectionReader(breachedFile.file, start, breachedFile.size-start))
+	for {
+		line, err := reader.ReadString('\n')
+		candidate := lineHash(line)
+		if candidate == hash {
+			return true, nil
+		}
+		if err == io.EOF || !strings.HasPrefix(candidate, prefix) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// lineFrom returns the first complete line starting at or after offset.
+func (breachedFile *BreachedFile) lineFrom(offset int64) (string, error) {
+	start, err := breachedFile.lineStart(offset)
+	if err != nil || start >= breachedFile.size {
+		return "", err
+	}
+
+	line, err := bufio.NewReader(io.NewSectionReader(breachedFile.file, start, breachedFile.size-start)).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return line, nil
+}
+
+// lineStart returns offset itself when it begins a line, or the position just
+// after the next newline otherwise.
+func (breachedFile *BreachedFile) lineStart(offset int64) (int64, error) {
+	if offset == 0 {
+		return 0, nil
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(breachedFile.file, offset-1, breachedFile.size-offset+1))
+	skipped, err := reader.ReadString('\n')
+	if err == io.EOF {
+		return breachedFile.size, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return offset - 1 + int64(len(skipped)), nil
+}
+
+func lineHash(line string) string {
+	hash, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+	return strings.ToUpper(hash)
+}
@@ -0,0 +1,80 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func writeBreachedFile(test *testing.T, passwords []string, padding int) string {
+	var lines []string
+	for _, password := range passwords {
+		lines = append(lines, sha1Hex(password)+":42")
+	}
+	for i := range padding {
+		lines = append(lines, fmt.Sprintf("%040X:%d", i*7919, i))
+	}
+	sort.Strings(lines)
+
+	path := filepath.Join(test.TempDir(), "pwned.txt")
+	assert.NoError(test, os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o600))
+	return path
+}
+
+func TestShouldFindBreachedPasswordsAnywhereInFile(test *testing.T) {
+	// given
+	breachedPasswords := []string{"password", "123456", "qwerty", "letmein", "correct horse battery staple"}
+	breachedFile, err := OpenBreachedFile(writeBreachedFile(test, breachedPasswords, 500))
+	assert.NoError(test, err)
+	defer breachedFile.Close()
+
+	for _, password := range breachedPasswords {
+		// when
+		breached, err := breachedFile.Breached(password)
+
+		// then
+		assert.NoError(test, err)
+		assert.True(test, breached, password)
+	}
+}
+
+func TestShouldNotFlagUnknownPasswords(test *testing.T) {
+	// given
+	breachedFile, err := OpenBreachedFile(writeBreachedFile(test, []string{"password"}, 500))
+	assert.NoError(test, err)
+	defer breachedFile.Close()
+
+	// when
+	breached, err := breachedFile.Breached("a much less common passphrase")
+
+	// then
+	assert.NoError(test, err)
+	assert.False(test, breached)
+}
+
+func TestShouldHandleSingleLineFile(test *testing.T) {
+	// given
+	breachedFile, err := OpenBreachedFile(writeBreachedFile(test, []string{"password"}, 0))
+	assert.NoError(test, err)
+	defer breachedFile.Close()
+
+	// when
+	found, _ := breachedFile.Breached("password")
+	missing, _ := breachedFile.Breached("hunter2")
+
+	// then
+	assert.True(test, found)
+	assert.False(test, missing)
+}
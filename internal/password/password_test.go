@@ -0,0 +1,84 @@
+package password
+
+import (
+	"cruder/internal/model"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testParams() Params {
+	return Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+}
+
+func TestShouldHashAndVerifyPassword(test *testing.T) {
+	// given
+	hasher := NewHasher(testParams(), DefaultPolicy())
+
+	// when
+	hash, err := hasher.Hash("correct horse battery staple")
+	other, _ := hasher.Hash("correct horse battery staple")
+	match, rehash, verifyErr := hasher.Verify("correct horse battery staple", hash)
+	wrong, _, _ := hasher.Verify("correct horse battery stapler", hash)
+
+	// then
+	assert.NoError(test, err)
+	assert.NoError(test, verifyErr)
+	assert.True(test, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+	assert.NotEqual(test, hash, other)
+	assert.True(test, match)
+	assert.False(test, rehash)
+	assert.False(test, wrong)
+}
+
+func TestShouldRequestRehashWhenParamsChange(test *testing.T) {
+	// given
+	oldHash, _ := NewHasher(testParams(), DefaultPolicy()).Hash("correct horse battery staple")
+	stronger := testParams()
+	stronger.Iterations = 2
+
+	// when
+	match, rehash, err := NewHasher(stronger, DefaultPolicy()).Verify("correct horse battery staple", oldHash)
+
+	// then
+	assert.NoError(test, err)
+	assert.True(test, match)
+	assert.True(test, rehash)
+}
+
+func TestShouldRejectMalformedHash(test *testing.T) {
+	// given
+	hasher := NewHasher(testParams(), DefaultPolicy())
+
+	// when
+	_, _, err := hasher.Verify("password", "$2a$10$bcrypt.hash")
+
+	// then
+	assert.ErrorIs(test, err, ErrMalformedHash)
+}
+
+type staticBreachedChecker bool
+
+func (checker staticBreachedChecker) Breached(password string) (bool, error) {
+	return bool(checker), nil
+}
+
+func TestShouldEnforcePasswordPolicy(test *testing.T) {
+	// given
+	policy := Policy{MinLength: 12, MaxLength: 16}
+	breachedPolicy := Policy{MinLength: 1, Breached: staticBreachedChecker(true)}
+
+	// when
+	tooShort := policy.Validate("short")
+	tooLong := policy.Validate(strings.Repeat("a", 17))
+	multibyte := policy.Validate(strings.Repeat("ü", 12))
+	breached := breachedPolicy.Validate("password")
+
+	// then
+	assert.ErrorIs(test, tooShort, model.ErrWeakPassword)
+	assert.ErrorIs(test, tooLong, model.ErrWeakPassword)
+	assert.NoError(test, multibyte)
+	assert.ErrorIs(test, breached, model.ErrWeakPassword)
+	assert.Contains(test, breached.Error(), "data breach")
+}
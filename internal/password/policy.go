@@ -0,0 +1,46 @@
+package password
+
+import (
+	"cruder/internal/model"
+	"fmt"
+	"unicode/utf8"
+)
+
+// BreachedChecker reports whether a password is known from a data breach.
+type BreachedChecker interface {
+	Breached(password string) (bool, error)
+}
+
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// Breached is optional; nil skips the breached-password check.
+	Breached BreachedChecker
+}
+
+func DefaultPolicy() Policy {
+	return Policy{MinLength: 12, MaxLength: 128}
+}
+
+// Validate counts length in characters rather than bytes.
+func (policy Policy) Validate(password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < policy.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", model.ErrWeakPassword, policy.MinLength)
+	}
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		return fmt.Errorf("%w: must be at most %d characters", model.ErrWeakPassword, policy.MaxLength)
+	}
+
+	if policy.Breached == nil {
+		return nil
+	}
+	breached, err := policy.Breached.Breached(password)
+	if err != nil {
+		return fmt.Errorf("failed to check breached passwords: %w", err)
+	}
+	if breached {
+		return fmt.Errorf("%w: it appears in a known data breach", model.ErrWeakPassword)
+	}
+	return nil
+}
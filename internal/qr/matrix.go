@@ -0,0 +1,257 @@
+package qr
+
+func (code *Code) setFunction(x, y int, dark bool) {
+	code.modules[y][x] = dark
+	code.function[y][x] = true
+}
+
+func (code *Code) drawFunctionPatterns() {
+	for i := range code.size {
+		code.setFunction(6, i, i%2 == 0)
+		code.setFunction(i, 6, i%2 == 0)
+	}
+
+	code.drawFinder(3, 3)
+	code.drawFinder(code.size-4, 3)
+	code.drawFinder(3, code.size-4)
+
+	positions := alignmentPositions(code.version, code.size)
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			// The corners taken by finder patterns get none.
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			code.drawAlignment(x, y)
+		}
+	}
+
+	code.drawFormatBits(0)
+	code.drawVersion()
+}
+
+func (code *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			distance := max(abs(dx), abs(dy))
+			if xx, yy := x+dx, y+dy; xx >= 0 && xx < code.size && yy >= 0 && yy < code.size {
+				code.setFunction(xx, yy, distance != 2 && distance != 4)
+			}
+		}
+	}
+}
+
+func (code *Code) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			code.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+func alignmentPositions(version, size int) []int {
+	if version == 1 {
+		return nil
+	}
+	count := version/7 + 2
+	step := (version*8 + count*3 + 5) / (count*4 - 4) * 2
+	positions := make([]int, count)
+	positions[0] = 6
+	for i, position := count-1, size-7; i >= 1; i, position = i-1, position-step {
+		positions[i] = position
+	}
+	return positions
+}
+
+// formatBits returns the 15 format information bits for mask, protected by
+// a BCH code and masked as the standard requires.
+func formatBits(mask int) int {
+	data := formatLevelM<<3 | mask
+	remainder := data
+	for range 10 {
+		remainder = (remainder << 1) ^ ((remainder >> 9) * 0x537)
+	}
+	return (data<<10 | remainder) ^ 0x5412
+}
+
+func (code *Code) drawFormatBits(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	for i := range 6 {
+		code.setFunction(8, i, bit(i))
+	}
+	code.setFunction(8, 7, bit(6))
+	code.setFunction(8, 8, bit(7))
+	code.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		code.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := range 8 {
+		code.setFunction(code.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		code.setFunction(8, code.size-15+i, bit(i))
+	}
+	code.setFunction(8, code.size-8, true)
+}
+
+func (code *Code) drawVersion() {
+	if code.version < 7 {
+		return
+	}
+	remainder := code.version
+	for range 12 {
+		remainder = (remainder << 1) ^ ((remainder >> 11) * 0x1F25)
+	}
+	bits := code.version<<12 | remainder
+	for i := range 18 {
+		dark := (bits>>i)&1 == 1
+		a, b := code.size-11+i%3, i/3
+		code.setFunction(a, b, dark)
+		code.setFunction(b, a, dark)
+	}
+}
+
+// drawCodewords places the bits in the zigzag order of the standard,
+// upwards and downwards in two-module columns from the right.
+func (code *Code) drawCodewords(codewords []byte) {
+	i := 0
+	for right := code.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vertical := range code.size {
+			for j := range 2 {
+				x := right - j
+				y := vertical
+				if (right+1)&2 == 0 {
+					y = code.size - 1 - vertical
+				}
+				if !code.function[y][x] && i < len(codewords)*8 {
+					code.modules[y][x] = (codewords[i>>3]>>(7-i&7))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+func masked(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// applyMask toggles the data modules selected by mask; applying it twice
+// undoes it.
+func (code *Code) applyMask(mask int) {
+	for y := range code.size {
+		for x := range code.size {
+			if !code.function[y][x] && masked(mask, x, y) {
+				code.modules[y][x] = !code.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the symbol is to read, per the four rules of the
+// standard; lower is better.
+func (code *Code) penalty() int {
+	penalty := 0
+	dark := 0
+	for y := range code.size {
+		for x := range code.size {
+			if code.modules[y][x] {
+				dark++
+			}
+			if x+1 < code.size && y+1 < code.size {
+				color := code.modules[y][x]
+				if color == code.modules[y][x+1] && color == code.modules[y+1][x] && color == code.modules[y+1][x+1] {
+					penalty += 3
+				}
+			}
+		}
+	}
+
+	for i := range code.size {
+		row := make([]bool, code.size)
+		column := make([]bool, code.size)
+		for j := range code.size {
+			row[j] = code.modules[i][j]
+			column[j] = code.modules[j][i]
+		}
+		penalty += linePenalty(row) + linePenalty(column)
+	}
+
+	total := code.size * code.size
+	deviation := abs(dark*20-total*10) / total
+	return penalty + deviation*10
+}
+
+// linePenalty scores runs of five or more same-colored modules and
+// finder-like 1:1:3:1:1 patterns next to four light modules.
+func linePenalty(line []bool) int {
+	penalty := 0
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			penalty += run - 2
+		}
+		run = 1
+	}
+
+	finder := []bool{true, false, true, true, true, false, true}
+	for i := 0; i+len(finder) <= len(line); i++ {
+		matches := true
+		for j, dark := range finder {
+			if line[i+j] != dark {
+				matches = false
+				break
+			}
+		}
+		if matches && (lightRun(line, i-4, i) || lightRun(line, i+len(finder), i+len(finder)+4)) {
+			penalty += 40
+		}
+	}
+	return penalty
+}
+
+// lightRun reports whether line[from:to] is light, counting modules outside
+// the symbol as light.
+func lightRun(line []bool, from, to int) bool {
+	for i := from; i < to; i++ {
+		if i >= 0 && i < len(line) && line[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,36 @@
+package qr
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// quietZone is the light border, in modules, that scanners need.
+const quietZone = 4
+
+// PNG renders the code with moduleSize pixels per module.
+func (code *Code) PNG(moduleSize int) ([]byte, error) {
+	side := (code.size + 2*quietZone) * moduleSize
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for y := range code.size {
+		for x := range code.size {
+			if !code.modules[y][x] {
+				continue
+			}
+			left, top := (x+quietZone)*moduleSize, (y+quietZone)*moduleSize
+			for dy := range moduleSize {
+				for dx := range moduleSize {
+					img.SetColorIndex(left+dx, top+dy, 1)
+				}
+			}
+		}
+	}
+
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, img); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
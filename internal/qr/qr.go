@@ -0,0 +1,215 @@
+// Package qr encodes QR codes (ISO/IEC 18004) in byte mode with error
+// correction level M, enough for otpauth URIs.
+package qr
+
+import (
+	"errors"
+)
+
+var ErrTooLong = errors.New("data too long for a QR code")
+
+// Error correction level M, per version 1 to 40.
+var (
+	eccCodewordsPerBlock = [41]int{-1,
+		10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26,
+		26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28}
+	eccBlocks = [41]int{-1,
+		1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16,
+		17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49}
+)
+
+// formatLevelM is the two error correction level bits in the format
+// information.
+const formatLevelM = 0
+
+// Code is a square grid of modules, true meaning dark.
+type Code struct {
+	version  int
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// Encode picks the smallest version that fits data.
+func Encode(data []byte) (*Code, error) {
+	version := 0
+	for candidate := 1; candidate <= 40; candidate++ {
+		if dataBits(len(data), candidate) <= dataCodewords(candidate)*8 {
+			version = candidate
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	code := &Code{version: version, size: version*4 + 17}
+	code.modules = newGrid(code.size)
+	code.function = newGrid(code.size)
+	code.drawFunctionPatterns()
+	code.drawCodewords(addECCAndInterleave(version, dataCodewordsFor(data, version)))
+
+	best, bestPenalty := 0, -1
+	for mask := range 8 {
+		code.applyMask(mask)
+		code.drawFormatBits(mask)
+		if penalty := code.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		code.applyMask(mask)
+	}
+	code.applyMask(best)
+	code.drawFormatBits(best)
+	return code, nil
+}
+
+func (code *Code) Size() int {
+	return code.size
+}
+
+// Dark reports whether the module at column x and row y is dark.
+func (code *Code) Dark(x, y int) bool {
+	return code.modules[y][x]
+}
+
+func newGrid(size int) [][]bool {
+	grid := make([][]bool, size)
+	for y := range grid {
+		grid[y] = make([]bool, size)
+	}
+	return grid
+}
+
+func countBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+func dataBits(length, version int) int {
+	return 4 + countBits(version) + length*8
+}
+
+func rawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		alignments := version/7 + 2
+		result -= (25*alignments-10)*alignments - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func dataCodewords(version int) int {
+	return rawDataModules(version)/8 - eccCodewordsPerBlock[version]*eccBlocks[version]
+}
+
+// dataCodewordsFor builds the byte mode segment with terminator and padding.
+func dataCodewordsFor(data []byte, version int) []byte {
+	var bits bitBuffer
+	bits.append(0b0100, 4)
+	bits.append(len(data), countBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+
+	capacity := dataCodewords(version) * 8
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i>>3] |= 1 << (7 - i&7)
+		}
+	}
+	return codewords
+}
+
+type bitBuffer []bool
+
+func (bits *bitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*bits = append(*bits, (value>>i)&1 == 1)
+	}
+}
+
+// addECCAndInterleave splits data into blocks, appends Reed-Solomon error
+// correction to each and interleaves them.
+func addECCAndInterleave(version int, data []byte) []byte {
+	blocks := eccBlocks[version]
+	eccLength := eccCodewordsPerBlock[version]
+	raw := rawDataModules(version) / 8
+	shortBlocks := blocks - raw%blocks
+	shortLength := raw / blocks
+
+	divisor := reedSolomonDivisor(eccLength)
+	padded := make([][]byte, blocks)
+	for i, offset := 0, 0; i < blocks; i++ {
+		length := shortLength - eccLength
+		if i >= shortBlocks {
+			length++
+		}
+		block := make([]byte, shortLength+1)
+		copy(block, data[offset:offset+length])
+		copy(block[len(block)-eccLength:], reedSolomonRemainder(data[offset:offset+length], divisor))
+		padded[i] = block
+		offset += length
+	}
+
+	result := make([]byte, 0, raw)
+	for i := 0; i <= shortLength; i++ {
+		for j, block := range padded {
+			// Short blocks have one data codeword less; skip its placeholder.
+			if i != shortLength-eccLength || j >= shortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for range degree {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+func reedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= gfMultiply(divisor[i], factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
@@ -0,0 +1,185 @@
+package qr
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decode reads data back from code the way a scanner would, checking the
+// format information and the error correction on the way.
+func decode(test *testing.T, code *Code) []byte {
+	format := 0
+	for i := range 6 {
+		format |= boolBit(code.modules[i][8]) << i
+	}
+	format |= boolBit(code.modules[7][8])<<6 | boolBit(code.modules[8][8])<<7 | boolBit(code.modules[8][7])<<8
+	for i := 9; i < 15; i++ {
+		format |= boolBit(code.modules[8][14-i]) << i
+	}
+	mask := -1
+	for candidate := range 8 {
+		if formatBits(candidate) == format {
+			mask = candidate
+		}
+	}
+	require.GreaterOrEqual(test, mask, 0, "format information %015b is not valid for level M", format)
+
+	var bits []bool
+	upward := true
+	for right := code.size - 1; right >= 1; right, upward = right-2, !upward {
+		if right == 6 {
+			right--
+		}
+		for vertical := range code.size {
+			y := vertical
+			if upward {
+				y = code.size - 1 - vertical
+			}
+			for _, x := range []int{right, right - 1} {
+				if !code.function[y][x] {
+					bits = append(bits, code.modules[y][x] != masked(mask, x, y))
+				}
+			}
+		}
+	}
+	codewords := make([]byte, len(bits)/8)
+	for i := range codewords {
+		for j := range 8 {
+			codewords[i] = codewords[i]<<1 | byte(boolBit(bits[i*8+j]))
+		}
+	}
+
+	blocks := eccBlocks[code.version]
+	eccLength := eccCodewordsPerBlock[code.version]
+	shortBlocks := blocks - len(codewords)%blocks
+	shortLength := len(codewords) / blocks
+	deinterleaved := make([][]byte, blocks)
+	position := 0
+	for i := 0; i <= shortLength; i++ {
+		for j := range blocks {
+			if i == shortLength-eccLength && j < shortBlocks {
+				continue
+			}
+			deinterleaved[j] = append(deinterleaved[j], codewords[position])
+			position++
+		}
+	}
+	var data []byte
+	for _, block := range deinterleaved {
+		for root, power := byte(1), 0; power < eccLength; root, power = gfMultiply(root, 2), power+1 {
+			syndrome := byte(0)
+			for _, codeword := range block {
+				syndrome = gfMultiply(syndrome, root) ^ codeword
+			}
+			require.Zero(test, syndrome, "block fails error correction check")
+		}
+		data = append(data, block[:len(block)-eccLength]...)
+	}
+
+	require.Equal(test, byte(0b0100), data[0]>>4, "expected byte mode")
+	reader := bitReader{data: data, position: 4}
+	length := reader.read(countBits(code.version))
+	decoded := make([]byte, length)
+	for i := range decoded {
+		decoded[i] = byte(reader.read(8))
+	}
+	return decoded
+}
+
+type bitReader struct {
+	data     []byte
+	position int
+}
+
+func (reader *bitReader) read(length int) int {
+	value := 0
+	for range length {
+		bit := (reader.data[reader.position/8] >> (7 - reader.position%8)) & 1
+		value = value<<1 | int(bit)
+		reader.position++
+	}
+	return value
+}
+
+func boolBit(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+func TestShouldRoundTripAcrossVersions(test *testing.T) {
+	for _, length := range []int{0, 1, 14, 15, 62, 106, 122, 180, 213, 214, 600, 2331} {
+		// given
+		data := []byte(strings.Repeat("otpauth://totp/x", length/16+1)[:length])
+
+		// when
+		code, err := Encode(data)
+
+		// then
+		require.NoError(test, err)
+		assert.Equal(test, data, decode(test, code), "length %d, version %d", length, code.version)
+	}
+}
+
+func TestShouldChooseSmallestVersion(test *testing.T) {
+	// Byte capacities at level M from the standard.
+	capacities := map[int]int{1: 14, 2: 26, 3: 42, 4: 62, 5: 84, 6: 106, 7: 122, 8: 152, 9: 180, 10: 213, 40: 2331}
+	for version, capacity := range capacities {
+		// when
+		fits, fitsErr := Encode(make([]byte, capacity))
+		overflows, overflowErr := Encode(make([]byte, capacity+1))
+
+		// then
+		require.NoError(test, fitsErr)
+		assert.Equal(test, version, fits.version)
+		assert.Equal(test, version*4+17, fits.Size())
+		if version < 40 {
+			require.NoError(test, overflowErr)
+			assert.Equal(test, version+1, overflows.version)
+		} else {
+			assert.ErrorIs(test, overflowErr, ErrTooLong)
+		}
+	}
+}
+
+func TestShouldMatchStandardTables(test *testing.T) {
+	assert.Equal(test, 0b101010000010010, formatBits(0))
+	assert.Equal(test, 0b101000100100101, formatBits(1))
+	assert.Equal(test, []int{6, 22, 38}, alignmentPositions(7, 45))
+	assert.Equal(test, []int{6, 34, 60, 86, 112, 138}, alignmentPositions(32, 145))
+
+	code, err := Encode(make([]byte, 110))
+	require.NoError(test, err)
+	require.Equal(test, 7, code.version)
+	version := 0
+	for i := range 18 {
+		version |= boolBit(code.modules[i/3][code.size-11+i%3]) << i
+	}
+	assert.Equal(test, 0x07C94, version)
+}
+
+func TestShouldRenderPNGWithQuietZone(test *testing.T) {
+	// given
+	code, err := Encode([]byte("otpauth://totp/cruder:jdoe?secret=JBSWY3DPEHPK3PXP&issuer=cruder"))
+	require.NoError(test, err)
+
+	// when
+	data, err := code.PNG(4)
+
+	// then
+	require.NoError(test, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(test, err)
+	side := (code.Size() + 2*quietZone) * 4
+	assert.Equal(test, side, img.Bounds().Dx())
+	red, _, _, _ := img.At(0, 0).RGBA()
+	assert.NotZero(test, red, "quiet zone is light")
+	red, _, _, _ = img.At(quietZone*4, quietZone*4).RGBA()
+	assert.Zero(test, red, "finder pattern corner is dark")
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/model"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+type APIKeyRepository interface {
+	Create(key *model.APIKey, hash []byte) error
+	// GetByHash returns active keys only.
+	GetByHash(hash []byte) (*model.APIKey, error)
+	Touch(id string) error
+}
+
+type apiKeyRepository struct {
+	db executor
+}
+
+func NewAPIKeyRepository(db executor) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+func (apiKeyRepository *apiKeyRepository) Create(key *model.APIKey, hash []byte) error {
+	query := `INSERT INTO api_keys (name, tenant_id, prefix, key_hash, roles) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+	err := apiKeyRepository.db.QueryRowContext(context.Background(), query, key.Name, key.TenantID, key.Prefix, hash,
+		pq.Array(key.Roles)).Scan(&key.ID, &key.CreatedAt)
+	key.CreatedAt = key.CreatedAt.UTC()
+	return err
+}
+
+func (apiKeyRepository *apiKeyRepository) GetByHash(hash []byte) (*model.APIKey, error) {
+	query := `SELECT id, name, tenant_id, prefix, roles, created_at, last_used_at FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL`
+	var key model.APIKey
+	var lastUsedAt sql.NullTime
+	err := apiKeyRepository.db.QueryRowContext(context.Background(), query, hash).Scan(&key.ID, &key.Name,
+		&key.TenantID, &key.Prefix, pq.Array(&key.Roles), &key.CreatedAt, &lastUsedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	key.CreatedAt = key.CreatedAt.UTC()
+	if lastUsedAt.Valid {
+		used := lastUsedAt.Time.UTC()
+		key.LastUsedAt = &used
+	}
+	return &key, nil
+}
+
+func (apiKeyRepository *apiKeyRepository) Touch(id string) error {
+	query := `UPDATE api_keys SET last_used_at = now() WHERE id = $1`
+	_, err := apiKeyRepository.db.ExecContext(context.Background(), query, id)
+	return err
+}
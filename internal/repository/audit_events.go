@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/model"
+	"database/sql"
+)
+
+type AuditRepository interface {
+	Record(event *model.AuditEvent) error
+	GetByUser(uuid string) ([]model.AuditEvent, error)
+}
+
+type auditRepository struct {
+	db executor
+}
+
+func NewAuditRepository(db executor) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (auditRepository *auditRepository) Record(event *model.AuditEvent) error {
+	query := `INSERT INTO audit_events (user_uuid, action, detail, actor, ip_address) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+	userUUID := sql.NullString{String: event.UserUUID, Valid: event.UserUUID != ""}
+	err := auditRepository.db.QueryRowContext(context.Background(), query, userUUID, string(event.Action), event.Detail,
+		event.Actor, event.IPAddress).Scan(&event.ID, &event.CreatedAt)
+	event.CreatedAt = event.CreatedAt.UTC()
+	return err
+}
+
+func (auditRepository *auditRepository) GetByUser(uuid string) ([]model.AuditEvent, error) {
+	query := `SELECT id, user_uuid, action, detail, actor, ip_address, created_at
+		FROM audit_events WHERE user_uuid = $1 ORDER BY id`
+	rows, err := auditRepository.db.QueryContext(context.Background(), query, uuid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []model.AuditEvent{}
+	for rows.Next() {
+		var event model.AuditEvent
+		if err := rows.Scan(&event.ID, &event.UserUUID, &event.Action, &event.Detail, &event.Actor, &event.IPAddress,
+			&event.CreatedAt); err != nil {
+			return nil, err
+		}
+		event.CreatedAt = event.CreatedAt.UTC()
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
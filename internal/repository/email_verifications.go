@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/model"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type EmailVerificationRepository interface {
+	Create(token *model.EmailVerificationToken, hash []byte) error
+	GetByHash(hash []byte) (*model.EmailVerificationToken, error)
+	// MarkUsed reports false if the token was already used, so concurrent
+	// verifications cannot both succeed.
+	MarkUsed(id int64) (bool, error)
+	// InvalidateForUser marks all unused tokens of the user as used.
+	InvalidateForUser(userUUID string) error
+	// CountSince counts the tokens issued to the user since the given time.
+	CountSince(userUUID string, since time.Time) (int, error)
+}
+
+type emailVerificationRepository struct {
+	db executor
+}
+
+func NewEmailVerificationRepository(db executor) EmailVerificationRepository {
+	return &emailVerificationRepository{db: db}
+}
+
+func (emailVerificationRepository *emailVerificationRepository) Create(token *model.EmailVerificationToken, hash []byte) error {
+	query := `INSERT INTO email_verification_tokens (user_uuid, email, token_hash, expires_at) VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+	err := emailVerificationRepository.db.QueryRowContext(context.Background(), query, token.UserUUID, token.Email, hash,
+		token.ExpiresAt).Scan(&token.ID, &token.CreatedAt)
+	token.CreatedAt = token.CreatedAt.UTC()
+	return err
+}
+
+func (emailVerificationRepository *emailVerificationRepository) GetByHash(hash []byte) (*model.EmailVerificationToken, error) {
+	query := `SELECT id, user_uuid, email, created_at, expires_at, used_at FROM email_verification_tokens
+		WHERE token_hash = $1`
+	var token model.EmailVerificationToken
+	var usedAt sql.NullTime
+	err := emailVerificationRepository.db.QueryRowContext(context.Background(), query, hash).Scan(&token.ID,
+		&token.UserUUID, &token.Email, &token.CreatedAt, &token.ExpiresAt, &usedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	token.CreatedAt = token.CreatedAt.UTC()
+	token.ExpiresAt = token.ExpiresAt.UTC()
+	token.UsedAt = utcTime(usedAt)
+	return &token, nil
+}
+
+func (emailVerificationRepository *emailVerificationRepository) MarkUsed(id int64) (bool, error) {
+	query := `UPDATE email_verification_tokens SET used_at = now() WHERE id = $1 AND used_at IS NULL`
+	result, err := emailVerificationRepository.db.ExecContext(context.Background(), query, id)
+	if err != nil {
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	return updated > 0, err
+}
+
+func (emailVerificationRepository *emailVerificationRepository) InvalidateForUser(userUUID string) error {
+	query := `UPDATE email_verification_tokens SET used_at = now() WHERE user_uuid = $1 AND used_at IS NULL`
+	_, err := emailVerificationRepository.db.ExecContext(context.Background(), query, userUUID)
+	return err
+}
+
+func (emailVerificationRepository *emailVerificationRepository) CountSince(userUUID string, since time.Time) (int, error) {
+	query := `SELECT COUNT(*) FROM email_verification_tokens WHERE user_uuid = $1 AND created_at > $2`
+	count := 0
+	err := emailVerificationRepository.db.QueryRowContext(context.Background(), query, userUUID, since).Scan(&count)
+	return count, err
+}
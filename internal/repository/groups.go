@@ -0,0 +1,352 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/model"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// GroupRepository manages groups, their members and their nesting. Groups
+// take their tenant from the transaction, so call it within a tenant-scoped
+// transaction.
+type GroupRepository interface {
+	Create(group *model.Group) error
+	GetByID(id string) (*model.Group, error)
+	List(page model.Page) ([]model.Group, int, error)
+	Update(group *model.Group) error
+	Delete(id string) error
+
+	GetMember(groupID, userUUID string) (*model.GroupMember, error)
+	ListMembers(groupID string, page model.Page) ([]model.GroupMember, int, error)
+	// SetMember adds the user to the group or changes their role.
+	SetMember(groupID, userUUID string, role model.GroupRole) error
+	RemoveMember(groupID, userUUID string) error
+	CountOwners(groupID string) (int, error)
+	// ListUserGroups lists the groups the user belongs to directly or
+	// through subgroups, ordered by name.
+	ListUserGroups(userUUID string, page model.Page) ([]model.UserGroup, int, error)
+	// RemoveUser removes the user from all groups. Where they were the only
+	// owner, the longest-standing remaining member becomes owner.
+	RemoveUser(userUUID string) error
+
+	ListSubgroups(groupID string, page model.Page) ([]model.Group, int, error)
+	// GetAncestorIDs returns the IDs of all groups groupID is nested in,
+	// directly or transitively.
+	GetAncestorIDs(groupID string) ([]string, error)
+	// LockHierarchy serialises changes to the nesting until the transaction
+	// ends, so concurrent changes cannot combine into a cycle.
+	LockHierarchy() error
+	AddSubgroup(parentID, childID string) error
+	RemoveSubgroup(parentID, childID string) (bool, error)
+}
+
+type groupRepository struct {
+	db executor
+}
+
+const (
+	selectGroupColumns = "SELECT id, tenant_id, name, description, created_at, updated_at FROM groups"
+
+	// groupHierarchyLockKey is the advisory lock taken by LockHierarchy.
+	groupHierarchyLockKey = 4242001
+)
+
+func NewGroupRepository(db executor) GroupRepository {
+	return &groupRepository{db: db}
+}
+
+func (groupRepository *groupRepository) scanGroup(row rowScanner, group *model.Group, extra ...any) error {
+	columns := append([]any{&group.ID, &group.TenantID, &group.Name, &group.Description, &group.CreatedAt, &group.UpdatedAt}, extra...)
+	if err := row.Scan(columns...); err != nil {
+		return err
+	}
+	group.CreatedAt = group.CreatedAt.UTC()
+	group.UpdatedAt = group.UpdatedAt.UTC()
+	return nil
+}
+
+func (groupRepository *groupRepository) translateError(err error, name string) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolationCode {
+		return fmt.Errorf("%s: %w", name, model.ErrGroupExists)
+	}
+	return err
+}
+
+func (groupRepository *groupRepository) Create(group *model.Group) error {
+	query := `INSERT INTO groups (name, description) VALUES ($1, $2) RETURNING id, tenant_id, created_at, updated_at`
+	err := groupRepository.db.QueryRowContext(context.Background(), query, group.Name, group.Description).
+		Scan(&group.ID, &group.TenantID, &group.CreatedAt, &group.UpdatedAt)
+	if err != nil {
+		return groupRepository.translateError(err, group.Name)
+	}
+	group.CreatedAt = group.CreatedAt.UTC()
+	group.UpdatedAt = group.UpdatedAt.UTC()
+	return nil
+}
+
+func (groupRepository *groupRepository) GetByID(id string) (*model.Group, error) {
+	row := groupRepository.db.QueryRowContext(context.Background(), selectGroupColumns+" WHERE id = $1", id)
+	var group model.Group
+	err := groupRepository.scanGroup(row, &group)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (groupRepository *groupRepository) List(page model.Page) ([]model.Group, int, error) {
+	query := `SELECT id, tenant_id, name, description, created_at, updated_at, COUNT(*) OVER () AS total
+		FROM groups ORDER BY name, id LIMIT $1 OFFSET $2`
+	groups, total, err := groupRepository.queryGroups(query, page.Limit, page.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err = pageTotal(groupRepository.db, total, len(groups), page.Offset, `SELECT COUNT(*) FROM groups`)
+	return groups, total, err
+}
+
+func (groupRepository *groupRepository) queryGroups(query string, args ...any) ([]model.Group, int, error) {
+	rows, err := groupRepository.db.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	groups := []model.Group{}
+	total := 0
+	for rows.Next() {
+		var group model.Group
+		if err := groupRepository.scanGroup(rows, &group, &total); err != nil {
+			return nil, 0, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, total, rows.Err()
+}
+
+func (groupRepository *groupRepository) Update(group *model.Group) error {
+	query := `UPDATE groups SET name = $2, description = $3, updated_at = now() WHERE id = $1 RETURNING updated_at`
+	err := groupRepository.db.QueryRowContext(context.Background(), query, group.ID, group.Name, group.Description).
+		Scan(&group.UpdatedAt)
+	if err != nil {
+		return groupRepository.translateError(err, group.Name)
+	}
+	group.UpdatedAt = group.UpdatedAt.UTC()
+	return nil
+}
+
+func (groupRepository *groupRepository) Delete(id string) error {
+	_, err := groupRepository.db.ExecContext(context.Background(), `DELETE FROM groups WHERE id = $1`, id)
+	return err
+}
+
+const selectMemberColumns = `SELECT u.id, u.uuid, u.username, u.email, u.full_name, u.status, u.tenant_id, u.created_at, u.updated_at, u.email_verified_at,
+		m.role, m.joined_at
+	FROM group_members m JOIN users u ON u.uuid = m.user_uuid`
+
+func (groupRepository *groupRepository) scanMember(row rowScanner, member *model.GroupMember, extra ...any) error {
+	user := &member.User
+	var emailVerifiedAt sql.NullTime
+	columns := append([]any{&user.ID, &user.UUID, &user.Username, &user.Email, &user.FullName, &user.Status,
+		&user.TenantID, &user.CreatedAt, &user.UpdatedAt, &emailVerifiedAt, &member.Role, &member.JoinedAt}, extra...)
+	if err := row.Scan(columns...); err != nil {
+		return err
+	}
+	user.EmailVerifiedAt = utcTime(emailVerifiedAt)
+	user.CreatedAt = user.CreatedAt.UTC()
+	user.UpdatedAt = user.UpdatedAt.UTC()
+	member.JoinedAt = member.JoinedAt.UTC()
+	return nil
+}
+
+func (groupRepository *groupRepository) GetMember(groupID, userUUID string) (*model.GroupMember, error) {
+	query := selectMemberColumns + ` WHERE m.group_id = $1 AND m.user_uuid = $2`
+	row := groupRepository.db.QueryRowContext(context.Background(), query, groupID, userUUID)
+	var member model.GroupMember
+	err := groupRepository.scanMember(row, &member)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (groupRepository *groupRepository) ListMembers(groupID string, page model.Page) ([]model.GroupMember, int, error) {
+	query := `SELECT u.id, u.uuid, u.username, u.email, u.full_name, u.status, u.tenant_id, u.created_at, u.updated_at, u.email_verified_at,
+			m.role, m.joined_at, COUNT(*) OVER () AS total
+		FROM group_members m JOIN users u ON u.uuid = m.user_uuid
+		WHERE m.group_id = $1
+		ORDER BY m.role = 'member', u.username, u.id
+		LIMIT $2 OFFSET $3`
+	rows, err := groupRepository.db.QueryContext(context.Background(), query, groupID, page.Limit, page.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	members := []model.GroupMember{}
+	total := 0
+	for rows.Next() {
+		var member model.GroupMember
+		if err := groupRepository.scanMember(rows, &member, &total); err != nil {
+			return nil, 0, err
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	countQuery := `SELECT COUNT(*) FROM group_members m JOIN users u ON u.uuid = m.user_uuid WHERE m.group_id = $1`
+	total, err = pageTotal(groupRepository.db, total, len(members), page.Offset, countQuery, groupID)
+	return members, total, err
+}
+
+func (groupRepository *groupRepository) SetMember(groupID, userUUID string, role model.GroupRole) error {
+	query := `INSERT INTO group_members (group_id, user_uuid, role) VALUES ($1, $2, $3)
+		ON CONFLICT (group_id, user_uuid) DO UPDATE SET role = EXCLUDED.role`
+	_, err := groupRepository.db.ExecContext(context.Background(), query, groupID, userUUID, role)
+	return err
+}
+
+func (groupRepository *groupRepository) RemoveMember(groupID, userUUID string) error {
+	query := `DELETE FROM group_members WHERE group_id = $1 AND user_uuid = $2`
+	_, err := groupRepository.db.ExecContext(context.Background(), query, groupID, userUUID)
+	return err
+}
+
+func (groupRepository *groupRepository) CountOwners(groupID string) (int, error) {
+	query := `SELECT COUNT(*) FROM group_members WHERE group_id = $1 AND role = 'owner'`
+	owners := 0
+	err := groupRepository.db.QueryRowContext(context.Background(), query, groupID).Scan(&owners)
+	return owners, err
+}
+
+// userMembershipsQuery selects into memberships the groups user $1 belongs
+// to directly or, as a member, through subgroups.
+const userMembershipsQuery = `WITH RECURSIVE reachable (group_id, role, inherited) AS (
+		SELECT group_id, role::text, false FROM group_members WHERE user_uuid = $1
+		UNION
+		SELECT s.parent_id, 'member'::text, true FROM group_subgroups s JOIN reachable r ON s.child_id = r.group_id
+	), memberships AS (
+		SELECT DISTINCT ON (group_id) group_id, role, inherited FROM reachable ORDER BY group_id, inherited
+	)`
+
+func (groupRepository *groupRepository) ListUserGroups(userUUID string, page model.Page) ([]model.UserGroup, int, error) {
+	query := userMembershipsQuery + `
+		SELECT g.id, g.tenant_id, g.name, g.description, g.created_at, g.updated_at, m.role, m.inherited, COUNT(*) OVER () AS total
+		FROM memberships m JOIN groups g ON g.id = m.group_id
+		ORDER BY g.name, g.id
+		LIMIT $2 OFFSET $3`
+	rows, err := groupRepository.db.QueryContext(context.Background(), query, userUUID, page.Limit, page.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	groups := []model.UserGroup{}
+	total := 0
+	for rows.Next() {
+		var userGroup model.UserGroup
+		if err := groupRepository.scanGroup(rows, &userGroup.Group, &userGroup.Role, &userGroup.Inherited, &total); err != nil {
+			return nil, 0, err
+		}
+		groups = append(groups, userGroup)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	countQuery := userMembershipsQuery + ` SELECT COUNT(*) FROM memberships m JOIN groups g ON g.id = m.group_id`
+	total, err = pageTotal(groupRepository.db, total, len(groups), page.Offset, countQuery, userUUID)
+	return groups, total, err
+}
+
+func (groupRepository *groupRepository) RemoveUser(userUUID string) error {
+	ctx := context.Background()
+	promote := `UPDATE group_members m SET role = 'owner'
+		FROM (
+			SELECT DISTINCT ON (group_id) group_id, user_uuid FROM group_members
+			WHERE user_uuid <> $1 AND group_id IN (
+				SELECT group_id FROM group_members WHERE user_uuid = $1 AND role = 'owner'
+				EXCEPT
+				SELECT group_id FROM group_members WHERE user_uuid <> $1 AND role = 'owner'
+			)
+			ORDER BY group_id, joined_at, user_uuid
+		) successor
+		WHERE m.group_id = successor.group_id AND m.user_uuid = successor.user_uuid`
+	if _, err := groupRepository.db.ExecContext(ctx, promote, userUUID); err != nil {
+		return err
+	}
+	_, err := groupRepository.db.ExecContext(ctx, `DELETE FROM group_members WHERE user_uuid = $1`, userUUID)
+	return err
+}
+
+func (groupRepository *groupRepository) ListSubgroups(groupID string, page model.Page) ([]model.Group, int, error) {
+	query := `SELECT g.id, g.tenant_id, g.name, g.description, g.created_at, g.updated_at, COUNT(*) OVER () AS total
+		FROM group_subgroups s JOIN groups g ON g.id = s.child_id
+		WHERE s.parent_id = $1
+		ORDER BY g.name, g.id LIMIT $2 OFFSET $3`
+	groups, total, err := groupRepository.queryGroups(query, groupID, page.Limit, page.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	countQuery := `SELECT COUNT(*) FROM group_subgroups s JOIN groups g ON g.id = s.child_id WHERE s.parent_id = $1`
+	total, err = pageTotal(groupRepository.db, total, len(groups), page.Offset, countQuery, groupID)
+	return groups, total, err
+}
+
+func (groupRepository *groupRepository) GetAncestorIDs(groupID string) ([]string, error) {
+	query := `WITH RECURSIVE ancestors (id) AS (
+			SELECT parent_id FROM group_subgroups WHERE child_id = $1
+			UNION
+			SELECT s.parent_id FROM group_subgroups s JOIN ancestors a ON s.child_id = a.id
+		)
+		SELECT id FROM ancestors`
+	rows, err := groupRepository.db.QueryContext(context.Background(), query, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (groupRepository *groupRepository) LockHierarchy() error {
+	_, err := groupRepository.db.ExecContext(context.Background(), `SELECT pg_advisory_xact_lock($1)`, groupHierarchyLockKey)
+	return err
+}
+
+func (groupRepository *groupRepository) AddSubgroup(parentID, childID string) error {
+	query := `INSERT INTO group_subgroups (parent_id, child_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	_, err := groupRepository.db.ExecContext(context.Background(), query, parentID, childID)
+	return err
+}
+
+func (groupRepository *groupRepository) RemoveSubgroup(parentID, childID string) (bool, error) {
+	query := `DELETE FROM group_subgroups WHERE parent_id = $1 AND child_id = $2`
+	result, err := groupRepository.db.ExecContext(context.Background(), query, parentID, childID)
+	if err != nil {
+		return false, err
+	}
+	removed, err := result.RowsAffected()
+	return removed > 0, err
+}
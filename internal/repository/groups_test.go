@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"cruder/internal/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldListInheritedGroupMemberships(test *testing.T) {
+	// given
+	repos, tenantA, _ := setupTenantTest(test)
+	scoped := repos.ForTenant(tenantA.ID)
+	user := &model.User{Username: "jdoe", Email: "jdoe@example.com"}
+	require.NoError(test, scoped.Users.Create(user))
+	var groups []model.UserGroup
+	total := 0
+
+	// when
+	err := scoped.WithinTransaction(func(repos *Repository) error {
+		parent := &model.Group{Name: "engineering"}
+		child := &model.Group{Name: "backend"}
+		require.NoError(test, repos.Groups.Create(parent))
+		require.NoError(test, repos.Groups.Create(child))
+		require.NoError(test, repos.Groups.AddSubgroup(parent.ID, child.ID))
+		require.NoError(test, repos.Groups.SetMember(child.ID, user.UUID, model.GroupRoleOwner))
+
+		var err error
+		groups, total, err = repos.Groups.ListUserGroups(user.UUID, model.Page{Limit: 10})
+		return err
+	})
+
+	// then
+	require.NoError(test, err)
+	assert.Equal(test, 2, total)
+	assert.Equal(test, "backend", groups[0].Group.Name)
+	assert.Equal(test, model.GroupRoleOwner, groups[0].Role)
+	assert.False(test, groups[0].Inherited)
+	assert.Equal(test, "engineering", groups[1].Group.Name)
+	assert.Equal(test, model.GroupRoleMember, groups[1].Role)
+	assert.True(test, groups[1].Inherited)
+}
+
+func TestShouldHandOverOwnershipWhenRemovingUser(test *testing.T) {
+	// given
+	repos, tenantA, _ := setupTenantTest(test)
+	scoped := repos.ForTenant(tenantA.ID)
+	owner := &model.User{Username: "owner", Email: "owner@example.com"}
+	member := &model.User{Username: "member", Email: "member@example.com"}
+	require.NoError(test, scoped.Users.Create(owner))
+	require.NoError(test, scoped.Users.Create(member))
+	var promoted *model.GroupMember
+	var removed *model.GroupMember
+
+	// when
+	err := scoped.WithinTransaction(func(repos *Repository) error {
+		group := &model.Group{Name: "ops"}
+		require.NoError(test, repos.Groups.Create(group))
+		require.NoError(test, repos.Groups.SetMember(group.ID, owner.UUID, model.GroupRoleOwner))
+		require.NoError(test, repos.Groups.SetMember(group.ID, member.UUID, model.GroupRoleMember))
+
+		if err := repos.Groups.RemoveUser(owner.UUID); err != nil {
+			return err
+		}
+		promoted, _ = repos.Groups.GetMember(group.ID, member.UUID)
+		removed, _ = repos.Groups.GetMember(group.ID, owner.UUID)
+		return nil
+	})
+
+	// then
+	require.NoError(test, err)
+	assert.Nil(test, removed)
+	assert.Equal(test, model.GroupRoleOwner, promoted.Role)
+}
+
+func TestShouldNeverReadAnotherTenantsGroups(test *testing.T) {
+	// given
+	repos, tenantA, tenantB := setupTenantTest(test)
+	group := &model.Group{Name: "ops"}
+	require.NoError(test, repos.ForTenant(tenantA.ID).WithinTransaction(func(repos *Repository) error {
+		return repos.Groups.Create(group)
+	}))
+	var found *model.Group
+	var listed []model.Group
+
+	// when
+	err := repos.ForTenant(tenantB.ID).WithinTransaction(func(repos *Repository) error {
+		var err error
+		if found, err = repos.Groups.GetByID(group.ID); err != nil {
+			return err
+		}
+		listed, _, err = repos.Groups.List(model.Page{Limit: 10})
+		return err
+	})
+
+	// then
+	require.NoError(test, err)
+	assert.Equal(test, tenantA.ID, group.TenantID)
+	assert.Nil(test, found)
+	assert.Empty(test, listed)
+}
+
+func TestShouldCountGroupsForPagesPastTheEnd(test *testing.T) {
+	// given
+	repos, tenantA, _ := setupTenantTest(test)
+	scoped := repos.ForTenant(tenantA.ID)
+	user := &model.User{Username: "jdoe", Email: "jdoe@example.com"}
+	require.NoError(test, scoped.Users.Create(user))
+	beyond := model.Page{Limit: 10, Offset: 10}
+	totals := map[string]int{}
+
+	// when
+	err := scoped.WithinTransaction(func(repos *Repository) error {
+		parent := &model.Group{Name: "engineering"}
+		child := &model.Group{Name: "backend"}
+		require.NoError(test, repos.Groups.Create(parent))
+		require.NoError(test, repos.Groups.Create(child))
+		require.NoError(test, repos.Groups.AddSubgroup(parent.ID, child.ID))
+		require.NoError(test, repos.Groups.SetMember(child.ID, user.UUID, model.GroupRoleOwner))
+
+		groups, total, err := repos.Groups.List(beyond)
+		require.NoError(test, err)
+		assert.Empty(test, groups)
+		totals["groups"] = total
+		members, total, err := repos.Groups.ListMembers(child.ID, beyond)
+		require.NoError(test, err)
+		assert.Empty(test, members)
+		totals["members"] = total
+		userGroups, total, err := repos.Groups.ListUserGroups(user.UUID, beyond)
+		require.NoError(test, err)
+		assert.Empty(test, userGroups)
+		totals["user groups"] = total
+		subgroups, total, err := repos.Groups.ListSubgroups(parent.ID, beyond)
+		require.NoError(test, err)
+		assert.Empty(test, subgroups)
+		totals["subgroups"] = total
+		return nil
+	})
+
+	// then
+	require.NoError(test, err)
+	assert.Equal(test, map[string]int{"groups": 2, "members": 1, "user groups": 2, "subgroups": 1}, totals)
+}
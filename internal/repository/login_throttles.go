@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/model"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type LoginThrottleRepository interface {
+	Get(key string) (*model.LoginThrottle, error)
+	// Acquire returns the throttle of key, creating it without failures if
+	// needed, and locks it until the transaction ends so concurrent logins
+	// are checked and counted one after another.
+	Acquire(key string, now time.Time) (*model.LoginThrottle, error)
+	// RecordFailure counts a failed login at now and returns the updated
+	// throttle. Failures before windowStart are forgotten.
+	RecordFailure(key string, now, windowStart time.Time) (*model.LoginThrottle, error)
+	// ReleaseFailure takes back a failure counted by RecordFailure for an
+	// attempt that did not fail.
+	ReleaseFailure(key string) error
+	// Lock blocks logins for key until the given time and starts counting
+	// failures afresh.
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+	// Prune removes throttles without failures or lockouts after before.
+	Prune(before time.Time) error
+}
+
+type loginThrottleRepository struct {
+	db executor
+}
+
+func NewLoginThrottleRepository(db executor) LoginThrottleRepository {
+	return &loginThrottleRepository{db: db}
+}
+
+func (loginThrottleRepository *loginThrottleRepository) Get(key string) (*model.LoginThrottle, error) {
+	query := `SELECT key, failures, last_failed_at, locked_until FROM login_throttles WHERE key = $1`
+	throttle, err := scanLoginThrottle(loginThrottleRepository.db.QueryRowContext(context.Background(), query, key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return throttle, err
+}
+
+func (loginThrottleRepository *loginThrottleRepository) Acquire(key string, now time.Time) (*model.LoginThrottle, error) {
+	insert := `INSERT INTO login_throttles (key, failures, last_failed_at) VALUES ($1, 0, $2) ON CONFLICT (key) DO NOTHING`
+	if _, err := loginThrottleRepository.db.ExecContext(context.Background(), insert, key, now); err != nil {
+		return nil, err
+	}
+	query := `SELECT key, failures, last_failed_at, locked_until FROM login_throttles WHERE key = $1 FOR UPDATE`
+	return scanLoginThrottle(loginThrottleRepository.db.QueryRowContext(context.Background(), query, key))
+}
+
+// RecordFailure increments in a single statement, so concurrent failures
+// on different replicas are all counted.
+func (loginThrottleRepository *loginThrottleRepository) RecordFailure(key string, now, windowStart time.Time) (*model.LoginThrottle, error) {
+	query := `INSERT INTO login_throttles (key, failures, last_failed_at) VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_throttles.last_failed_at < $3 THEN 1 ELSE login_throttles.failures + 1 END,
+			last_failed_at = EXCLUDED.last_failed_at
+		RETURNING key, failures, last_failed_at, locked_until`
+	return scanLoginThrottle(loginThrottleRepository.db.QueryRowContext(context.Background(), query, key, now, windowStart))
+}
+
+func (loginThrottleRepository *loginThrottleRepository) ReleaseFailure(key string) error {
+	query := `UPDATE login_throttles SET failures = GREATEST(failures - 1, 0) WHERE key = $1`
+	_, err := loginThrottleRepository.db.ExecContext(context.Background(), query, key)
+	return err
+}
+
+func (loginThrottleRepository *loginThrottleRepository) Lock(key string, until time.Time) error {
+	query := `UPDATE login_throttles SET failures = 0, locked_until = $2 WHERE key = $1`
+	_, err := loginThrottleRepository.db.ExecContext(context.Background(), query, key, until)
+	return err
+}
+
+func (loginThrottleRepository *loginThrottleRepository) Reset(key string) error {
+	_, err := loginThrottleRepository.db.ExecContext(context.Background(), `DELETE FROM login_throttles WHERE key = $1`, key)
+	return err
+}
+
+func (loginThrottleRepository *loginThrottleRepository) Prune(before time.Time) error {
+	query := `DELETE FROM login_throttles WHERE last_failed_at < $1 AND (locked_until IS NULL OR locked_until < $1)`
+	_, err := loginThrottleRepository.db.ExecContext(context.Background(), query, before)
+	return err
+}
+
+func scanLoginThrottle(row *sql.Row) (*model.LoginThrottle, error) {
+	var throttle model.LoginThrottle
+	var lockedUntil sql.NullTime
+	if err := row.Scan(&throttle.Key, &throttle.Failures, &throttle.LastFailedAt, &lockedUntil); err != nil {
+		return nil, err
+	}
+	throttle.LastFailedAt = throttle.LastFailedAt.UTC()
+	throttle.LockedUntil = utcTime(lockedUntil)
+	return &throttle, nil
+}
@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/model"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type MFARepository interface {
+	Get(userUUID string) (*model.UserMFA, error)
+	// SavePending stores a new, not yet enabled secret, replacing any
+	// earlier one.
+	SavePending(mfa *model.UserMFA) error
+	Enable(userUUID string) error
+	Delete(userUUID string) error
+	// UseCounter records the time step of an accepted code. It reports false
+	// if that step or a later one was already used.
+	UseCounter(userUUID string, counter int64) (bool, error)
+
+	ReplaceRecoveryCodes(userUUID string, hashes [][]byte) error
+	// UseRecoveryCode reports false if the code is unknown or used.
+	UseRecoveryCode(userUUID string, hash []byte) (bool, error)
+	CountRecoveryCodes(userUUID string) (int, error)
+
+	CreateChallenge(challenge *model.MFAChallenge, hash []byte) error
+	GetChallenge(hash []byte) (*model.MFAChallenge, error)
+	RecordChallengeFailure(id int64) error
+	// ConsumeChallenge reports false if the challenge was already used.
+	ConsumeChallenge(id int64) (bool, error)
+	// DeleteExpiredChallenges removes challenges that expired before the
+	// given time.
+	DeleteExpiredChallenges(before time.Time) error
+}
+
+type mfaRepository struct {
+	db executor
+}
+
+func NewMFARepository(db executor) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+func (mfaRepository *mfaRepository) Get(userUUID string) (*model.UserMFA, error) {
+	query := `SELECT user_uuid, secret, created_at, enabled_at, last_counter FROM user_mfa WHERE user_uuid = $1`
+	var mfa model.UserMFA
+	var enabledAt sql.NullTime
+	var lastCounter sql.NullInt64
+	err := mfaRepository.db.QueryRowContext(context.Background(), query, userUUID).Scan(&mfa.UserUUID, &mfa.Secret,
+		&mfa.CreatedAt, &enabledAt, &lastCounter)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	mfa.CreatedAt = mfa.CreatedAt.UTC()
+	mfa.EnabledAt = utcTime(enabledAt)
+	if lastCounter.Valid {
+		mfa.LastCounter = &lastCounter.Int64
+	}
+	return &mfa, nil
+}
+
+func (mfaRepository *mfaRepository) SavePending(mfa *model.UserMFA) error {
+	query := `INSERT INTO user_mfa (user_uuid, secret) VALUES ($1, $2)
+		ON CONFLICT (user_uuid) DO UPDATE SET secret = EXCLUDED.secret, created_at = now(), enabled_at = NULL,
+			last_counter = NULL
+		RETURNING created_at`
+	err := mfaRepository.db.QueryRowContext(context.Background(), query, mfa.UserUUID, mfa.Secret).Scan(&mfa.CreatedAt)
+	mfa.CreatedAt = mfa.CreatedAt.UTC()
+	mfa.EnabledAt = nil
+	mfa.LastCounter = nil
+	return err
+}
+
+func (mfaRepository *mfaRepository) Enable(userUUID string) error {
+	query := `UPDATE user_mfa SET enabled_at = now() WHERE user_uuid = $1 AND enabled_at IS NULL`
+	_, err := mfaRepository.db.ExecContext(context.Background(), query, userUUID)
+	return err
+}
+
+func (mfaRepository *mfaRepository) Delete(userUUID string) error {
+	if _, err := mfaRepository.db.ExecContext(context.Background(),
+		`DELETE FROM mfa_recovery_codes WHERE user_uuid = $1`, userUUID); err != nil {
+		return err
+	}
+	_, err := mfaRepository.db.ExecContext(context.Background(), `DELETE FROM user_mfa WHERE user_uuid = $1`, userUUID)
+	return err
+}
+
+func (mfaRepository *mfaRepository) UseCounter(userUUID string, counter int64) (bool, error) {
+	query := `UPDATE user_mfa SET last_counter = $2
+		WHERE user_uuid = $1 AND (last_counter IS NULL OR last_counter < $2)`
+	result, err := mfaRepository.db.ExecContext(context.Background(), query, userUUID, counter)
+	if err != nil {
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	return updated > 0, err
+}
+
+func (mfaRepository *mfaRepository) ReplaceRecoveryCodes(userUUID string, hashes [][]byte) error {
+	if _, err := mfaRepository.db.ExecContext(context.Background(),
+		`DELETE FROM mfa_recovery_codes WHERE user_uuid = $1`, userUUID); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if _, err := mfaRepository.db.ExecContext(context.Background(),
+			`INSERT INTO mfa_recovery_codes (user_uuid, code_hash) VALUES ($1, $2)`, userUUID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mfaRepository *mfaRepository) UseRecoveryCode(userUUID string, hash []byte) (bool, error) {
+	query := `UPDATE mfa_recovery_codes SET used_at = now()
+		WHERE user_uuid = $1 AND code_hash = $2 AND used_at IS NULL`
+	result, err := mfaRepository.db.ExecContext(context.Background(), query, userUUID, hash)
+	if err != nil {
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	return updated > 0, err
+}
+
+func (mfaRepository *mfaRepository) CountRecoveryCodes(userUUID string) (int, error) {
+	query := `SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_uuid = $1 AND used_at IS NULL`
+	count := 0
+	err := mfaRepository.db.QueryRowContext(context.Background(), query, userUUID).Scan(&count)
+	return count, err
+}
+
+func (mfaRepository *mfaRepository) CreateChallenge(challenge *model.MFAChallenge, hash []byte) error {
+	query := `INSERT INTO mfa_challenges (user_uuid, token_hash, expires_at) VALUES ($1, $2, $3) RETURNING id`
+	return mfaRepository.db.QueryRowContext(context.Background(), query, challenge.UserUUID, hash, challenge.ExpiresAt).
+		Scan(&challenge.ID)
+}
+
+func (mfaRepository *mfaRepository) GetChallenge(hash []byte) (*model.MFAChallenge, error) {
+	query := `SELECT id, user_uuid, expires_at, attempts, used_at FROM mfa_challenges WHERE token_hash = $1`
+	var challenge model.MFAChallenge
+	var usedAt sql.NullTime
+	err := mfaRepository.db.QueryRowContext(context.Background(), query, hash).Scan(&challenge.ID, &challenge.UserUUID,
+		&challenge.ExpiresAt, &challenge.Attempts, &usedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	challenge.ExpiresAt = challenge.ExpiresAt.UTC()
+	challenge.UsedAt = utcTime(usedAt)
+	return &challenge, nil
+}
+
+func (mfaRepository *mfaRepository) RecordChallengeFailure(id int64) error {
+	query := `UPDATE mfa_challenges SET attempts = attempts + 1 WHERE id = $1`
+	_, err := mfaRepository.db.ExecContext(context.Background(), query, id)
+	return err
+}
+
+func (mfaRepository *mfaRepository) ConsumeChallenge(id int64) (bool, error) {
+	query := `UPDATE mfa_challenges SET used_at = now() WHERE id = $1 AND used_at IS NULL`
+	result, err := mfaRepository.db.ExecContext(context.Background(), query, id)
+	if err != nil {
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	return updated > 0, err
+}
+
+func (mfaRepository *mfaRepository) DeleteExpiredChallenges(before time.Time) error {
+	query := `DELETE FROM mfa_challenges WHERE expires_at < $1`
+	_, err := mfaRepository.db.ExecContext(context.Background(), query, before)
+	return err
+}
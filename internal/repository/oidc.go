@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/model"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type OIDCRepository interface {
+	CreateClient(client *model.OIDCClient) error
+	GetClient(id string) (*model.OIDCClient, error)
+	CreateCode(code *model.AuthorizationCode, hash []byte) error
+	GetCode(hash []byte) (*model.AuthorizationCode, error)
+	// UseCode reports false when the code was already used, which means it
+	// is being replayed.
+	UseCode(id int64) (bool, error)
+	CreateAccessToken(accessToken *model.OIDCAccessToken, hash []byte) error
+	GetAccessToken(hash []byte) (*model.OIDCAccessToken, error)
+	// RevokeAccessTokens deletes the access tokens exchanged for a code.
+	RevokeAccessTokens(codeID int64) error
+	// DeleteExpired removes codes and access tokens that expired before the
+	// given time.
+	DeleteExpired(before time.Time) error
+}
+
+type oidcRepository struct {
+	db executor
+}
+
+func NewOIDCRepository(db executor) OIDCRepository {
+	return &oidcRepository{db: db}
+}
+
+func (oidcRepository *oidcRepository) CreateClient(client *model.OIDCClient) error {
+	var secretHash any
+	if !client.Public() {
+		secretHash = client.SecretHash
+	}
+	query := `INSERT INTO oidc_clients (id, tenant_id, name, secret_hash, redirect_uris) VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at`
+	err := oidcRepository.db.QueryRowContext(context.Background(), query, client.ID, client.TenantID, client.Name,
+		secretHash, pq.Array(client.RedirectURIs)).Scan(&client.CreatedAt)
+	client.CreatedAt = client.CreatedAt.UTC()
+	return err
+}
+
+func (oidcRepository *oidcRepository) GetClient(id string) (*model.OIDCClient, error) {
+	query := `SELECT id, tenant_id, name, secret_hash, redirect_uris, created_at FROM oidc_clients WHERE id = $1`
+	var client model.OIDCClient
+	err := oidcRepository.db.QueryRowContext(context.Background(), query, id).Scan(&client.ID, &client.TenantID,
+		&client.Name, &client.SecretHash, pq.Array(&client.RedirectURIs), &client.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	client.CreatedAt = client.CreatedAt.UTC()
+	return &client, nil
+}
+
+func (oidcRepository *oidcRepository) CreateCode(code *model.AuthorizationCode, hash []byte) error {
+	query := `INSERT INTO oidc_authorization_codes (code_hash, client_id, user_uuid, redirect_uri, scope, nonce,
+		code_challenge, auth_time, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+	return oidcRepository.db.QueryRowContext(context.Background(), query, hash, code.ClientID, code.UserUUID,
+		code.RedirectURI, code.Scope, code.Nonce, code.CodeChallenge, code.AuthTime, code.ExpiresAt).Scan(&code.ID)
+}
+
+func (oidcRepository *oidcRepository) GetCode(hash []byte) (*model.AuthorizationCode, error) {
+	query := `SELECT id, client_id, user_uuid, redirect_uri, scope, nonce, code_challenge, auth_time, expires_at,
+		used_at FROM oidc_authorization_codes WHERE code_hash = $1`
+	var code model.AuthorizationCode
+	var usedAt sql.NullTime
+	err := oidcRepository.db.QueryRowContext(context.Background(), query, hash).Scan(&code.ID, &code.ClientID,
+		&code.UserUUID, &code.RedirectURI, &code.Scope, &code.Nonce, &code.CodeChallenge, &code.AuthTime,
+		&code.ExpiresAt, &usedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	code.AuthTime = code.AuthTime.UTC()
+	code.ExpiresAt = code.ExpiresAt.UTC()
+	code.UsedAt = utcTime(usedAt)
+	return &code, nil
+}
+
+func (oidcRepository *oidcRepository) UseCode(id int64) (bool, error) {
+	query := `UPDATE oidc_authorization_codes SET used_at = now() WHERE id = $1 AND used_at IS NULL`
+	result, err := oidcRepository.db.ExecContext(context.Background(), query, id)
+	if err != nil {
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	return updated > 0, err
+}
+
+func (oidcRepository *oidcRepository) CreateAccessToken(accessToken *model.OIDCAccessToken, hash []byte) error {
+	query := `INSERT INTO oidc_access_tokens (token_hash, code_id, client_id, user_uuid, scope, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := oidcRepository.db.ExecContext(context.Background(), query, hash, accessToken.CodeID,
+		accessToken.ClientID, accessToken.UserUUID, accessToken.Scope, accessToken.ExpiresAt)
+	return err
+}
+
+func (oidcRepository *oidcRepository) GetAccessToken(hash []byte) (*model.OIDCAccessToken, error) {
+	query := `SELECT code_id, client_id, user_uuid, scope, expires_at FROM oidc_access_tokens WHERE token_hash = $1`
+	var accessToken model.OIDCAccessToken
+	err := oidcRepository.db.QueryRowContext(context.Background(), query, hash).Scan(&accessToken.CodeID,
+		&accessToken.ClientID, &accessToken.UserUUID, &accessToken.Scope, &accessToken.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	accessToken.ExpiresAt = accessToken.ExpiresAt.UTC()
+	return &accessToken, nil
+}
+
+func (oidcRepository *oidcRepository) RevokeAccessTokens(codeID int64) error {
+	query := `DELETE FROM oidc_access_tokens WHERE code_id = $1`
+	_, err := oidcRepository.db.ExecContext(context.Background(), query, codeID)
+	return err
+}
+
+func (oidcRepository *oidcRepository) DeleteExpired(before time.Time) error {
+	if _, err := oidcRepository.db.ExecContext(context.Background(),
+		`DELETE FROM oidc_authorization_codes WHERE expires_at < $1`, before); err != nil {
+		return err
+	}
+	_, err := oidcRepository.db.ExecContext(context.Background(),
+		`DELETE FROM oidc_access_tokens WHERE expires_at < $1`, before)
+	return err
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/model"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+type OrganizationRepository interface {
+	Create(organization *model.Organization) error
+	GetBySlug(slug string) (*model.Organization, error)
+}
+
+type organizationRepository struct {
+	db executor
+}
+
+func NewOrganizationRepository(db executor) OrganizationRepository {
+	return &organizationRepository{db: db}
+}
+
+func (organizationRepository *organizationRepository) Create(organization *model.Organization) error {
+	query := `INSERT INTO organizations (slug, name) VALUES ($1, $2) RETURNING id, created_at`
+	err := organizationRepository.db.QueryRowContext(context.Background(), query, organization.Slug, organization.Name).
+		Scan(&organization.ID, &organization.CreatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolationCode {
+		return fmt.Errorf("%s: %w", organization.Slug, model.ErrOrganizationExists)
+	}
+	organization.CreatedAt = organization.CreatedAt.UTC()
+	return err
+}
+
+func (organizationRepository *organizationRepository) GetBySlug(slug string) (*model.Organization, error) {
+	query := `SELECT id, slug, name, created_at FROM organizations WHERE slug = $1`
+	var organization model.Organization
+	err := organizationRepository.db.QueryRowContext(context.Background(), query, slug).
+		Scan(&organization.ID, &organization.Slug, &organization.Name, &organization.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	organization.CreatedAt = organization.CreatedAt.UTC()
+	return &organization, nil
+}
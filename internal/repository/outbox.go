@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/model"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type OutboxRepository interface {
+	Append(event model.DomainEvent) error
+	// ClaimPending returns up to limit pending messages in insertion order,
+	// or none while another replica's relay holds the claim. The claim lasts
+	// until the transaction ends, so call it within a transaction.
+	ClaimPending(limit int) ([]model.OutboxMessage, error)
+	MarkPublished(id int64) error
+	MarkRetry(id int64, attempts int, availableAt time.Time, lastError string) error
+	MarkDead(id int64, attempts int, lastError string) error
+}
+
+type outboxRepository struct {
+	db executor
+}
+
+// outboxRelayLockKey is the advisory lock taken by ClaimPending. One relay
+// claims the whole queue rather than skipping locked rows, since a message
+// being retried must keep blocking the messages behind it.
+const outboxRelayLockKey = 4242002
+
+func NewOutboxRepository(db executor) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (outboxRepository *outboxRepository) Append(event model.DomainEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", event.EventType(), err)
+	}
+
+	query := `INSERT INTO outbox (aggregate_id, event_type, payload) VALUES ($1, $2, $3)`
+	_, err = outboxRepository.db.ExecContext(context.Background(), query, event.AggregateID(), string(event.EventType()), payload)
+	return err
+}
+
+func (outboxRepository *outboxRepository) ClaimPending(limit int) ([]model.OutboxMessage, error) {
+	ctx := context.Background()
+	claimed := false
+	if err := outboxRepository.db.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, outboxRelayLockKey).Scan(&claimed); err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, nil
+	}
+
+	query := `SELECT id, aggregate_id, event_type, payload, status, attempts, COALESCE(last_error, ''), available_at, created_at
+		FROM outbox WHERE status = $1 ORDER BY id LIMIT $2`
+	rows, err := outboxRepository.db.QueryContext(ctx, query, string(model.OutboxStatusPending), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []model.OutboxMessage
+	for rows.Next() {
+		var message model.OutboxMessage
+		if err := rows.Scan(&message.ID, &message.AggregateID, &message.EventType, &message.Payload, &message.Status,
+			&message.Attempts, &message.LastError, &message.AvailableAt, &message.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, rows.Err()
+}
+
+func (outboxRepository *outboxRepository) MarkPublished(id int64) error {
+	query := `UPDATE outbox SET status = $1, published_at = CURRENT_TIMESTAMP WHERE id = $2`
+	_, err := outboxRepository.db.ExecContext(context.Background(), query, string(model.OutboxStatusPublished), id)
+	return err
+}
+
+func (outboxRepository *outboxRepository) MarkRetry(id int64, attempts int, availableAt time.Time, lastError string) error {
+	query := `UPDATE outbox SET attempts = $1, available_at = $2, last_error = $3 WHERE id = $4`
+	_, err := outboxRepository.db.ExecContext(context.Background(), query, attempts, availableAt, lastError, id)
+	return err
+}
+
+func (outboxRepository *outboxRepository) MarkDead(id int64, attempts int, lastError string) error {
+	query := `UPDATE outbox SET status = $1, attempts = $2, last_error = $3 WHERE id = $4`
+	_, err := outboxRepository.db.ExecContext(context.Background(), query, string(model.OutboxStatusDead), attempts, lastError, id)
+	return err
+}
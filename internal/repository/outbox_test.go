@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"cruder/internal/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldLetOneRelayClaimTheOutboxAtATime(test *testing.T) {
+	// given
+	repos, tenantA, _ := setupTenantTest(test)
+	user := &model.User{Username: "jdoe", Email: "jdoe@example.com"}
+	require.NoError(test, repos.ForTenant(tenantA.ID).Users.Create(user))
+	event := model.UserDeleted{UUID: user.UUID, TenantID: tenantA.ID}
+	require.NoError(test, repos.Outbox.Append(event))
+	test.Cleanup(func() { _, _ = repos.db.Exec(`DELETE FROM outbox WHERE aggregate_id = $1`, event.UUID) })
+	var first, second []model.OutboxMessage
+
+	// when
+	err := repos.WithinTransaction(func(claimer *Repository) error {
+		var err error
+		if first, err = claimer.Outbox.ClaimPending(1000); err != nil {
+			return err
+		}
+		return repos.WithinTransaction(func(other *Repository) error {
+			second, err = other.Outbox.ClaimPending(1000)
+			return err
+		})
+	})
+
+	// then
+	require.NoError(test, err)
+	assert.NotEmpty(test, first)
+	assert.Empty(test, second)
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/model"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type PasswordResetRepository interface {
+	Create(token *model.PasswordResetToken, hash []byte) error
+	GetByHash(hash []byte) (*model.PasswordResetToken, error)
+	// MarkUsed reports false if the token was already used, so concurrent
+	// resets cannot both succeed.
+	MarkUsed(id int64) (bool, error)
+	// InvalidateForUser marks all unused tokens of the user as used.
+	InvalidateForUser(userUUID string) error
+	RecordRequest(email, ipAddress string) error
+	// CountRequestsSince counts the reset requests for email and from
+	// ipAddress since the given time.
+	CountRequestsSince(email, ipAddress string, since time.Time) (byEmail int, byIP int, err error)
+	// PruneRequests deletes requests made before the given time.
+	PruneRequests(before time.Time) error
+}
+
+type passwordResetRepository struct {
+	db executor
+}
+
+func NewPasswordResetRepository(db executor) PasswordResetRepository {
+	return &passwordResetRepository{db: db}
+}
+
+func (passwordResetRepository *passwordResetRepository) Create(token *model.PasswordResetToken, hash []byte) error {
+	query := `INSERT INTO password_reset_tokens (user_uuid, token_hash, expires_at) VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+	err := passwordResetRepository.db.QueryRowContext(context.Background(), query, token.UserUUID, hash, token.ExpiresAt).
+		Scan(&token.ID, &token.CreatedAt)
+	token.CreatedAt = token.CreatedAt.UTC()
+	return err
+}
+
+func (passwordResetRepository *passwordResetRepository) GetByHash(hash []byte) (*model.PasswordResetToken, error) {
+	query := `SELECT id, user_uuid, created_at, expires_at, used_at FROM password_reset_tokens WHERE token_hash = $1`
+	var token model.PasswordResetToken
+	var usedAt sql.NullTime
+	err := passwordResetRepository.db.QueryRowContext(context.Background(), query, hash).Scan(&token.ID,
+		&token.UserUUID, &token.CreatedAt, &token.ExpiresAt, &usedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	token.CreatedAt = token.CreatedAt.UTC()
+	token.ExpiresAt = token.ExpiresAt.UTC()
+	token.UsedAt = utcTime(usedAt)
+	return &token, nil
+}
+
+func (passwordResetRepository *passwordResetRepository) MarkUsed(id int64) (bool, error) {
+	query := `UPDATE password_reset_tokens SET used_at = now() WHERE id = $1 AND used_at IS NULL`
+	result, err := passwordResetRepository.db.ExecContext(context.Background(), query, id)
+	if err != nil {
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	return updated > 0, err
+}
+
+func (passwordResetRepository *passwordResetRepository) InvalidateForUser(userUUID string) error {
+	query := `UPDATE password_reset_tokens SET used_at = now() WHERE user_uuid = $1 AND used_at IS NULL`
+	_, err := passwordResetRepository.db.ExecContext(context.Background(), query, userUUID)
+	return err
+}
+
+func (passwordResetRepository *passwordResetRepository) RecordRequest(email, ipAddress string) error {
+	query := `INSERT INTO password_reset_requests (email, ip_address) VALUES ($1, $2)`
+	_, err := passwordResetRepository.db.ExecContext(context.Background(), query, email, ipAddress)
+	return err
+}
+
+func (passwordResetRepository *passwordResetRepository) CountRequestsSince(email, ipAddress string, since time.Time) (int, int, error) {
+	query := `SELECT
+			(SELECT COUNT(*) FROM password_reset_requests WHERE email = $1 AND created_at > $3),
+			(SELECT COUNT(*) FROM password_reset_requests WHERE ip_address = $2 AND created_at > $3)`
+	byEmail, byIP := 0, 0
+	err := passwordResetRepository.db.QueryRowContext(context.Background(), query, email, ipAddress, since).
+		Scan(&byEmail, &byIP)
+	return byEmail, byIP, err
+}
+
+func (passwordResetRepository *passwordResetRepository) PruneRequests(before time.Time) error {
+	query := `DELETE FROM password_reset_requests WHERE created_at < $1`
+	_, err := passwordResetRepository.db.ExecContext(context.Background(), query, before)
+	return err
+}
@@ -1,13 +1,173 @@
 package repository
 
-import "database/sql"
+import (
+	"context"
+	"cruder/internal/model"
+	"database/sql"
+	"fmt"
+)
+
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// pageTotal returns the total of a page that was read with
+// COUNT(*) OVER (). A page past the last row has no row to carry the total,
+// so countQuery counts the matches instead.
+func pageTotal(db executor, total, rows, offset int, countQuery string, args ...any) (int, error) {
+	if rows > 0 || offset == 0 {
+		return total, nil
+	}
+	err := db.QueryRowContext(context.Background(), countQuery, args...).Scan(&total)
+	return total, err
+}
+
+type Transactor interface {
+	WithinTransaction(fn func(repos *Repository) error) error
+}
+
+// TenantRole is the database role tenant-scoped transactions switch to. The
+// row-level security policy on users applies to it and filters on the
+// transaction's app.tenant_id setting.
+const TenantRole = "cruder_tenant"
 
 type Repository struct {
-	Users UserRepository
+	db            *sql.DB
+	inTransaction bool
+	userCache     *UserCache
+	tenantScoped  bool
+	tenantID      string
+	Users         UserRepository
+	Outbox        OutboxRepository
+	Webhooks      WebhookRepository
+
+	StatusTransitions StatusTransitionRepository
+	Sessions          SessionRepository
+	APIKeys           APIKeyRepository
+	Roles             RoleRepository
+	Organizations     OrganizationRepository
+	Groups            GroupRepository
+
+	EmailVerifications EmailVerificationRepository
+	PasswordResets     PasswordResetRepository
+	MFA                MFARepository
+	LoginThrottles     LoginThrottleRepository
+	Audit              AuditRepository
+	OIDC               OIDCRepository
 }
 
 func NewRepository(db *sql.DB) *Repository {
+	repos := newRepository(db)
+	repos.db = db
+	return repos
+}
+
+func newRepository(db executor) *Repository {
 	return &Repository{
-		Users: NewUserRepository(db),
+		Users:    NewUserRepository(db),
+		Outbox:   NewOutboxRepository(db),
+		Webhooks: NewWebhookRepository(db),
+
+		StatusTransitions: NewStatusTransitionRepository(db),
+		Sessions:          NewSessionRepository(db),
+		APIKeys:           NewAPIKeyRepository(db),
+		Roles:             NewRoleRepository(db),
+		Organizations:     NewOrganizationRepository(db),
+		Groups:            NewGroupRepository(db),
+
+		EmailVerifications: NewEmailVerificationRepository(db),
+		PasswordResets:     NewPasswordResetRepository(db),
+		MFA:                NewMFARepository(db),
+		LoginThrottles:     NewLoginThrottleRepository(db),
+		Audit:              NewAuditRepository(db),
+		OIDC:               NewOIDCRepository(db),
+	}
+}
+
+// EnableUserCache serves tenant-scoped user lookups made outside
+// transactions from userCache. Writes invalidate it, after commit when made
+// inside transactions.
+func (repository *Repository) EnableUserCache(userCache *UserCache) {
+	repository.userCache = userCache
+	repository.Users = &invalidatingUserRepository{UserRepository: repository.Users, invalidate: userCache.Invalidate}
+}
+
+// ForTenant returns repositories whose user queries only see the users of
+// tenantID, enforced by row-level security. Without a tenant ID they see no
+// users at all. The unscoped repositories are for system tasks such as
+// authentication that have to look across tenants.
+func (repository *Repository) ForTenant(tenantID string) *Repository {
+	scoped := *repository
+	scoped.tenantScoped = true
+	scoped.tenantID = tenantID
+	scoped.Users = &tenantUserRepository{transactor: &scoped}
+	if repository.userCache != nil {
+		scoped.Users = NewCachedUserRepository(scoped.Users, repository.userCache, tenantID)
+	}
+	return &scoped
+}
+
+// TenantID returns the tenant the repositories are scoped to, or "" when
+// they are unscoped.
+func (repository *Repository) TenantID() string {
+	return repository.tenantID
+}
+
+func (repository *Repository) UserCache() *UserCache {
+	return repository.userCache
+}
+
+func (repository *Repository) WithinTransaction(fn func(repos *Repository) error) error {
+	if repository.inTransaction {
+		return fn(repository)
+	}
+
+	tx, err := repository.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if repository.tenantScoped {
+		if err := scopeToTenant(tx, repository.tenantID); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	txRepos := newRepository(tx)
+	txRepos.db = repository.db
+	txRepos.inTransaction = true
+	txRepos.tenantScoped = repository.tenantScoped
+	txRepos.tenantID = repository.tenantID
+	var written []model.User
+	if repository.userCache != nil {
+		txRepos.Users = &invalidatingUserRepository{UserRepository: txRepos.Users, invalidate: func(users ...model.User) {
+			written = append(written, users...)
+		}}
+	}
+	if err := fn(txRepos); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rollbackErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	repository.userCache.Invalidate(written...)
+	return nil
+}
+
+func scopeToTenant(tx *sql.Tx, tenantID string) error {
+	ctx := context.Background()
+	if _, err := tx.ExecContext(ctx, "SET LOCAL ROLE "+TenantRole); err != nil {
+		return fmt.Errorf("failed to switch to tenant role: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('app.tenant_id', $1, true)", tenantID); err != nil {
+		return fmt.Errorf("failed to set tenant: %w", err)
 	}
+	return nil
 }
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+)
+
+type RoleRepository interface {
+	GetByUser(uuid string) ([]string, error)
+	// Replace sets the roles of a user to exactly roles. Call it within a
+	// transaction.
+	Replace(uuid string, roles []string) error
+}
+
+type roleRepository struct {
+	db executor
+}
+
+func NewRoleRepository(db executor) RoleRepository {
+	return &roleRepository{db: db}
+}
+
+func (roleRepository *roleRepository) GetByUser(uuid string) ([]string, error) {
+	query := `SELECT role FROM user_roles WHERE user_uuid = $1 ORDER BY role`
+	rows, err := roleRepository.db.QueryContext(context.Background(), query, uuid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+func (roleRepository *roleRepository) Replace(uuid string, roles []string) error {
+	ctx := context.Background()
+	if _, err := roleRepository.db.ExecContext(ctx, `DELETE FROM user_roles WHERE user_uuid = $1`, uuid); err != nil {
+		return err
+	}
+	query := `INSERT INTO user_roles (user_uuid, role) SELECT $1, unnest($2::text[])`
+	_, err := roleRepository.db.ExecContext(ctx, query, uuid, pq.Array(roles))
+	return err
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/model"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type SessionRepository interface {
+	Create(session *model.Session) error
+	Get(id string) (*model.Session, error)
+	Extend(id string, expiresAt time.Time) error
+	Revoke(id string, reason string) error
+	RevokeAllForUser(userUUID string, reason string) error
+	// RevokeOthersForUser revokes the user's sessions except keepID, which
+	// may be empty.
+	RevokeOthersForUser(userUUID, keepID, reason string) error
+	// ListActiveForUser returns the sessions neither revoked nor expired at
+	// now, most recently used first.
+	ListActiveForUser(userUUID string, now time.Time) ([]model.Session, error)
+	// DeleteEnded deletes sessions that expired or were revoked before the
+	// given time, with their refresh tokens, and returns how many.
+	DeleteEnded(before time.Time) (int64, error)
+	AddRefreshToken(token *model.RefreshToken) error
+	GetRefreshToken(hash []byte) (*model.RefreshToken, error)
+	// MarkRefreshTokenUsed reports false when the token was already used,
+	// which means it is being replayed.
+	MarkRefreshTokenUsed(id int64) (bool, error)
+}
+
+type sessionRepository struct {
+	db executor
+}
+
+func NewSessionRepository(db executor) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+func (sessionRepository *sessionRepository) Create(session *model.Session) error {
+	query := `INSERT INTO auth_sessions (user_uuid, tenant_id, user_agent, ip_address, expires_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, last_used_at`
+	err := sessionRepository.db.QueryRowContext(context.Background(), query, session.UserUUID, session.TenantID,
+		session.UserAgent, session.IPAddress, session.ExpiresAt).Scan(&session.ID, &session.CreatedAt, &session.LastUsedAt)
+	session.CreatedAt = session.CreatedAt.UTC()
+	session.LastUsedAt = session.LastUsedAt.UTC()
+	return err
+}
+
+func (sessionRepository *sessionRepository) Get(id string) (*model.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM auth_sessions WHERE id = $1`
+	session, err := scanSession(sessionRepository.db.QueryRowContext(context.Background(), query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (sessionRepository *sessionRepository) ListActiveForUser(userUUID string, now time.Time) ([]model.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM auth_sessions
+		WHERE user_uuid = $1 AND revoked_at IS NULL AND expires_at > $2 ORDER BY last_used_at DESC`
+	rows, err := sessionRepository.db.QueryContext(context.Background(), query, userUUID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []model.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	return sessions, rows.Err()
+}
+
+const sessionColumns = `id, user_uuid, tenant_id, user_agent, ip_address, created_at, last_used_at, expires_at, revoked_at`
+
+func scanSession(row rowScanner) (*model.Session, error) {
+	var session model.Session
+	var revokedAt sql.NullTime
+	if err := row.Scan(&session.ID, &session.UserUUID, &session.TenantID, &session.UserAgent, &session.IPAddress,
+		&session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	session.CreatedAt = session.CreatedAt.UTC()
+	session.LastUsedAt = session.LastUsedAt.UTC()
+	session.ExpiresAt = session.ExpiresAt.UTC()
+	session.RevokedAt = utcTime(revokedAt)
+	return &session, nil
+}
+
+func (sessionRepository *sessionRepository) Extend(id string, expiresAt time.Time) error {
+	query := `UPDATE auth_sessions SET last_used_at = now(), expires_at = $1 WHERE id = $2`
+	_, err := sessionRepository.db.ExecContext(context.Background(), query, expiresAt, id)
+	return err
+}
+
+func (sessionRepository *sessionRepository) Revoke(id string, reason string) error {
+	query := `UPDATE auth_sessions SET revoked_at = now(), revoked_reason = $1
+		WHERE id = $2 AND revoked_at IS NULL`
+	_, err := sessionRepository.db.ExecContext(context.Background(), query, reason, id)
+	return err
+}
+
+func (sessionRepository *sessionRepository) RevokeAllForUser(userUUID string, reason string) error {
+	query := `UPDATE auth_sessions SET revoked_at = now(), revoked_reason = $1
+		WHERE user_uuid = $2 AND revoked_at IS NULL`
+	_, err := sessionRepository.db.ExecContext(context.Background(), query, reason, userUUID)
+	return err
+}
+
+func (sessionRepository *sessionRepository) RevokeOthersForUser(userUUID, keepID, reason string) error {
+	query := `UPDATE auth_sessions SET revoked_at = now(), revoked_reason = $1
+		WHERE user_uuid = $2 AND revoked_at IS NULL AND id::text <> $3`
+	_, err := sessionRepository.db.ExecContext(context.Background(), query, reason, userUUID, keepID)
+	return err
+}
+
+func (sessionRepository *sessionRepository) DeleteEnded(before time.Time) (int64, error) {
+	query := `DELETE FROM auth_sessions WHERE expires_at < $1 OR revoked_at < $1`
+	result, err := sessionRepository.db.ExecContext(context.Background(), query, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (sessionRepository *sessionRepository) AddRefreshToken(token *model.RefreshToken) error {
+	query := `INSERT INTO refresh_tokens (session_id, token_hash, expires_at) VALUES ($1, $2, $3) RETURNING id`
+	return sessionRepository.db.QueryRowContext(context.Background(), query, token.SessionID, token.Hash,
+		token.ExpiresAt).Scan(&token.ID)
+}
+
+func (sessionRepository *sessionRepository) GetRefreshToken(hash []byte) (*model.RefreshToken, error) {
+	query := `SELECT id, session_id, token_hash, expires_at, used_at FROM refresh_tokens WHERE token_hash = $1`
+	var token model.RefreshToken
+	var usedAt sql.NullTime
+	err := sessionRepository.db.QueryRowContext(context.Background(), query, hash).Scan(&token.ID,
+		&token.SessionID, &token.Hash, &token.ExpiresAt, &usedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	token.ExpiresAt = token.ExpiresAt.UTC()
+	if usedAt.Valid {
+		used := usedAt.Time.UTC()
+		token.UsedAt = &used
+	}
+	return &token, nil
+}
+
+func (sessionRepository *sessionRepository) MarkRefreshTokenUsed(id int64) (bool, error) {
+	query := `UPDATE refresh_tokens SET used_at = now() WHERE id = $1 AND used_at IS NULL`
+	result, err := sessionRepository.db.ExecContext(context.Background(), query, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
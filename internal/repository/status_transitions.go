@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/model"
+)
+
+type StatusTransitionRepository interface {
+	Record(transition *model.StatusTransition) error
+	GetByUser(uuid string) ([]model.StatusTransition, error)
+}
+
+type statusTransitionRepository struct {
+	db executor
+}
+
+func NewStatusTransitionRepository(db executor) StatusTransitionRepository {
+	return &statusTransitionRepository{db: db}
+}
+
+func (statusTransitionRepository *statusTransitionRepository) Record(transition *model.StatusTransition) error {
+	query := `INSERT INTO user_status_transitions (user_uuid, from_status, to_status, reason, actor)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+	err := statusTransitionRepository.db.QueryRowContext(context.Background(), query, transition.UserUUID,
+		string(transition.From), string(transition.To), transition.Reason, transition.Actor).
+		Scan(&transition.ID, &transition.CreatedAt)
+	transition.CreatedAt = transition.CreatedAt.UTC()
+	return err
+}
+
+func (statusTransitionRepository *statusTransitionRepository) GetByUser(uuid string) ([]model.StatusTransition, error) {
+	query := `SELECT id, user_uuid, from_status, to_status, reason, actor, created_at
+		FROM user_status_transitions WHERE user_uuid = $1 ORDER BY id`
+	rows, err := statusTransitionRepository.db.QueryContext(context.Background(), query, uuid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transitions := []model.StatusTransition{}
+	for rows.Next() {
+		var transition model.StatusTransition
+		if err := rows.Scan(&transition.ID, &transition.UserUUID, &transition.From, &transition.To,
+			&transition.Reason, &transition.Actor, &transition.CreatedAt); err != nil {
+			return nil, err
+		}
+		transition.CreatedAt = transition.CreatedAt.UTC()
+		transitions = append(transitions, transition)
+	}
+	return transitions, rows.Err()
+}
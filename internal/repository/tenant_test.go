@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/model"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTenantTest needs a migrated database named by TEST_POSTGRES_DSN and
+// returns two fresh organizations in it.
+func setupTenantTest(test *testing.T) (*Repository, *model.Organization, *model.Organization) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		test.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	connection, err := NewPostgresConnection(dsn)
+	require.NoError(test, err)
+	test.Cleanup(func() { _ = connection.DB().Close() })
+
+	repos := NewRepository(connection.DB())
+	suffix := time.Now().UnixNano()
+	tenantA := &model.Organization{Slug: fmt.Sprintf("tenant-a-%d", suffix), Name: "Tenant A"}
+	tenantB := &model.Organization{Slug: fmt.Sprintf("tenant-b-%d", suffix), Name: "Tenant B"}
+	require.NoError(test, repos.Organizations.Create(tenantA))
+	require.NoError(test, repos.Organizations.Create(tenantB))
+	test.Cleanup(func() {
+		_, _ = connection.DB().Exec(`DELETE FROM groups WHERE tenant_id IN ($1, $2)`, tenantA.ID, tenantB.ID)
+		_, _ = connection.DB().Exec(`DELETE FROM webhooks WHERE tenant_id IN ($1, $2)`, tenantA.ID, tenantB.ID)
+		_, _ = connection.DB().Exec(`DELETE FROM users WHERE tenant_id IN ($1, $2)`, tenantA.ID, tenantB.ID)
+		_, _ = connection.DB().Exec(`DELETE FROM organizations WHERE id IN ($1, $2)`, tenantA.ID, tenantB.ID)
+	})
+	return repos, tenantA, tenantB
+}
+
+func TestShouldNeverReadAnotherTenantsUsers(test *testing.T) {
+	// given
+	repos, tenantA, tenantB := setupTenantTest(test)
+	usersA := repos.ForTenant(tenantA.ID).Users
+	usersB := repos.ForTenant(tenantB.ID).Users
+	user := &model.User{Username: "jdoe", Email: "jdoe@example.com"}
+	require.NoError(test, usersA.Create(user))
+
+	// when
+	byUUID, uuidErr := usersB.GetByUUID(user.UUID)
+	byID, idErr := usersB.GetByID(int64(user.ID))
+	byUsername, usernameErr := usersB.GetByUsername("jdoe")
+	byEmail, emailErr := usersB.GetByEmail("jdoe@example.com")
+	all, allErr := usersB.GetAll(model.UserFilter{})
+	found, _, searchErr := usersB.Search(model.SearchQuery{Query: "jdoe", Limit: 10})
+	updateErr := usersB.Update(user.UUID, &model.User{Username: "hijacked", Email: "evil@example.com"})
+	deleteErr := usersB.Delete(user.UUID)
+	unchanged, _ := usersA.GetByUUID(user.UUID)
+
+	// then
+	assert.Equal(test, tenantA.ID, user.TenantID)
+	assert.NoError(test, uuidErr)
+	assert.NoError(test, idErr)
+	assert.NoError(test, usernameErr)
+	assert.NoError(test, emailErr)
+	assert.NoError(test, allErr)
+	assert.NoError(test, searchErr)
+	assert.NoError(test, updateErr)
+	assert.NoError(test, deleteErr)
+	assert.Nil(test, byUUID)
+	assert.Nil(test, byID)
+	assert.Nil(test, byUsername)
+	assert.Nil(test, byEmail)
+	assert.Empty(test, all)
+	assert.Empty(test, found)
+	assert.Equal(test, "jdoe", unchanged.Username)
+}
+
+func TestShouldEnforceRowLevelSecurityOnRawQueries(test *testing.T) {
+	// given
+	repos, tenantA, tenantB := setupTenantTest(test)
+	require.NoError(test, repos.ForTenant(tenantA.ID).Users.Create(&model.User{Username: "jdoe", Email: "jdoe@example.com"}))
+
+	// when
+	visible := -1
+	countErr := repos.ForTenant(tenantB.ID).WithinTransaction(func(scoped *Repository) error {
+		tx := scoped.Users.(*userRepository).db
+		return tx.QueryRowContext(context.Background(), `SELECT COUNT(*) FROM users WHERE tenant_id = $1`, tenantA.ID).Scan(&visible)
+	})
+	insertErr := repos.ForTenant(tenantB.ID).WithinTransaction(func(scoped *Repository) error {
+		tx := scoped.Users.(*userRepository).db
+		_, err := tx.ExecContext(context.Background(), `INSERT INTO users (username, email, tenant_id) VALUES ('mallory', 'mallory@example.com', $1)`, tenantA.ID)
+		return err
+	})
+
+	// then
+	assert.NoError(test, countErr)
+	assert.Equal(test, 0, visible)
+	assert.ErrorContains(test, insertErr, "row-level security")
+}
+
+func TestShouldAllowSameUsernameInDifferentTenants(test *testing.T) {
+	// given
+	repos, tenantA, tenantB := setupTenantTest(test)
+	require.NoError(test, repos.ForTenant(tenantA.ID).Users.Create(&model.User{Username: "jdoe", Email: "jdoe@example.com"}))
+
+	// when
+	otherTenant := repos.ForTenant(tenantB.ID).Users.Create(&model.User{Username: "jdoe", Email: "jdoe@example.com"})
+	sameTenant := repos.ForTenant(tenantA.ID).Users.Create(&model.User{Username: "jdoe", Email: "other@example.com"})
+
+	// then
+	assert.NoError(test, otherTenant)
+	assert.ErrorIs(test, sameTenant, model.ErrUserAlreadyExists)
+}
+
+func TestShouldRejectUserWritesWithoutTenant(test *testing.T) {
+	// given
+	repos, _, _ := setupTenantTest(test)
+
+	// when
+	err := repos.ForTenant("").Users.Create(&model.User{Username: "orphan", Email: "orphan@example.com"})
+
+	// then
+	assert.Error(test, err)
+}
+
+func TestShouldPageTenantUsersWithTotal(test *testing.T) {
+	// given
+	repos, tenantA, tenantB := setupTenantTest(test)
+	usersA := repos.ForTenant(tenantA.ID).Users
+	for _, name := range []string{"alice", "bob", "carol"} {
+		require.NoError(test, usersA.Create(&model.User{Username: name, Email: name + "@example.com"}))
+	}
+	require.NoError(test, repos.ForTenant(tenantB.ID).Users.Create(&model.User{Username: "dave", Email: "dave@example.com"}))
+	sorted := model.UserFilter{Sort: model.UserSort{Field: "username"}}
+
+	// when
+	page, total, err := usersA.GetPage(sorted, 1, 1)
+	beyond, beyondTotal, beyondErr := usersA.GetPage(sorted, 5, 1)
+
+	// then
+	require.NoError(test, err)
+	require.NoError(test, beyondErr)
+	assert.Equal(test, 3, total)
+	require.Len(test, page, 1)
+	assert.Equal(test, "bob", page[0].Username)
+	assert.Empty(test, beyond)
+	assert.Equal(test, 3, beyondTotal)
+}
+
+func TestShouldMatchWildcardsInFullNameFilterLiterally(test *testing.T) {
+	// given
+	repos, tenantA, _ := setupTenantTest(test)
+	users := repos.ForTenant(tenantA.ID).Users
+	for name, fullName := range map[string]string{"ann": "Ann 100% Smith", "lee": "Ann_Lee", "bob": "Ann Lee"} {
+		require.NoError(test, users.Create(&model.User{Username: name, Email: name + "@example.com", FullName: fullName}))
+	}
+
+	// when
+	percent, percentErr := users.GetAll(model.UserFilter{FullName: "%"})
+	underscore, underscoreErr := users.GetAll(model.UserFilter{FullName: "n_L"})
+
+	// then
+	require.NoError(test, percentErr)
+	require.NoError(test, underscoreErr)
+	require.Len(test, percent, 1)
+	assert.Equal(test, "ann", percent[0].Username)
+	require.Len(test, underscore, 1)
+	assert.Equal(test, "lee", underscore[0].Username)
+}
+
+func TestShouldCountSearchMatchesForPagePastTheEnd(test *testing.T) {
+	// given
+	repos, tenantA, _ := setupTenantTest(test)
+	users := repos.ForTenant(tenantA.ID).Users
+	for _, name := range []string{"jdoe", "jdoe2"} {
+		require.NoError(test, users.Create(&model.User{Username: name, Email: name + "@example.com"}))
+	}
+
+	// when
+	page, total, err := users.Search(model.SearchQuery{Query: "jdoe", Limit: 10})
+	beyond, beyondTotal, beyondErr := users.Search(model.SearchQuery{Query: "jdoe", Limit: 10, Offset: 10})
+
+	// then
+	require.NoError(test, err)
+	require.NoError(test, beyondErr)
+	assert.Len(test, page, 2)
+	assert.Equal(test, 2, total)
+	assert.Empty(test, beyond)
+	assert.Equal(test, 2, beyondTotal)
+}
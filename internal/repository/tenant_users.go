@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"cruder/internal/model"
+)
+
+// tenantUserRepository runs every call in its own tenant-scoped transaction,
+// because the tenant is a transaction-local setting.
+type tenantUserRepository struct {
+	transactor Transactor
+}
+
+func (repository *tenantUserRepository) GetAll(filter model.UserFilter) ([]model.User, error) {
+	var users []model.User
+	err := repository.transactor.WithinTransaction(func(repos *Repository) error {
+		var err error
+		users, err = repos.Users.GetAll(filter)
+		return err
+	})
+	return users, err
+}
+
+func (repository *tenantUserRepository) GetPage(filter model.UserFilter, offset, limit int) ([]model.User, int, error) {
+	var users []model.User
+	total := 0
+	err := repository.transactor.WithinTransaction(func(repos *Repository) error {
+		var err error
+		users, total, err = repos.Users.GetPage(filter, offset, limit)
+		return err
+	})
+	return users, total, err
+}
+
+func (repository *tenantUserRepository) Stream(filter model.UserFilter, fn func(user *model.User) error) error {
+	return repository.transactor.WithinTransaction(func(repos *Repository) error {
+		return repos.Users.Stream(filter, fn)
+	})
+}
+
+func (repository *tenantUserRepository) Search(query model.SearchQuery) ([]model.SearchResult, int, error) {
+	var results []model.SearchResult
+	total := 0
+	err := repository.transactor.WithinTransaction(func(repos *Repository) error {
+		var err error
+		results, total, err = repos.Users.Search(query)
+		return err
+	})
+	return results, total, err
+}
+
+func (repository *tenantUserRepository) GetByUsername(username string) (*model.User, error) {
+	return repository.getOne(func(users UserRepository) (*model.User, error) { return users.GetByUsername(username) })
+}
+
+func (repository *tenantUserRepository) GetByEmail(email string) (*model.User, error) {
+	return repository.getOne(func(users UserRepository) (*model.User, error) { return users.GetByEmail(email) })
+}
+
+func (repository *tenantUserRepository) GetByID(id int64) (*model.User, error) {
+	return repository.getOne(func(users UserRepository) (*model.User, error) { return users.GetByID(id) })
+}
+
+func (repository *tenantUserRepository) GetByUUID(uuid string) (*model.User, error) {
+	return repository.getOne(func(users UserRepository) (*model.User, error) { return users.GetByUUID(uuid) })
+}
+
+func (repository *tenantUserRepository) Create(user *model.User) error {
+	return repository.write(func(users UserRepository) error { return users.Create(user) })
+}
+
+func (repository *tenantUserRepository) CreateMany(users []*model.User) error {
+	return repository.write(func(userRepository UserRepository) error { return userRepository.CreateMany(users) })
+}
+
+func (repository *tenantUserRepository) Update(uuid string, user *model.User) error {
+	return repository.write(func(users UserRepository) error { return users.Update(uuid, user) })
+}
+
+func (repository *tenantUserRepository) UpdateStatus(uuid string, user *model.User) error {
+	return repository.write(func(users UserRepository) error { return users.UpdateStatus(uuid, user) })
+}
+
+func (repository *tenantUserRepository) GetPasswordHash(uuid string) (string, error) {
+	hash := ""
+	err := repository.transactor.WithinTransaction(func(repos *Repository) error {
+		var err error
+		hash, err = repos.Users.GetPasswordHash(uuid)
+		return err
+	})
+	return hash, err
+}
+
+func (repository *tenantUserRepository) UpdatePasswordHash(uuid string, hash string) error {
+	return repository.write(func(users UserRepository) error { return users.UpdatePasswordHash(uuid, hash) })
+}
+
+func (repository *tenantUserRepository) MarkEmailVerified(uuid string, email string) (bool, error) {
+	verified := false
+	err := repository.transactor.WithinTransaction(func(repos *Repository) error {
+		var err error
+		verified, err = repos.Users.MarkEmailVerified(uuid, email)
+		return err
+	})
+	return verified, err
+}
+
+func (repository *tenantUserRepository) Delete(uuid string) error {
+	return repository.write(func(users UserRepository) error { return users.Delete(uuid) })
+}
+
+func (repository *tenantUserRepository) getOne(lookup func(users UserRepository) (*model.User, error)) (*model.User, error) {
+	var user *model.User
+	err := repository.transactor.WithinTransaction(func(repos *Repository) error {
+		var err error
+		user, err = lookup(repos.Users)
+		return err
+	})
+	return user, err
+}
+
+func (repository *tenantUserRepository) write(fn func(users UserRepository) error) error {
+	return repository.transactor.WithinTransaction(func(repos *Repository) error {
+		return fn(repos.Users)
+	})
+}
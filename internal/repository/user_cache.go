@@ -0,0 +1,367 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/cache"
+	"cruder/internal/model"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/sync/singleflight"
+)
+
+// UserCacheChannel is the Postgres notification channel fed by the users
+// table trigger, so every replica drops entries for rows changed elsewhere.
+const UserCacheChannel = "user_cache_invalidations"
+
+type UserCacheConfig struct {
+	Enabled     bool
+	Size        int
+	TTL         time.Duration
+	NegativeTTL time.Duration
+}
+
+func DefaultUserCacheConfig() UserCacheConfig {
+	return UserCacheConfig{
+		Enabled:     true,
+		Size:        10000,
+		TTL:         5 * time.Minute,
+		NegativeTTL: 30 * time.Second,
+	}
+}
+
+type UserCacheStats struct {
+	Hits          uint64 `json:"hits"`
+	NegativeHits  uint64 `json:"negative_hits"`
+	Misses        uint64 `json:"misses"`
+	Evictions     uint64 `json:"evictions"`
+	Invalidations uint64 `json:"invalidations"`
+	Entries       int    `json:"entries"`
+}
+
+// UserCache holds users by tenant and id, uuid or username, so a lookup
+// never sees another tenant's entries. Not-found lookups are cached
+// as nil entries for NegativeTTL. Every positive entry is indexed by the
+// user's UUID so an invalidation also drops keys for a since-renamed username.
+type UserCache struct {
+	config UserCacheConfig
+	group  singleflight.Group
+
+	mutex      sync.Mutex
+	entries    *cache.LRU[string, *model.User]
+	keysByUUID map[string]map[string]struct{}
+	generation uint64
+
+	hits          atomic.Uint64
+	negativeHits  atomic.Uint64
+	misses        atomic.Uint64
+	evictions     atomic.Uint64
+	invalidations atomic.Uint64
+}
+
+func NewUserCache(config UserCacheConfig) *UserCache {
+	userCache := &UserCache{config: config, keysByUUID: make(map[string]map[string]struct{})}
+	userCache.entries = cache.NewLRU(config.Size, func(key string, user *model.User) {
+		userCache.evictions.Add(1)
+		userCache.unindex(key, user)
+	})
+	return userCache
+}
+
+func (userCache *UserCache) Stats() UserCacheStats {
+	userCache.mutex.Lock()
+	entries := userCache.entries.Len()
+	userCache.mutex.Unlock()
+
+	return UserCacheStats{
+		Hits:          userCache.hits.Load(),
+		NegativeHits:  userCache.negativeHits.Load(),
+		Misses:        userCache.misses.Load(),
+		Evictions:     userCache.evictions.Load(),
+		Invalidations: userCache.invalidations.Load(),
+		Entries:       entries,
+	}
+}
+
+// Invalidate drops every entry for the given users. Only UUID is required;
+// ID and Username also clear negative entries for a newly created user.
+func (userCache *UserCache) Invalidate(users ...model.User) {
+	if userCache == nil || len(users) == 0 {
+		return
+	}
+
+	var keys []string
+	userCache.mutex.Lock()
+	userCache.generation++
+	for _, user := range users {
+		for key := range userCache.keysByUUID[user.UUID] {
+			keys = append(keys, key)
+		}
+		keys = append(keys, userKeys(user)...)
+	}
+	for _, key := range keys {
+		userCache.remove(key)
+	}
+	userCache.mutex.Unlock()
+
+	userCache.invalidations.Add(uint64(len(users)))
+	for _, key := range keys {
+		userCache.group.Forget(key)
+	}
+}
+
+// Purge drops all entries, e.g. after notifications may have been missed.
+func (userCache *UserCache) Purge() {
+	userCache.mutex.Lock()
+	defer userCache.mutex.Unlock()
+
+	userCache.generation++
+	userCache.entries.Purge()
+	userCache.keysByUUID = make(map[string]map[string]struct{})
+}
+
+func (userCache *UserCache) get(key string, load func() (*model.User, error)) (*model.User, error) {
+	userCache.mutex.Lock()
+	user, found := userCache.entries.Get(key)
+	generation := userCache.generation
+	userCache.mutex.Unlock()
+
+	if found {
+		if user == nil {
+			userCache.negativeHits.Add(1)
+			return nil, nil
+		}
+		userCache.hits.Add(1)
+		return cloneUser(user), nil
+	}
+
+	userCache.misses.Add(1)
+	value, err, _ := userCache.group.Do(key, func() (any, error) {
+		user, err := load()
+		if err != nil {
+			return nil, err
+		}
+		userCache.store(key, user, generation)
+		return user, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cloneUser(value.(*model.User)), nil
+}
+
+// store skips the write if an invalidation ran while the user was loading,
+// since the loaded row may predate it.
+func (userCache *UserCache) store(key string, user *model.User, generation uint64) {
+	userCache.mutex.Lock()
+	defer userCache.mutex.Unlock()
+
+	if generation != userCache.generation {
+		return
+	}
+
+	userCache.remove(key)
+	if user == nil {
+		userCache.entries.Add(key, nil, userCache.config.NegativeTTL)
+		return
+	}
+
+	userCache.entries.Add(key, cloneUser(user), userCache.config.TTL)
+	if userCache.keysByUUID[user.UUID] == nil {
+		userCache.keysByUUID[user.UUID] = make(map[string]struct{})
+	}
+	userCache.keysByUUID[user.UUID][key] = struct{}{}
+}
+
+func (userCache *UserCache) remove(key string) {
+	if user, found := userCache.entries.Remove(key); found {
+		userCache.unindex(key, user)
+	}
+}
+
+func (userCache *UserCache) unindex(key string, user *model.User) {
+	if user == nil {
+		return
+	}
+	keys := userCache.keysByUUID[user.UUID]
+	delete(keys, key)
+	if len(keys) == 0 {
+		delete(userCache.keysByUUID, user.UUID)
+	}
+}
+
+func userKeys(user model.User) []string {
+	var keys []string
+	if user.UUID != "" {
+		keys = append(keys, uuidKey(user.TenantID, user.UUID))
+	}
+	if user.ID != 0 {
+		keys = append(keys, idKey(user.TenantID, int64(user.ID)))
+	}
+	if user.Username != "" {
+		keys = append(keys, usernameKey(user.TenantID, user.Username))
+	}
+	return keys
+}
+
+func idKey(tenantID string, id int64) string { return tenantID + "/id:" + strconv.FormatInt(id, 10) }
+func uuidKey(tenantID, uuid string) string   { return tenantID + "/uuid:" + uuid }
+func usernameKey(tenantID, username string) string {
+	return tenantID + "/username:" + username
+}
+
+func cloneUser(user *model.User) *model.User {
+	if user == nil {
+		return nil
+	}
+	clone := *user
+	return &clone
+}
+
+// invalidatingUserRepository reports every user it writes. Inside a
+// transaction the report is deferred until commit.
+type invalidatingUserRepository struct {
+	UserRepository
+	invalidate func(users ...model.User)
+}
+
+func (repository *invalidatingUserRepository) Create(user *model.User) error {
+	if err := repository.UserRepository.Create(user); err != nil {
+		return err
+	}
+	repository.invalidate(*user)
+	return nil
+}
+
+func (repository *invalidatingUserRepository) CreateMany(users []*model.User) error {
+	if err := repository.UserRepository.CreateMany(users); err != nil {
+		return err
+	}
+	for _, user := range users {
+		repository.invalidate(*user)
+	}
+	return nil
+}
+
+func (repository *invalidatingUserRepository) Update(uuid string, user *model.User) error {
+	if err := repository.UserRepository.Update(uuid, user); err != nil {
+		return err
+	}
+	repository.invalidate(model.User{UUID: uuid}, *user)
+	return nil
+}
+
+func (repository *invalidatingUserRepository) UpdateStatus(uuid string, user *model.User) error {
+	if err := repository.UserRepository.UpdateStatus(uuid, user); err != nil {
+		return err
+	}
+	repository.invalidate(model.User{UUID: uuid})
+	return nil
+}
+
+func (repository *invalidatingUserRepository) UpdatePasswordHash(uuid string, hash string) error {
+	if err := repository.UserRepository.UpdatePasswordHash(uuid, hash); err != nil {
+		return err
+	}
+	repository.invalidate(model.User{UUID: uuid})
+	return nil
+}
+
+func (repository *invalidatingUserRepository) MarkEmailVerified(uuid string, email string) (bool, error) {
+	verified, err := repository.UserRepository.MarkEmailVerified(uuid, email)
+	if err != nil {
+		return false, err
+	}
+	repository.invalidate(model.User{UUID: uuid})
+	return verified, nil
+}
+
+func (repository *invalidatingUserRepository) Delete(uuid string) error {
+	if err := repository.UserRepository.Delete(uuid); err != nil {
+		return err
+	}
+	repository.invalidate(model.User{UUID: uuid})
+	return nil
+}
+
+type cachedUserRepository struct {
+	*invalidatingUserRepository
+	cache    *UserCache
+	tenantID string
+}
+
+// NewCachedUserRepository serves GetByUsername, GetByID and GetByUUID of the
+// users of tenantID from userCache and invalidates it on every write made
+// through it. userRepository must only return users of tenantID.
+func NewCachedUserRepository(userRepository UserRepository, userCache *UserCache, tenantID string) UserRepository {
+	return &cachedUserRepository{
+		invalidatingUserRepository: &invalidatingUserRepository{UserRepository: userRepository, invalidate: userCache.Invalidate},
+		cache:                      userCache,
+		tenantID:                   tenantID,
+	}
+}
+
+func (repository *cachedUserRepository) GetByUsername(username string) (*model.User, error) {
+	return repository.cache.get(usernameKey(repository.tenantID, username), func() (*model.User, error) {
+		return repository.UserRepository.GetByUsername(username)
+	})
+}
+
+func (repository *cachedUserRepository) GetByID(id int64) (*model.User, error) {
+	return repository.cache.get(idKey(repository.tenantID, id), func() (*model.User, error) {
+		return repository.UserRepository.GetByID(id)
+	})
+}
+
+func (repository *cachedUserRepository) GetByUUID(uuid string) (*model.User, error) {
+	return repository.cache.get(uuidKey(repository.tenantID, uuid), func() (*model.User, error) {
+		return repository.UserRepository.GetByUUID(uuid)
+	})
+}
+
+// ListenUserCacheInvalidations invalidates userCache for every row change
+// notified on UserCacheChannel until ctx is cancelled. The whole cache is
+// purged after a reconnect because notifications may have been missed.
+func ListenUserCacheInvalidations(ctx context.Context, dsn string, userCache *UserCache) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("user cache listener: %v", err)
+		}
+		if event == pq.ListenerEventReconnected {
+			userCache.Purge()
+		}
+	})
+	if err := listener.Listen(UserCacheChannel); err != nil {
+		_ = listener.Close()
+		return fmt.Errorf("failed to listen on %s: %w", UserCacheChannel, err)
+	}
+
+	go func() {
+		defer listener.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case notification := <-listener.Notify:
+				if notification == nil {
+					userCache.Purge()
+					continue
+				}
+				var user model.User
+				if err := json.Unmarshal([]byte(notification.Extra), &user); err != nil {
+					log.Printf("user cache listener: failed to decode notification: %v", err)
+					userCache.Purge()
+					continue
+				}
+				userCache.Invalidate(user)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"cruder/internal/model"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingUserRepository struct {
+	UserRepository
+	mutex   sync.Mutex
+	users   map[string]*model.User
+	lookups atomic.Int32
+	release chan struct{}
+}
+
+func newCountingUserRepository(users ...*model.User) *countingUserRepository {
+	repository := &countingUserRepository{users: make(map[string]*model.User)}
+	for _, user := range users {
+		repository.users[user.UUID] = user
+	}
+	return repository
+}
+
+func (repository *countingUserRepository) find(match func(user *model.User) bool) (*model.User, error) {
+	repository.lookups.Add(1)
+	if repository.release != nil {
+		<-repository.release
+	}
+
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	for _, user := range repository.users {
+		if match(user) {
+			clone := *user
+			return &clone, nil
+		}
+	}
+	return nil, nil
+}
+
+func (repository *countingUserRepository) GetByUsername(username string) (*model.User, error) {
+	return repository.find(func(user *model.User) bool { return user.Username == username })
+}
+
+func (repository *countingUserRepository) GetByID(id int64) (*model.User, error) {
+	return repository.find(func(user *model.User) bool { return int64(user.ID) == id })
+}
+
+func (repository *countingUserRepository) GetByUUID(uuid string) (*model.User, error) {
+	return repository.find(func(user *model.User) bool { return user.UUID == uuid })
+}
+
+func (repository *countingUserRepository) Create(user *model.User) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	repository.users[user.UUID] = user
+	return nil
+}
+
+func (repository *countingUserRepository) Update(uuid string, user *model.User) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	repository.users[uuid] = user
+	return nil
+}
+
+func testUserCacheConfig() UserCacheConfig {
+	return UserCacheConfig{Enabled: true, Size: 100, TTL: time.Minute, NegativeTTL: time.Minute}
+}
+
+func TestShouldServeRepeatedLookupsFromCache(test *testing.T) {
+	// given
+	next := newCountingUserRepository(&model.User{ID: 1, UUID: "u-1", Username: "jdoe"})
+	userCache := NewUserCache(testUserCacheConfig())
+	cached := NewCachedUserRepository(next, userCache, "")
+
+	// when
+	first, _ := cached.GetByUsername("jdoe")
+	first.Username = "mutated"
+	second, err := cached.GetByUsername("jdoe")
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, "jdoe", second.Username)
+	assert.EqualValues(test, 1, next.lookups.Load())
+	assert.Equal(test, UserCacheStats{Hits: 1, Misses: 1, Entries: 1}, userCache.Stats())
+}
+
+func TestShouldCacheNotFoundUntilUserIsCreated(test *testing.T) {
+	// given
+	next := newCountingUserRepository()
+	userCache := NewUserCache(testUserCacheConfig())
+	cached := NewCachedUserRepository(next, userCache, "")
+	missing, _ := cached.GetByUsername("jdoe")
+	stillMissing, _ := cached.GetByUsername("jdoe")
+
+	// when
+	_ = cached.Create(&model.User{ID: 1, UUID: "u-1", Username: "jdoe"})
+	created, err := cached.GetByUsername("jdoe")
+
+	// then
+	assert.NoError(test, err)
+	assert.Nil(test, missing)
+	assert.Nil(test, stillMissing)
+	assert.Equal(test, "u-1", created.UUID)
+	assert.EqualValues(test, 2, next.lookups.Load())
+	assert.EqualValues(test, 1, userCache.Stats().NegativeHits)
+}
+
+func TestShouldDropRenamedUsernameOnInvalidation(test *testing.T) {
+	// given
+	next := newCountingUserRepository(&model.User{ID: 1, UUID: "u-1", Username: "jdoe"})
+	userCache := NewUserCache(testUserCacheConfig())
+	cached := NewCachedUserRepository(next, userCache, "")
+	_, _ = cached.GetByUsername("jdoe")
+	_, _ = cached.GetByID(1)
+
+	// when
+	_ = next.Update("u-1", &model.User{ID: 1, UUID: "u-1", Username: "john"})
+	userCache.Invalidate(model.User{UUID: "u-1"})
+	oldName, _ := cached.GetByUsername("jdoe")
+	byID, _ := cached.GetByID(1)
+
+	// then
+	assert.Nil(test, oldName)
+	assert.Equal(test, "john", byID.Username)
+	assert.EqualValues(test, 1, userCache.Stats().Invalidations)
+}
+
+func TestShouldCollapseConcurrentMisses(test *testing.T) {
+	// given
+	next := newCountingUserRepository(&model.User{ID: 1, UUID: "u-1", Username: "jdoe"})
+	next.release = make(chan struct{})
+	cached := NewCachedUserRepository(next, NewUserCache(testUserCacheConfig()), "")
+
+	// when
+	var waitGroup sync.WaitGroup
+	results := make([]*model.User, 10)
+	for i := range results {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			results[i], _ = cached.GetByUUID("u-1")
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(next.release)
+	waitGroup.Wait()
+
+	// then
+	assert.EqualValues(test, 1, next.lookups.Load())
+	for _, user := range results {
+		assert.Equal(test, "jdoe", user.Username)
+	}
+}
+
+func TestShouldNotStoreLookupRacingAnInvalidation(test *testing.T) {
+	// given
+	next := newCountingUserRepository(&model.User{ID: 1, UUID: "u-1", Username: "jdoe"})
+	userCache := NewUserCache(testUserCacheConfig())
+
+	// when
+	stale, _ := userCache.get(uuidKey("", "u-1"), func() (*model.User, error) {
+		user, err := next.GetByUUID("u-1")
+		userCache.Invalidate(model.User{UUID: "u-1"})
+		return user, err
+	})
+
+	// then
+	assert.Equal(test, "jdoe", stale.Username)
+	assert.Zero(test, userCache.Stats().Entries)
+}
+
+func TestShouldNeverServeCachedUsersAcrossTenants(test *testing.T) {
+	// given
+	userCache := NewUserCache(testUserCacheConfig())
+	tenantA := NewCachedUserRepository(newCountingUserRepository(&model.User{ID: 1, UUID: "u-1", Username: "jdoe", TenantID: "tenant-a"}), userCache, "tenant-a")
+	tenantB := NewCachedUserRepository(newCountingUserRepository(), userCache, "tenant-b")
+	cachedA, _ := tenantA.GetByUUID("u-1")
+	_, _ = tenantA.GetByUsername("jdoe")
+	_, _ = tenantA.GetByID(1)
+
+	// when
+	byUUID, uuidErr := tenantB.GetByUUID("u-1")
+	byUsername, usernameErr := tenantB.GetByUsername("jdoe")
+	byID, idErr := tenantB.GetByID(1)
+
+	// then
+	assert.Equal(test, "u-1", cachedA.UUID)
+	assert.NoError(test, uuidErr)
+	assert.NoError(test, usernameErr)
+	assert.NoError(test, idErr)
+	assert.Nil(test, byUUID)
+	assert.Nil(test, byUsername)
+	assert.Nil(test, byID)
+}
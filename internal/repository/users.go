@@ -4,25 +4,76 @@ import (
 	"context"
 	"cruder/internal/model"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 type UserRepository interface {
-	GetAll() ([]model.User, error)
+	GetAll(filter model.UserFilter) ([]model.User, error)
+	// GetPage returns up to limit matching users after skipping offset of
+	// them, and how many match in total.
+	GetPage(filter model.UserFilter, offset, limit int) ([]model.User, int, error)
+	Stream(filter model.UserFilter, fn func(user *model.User) error) error
+	Search(query model.SearchQuery) ([]model.SearchResult, int, error)
 	GetByUsername(username string) (*model.User, error)
+	GetByEmail(email string) (*model.User, error)
 	GetByID(id int64) (*model.User, error)
 	GetByUUID(uuid string) (*model.User, error)
 	Create(user *model.User) error
+	CreateMany(users []*model.User) error
 	Update(uuid string, user *model.User) error
+	UpdateStatus(uuid string, user *model.User) error
+	GetPasswordHash(uuid string) (string, error)
+	UpdatePasswordHash(uuid string, hash string) error
+	MarkEmailVerified(uuid string, email string) (bool, error)
 	Delete(uuid string) error
 }
 
 type userRepository struct {
-	db *sql.DB
+	db executor
 }
 
-const selectUserColumns = "SELECT id, uuid, username, email, full_name FROM users"
+const (
+	selectUserColumns = "SELECT id, uuid, username, email, full_name, status, tenant_id, created_at, updated_at, email_verified_at FROM users"
+
+	// createManyChunkSize keeps multi-row inserts well below Postgres' limit of
+	// 65535 bind parameters per statement.
+	createManyChunkSize = 1000
+
+	uniqueViolationCode = "23505"
+
+	// searchUsersCondition matches $1 by full text, by pg_trgm word
+	// similarity or, as the escaped pattern $2, verbatim.
+	searchUsersCondition = `search_vector @@ plainto_tsquery('simple', $1)
+		OR $1 <% username OR $1 <% email OR $1 <% full_name
+		OR username ILIKE $2 OR email ILIKE $2 OR full_name ILIKE $2`
+
+	// searchUsersQuery ranks users by the best pg_trgm word similarity across
+	// the searchable columns plus the full-text rank, and boosts verbatim
+	// substring matches so they outrank typo matches.
+	searchUsersQuery = `SELECT id, uuid, username, email, full_name, status, tenant_id, created_at, updated_at, email_verified_at,
+			score, COUNT(*) OVER () AS total
+		FROM (
+			SELECT id, uuid, username, email, full_name, status, tenant_id, created_at, updated_at, email_verified_at,
+				GREATEST(word_similarity($1, username), word_similarity($1, email), word_similarity($1, COALESCE(full_name, '')))
+				+ ts_rank(search_vector, plainto_tsquery('simple', $1))
+				+ CASE WHEN username ILIKE $2 OR email ILIKE $2 OR full_name ILIKE $2 THEN 1 ELSE 0 END AS score
+			FROM users
+			WHERE ` + searchUsersCondition + `
+		) matches
+		ORDER BY score DESC, id
+		LIMIT $3 OFFSET $4`
+
+	countSearchUsersQuery = `SELECT COUNT(*) FROM users WHERE ` + searchUsersCondition
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
 
-func NewUserRepository(db *sql.DB) UserRepository {
+func NewUserRepository(db executor) UserRepository {
 	return &userRepository{db: db}
 }
 
@@ -30,9 +81,24 @@ func (userRepository *userRepository) buildSelectQuery(whereClause string) strin
 	return selectUserColumns + " WHERE " + whereClause
 }
 
+// scanUser reads the selectUserColumns into user, followed by any extra
+// columns, and normalises the timestamps to UTC.
+func (userRepository *userRepository) scanUser(row rowScanner, user *model.User, extra ...any) error {
+	var emailVerifiedAt sql.NullTime
+	columns := append([]any{&user.ID, &user.UUID, &user.Username, &user.Email, &user.FullName, &user.Status, &user.TenantID,
+		&user.CreatedAt, &user.UpdatedAt, &emailVerifiedAt}, extra...)
+	if err := row.Scan(columns...); err != nil {
+		return err
+	}
+	user.EmailVerifiedAt = utcTime(emailVerifiedAt)
+	user.CreatedAt = user.CreatedAt.UTC()
+	user.UpdatedAt = user.UpdatedAt.UTC()
+	return nil
+}
+
 func (userRepository *userRepository) scanUserRow(row *sql.Row) (*model.User, error) {
 	var user model.User
-	err := row.Scan(&user.ID, &user.UUID, &user.Username, &user.Email, &user.FullName)
+	err := userRepository.scanUser(row, &user)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -42,23 +108,128 @@ func (userRepository *userRepository) scanUserRow(row *sql.Row) (*model.User, er
 	return &user, nil
 }
 
-func (userRepository *userRepository) GetAll() ([]model.User, error) {
-	rows, err := userRepository.db.QueryContext(context.Background(), selectUserColumns)
+func (userRepository *userRepository) buildFilterQuery(filter model.UserFilter) (string, []any) {
+	where, args := userRepository.buildWhereClause(filter)
+	return selectUserColumns + where + userRepository.buildOrderBy(filter.Sort), args
+}
+
+// buildWhereClause returns the conditions of filter, if any, as a WHERE
+// clause.
+func (userRepository *userRepository) buildWhereClause(filter model.UserFilter) (string, []any) {
+	var conditions []string
+	var args []any
+	addCondition := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Username != "" {
+		addCondition("username = $%d", filter.Username)
+	}
+	if filter.Email != "" {
+		addCondition("email = $%d", filter.Email)
+	}
+	if filter.FullName != "" {
+		addCondition("full_name ILIKE '%%' || $%d || '%%'", likeEscaper.Replace(filter.FullName))
+	}
+	if filter.Status != "" {
+		addCondition("status = $%d", string(filter.Status))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		addCondition("created_at > $%d", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		addCondition("created_at < $%d", filter.CreatedBefore)
+	}
+	if !filter.UpdatedAfter.IsZero() {
+		addCondition("updated_at > $%d", filter.UpdatedAfter)
+	}
+	if !filter.UpdatedBefore.IsZero() {
+		addCondition("updated_at < $%d", filter.UpdatedBefore)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// buildOrderBy breaks ties on id so pages and exports are stable. The sort
+// field has already been validated by model.ParseUserSort.
+func (userRepository *userRepository) buildOrderBy(sort model.UserSort) string {
+	direction := ""
+	if sort.Descending {
+		direction = " DESC"
+	}
+	if sort.Field == "" || sort.Field == "id" {
+		return " ORDER BY id" + direction
+	}
+	return " ORDER BY " + pq.QuoteIdentifier(sort.Field) + direction + ", id" + direction
+}
+
+func (userRepository *userRepository) GetAll(filter model.UserFilter) ([]model.User, error) {
+	var users []model.User
+	err := userRepository.Stream(filter, func(user *model.User) error {
+		users = append(users, *user)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+
+	return users, nil
+}
+
+func (userRepository *userRepository) GetPage(filter model.UserFilter, offset, limit int) ([]model.User, int, error) {
+	where, args := userRepository.buildWhereClause(filter)
+	total := 0
+	if err := userRepository.db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM users"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	if limit <= 0 || offset >= total {
+		return nil, total, nil
+	}
+
+	query := selectUserColumns + where + userRepository.buildOrderBy(filter.Sort) +
+		fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	rows, err := userRepository.db.QueryContext(context.Background(), query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
 	defer rows.Close()
 
 	var users []model.User
 	for rows.Next() {
 		var user model.User
-		if err := rows.Scan(&user.ID, &user.UUID, &user.Username, &user.Email, &user.FullName); err != nil {
-			return nil, err
+		if err := userRepository.scanUser(rows, &user); err != nil {
+			return nil, 0, err
 		}
 		users = append(users, user)
 	}
+	return users, total, rows.Err()
+}
 
-	return users, rows.Err()
+// Stream calls fn for every matching user while reading from the database
+// cursor, so callers can process any number of rows in constant memory.
+func (userRepository *userRepository) Stream(filter model.UserFilter, fn func(user *model.User) error) error {
+	query, args := userRepository.buildFilterQuery(filter)
+	rows, err := userRepository.db.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user model.User
+		if err := userRepository.scanUser(rows, &user); err != nil {
+			return err
+		}
+		if err := fn(&user); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
 }
 
 func (userRepository *userRepository) GetByUsername(username string) (*model.User, error) {
@@ -66,6 +237,11 @@ func (userRepository *userRepository) GetByUsername(username string) (*model.Use
 	return userRepository.scanUserRow(row)
 }
 
+func (userRepository *userRepository) GetByEmail(email string) (*model.User, error) {
+	row := userRepository.db.QueryRowContext(context.Background(), userRepository.buildSelectQuery("email = $1"), email)
+	return userRepository.scanUserRow(row)
+}
+
 func (userRepository *userRepository) GetByID(id int64) (*model.User, error) {
 	row := userRepository.db.QueryRowContext(context.Background(), userRepository.buildSelectQuery("id = $1"), id)
 	return userRepository.scanUserRow(row)
@@ -76,16 +252,130 @@ func (userRepository *userRepository) GetByUUID(uuid string) (*model.User, error
 	return userRepository.scanUserRow(row)
 }
 
-func (userRepository *userRepository) Create(user *model.User) error {
-	query := `INSERT INTO users (username, email, full_name) VALUES ($1, $2, $3) RETURNING id, uuid`
-	err := userRepository.db.QueryRowContext(context.Background(), query, user.Username, user.Email, user.FullName).
-		Scan(&user.ID, &user.UUID)
+func (userRepository *userRepository) translateError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolationCode {
+		return fmt.Errorf("%s: %w", pqErr.Constraint, model.ErrUserAlreadyExists)
+	}
 	return err
 }
 
+func (userRepository *userRepository) Create(user *model.User) error {
+	query := `INSERT INTO users (username, email, full_name, password_hash) VALUES ($1, $2, $3, $4)
+		RETURNING id, uuid, status, tenant_id, created_at, updated_at`
+	err := userRepository.db.QueryRowContext(context.Background(), query, user.Username, user.Email, user.FullName, nullString(user.PasswordHash)).
+		Scan(&user.ID, &user.UUID, &user.Status, &user.TenantID, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return userRepository.translateError(err)
+	}
+	user.CreatedAt = user.CreatedAt.UTC()
+	user.UpdatedAt = user.UpdatedAt.UTC()
+	return nil
+}
+
+// CreateMany inserts users with multi-row INSERT statements and fills in the
+// generated id, uuid and timestamps of each user. Either every user is
+// inserted or, when run inside a transaction, none are.
+func (userRepository *userRepository) CreateMany(users []*model.User) error {
+	for start := 0; start < len(users); start += createManyChunkSize {
+		end := min(start+createManyChunkSize, len(users))
+		if err := userRepository.createChunk(users[start:end]); err != nil {
+			return userRepository.translateError(err)
+		}
+	}
+	return nil
+}
+
+func (userRepository *userRepository) createChunk(users []*model.User) error {
+	placeholders := make([]string, 0, len(users))
+	args := make([]any, 0, len(users)*4)
+	byUsername := make(map[string]*model.User, len(users))
+	for i, user := range users {
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d)", i*4+1, i*4+2, i*4+3, i*4+4))
+		args = append(args, user.Username, user.Email, user.FullName, nullString(user.PasswordHash))
+		byUsername[user.Username] = user
+	}
+
+	query := `INSERT INTO users (username, email, full_name, password_hash) VALUES ` + strings.Join(placeholders, ", ") +
+		` RETURNING id, uuid, username, status, tenant_id, created_at, updated_at`
+	rows, err := userRepository.db.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var uuid, username, tenantID string
+		var status model.UserStatus
+		var createdAt, updatedAt time.Time
+		if err := rows.Scan(&id, &uuid, &username, &status, &tenantID, &createdAt, &updatedAt); err != nil {
+			return err
+		}
+		if user, ok := byUsername[username]; ok {
+			user.ID = id
+			user.UUID = uuid
+			user.Status = status
+			user.TenantID = tenantID
+			user.CreatedAt = createdAt.UTC()
+			user.UpdatedAt = updatedAt.UTC()
+		}
+	}
+	return rows.Err()
+}
+
+// Update resets email_verified_at when the email changes.
 func (userRepository *userRepository) Update(uuid string, user *model.User) error {
-	query := `UPDATE users SET username = $1, email = $2, full_name = $3 WHERE uuid = $4`
-	_, err := userRepository.db.ExecContext(context.Background(), query, user.Username, user.Email, user.FullName, uuid)
+	query := `UPDATE users SET username = $1, email = $2, full_name = $3,
+			email_verified_at = CASE WHEN email = $2 THEN email_verified_at END
+		WHERE uuid = $4 RETURNING updated_at, email_verified_at`
+	var emailVerifiedAt sql.NullTime
+	err := userRepository.db.QueryRowContext(context.Background(), query, user.Username, user.Email, user.FullName, uuid).
+		Scan(&user.UpdatedAt, &emailVerifiedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	user.UpdatedAt = user.UpdatedAt.UTC()
+	user.EmailVerifiedAt = utcTime(emailVerifiedAt)
+	return userRepository.translateError(err)
+}
+
+// MarkEmailVerified records that the user verified email, unless it is no
+// longer their email.
+func (userRepository *userRepository) MarkEmailVerified(uuid string, email string) (bool, error) {
+	query := `UPDATE users SET email_verified_at = now() WHERE uuid = $1 AND email = $2`
+	result, err := userRepository.db.ExecContext(context.Background(), query, uuid, email)
+	if err != nil {
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	return updated > 0, err
+}
+
+func (userRepository *userRepository) UpdateStatus(uuid string, user *model.User) error {
+	query := `UPDATE users SET status = $1 WHERE uuid = $2 RETURNING updated_at`
+	err := userRepository.db.QueryRowContext(context.Background(), query, string(user.Status), uuid).Scan(&user.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	user.UpdatedAt = user.UpdatedAt.UTC()
+	return err
+}
+
+// GetPasswordHash returns "" for unknown users and users without a password.
+func (userRepository *userRepository) GetPasswordHash(uuid string) (string, error) {
+	var hash sql.NullString
+	query := `SELECT password_hash FROM users WHERE uuid = $1`
+	err := userRepository.db.QueryRowContext(context.Background(), query, uuid).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return hash.String, err
+}
+
+func (userRepository *userRepository) UpdatePasswordHash(uuid string, hash string) error {
+	query := `UPDATE users SET password_hash = $1 WHERE uuid = $2`
+	_, err := userRepository.db.ExecContext(context.Background(), query, nullString(hash), uuid)
 	return err
 }
 
@@ -94,3 +384,40 @@ func (userRepository *userRepository) Delete(uuid string) error {
 	_, err := userRepository.db.ExecContext(context.Background(), query, uuid)
 	return err
 }
+
+func (userRepository *userRepository) Search(query model.SearchQuery) ([]model.SearchResult, int, error) {
+	pattern := "%" + likeEscaper.Replace(query.Query) + "%"
+	rows, err := userRepository.db.QueryContext(context.Background(), searchUsersQuery, query.Query, pattern, query.Limit, query.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var results []model.SearchResult
+	total := 0
+	for rows.Next() {
+		var result model.SearchResult
+		if err := userRepository.scanUser(rows, &result.User, &result.Score, &total); err != nil {
+			return nil, 0, err
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	total, err = pageTotal(userRepository.db, total, len(results), query.Offset, countSearchUsersQuery, query.Query, pattern)
+	return results, total, err
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func utcTime(value sql.NullTime) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	utc := value.Time.UTC()
+	return &utc
+}
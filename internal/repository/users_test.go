@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"cruder/internal/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldEscapeWildcardsInFullNameFilter(test *testing.T) {
+	// given
+	repository := &userRepository{}
+
+	// when
+	where, args := repository.buildWhereClause(model.UserFilter{FullName: `50%_off\`})
+
+	// then
+	assert.Equal(test, " WHERE full_name ILIKE '%' || $1 || '%'", where)
+	assert.Equal(test, []any{`50\%\_off\\`}, args)
+}
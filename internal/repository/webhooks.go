@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/model"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// WebhookRepository manages webhooks and their deliveries. Webhooks take
+// their tenant from the transaction, so manage them within a tenant-scoped
+// transaction; the sink and dispatcher use it unscoped across tenants.
+type WebhookRepository interface {
+	GetAll() ([]model.Webhook, error)
+	GetByID(id int64) (*model.Webhook, error)
+	// GetActiveForEvent returns the active webhooks of tenantID subscribed
+	// to eventType.
+	GetActiveForEvent(tenantID string, eventType model.EventType) ([]model.Webhook, error)
+	Create(webhook *model.Webhook) error
+	UpdateHealth(id int64, consecutiveFailures int, active bool) error
+	Delete(id int64) error
+	// CreateDelivery does nothing if the webhook already has a delivery of
+	// the same outbox message, leaving delivery.ID 0. Redeliveries have no
+	// OutboxID.
+	CreateDelivery(delivery *model.WebhookDelivery) error
+	UpdateDelivery(delivery *model.WebhookDelivery) error
+	GetDelivery(id int64) (*model.WebhookDelivery, error)
+	GetDeliveries(webhookID int64, limit int) ([]model.WebhookDelivery, error)
+	// FailPendingDeliveries gives up on the pending deliveries of a webhook
+	// that was disabled. They can still be redelivered.
+	FailPendingDeliveries(webhookID int64, reason string) error
+	// ClaimDueDeliveries returns up to limit pending deliveries of active
+	// webhooks due at now and postpones them to leaseUntil, so other
+	// replicas skip them while they are sent. A claim left by a crashed
+	// replica expires at leaseUntil.
+	ClaimDueDeliveries(now, leaseUntil time.Time, limit int) ([]model.WebhookDelivery, error)
+}
+
+type webhookRepository struct {
+	db executor
+}
+
+const (
+	selectWebhookColumns = "SELECT id, tenant_id, url, events, secret, active, consecutive_failures, created_at FROM webhooks"
+	deliveryColumns      = `d.id, d.webhook_id, d.tenant_id, d.event_type, d.payload, d.status, d.attempts, COALESCE(d.response_status, 0),
+		COALESCE(d.request_snippet, ''), COALESCE(d.response_snippet, ''), COALESCE(d.last_error, ''), d.next_attempt_at, d.created_at`
+	selectDeliveryColumns = "SELECT " + deliveryColumns + " FROM webhook_deliveries d"
+)
+
+func NewWebhookRepository(db executor) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func (webhookRepository *webhookRepository) scanWebhook(row rowScanner) (*model.Webhook, error) {
+	var webhook model.Webhook
+	err := row.Scan(&webhook.ID, &webhook.TenantID, &webhook.URL, pq.Array(&webhook.Events), &webhook.Secret, &webhook.Active,
+		&webhook.ConsecutiveFailures, &webhook.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (webhookRepository *webhookRepository) scanDelivery(row rowScanner) (*model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	err := row.Scan(&delivery.ID, &delivery.WebhookID, &delivery.TenantID, &delivery.EventType, &delivery.Payload, &delivery.Status,
+		&delivery.Attempts, &delivery.ResponseStatus, &delivery.RequestSnippet, &delivery.ResponseSnippet,
+		&delivery.LastError, &delivery.NextAttemptAt, &delivery.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (webhookRepository *webhookRepository) queryWebhooks(query string, args ...any) ([]model.Webhook, error) {
+	rows, err := webhookRepository.db.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []model.Webhook
+	for rows.Next() {
+		webhook, err := webhookRepository.scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (webhookRepository *webhookRepository) queryDeliveries(query string, args ...any) ([]model.WebhookDelivery, error) {
+	rows, err := webhookRepository.db.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		delivery, err := webhookRepository.scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (webhookRepository *webhookRepository) GetAll() ([]model.Webhook, error) {
+	return webhookRepository.queryWebhooks(selectWebhookColumns + " ORDER BY id")
+}
+
+func (webhookRepository *webhookRepository) GetByID(id int64) (*model.Webhook, error) {
+	row := webhookRepository.db.QueryRowContext(context.Background(), selectWebhookColumns+" WHERE id = $1", id)
+	webhook, err := webhookRepository.scanWebhook(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return webhook, err
+}
+
+func (webhookRepository *webhookRepository) GetActiveForEvent(tenantID string, eventType model.EventType) ([]model.Webhook, error) {
+	query := selectWebhookColumns + " WHERE tenant_id = $1 AND active AND ($2 = ANY(events) OR $3 = ANY(events)) ORDER BY id"
+	return webhookRepository.queryWebhooks(query, tenantID, string(eventType), model.WebhookEventWildcard)
+}
+
+func (webhookRepository *webhookRepository) Create(webhook *model.Webhook) error {
+	query := `INSERT INTO webhooks (url, events, secret, active) VALUES ($1, $2, $3, $4) RETURNING id, tenant_id, created_at`
+	return webhookRepository.db.QueryRowContext(context.Background(), query, webhook.URL, pq.Array(webhook.Events), webhook.Secret, webhook.Active).
+		Scan(&webhook.ID, &webhook.TenantID, &webhook.CreatedAt)
+}
+
+func (webhookRepository *webhookRepository) UpdateHealth(id int64, consecutiveFailures int, active bool) error {
+	query := `UPDATE webhooks SET consecutive_failures = $1, active = $2 WHERE id = $3`
+	_, err := webhookRepository.db.ExecContext(context.Background(), query, consecutiveFailures, active, id)
+	return err
+}
+
+func (webhookRepository *webhookRepository) Delete(id int64) error {
+	query := `DELETE FROM webhooks WHERE id = $1`
+	_, err := webhookRepository.db.ExecContext(context.Background(), query, id)
+	return err
+}
+
+func (webhookRepository *webhookRepository) CreateDelivery(delivery *model.WebhookDelivery) error {
+	query := `INSERT INTO webhook_deliveries (webhook_id, tenant_id, outbox_id, event_type, payload, status, next_attempt_at)
+		VALUES ($1, $2, NULLIF($3, 0), $4, $5, $6, $7)
+		ON CONFLICT (webhook_id, outbox_id) DO NOTHING RETURNING id, created_at`
+	err := webhookRepository.db.QueryRowContext(context.Background(), query, delivery.WebhookID, delivery.TenantID, delivery.OutboxID,
+		string(delivery.EventType), delivery.Payload, string(delivery.Status), delivery.NextAttemptAt).Scan(&delivery.ID, &delivery.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	return err
+}
+
+func (webhookRepository *webhookRepository) UpdateDelivery(delivery *model.WebhookDelivery) error {
+	query := `UPDATE webhook_deliveries SET status = $1, attempts = $2, response_status = $3, request_snippet = $4,
+		response_snippet = $5, last_error = $6, next_attempt_at = $7 WHERE id = $8`
+	_, err := webhookRepository.db.ExecContext(context.Background(), query, string(delivery.Status), delivery.Attempts,
+		delivery.ResponseStatus, delivery.RequestSnippet, delivery.ResponseSnippet, delivery.LastError,
+		delivery.NextAttemptAt, delivery.ID)
+	return err
+}
+
+func (webhookRepository *webhookRepository) GetDelivery(id int64) (*model.WebhookDelivery, error) {
+	row := webhookRepository.db.QueryRowContext(context.Background(), selectDeliveryColumns+" WHERE d.id = $1", id)
+	delivery, err := webhookRepository.scanDelivery(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return delivery, err
+}
+
+func (webhookRepository *webhookRepository) GetDeliveries(webhookID int64, limit int) ([]model.WebhookDelivery, error) {
+	query := selectDeliveryColumns + " WHERE d.webhook_id = $1 ORDER BY d.id DESC LIMIT $2"
+	return webhookRepository.queryDeliveries(query, webhookID, limit)
+}
+
+func (webhookRepository *webhookRepository) FailPendingDeliveries(webhookID int64, reason string) error {
+	query := `UPDATE webhook_deliveries SET status = $1, last_error = $2 WHERE webhook_id = $3 AND status = $4`
+	_, err := webhookRepository.db.ExecContext(context.Background(), query, string(model.DeliveryStatusFailed), reason,
+		webhookID, string(model.DeliveryStatusPending))
+	return err
+}
+
+func (webhookRepository *webhookRepository) ClaimDueDeliveries(now, leaseUntil time.Time, limit int) ([]model.WebhookDelivery, error) {
+	query := `WITH due AS (
+			SELECT d.id FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+			WHERE d.status = $1 AND d.next_attempt_at <= $2 AND w.active ORDER BY d.id LIMIT $3
+			FOR UPDATE OF d SKIP LOCKED
+		), claimed AS (
+			UPDATE webhook_deliveries SET next_attempt_at = $4 FROM due WHERE webhook_deliveries.id = due.id
+			RETURNING webhook_deliveries.*
+		)
+		SELECT ` + deliveryColumns + ` FROM claimed d ORDER BY d.id`
+	return webhookRepository.queryDeliveries(query, string(model.DeliveryStatusPending), now, limit, leaseUntil)
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"cruder/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldNeverShareWebhooksAcrossTenants(test *testing.T) {
+	// given
+	repos, tenantA, tenantB := setupTenantTest(test)
+	webhook := &model.Webhook{URL: "https://a.example.com/hook", Events: []string{"*"}, Secret: "s3cret", Active: true}
+	require.NoError(test, repos.ForTenant(tenantA.ID).WithinTransaction(func(scoped *Repository) error {
+		return scoped.Webhooks.Create(webhook)
+	}))
+	var byID *model.Webhook
+	var all []model.Webhook
+
+	// when
+	readErr := repos.ForTenant(tenantB.ID).WithinTransaction(func(scoped *Repository) error {
+		var err error
+		if byID, err = scoped.Webhooks.GetByID(webhook.ID); err != nil {
+			return err
+		}
+		all, err = scoped.Webhooks.GetAll()
+		return err
+	})
+	activeA, activeAErr := repos.Webhooks.GetActiveForEvent(tenantA.ID, model.EventUserCreated)
+	activeB, activeBErr := repos.Webhooks.GetActiveForEvent(tenantB.ID, model.EventUserCreated)
+	deliveryErr := repos.ForTenant(tenantB.ID).WithinTransaction(func(scoped *Repository) error {
+		return scoped.Webhooks.CreateDelivery(&model.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			TenantID:      tenantA.ID,
+			EventType:     model.EventUserCreated,
+			Payload:       []byte(`{}`),
+			Status:        model.DeliveryStatusPending,
+			NextAttemptAt: time.Now(),
+		})
+	})
+
+	// then
+	assert.Equal(test, tenantA.ID, webhook.TenantID)
+	assert.NoError(test, readErr)
+	assert.Nil(test, byID)
+	assert.Empty(test, all)
+	assert.NoError(test, activeAErr)
+	assert.Len(test, activeA, 1)
+	assert.NoError(test, activeBErr)
+	assert.Empty(test, activeB)
+	assert.ErrorContains(test, deliveryErr, "row-level security")
+}
+
+func TestShouldClaimDueDeliveryOnceUntilLeaseExpires(test *testing.T) {
+	// given
+	repos, tenantA, _ := setupTenantTest(test)
+	webhook := &model.Webhook{URL: "https://a.example.com/hook", Events: []string{"*"}, Secret: "s3cret", Active: true}
+	require.NoError(test, repos.ForTenant(tenantA.ID).WithinTransaction(func(scoped *Repository) error {
+		return scoped.Webhooks.Create(webhook)
+	}))
+	now := time.Now()
+	delivery := &model.WebhookDelivery{
+		WebhookID:     webhook.ID,
+		TenantID:      tenantA.ID,
+		EventType:     model.EventUserCreated,
+		Payload:       []byte(`{}`),
+		Status:        model.DeliveryStatusPending,
+		NextAttemptAt: now.Add(-time.Minute),
+	}
+	require.NoError(test, repos.Webhooks.CreateDelivery(delivery))
+	claimedIDs := func(deliveries []model.WebhookDelivery) []int64 {
+		var ids []int64
+		for _, claimed := range deliveries {
+			if claimed.WebhookID == webhook.ID {
+				ids = append(ids, claimed.ID)
+			}
+		}
+		return ids
+	}
+
+	// when
+	first, firstErr := repos.Webhooks.ClaimDueDeliveries(now, now.Add(time.Minute), 1000)
+	second, secondErr := repos.Webhooks.ClaimDueDeliveries(now, now.Add(time.Minute), 1000)
+	expired, expiredErr := repos.Webhooks.ClaimDueDeliveries(now.Add(2*time.Minute), now.Add(3*time.Minute), 1000)
+
+	// then
+	assert.NoError(test, firstErr)
+	assert.NoError(test, secondErr)
+	assert.NoError(test, expiredErr)
+	assert.Equal(test, []int64{delivery.ID}, claimedIDs(first))
+	assert.Empty(test, claimedIDs(second))
+	assert.Equal(test, []int64{delivery.ID}, claimedIDs(expired))
+}
+
+func TestShouldCreateOneDeliveryPerOutboxMessage(test *testing.T) {
+	// given
+	repos, tenantA, _ := setupTenantTest(test)
+	webhook := &model.Webhook{URL: "https://a.example.com/hook", Events: []string{"*"}, Secret: "s3cret", Active: true}
+	require.NoError(test, repos.ForTenant(tenantA.ID).WithinTransaction(func(scoped *Repository) error {
+		return scoped.Webhooks.Create(webhook)
+	}))
+	newDelivery := func(outboxID int64) *model.WebhookDelivery {
+		return &model.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			TenantID:      tenantA.ID,
+			OutboxID:      outboxID,
+			EventType:     model.EventUserCreated,
+			Payload:       []byte(`{}`),
+			Status:        model.DeliveryStatusPending,
+			NextAttemptAt: time.Now(),
+		}
+	}
+	first, again, redelivery := newDelivery(42), newDelivery(42), newDelivery(0)
+
+	// when
+	firstErr := repos.Webhooks.CreateDelivery(first)
+	againErr := repos.Webhooks.CreateDelivery(again)
+	redeliveryErr := repos.Webhooks.CreateDelivery(redelivery)
+	deliveries, listErr := repos.Webhooks.GetDeliveries(webhook.ID, 10)
+
+	// then
+	assert.NoError(test, firstErr)
+	assert.NoError(test, againErr)
+	assert.NoError(test, redeliveryErr)
+	assert.NoError(test, listErr)
+	assert.NotZero(test, first.ID)
+	assert.Zero(test, again.ID)
+	assert.NotZero(test, redelivery.ID)
+	assert.Len(test, deliveries, 2)
+}
@@ -0,0 +1,147 @@
+// Package search holds the matching rules shared by every user search
+// implementation: pg_trgm compatible trigram similarity for repositories
+// without Postgres, and highlighting of matched fragments.
+package search
+
+import (
+	"cruder/internal/model"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Threshold is the minimum score for a match, mirroring pg_trgm's default
+// similarity threshold.
+const Threshold = 0.3
+
+const (
+	markStart = "<mark>"
+	markEnd   = "</mark>"
+)
+
+// Trigrams splits text into words of letters and digits and returns the set
+// of lower-cased trigrams of each word padded with two leading spaces and one
+// trailing space, as pg_trgm does.
+func Trigrams(text string) map[string]struct{} {
+	trigrams := make(map[string]struct{})
+	for _, word := range words(text) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			trigrams[string(padded[i:i+3])] = struct{}{}
+		}
+	}
+	return trigrams
+}
+
+// Similarity is the Jaccard index of the trigram sets of a and b.
+func Similarity(a, b string) float64 {
+	left, right := Trigrams(a), Trigrams(b)
+	if len(left) == 0 || len(right) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for trigram := range left {
+		if _, ok := right[trigram]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(left)+len(right)-shared)
+}
+
+// Score rates how well query matches the best of fields: the highest trigram
+// similarity against a whole field or any of its words, plus one when the
+// query appears verbatim so exact substrings outrank typo matches.
+func Score(query string, fields ...string) float64 {
+	query = strings.ToLower(strings.TrimSpace(query))
+	best := 0.0
+	for _, field := range fields {
+		lowered := strings.ToLower(field)
+		score := Similarity(query, lowered)
+		for _, word := range words(lowered) {
+			score = max(score, Similarity(query, word))
+		}
+		if query != "" && strings.Contains(lowered, query) {
+			score++
+		}
+		best = max(best, score)
+	}
+	return best
+}
+
+// Highlight HTML-escapes text and wraps every case-insensitive occurrence of
+// a query term in <mark> tags. The second result reports whether anything
+// was marked.
+func Highlight(text, query string) (string, bool) {
+	terms := words(query)
+	if len(terms) == 0 || text == "" {
+		return html.EscapeString(text), false
+	}
+
+	runes := []rune(text)
+	lowered := []rune(strings.ToLower(text))
+	if len(lowered) != len(runes) {
+		return html.EscapeString(text), false
+	}
+
+	marked := make([]bool, len(runes))
+	for _, term := range terms {
+		needle := []rune(term)
+		for start := 0; start+len(needle) <= len(lowered); start++ {
+			if string(lowered[start:start+len(needle)]) == term {
+				for i := start; i < start+len(needle); i++ {
+					marked[i] = true
+				}
+			}
+		}
+	}
+
+	var builder strings.Builder
+	found := false
+	for i := 0; i < len(runes); {
+		j := i
+		for j < len(runes) && marked[j] == marked[i] {
+			j++
+		}
+		fragment := html.EscapeString(string(runes[i:j]))
+		if marked[i] {
+			found = true
+			builder.WriteString(markStart + fragment + markEnd)
+		} else {
+			builder.WriteString(fragment)
+		}
+		i = j
+	}
+	return builder.String(), found
+}
+
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Match is the portable equivalent of the Postgres search query for user
+// repositories without pg_trgm. It scans every user from stream, keeps those
+// scoring at least Threshold and returns the requested page ordered by score
+// together with the total number of matches.
+func Match(query model.SearchQuery, stream func(fn func(user *model.User) error) error) ([]model.SearchResult, int, error) {
+	var results []model.SearchResult
+	err := stream(func(user *model.User) error {
+		score := Score(query.Query, user.Username, user.Email, user.FullName)
+		if score >= Threshold {
+			results = append(results, model.SearchResult{User: *user, Score: score})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	total := len(results)
+	start := min(max(query.Offset, 0), total)
+	end := min(start+query.Limit, total)
+	return results[start:end], total, nil
+}
@@ -0,0 +1,72 @@
+package search
+
+import (
+	"cruder/internal/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldComputeTrigramSimilarityLikePgTrgm(test *testing.T) {
+	// when
+	identical := Similarity("word", "WORD")
+	typo := Similarity("jonh", "john")
+	unrelated := Similarity("alice", "bob")
+
+	// then
+	assert.Equal(test, 1.0, identical)
+	assert.Equal(test, 0.25, typo)
+	assert.Equal(test, 0.0, unrelated)
+	assert.Len(test, Trigrams("cat"), 4)
+}
+
+func TestShouldScoreSubstringAboveTypoMatch(test *testing.T) {
+	// when
+	substring := Score("smi", "asmith", "asmith@example.com", "Alice Smith")
+	typo := Score("smiht", "asmith", "asmith@example.com", "Alice Smith")
+	miss := Score("zzz", "asmith", "asmith@example.com", "Alice Smith")
+
+	// then
+	assert.Greater(test, substring, 1.0)
+	assert.GreaterOrEqual(test, typo, Threshold)
+	assert.Less(test, typo, 1.0)
+	assert.Less(test, miss, Threshold)
+}
+
+func TestShouldHighlightEscapedFragments(test *testing.T) {
+	// when
+	highlighted, found := Highlight("<b>John</b> Johnson", "john")
+	_, missing := Highlight("Alice", "john")
+
+	// then
+	assert.True(test, found)
+	assert.Equal(test, "&lt;b&gt;<mark>John</mark>&lt;/b&gt; <mark>John</mark>son", highlighted)
+	assert.False(test, missing)
+}
+
+func TestShouldMatchAndPaginateUsersByScore(test *testing.T) {
+	// given
+	users := []model.User{
+		{ID: 1, Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"},
+		{ID: 2, Username: "asmith", Email: "asmith@example.com", FullName: "Alice Smith"},
+		{ID: 3, Username: "jsmith", Email: "js@example.com", FullName: "Jon Smyth"},
+	}
+	stream := func(fn func(user *model.User) error) error {
+		for i := range users {
+			if err := fn(&users[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// when
+	firstPage, total, err := Match(model.SearchQuery{Query: "smith", Limit: 1}, stream)
+	secondPage, _, _ := Match(model.SearchQuery{Query: "smith", Limit: 1, Offset: 1}, stream)
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, 2, total)
+	assert.Equal(test, 2, firstPage[0].User.ID)
+	assert.Equal(test, 3, secondPage[0].User.ID)
+}
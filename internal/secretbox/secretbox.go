@@ -0,0 +1,62 @@
+// Package secretbox encrypts small secrets for storage with AES-256-GCM.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+const KeySize = 32
+
+var ErrDecrypt = errors.New("failed to decrypt secret")
+
+type Box struct {
+	aead cipher.AEAD
+}
+
+func New(key []byte) (*Box, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// ParseKey decodes a base64 key, e.g. from `openssl rand -base64 32`.
+func ParseKey(encoded string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+// Seal encrypts plaintext and binds it to associatedData, which Open must be
+// given again. Binding a secret to its owner stops it from being copied to
+// another row.
+func (box *Box) Seal(plaintext, associatedData []byte) ([]byte, error) {
+	nonce := make([]byte, box.aead.NonceSize(), box.aead.NonceSize()+len(plaintext)+box.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return box.aead.Seal(nonce, nonce, plaintext, associatedData), nil
+}
+
+func (box *Box) Open(sealed, associatedData []byte) ([]byte, error) {
+	if len(sealed) < box.aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, ciphertext := sealed[:box.aead.NonceSize()], sealed[box.aead.NonceSize():]
+	plaintext, err := box.aead.Open(nil, nonce, ciphertext, associatedData)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,46 @@
+package secretbox
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldOpenWhatItSealed(test *testing.T) {
+	// given
+	box, err := New(bytes.Repeat([]byte{7}, KeySize))
+	require.NoError(test, err)
+
+	// when
+	sealed, sealErr := box.Seal([]byte("totp secret"), []byte("user-1"))
+	opened, openErr := box.Open(sealed, []byte("user-1"))
+	_, otherUserErr := box.Open(sealed, []byte("user-2"))
+	again, _ := box.Seal([]byte("totp secret"), []byte("user-1"))
+
+	// then
+	assert.NoError(test, sealErr)
+	assert.NoError(test, openErr)
+	assert.Equal(test, []byte("totp secret"), opened)
+	assert.NotContains(test, string(sealed), "totp secret")
+	assert.ErrorIs(test, otherUserErr, ErrDecrypt)
+	assert.NotEqual(test, sealed, again, "nonces differ")
+}
+
+func TestShouldRejectWrongKeyAndShortKeys(test *testing.T) {
+	// given
+	box, _ := New(bytes.Repeat([]byte{7}, KeySize))
+	other, _ := New(bytes.Repeat([]byte{8}, KeySize))
+	sealed, _ := box.Seal([]byte("totp secret"), nil)
+
+	// when
+	_, wrongKeyErr := other.Open(sealed, nil)
+	_, truncatedErr := box.Open(sealed[:4], nil)
+	_, shortKeyErr := New([]byte("short"))
+
+	// then
+	assert.ErrorIs(test, wrongKeyErr, ErrDecrypt)
+	assert.ErrorIs(test, truncatedErr, ErrDecrypt)
+	assert.Error(test, shortKeyErr)
+}
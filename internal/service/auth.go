@@ -0,0 +1,142 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/password"
+	"cruder/internal/repository"
+	"errors"
+	"log"
+	"net/mail"
+	"time"
+)
+
+type AuthService interface {
+	// Login is throttled per account and per ipAddress, see
+	// LoginThrottlePolicy. Throttled attempts fail with a
+	// LoginThrottledError before the password is checked.
+	Login(request *model.LoginRequest, ipAddress string) (*model.User, error)
+	ChangePassword(uuid string, request *model.ChangePasswordRequest) error
+	// Unlock lifts a lockout of the user's account and forgets its failed
+	// logins.
+	Unlock(uuid, actor string) error
+}
+
+type authService struct {
+	userRepository repository.UserRepository
+	transactor     repository.Transactor
+	config         Config
+	passwords      *password.Hasher
+	now            func() time.Time
+	// tenantID scopes the throttles of unknown logins, which belong to no user.
+	tenantID string
+}
+
+func NewAuthService(userRepository repository.UserRepository, transactor repository.Transactor, config Config) AuthService {
+	return newAuthService("", userRepository, transactor, config)
+}
+
+func newAuthService(tenantID string, userRepository repository.UserRepository, transactor repository.Transactor, config Config) *authService {
+	return &authService{
+		tenantID:       tenantID,
+		userRepository: userRepository,
+		transactor:     transactor,
+		config:         config,
+		passwords:      password.NewHasher(config.PasswordParams, config.PasswordPolicy),
+		now:            time.Now,
+	}
+}
+
+// Login accepts a username or an email address. Unknown users, users without
+// a password and wrong passwords all fail with ErrInvalidCredentials after
+// the same amount of hashing work, so responses do not reveal which it was.
+// Unknown logins are throttled like accounts for the same reason.
+func (authService *authService) Login(request *model.LoginRequest, ipAddress string) (*model.User, error) {
+	user, err := authService.findByLogin(request.Login)
+	if err != nil {
+		return nil, err
+	}
+
+	attempt := &loginAttempt{user: user, accountKey: accountThrottleKey(authService.tenantID, user, request.Login), ipAddress: ipAddress}
+	if err := authService.reserveAttempt(attempt); err != nil {
+		return nil, err
+	}
+	if err := authService.verify(user, request.Password); err != nil {
+		var settleErr error
+		if errors.Is(err, model.ErrInvalidCredentials) {
+			settleErr = authService.recordFailure(attempt)
+		} else {
+			settleErr = authService.releaseAttempt(attempt)
+		}
+		if settleErr != nil {
+			return nil, settleErr
+		}
+		return nil, err
+	}
+	if err := authService.resetThrottle(attempt); err != nil {
+		return nil, err
+	}
+	if user.Status == model.UserStatusSuspended || user.Status == model.UserStatusDeactivated {
+		return nil, model.ErrAccountDisabled
+	}
+	return user, nil
+}
+
+func (authService *authService) ChangePassword(uuid string, request *model.ChangePasswordRequest) error {
+	user, err := existingUser(authService.userRepository.GetByUUID(uuid))
+	if err != nil {
+		return err
+	}
+
+	if err := authService.verify(user, request.OldPassword); err != nil {
+		return err
+	}
+	if err := authService.passwords.Validate(request.NewPassword); err != nil {
+		return err
+	}
+
+	hash, err := authService.passwords.Hash(request.NewPassword)
+	if err != nil {
+		return err
+	}
+	return authService.userRepository.UpdatePasswordHash(uuid, hash)
+}
+
+func (authService *authService) findByLogin(login string) (*model.User, error) {
+	if _, err := mail.ParseAddress(login); err == nil {
+		return authService.userRepository.GetByEmail(login)
+	}
+	return authService.userRepository.GetByUsername(login)
+}
+
+// verify checks candidate against the user's stored hash and upgrades the
+// hash when it was made with outdated parameters. user may be nil.
+func (authService *authService) verify(user *model.User, candidate string) error {
+	hash := ""
+	if user != nil {
+		var err error
+		if hash, err = authService.userRepository.GetPasswordHash(user.UUID); err != nil {
+			return err
+		}
+	}
+	if hash == "" {
+		authService.passwords.VerifyDummy(candidate)
+		return model.ErrInvalidCredentials
+	}
+
+	match, rehash, err := authService.passwords.Verify(candidate, hash)
+	if err != nil {
+		return err
+	}
+	if !match {
+		return model.ErrInvalidCredentials
+	}
+
+	if rehash {
+		if upgraded, err := authService.passwords.Hash(candidate); err == nil {
+			if err := authService.userRepository.UpdatePasswordHash(user.UUID, upgraded); err != nil {
+				log.Printf("failed to upgrade password hash for user %s: %v", user.UUID, err)
+			}
+		}
+	}
+	return nil
+}
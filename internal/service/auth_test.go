@@ -0,0 +1,123 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/password"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPasswordConfig() Config {
+	config := DefaultConfig()
+	config.PasswordParams = password.Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	return config
+}
+
+func setupAuthTest() (*mockUserRepository, UserService, AuthService) {
+	mockRepo := newMockUserRepository()
+	config := testPasswordConfig()
+	transactor := &mockTransactor{repos: newMockRepos(mockRepo, &mockOutboxRepository{}), users: mockRepo}
+	return mockRepo, NewUserService(mockRepo, transactor, config), NewAuthService(mockRepo, transactor, config)
+}
+
+func TestShouldLoginWithUsernameOrEmail(test *testing.T) {
+	// given
+	mockRepo, userService, authService := setupAuthTest()
+	created, err := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", Password: "correct horse battery"})
+
+	// when
+	byUsername, usernameErr := authService.Login(&model.LoginRequest{Login: "jdoe", Password: "correct horse battery"}, "")
+	byEmail, emailErr := authService.Login(&model.LoginRequest{Login: "jdoe@example.com", Password: "correct horse battery"}, "")
+
+	// then
+	assert.NoError(test, err)
+	assert.NoError(test, usernameErr)
+	assert.NoError(test, emailErr)
+	assert.Equal(test, created.UUID, byUsername.UUID)
+	assert.Equal(test, created.UUID, byEmail.UUID)
+	assert.Contains(test, mockRepo.passwordHashes[created.UUID], "$argon2id$")
+}
+
+func TestShouldRejectInvalidCredentialsUniformly(test *testing.T) {
+	// given
+	_, userService, authService := setupAuthTest()
+	_, _ = userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", Password: "correct horse battery"})
+	_, _ = userService.CreateUser(&model.CreateUserRequest{Username: "nopass", Email: "nopass@example.com"})
+
+	// when
+	_, wrongPassword := authService.Login(&model.LoginRequest{Login: "jdoe", Password: "wrong horse battery"}, "")
+	_, unknownUser := authService.Login(&model.LoginRequest{Login: "ghost", Password: "correct horse battery"}, "")
+	_, noPassword := authService.Login(&model.LoginRequest{Login: "nopass", Password: "correct horse battery"}, "")
+
+	// then
+	assert.ErrorIs(test, wrongPassword, model.ErrInvalidCredentials)
+	assert.ErrorIs(test, unknownUser, model.ErrInvalidCredentials)
+	assert.ErrorIs(test, noPassword, model.ErrInvalidCredentials)
+}
+
+func TestShouldRejectLoginForSuspendedUser(test *testing.T) {
+	// given
+	_, userService, authService := setupAuthTest()
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", Password: "correct horse battery"})
+	_, _ = userService.TransitionUserStatus(user.UUID, model.UserStatusActive, "verified", "admin")
+	_, _ = userService.TransitionUserStatus(user.UUID, model.UserStatusSuspended, "abuse", "admin")
+
+	// when
+	result, err := authService.Login(&model.LoginRequest{Login: "jdoe", Password: "correct horse battery"}, "")
+
+	// then
+	assert.ErrorIs(test, err, model.ErrAccountDisabled)
+	assert.Nil(test, result)
+}
+
+func TestShouldRehashPasswordOnLoginWhenParamsChange(test *testing.T) {
+	// given
+	mockRepo, userService, _ := setupAuthTest()
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", Password: "correct horse battery"})
+	oldHash := mockRepo.passwordHashes[user.UUID]
+	config := testPasswordConfig()
+	config.PasswordParams.Iterations = 2
+
+	// when
+	transactor := &mockTransactor{repos: newMockRepos(mockRepo, &mockOutboxRepository{}), users: mockRepo}
+	_, err := NewAuthService(mockRepo, transactor, config).Login(&model.LoginRequest{Login: "jdoe", Password: "correct horse battery"}, "")
+
+	// then
+	assert.NoError(test, err)
+	assert.NotEqual(test, oldHash, mockRepo.passwordHashes[user.UUID])
+	assert.Contains(test, mockRepo.passwordHashes[user.UUID], "m=1024,t=2,p=1")
+}
+
+func TestShouldChangePasswordWithOldPassword(test *testing.T) {
+	// given
+	_, userService, authService := setupAuthTest()
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", Password: "correct horse battery"})
+
+	// when
+	wrongOld := authService.ChangePassword(user.UUID, &model.ChangePasswordRequest{OldPassword: "nope", NewPassword: "a brand new passphrase"})
+	weakNew := authService.ChangePassword(user.UUID, &model.ChangePasswordRequest{OldPassword: "correct horse battery", NewPassword: "short"})
+	changed := authService.ChangePassword(user.UUID, &model.ChangePasswordRequest{OldPassword: "correct horse battery", NewPassword: "a brand new passphrase"})
+	_, oldLogin := authService.Login(&model.LoginRequest{Login: "jdoe", Password: "correct horse battery"}, "")
+	_, newLogin := authService.Login(&model.LoginRequest{Login: "jdoe", Password: "a brand new passphrase"}, "")
+
+	// then
+	assert.ErrorIs(test, wrongOld, model.ErrInvalidCredentials)
+	assert.ErrorIs(test, weakNew, model.ErrWeakPassword)
+	assert.NoError(test, changed)
+	assert.ErrorIs(test, oldLogin, model.ErrInvalidCredentials)
+	assert.NoError(test, newLogin)
+}
+
+func TestShouldRejectWeakPasswordOnCreate(test *testing.T) {
+	// given
+	mockRepo, userService, _ := setupAuthTest()
+
+	// when
+	result, err := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", Password: "short"})
+
+	// then
+	assert.ErrorIs(test, err, model.ErrWeakPassword)
+	assert.Nil(test, result)
+	assert.Empty(test, mockRepo.users)
+}
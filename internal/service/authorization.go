@@ -0,0 +1,505 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"errors"
+)
+
+// authorizedUserService enforces the role policy for one principal in front
+// of a UserService.
+type authorizedUserService struct {
+	UserService
+	principal *model.Principal
+}
+
+// AuthorizeUsers restricts users to what principal is allowed to do.
+func AuthorizeUsers(users UserService, principal *model.Principal) UserService {
+	return &authorizedUserService{UserService: users, principal: principal}
+}
+
+func (authorizedUserService *authorizedUserService) authorize(permission model.Permission, targetUUID string) error {
+	return Authorize(authorizedUserService.principal, permission, targetUUID)
+}
+
+func (authorizedUserService *authorizedUserService) GetAllUsers(filter model.UserFilter) ([]model.User, error) {
+	if err := authorizedUserService.authorize(model.PermissionReadUsers, ""); err != nil {
+		return nil, err
+	}
+	return authorizedUserService.UserService.GetAllUsers(filter)
+}
+
+func (authorizedUserService *authorizedUserService) GetUserPage(filter model.UserFilter, offset, limit int) ([]model.User, int, error) {
+	if err := authorizedUserService.authorize(model.PermissionReadUsers, ""); err != nil {
+		return nil, 0, err
+	}
+	return authorizedUserService.UserService.GetUserPage(filter, offset, limit)
+}
+
+func (authorizedUserService *authorizedUserService) ExportUsers(filter model.UserFilter, fn func(user *model.User) error) error {
+	if err := authorizedUserService.authorize(model.PermissionReadUsers, ""); err != nil {
+		return err
+	}
+	return authorizedUserService.UserService.ExportUsers(filter, fn)
+}
+
+func (authorizedUserService *authorizedUserService) SearchUsers(query model.SearchQuery) (*model.SearchPage, error) {
+	if err := authorizedUserService.authorize(model.PermissionReadUsers, ""); err != nil {
+		return nil, err
+	}
+	return authorizedUserService.UserService.SearchUsers(query)
+}
+
+func (authorizedUserService *authorizedUserService) GetUserByUsername(username string) (*model.User, error) {
+	return authorizedUserService.readOne(func() (*model.User, error) {
+		return authorizedUserService.UserService.GetUserByUsername(username)
+	})
+}
+
+func (authorizedUserService *authorizedUserService) GetUserByID(id int64) (*model.User, error) {
+	return authorizedUserService.readOne(func() (*model.User, error) {
+		return authorizedUserService.UserService.GetUserByID(id)
+	})
+}
+
+func (authorizedUserService *authorizedUserService) GetUserByUUID(uuid string) (*model.User, error) {
+	if err := authorizedUserService.authorize(model.PermissionReadUsers, uuid); err != nil {
+		return nil, err
+	}
+	return authorizedUserService.UserService.GetUserByUUID(uuid)
+}
+
+// readOne serves lookups by a key other than the UUID. Callers who may only
+// read themselves get ErrForbidden for every other outcome, including
+// unknown users, so they cannot probe which users exist.
+func (authorizedUserService *authorizedUserService) readOne(lookup func() (*model.User, error)) (*model.User, error) {
+	if err := authorizedUserService.authorize(model.PermissionReadUsers, ""); err == nil {
+		return lookup()
+	} else if errors.Is(err, model.ErrUnauthenticated) {
+		return nil, err
+	}
+
+	user, err := lookup()
+	if err != nil && !errors.Is(err, model.ErrUserNotFound) {
+		return nil, err
+	}
+	uuid := ""
+	if user != nil {
+		uuid = user.UUID
+	}
+	if err := authorizedUserService.authorize(model.PermissionReadUsers, uuid); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (authorizedUserService *authorizedUserService) CreateUser(request *model.CreateUserRequest) (*model.User, error) {
+	if err := authorizedUserService.authorize(model.PermissionCreateUsers, ""); err != nil {
+		return nil, err
+	}
+	return authorizedUserService.UserService.CreateUser(request)
+}
+
+func (authorizedUserService *authorizedUserService) UpdateUser(uuid string, request *model.UpdateUserRequest) (*model.User, error) {
+	if err := authorizedUserService.authorize(model.PermissionUpdateUsers, uuid); err != nil {
+		return nil, err
+	}
+	return authorizedUserService.UserService.UpdateUser(uuid, request)
+}
+
+func (authorizedUserService *authorizedUserService) DeleteUser(uuid string) error {
+	if err := authorizedUserService.authorize(model.PermissionDeleteUsers, uuid); err != nil {
+		return err
+	}
+	return authorizedUserService.UserService.DeleteUser(uuid)
+}
+
+func (authorizedUserService *authorizedUserService) TransitionUserStatus(uuid string, target model.UserStatus, reason, actor string) (*model.User, error) {
+	if err := authorizedUserService.authorize(model.PermissionChangeStatus, uuid); err != nil {
+		return nil, err
+	}
+	return authorizedUserService.UserService.TransitionUserStatus(uuid, target, reason, actor)
+}
+
+func (authorizedUserService *authorizedUserService) GetStatusTransitions(uuid string) ([]model.StatusTransition, error) {
+	if err := authorizedUserService.authorize(model.PermissionReadUsers, uuid); err != nil {
+		return nil, err
+	}
+	return authorizedUserService.UserService.GetStatusTransitions(uuid)
+}
+
+func (authorizedUserService *authorizedUserService) GetAuditEvents(uuid string) ([]model.AuditEvent, error) {
+	if err := authorizedUserService.authorize(model.PermissionReadUsers, uuid); err != nil {
+		return nil, err
+	}
+	return authorizedUserService.UserService.GetAuditEvents(uuid)
+}
+
+// ExecuteBatch authorizes every operation up front, so a batch either runs
+// with all its permissions or not at all.
+func (authorizedUserService *authorizedUserService) ExecuteBatch(request *model.BatchRequest) ([]model.BatchItemResult, error) {
+	for _, operation := range request.Operations {
+		permission := model.PermissionCreateUsers
+		switch operation.Op {
+		case model.BatchOpUpdate:
+			permission = model.PermissionUpdateUsers
+		case model.BatchOpDelete:
+			permission = model.PermissionDeleteUsers
+		}
+		if err := authorizedUserService.authorize(permission, operation.UUID); err != nil {
+			return nil, err
+		}
+	}
+	return authorizedUserService.UserService.ExecuteBatch(request)
+}
+
+func (authorizedUserService *authorizedUserService) ImportUser(request *model.CreateUserRequest, options model.ImportOptions) (model.ImportAction, *model.User, error) {
+	if err := authorizedUserService.authorize(model.PermissionCreateUsers, ""); err != nil {
+		return model.ImportActionFailed, nil, err
+	}
+	if options.UpsertBy != "" {
+		if err := authorizedUserService.authorize(model.PermissionUpdateUsers, ""); err != nil {
+			return model.ImportActionFailed, nil, err
+		}
+	}
+	return authorizedUserService.UserService.ImportUser(request, options)
+}
+
+type authorizedAuthService struct {
+	AuthService
+	principal *model.Principal
+}
+
+// AuthorizeAuth restricts password changes and unlocking logins to what
+// principal is allowed to do.
+func AuthorizeAuth(auth AuthService, principal *model.Principal) AuthService {
+	return &authorizedAuthService{AuthService: auth, principal: principal}
+}
+
+func (authorizedAuthService *authorizedAuthService) ChangePassword(uuid string, request *model.ChangePasswordRequest) error {
+	if err := Authorize(authorizedAuthService.principal, model.PermissionChangePassword, uuid); err != nil {
+		return err
+	}
+	return authorizedAuthService.AuthService.ChangePassword(uuid, request)
+}
+
+func (authorizedAuthService *authorizedAuthService) Unlock(uuid, actor string) error {
+	if err := Authorize(authorizedAuthService.principal, model.PermissionUnlockLogins, uuid); err != nil {
+		return err
+	}
+	return authorizedAuthService.AuthService.Unlock(uuid, actor)
+}
+
+type authorizedSessionService struct {
+	SessionService
+	principal *model.Principal
+}
+
+// AuthorizeSessions lets principal manage the sessions of the users it may.
+// The cleanup is a system task and passes through.
+func AuthorizeSessions(sessions SessionService, principal *model.Principal) SessionService {
+	return &authorizedSessionService{SessionService: sessions, principal: principal}
+}
+
+func (authorizedSessionService *authorizedSessionService) ListSessions(uuid, currentID string) ([]model.Session, error) {
+	if err := Authorize(authorizedSessionService.principal, model.PermissionManageSessions, uuid); err != nil {
+		return nil, err
+	}
+	return authorizedSessionService.SessionService.ListSessions(uuid, currentID)
+}
+
+func (authorizedSessionService *authorizedSessionService) RevokeSession(uuid, sessionID, actor string) error {
+	if err := Authorize(authorizedSessionService.principal, model.PermissionManageSessions, uuid); err != nil {
+		return err
+	}
+	return authorizedSessionService.SessionService.RevokeSession(uuid, sessionID, actor)
+}
+
+func (authorizedSessionService *authorizedSessionService) RevokeOtherSessions(uuid, keepID, actor string) error {
+	if err := Authorize(authorizedSessionService.principal, model.PermissionManageSessions, uuid); err != nil {
+		return err
+	}
+	return authorizedSessionService.SessionService.RevokeOtherSessions(uuid, keepID, actor)
+}
+
+type authorizedRoleService struct {
+	RoleService
+	principal *model.Principal
+}
+
+// AuthorizeRoles restricts role management to what principal is allowed to do.
+func AuthorizeRoles(roles RoleService, principal *model.Principal) RoleService {
+	return &authorizedRoleService{RoleService: roles, principal: principal}
+}
+
+func (authorizedRoleService *authorizedRoleService) GetRoles(uuid string) (*model.UserRoles, error) {
+	if err := Authorize(authorizedRoleService.principal, model.PermissionReadUsers, uuid); err != nil {
+		return nil, err
+	}
+	return authorizedRoleService.RoleService.GetRoles(uuid)
+}
+
+func (authorizedRoleService *authorizedRoleService) SetRoles(uuid string, roles []model.Role) (*model.UserRoles, error) {
+	if err := Authorize(authorizedRoleService.principal, model.PermissionManageRoles, uuid); err != nil {
+		return nil, err
+	}
+	return authorizedRoleService.RoleService.SetRoles(uuid, roles)
+}
+
+type authorizedGroupService struct {
+	GroupService
+	principal *model.Principal
+}
+
+// AuthorizeGroups restricts groups to what principal is allowed to do. On
+// top of their roles, users may read the groups they are members of and
+// manage the groups they own.
+func AuthorizeGroups(groups GroupService, principal *model.Principal) GroupService {
+	return &authorizedGroupService{GroupService: groups, principal: principal}
+}
+
+func (authorizedGroupService *authorizedGroupService) authorizeGroup(permission model.Permission, groupID string) error {
+	err := Authorize(authorizedGroupService.principal, permission, "")
+	if err == nil || errors.Is(err, model.ErrUnauthenticated) || authorizedGroupService.principal.Kind != model.PrincipalUser {
+		return err
+	}
+
+	role, lookupErr := authorizedGroupService.GroupService.GetMemberRole(groupID, authorizedGroupService.principal.ID)
+	if lookupErr != nil {
+		return lookupErr
+	}
+	if role == model.GroupRoleOwner || (role == model.GroupRoleMember && permission == model.PermissionReadGroups) {
+		return nil
+	}
+	return err
+}
+
+func (authorizedGroupService *authorizedGroupService) ListGroups(page model.Page) (*model.GroupPage, error) {
+	if err := Authorize(authorizedGroupService.principal, model.PermissionReadGroups, ""); err != nil {
+		return nil, err
+	}
+	return authorizedGroupService.GroupService.ListGroups(page)
+}
+
+func (authorizedGroupService *authorizedGroupService) GetGroup(id string) (*model.Group, error) {
+	if err := authorizedGroupService.authorizeGroup(model.PermissionReadGroups, id); err != nil {
+		return nil, err
+	}
+	return authorizedGroupService.GroupService.GetGroup(id)
+}
+
+func (authorizedGroupService *authorizedGroupService) CreateGroup(request *model.CreateGroupRequest) (*model.Group, error) {
+	if err := Authorize(authorizedGroupService.principal, model.PermissionManageGroups, ""); err != nil {
+		return nil, err
+	}
+	return authorizedGroupService.GroupService.CreateGroup(request)
+}
+
+func (authorizedGroupService *authorizedGroupService) UpdateGroup(id string, request *model.UpdateGroupRequest) (*model.Group, error) {
+	if err := authorizedGroupService.authorizeGroup(model.PermissionManageGroups, id); err != nil {
+		return nil, err
+	}
+	return authorizedGroupService.GroupService.UpdateGroup(id, request)
+}
+
+func (authorizedGroupService *authorizedGroupService) DeleteGroup(id string) error {
+	if err := authorizedGroupService.authorizeGroup(model.PermissionManageGroups, id); err != nil {
+		return err
+	}
+	return authorizedGroupService.GroupService.DeleteGroup(id)
+}
+
+func (authorizedGroupService *authorizedGroupService) ListMembers(groupID string, page model.Page) (*model.GroupMemberPage, error) {
+	if err := authorizedGroupService.authorizeGroup(model.PermissionReadGroups, groupID); err != nil {
+		return nil, err
+	}
+	return authorizedGroupService.GroupService.ListMembers(groupID, page)
+}
+
+func (authorizedGroupService *authorizedGroupService) GetMemberRole(groupID, userUUID string) (model.GroupRole, error) {
+	if err := authorizedGroupService.authorizeGroup(model.PermissionReadGroups, groupID); err != nil {
+		return "", err
+	}
+	return authorizedGroupService.GroupService.GetMemberRole(groupID, userUUID)
+}
+
+func (authorizedGroupService *authorizedGroupService) SetMember(groupID, userUUID string, role model.GroupRole) (*model.GroupMember, error) {
+	if err := authorizedGroupService.authorizeGroup(model.PermissionManageGroups, groupID); err != nil {
+		return nil, err
+	}
+	return authorizedGroupService.GroupService.SetMember(groupID, userUUID, role)
+}
+
+func (authorizedGroupService *authorizedGroupService) RemoveMember(groupID, userUUID string) error {
+	if err := authorizedGroupService.authorizeGroup(model.PermissionManageGroups, groupID); err != nil {
+		return err
+	}
+	return authorizedGroupService.GroupService.RemoveMember(groupID, userUUID)
+}
+
+func (authorizedGroupService *authorizedGroupService) ListUserGroups(userUUID string, page model.Page) (*model.UserGroupPage, error) {
+	if err := Authorize(authorizedGroupService.principal, model.PermissionReadGroups, userUUID); err != nil {
+		return nil, err
+	}
+	return authorizedGroupService.GroupService.ListUserGroups(userUUID, page)
+}
+
+func (authorizedGroupService *authorizedGroupService) ListSubgroups(groupID string, page model.Page) (*model.GroupPage, error) {
+	if err := authorizedGroupService.authorizeGroup(model.PermissionReadGroups, groupID); err != nil {
+		return nil, err
+	}
+	return authorizedGroupService.GroupService.ListSubgroups(groupID, page)
+}
+
+// AddSubgroup requires managing both groups, since nesting grants the
+// child's members membership of the parent.
+func (authorizedGroupService *authorizedGroupService) AddSubgroup(parentID, childID string) error {
+	if err := authorizedGroupService.authorizeGroup(model.PermissionManageGroups, parentID); err != nil {
+		return err
+	}
+	if err := authorizedGroupService.authorizeGroup(model.PermissionManageGroups, childID); err != nil {
+		return err
+	}
+	return authorizedGroupService.GroupService.AddSubgroup(parentID, childID)
+}
+
+func (authorizedGroupService *authorizedGroupService) RemoveSubgroup(parentID, childID string) error {
+	if err := authorizedGroupService.authorizeGroup(model.PermissionManageGroups, parentID); err != nil {
+		return err
+	}
+	return authorizedGroupService.GroupService.RemoveSubgroup(parentID, childID)
+}
+
+type authorizedVerificationService struct {
+	EmailVerificationService
+	principal *model.Principal
+}
+
+// AuthorizeVerifications lets principal resend verification emails to the
+// users it may update.
+func AuthorizeVerifications(verifications EmailVerificationService, principal *model.Principal) EmailVerificationService {
+	return &authorizedVerificationService{EmailVerificationService: verifications, principal: principal}
+}
+
+func (authorizedVerificationService *authorizedVerificationService) ResendVerification(uuid string) error {
+	if err := Authorize(authorizedVerificationService.principal, model.PermissionUpdateUsers, uuid); err != nil {
+		return err
+	}
+	return authorizedVerificationService.EmailVerificationService.ResendVerification(uuid)
+}
+
+type authorizedMFAService struct {
+	MFAService
+	principal *model.Principal
+}
+
+// AuthorizeMFA lets users manage their own second factor, since that shows
+// its secret, and admins reset it for others. The login steps need no
+// principal and pass through.
+func AuthorizeMFA(mfa MFAService, principal *model.Principal) MFAService {
+	return &authorizedMFAService{MFAService: mfa, principal: principal}
+}
+
+func (authorizedMFAService *authorizedMFAService) GetStatus(uuid string) (*model.MFAStatus, error) {
+	if err := Authorize(authorizedMFAService.principal, model.PermissionReadUsers, uuid); err != nil {
+		return nil, err
+	}
+	return authorizedMFAService.MFAService.GetStatus(uuid)
+}
+
+func (authorizedMFAService *authorizedMFAService) Enroll(uuid string) (*model.MFAEnrollment, error) {
+	if err := Authorize(authorizedMFAService.principal, model.PermissionEnrollMFA, uuid); err != nil {
+		return nil, err
+	}
+	return authorizedMFAService.MFAService.Enroll(uuid)
+}
+
+func (authorizedMFAService *authorizedMFAService) ConfirmEnrollment(uuid, code string) error {
+	if err := Authorize(authorizedMFAService.principal, model.PermissionEnrollMFA, uuid); err != nil {
+		return err
+	}
+	return authorizedMFAService.MFAService.ConfirmEnrollment(uuid, code)
+}
+
+func (authorizedMFAService *authorizedMFAService) RegenerateRecoveryCodes(uuid, code string) (*model.RecoveryCodes, error) {
+	if err := Authorize(authorizedMFAService.principal, model.PermissionEnrollMFA, uuid); err != nil {
+		return nil, err
+	}
+	return authorizedMFAService.MFAService.RegenerateRecoveryCodes(uuid, code)
+}
+
+func (authorizedMFAService *authorizedMFAService) Disable(uuid, code string) error {
+	if err := Authorize(authorizedMFAService.principal, model.PermissionEnrollMFA, uuid); err != nil {
+		return err
+	}
+	return authorizedMFAService.MFAService.Disable(uuid, code)
+}
+
+func (authorizedMFAService *authorizedMFAService) Reset(uuid string) error {
+	if err := Authorize(authorizedMFAService.principal, model.PermissionResetMFA, uuid); err != nil {
+		return err
+	}
+	return authorizedMFAService.MFAService.Reset(uuid)
+}
+
+type authorizedWebhookService struct {
+	WebhookService
+	principal *model.Principal
+}
+
+// AuthorizeWebhooks restricts webhooks to principals that may manage them.
+func AuthorizeWebhooks(webhooks WebhookService, principal *model.Principal) WebhookService {
+	return &authorizedWebhookService{WebhookService: webhooks, principal: principal}
+}
+
+func (authorizedWebhookService *authorizedWebhookService) authorize() error {
+	return Authorize(authorizedWebhookService.principal, model.PermissionManageWebhooks, "")
+}
+
+func (authorizedWebhookService *authorizedWebhookService) GetAllWebhooks() ([]model.Webhook, error) {
+	if err := authorizedWebhookService.authorize(); err != nil {
+		return nil, err
+	}
+	return authorizedWebhookService.WebhookService.GetAllWebhooks()
+}
+
+func (authorizedWebhookService *authorizedWebhookService) GetWebhook(id int64) (*model.Webhook, error) {
+	if err := authorizedWebhookService.authorize(); err != nil {
+		return nil, err
+	}
+	return authorizedWebhookService.WebhookService.GetWebhook(id)
+}
+
+func (authorizedWebhookService *authorizedWebhookService) CreateWebhook(request *model.CreateWebhookRequest) (*model.Webhook, error) {
+	if err := authorizedWebhookService.authorize(); err != nil {
+		return nil, err
+	}
+	return authorizedWebhookService.WebhookService.CreateWebhook(request)
+}
+
+func (authorizedWebhookService *authorizedWebhookService) EnableWebhook(id int64) (*model.Webhook, error) {
+	if err := authorizedWebhookService.authorize(); err != nil {
+		return nil, err
+	}
+	return authorizedWebhookService.WebhookService.EnableWebhook(id)
+}
+
+func (authorizedWebhookService *authorizedWebhookService) DeleteWebhook(id int64) error {
+	if err := authorizedWebhookService.authorize(); err != nil {
+		return err
+	}
+	return authorizedWebhookService.WebhookService.DeleteWebhook(id)
+}
+
+func (authorizedWebhookService *authorizedWebhookService) GetDeliveries(webhookID int64) ([]model.WebhookDelivery, error) {
+	if err := authorizedWebhookService.authorize(); err != nil {
+		return nil, err
+	}
+	return authorizedWebhookService.WebhookService.GetDeliveries(webhookID)
+}
+
+func (authorizedWebhookService *authorizedWebhookService) Redeliver(webhookID, deliveryID int64) (*model.WebhookDelivery, error) {
+	if err := authorizedWebhookService.authorize(); err != nil {
+		return nil, err
+	}
+	return authorizedWebhookService.WebhookService.Redeliver(webhookID, deliveryID)
+}
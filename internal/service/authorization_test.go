@@ -0,0 +1,167 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func userPrincipal(uuid string, roles ...string) *model.Principal {
+	return &model.Principal{Kind: model.PrincipalUser, ID: uuid, Roles: roles}
+}
+
+func TestShouldLetUsersReadAndUpdateOnlyThemselves(test *testing.T) {
+	// given
+	_, userService := setupTest()
+	self, _ := userService.CreateUser(&model.CreateUserRequest{Username: "self", Email: "self@example.com"})
+	other, _ := userService.CreateUser(&model.CreateUserRequest{Username: "other", Email: "other@example.com"})
+	users := AuthorizeUsers(userService, userPrincipal(self.UUID))
+
+	// when
+	_, readSelfErr := users.GetUserByUUID(self.UUID)
+	_, readSelfByNameErr := users.GetUserByUsername("self")
+	_, updateSelfErr := users.UpdateUser(self.UUID, &model.UpdateUserRequest{FullName: "Self"})
+	_, readOtherErr := users.GetUserByUsername("other")
+	_, readMissingErr := users.GetUserByUsername("missing")
+	_, updateOtherErr := users.UpdateUser(other.UUID, &model.UpdateUserRequest{FullName: "Other"})
+	_, listErr := users.GetAllUsers(model.UserFilter{})
+	_, statusErr := users.TransitionUserStatus(self.UUID, model.UserStatusActive, "self", "self")
+	deleteSelfErr := users.DeleteUser(self.UUID)
+
+	// then
+	assert.NoError(test, readSelfErr)
+	assert.NoError(test, readSelfByNameErr)
+	assert.NoError(test, updateSelfErr)
+	assert.ErrorIs(test, readOtherErr, model.ErrForbidden)
+	assert.ErrorIs(test, readMissingErr, model.ErrForbidden)
+	assert.ErrorIs(test, updateOtherErr, model.ErrForbidden)
+	assert.ErrorIs(test, listErr, model.ErrForbidden)
+	assert.ErrorIs(test, statusErr, model.ErrForbidden)
+	assert.ErrorIs(test, deleteSelfErr, model.ErrForbidden)
+}
+
+func TestShouldLetSupportReadEveryoneButNotDelete(test *testing.T) {
+	// given
+	_, userService := setupTest()
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	users := AuthorizeUsers(userService, userPrincipal("support-uuid", "support"))
+
+	// when
+	listed, listErr := users.GetAllUsers(model.UserFilter{})
+	_, readErr := users.GetUserByUUID(user.UUID)
+	_, createErr := users.CreateUser(&model.CreateUserRequest{Username: "new", Email: "new@example.com"})
+	deleteErr := users.DeleteUser(user.UUID)
+
+	// then
+	assert.NoError(test, listErr)
+	assert.Len(test, listed, 1)
+	assert.NoError(test, readErr)
+	assert.ErrorIs(test, createErr, model.ErrForbidden)
+	assert.ErrorIs(test, deleteErr, model.ErrForbidden)
+}
+
+func TestShouldNotLetSupportChangeAdmins(test *testing.T) {
+	// given
+	repos, userService, _ := setupTokenTest(test)
+	admin, _ := userService.CreateUser(&model.CreateUserRequest{Username: "root", Email: "root@example.com"})
+	roleService := NewRoleService(&mockTransactor{repos: repos, users: repos.Users.(*mockUserRepository)})
+	_, _ = roleService.SetRoles(admin.UUID, []model.Role{model.RoleAdmin})
+	users := AuthorizeUsers(userService, userPrincipal("support-uuid", "support"))
+
+	// when
+	_, updateErr := users.UpdateUser(admin.UUID, &model.UpdateUserRequest{Email: "attacker@example.com"})
+	_, statusErr := users.TransitionUserStatus(admin.UUID, model.UserStatusSuspended, "support", "support")
+
+	// then
+	assert.ErrorIs(test, updateErr, model.ErrForbidden)
+	assert.ErrorIs(test, statusErr, model.ErrForbidden)
+	assert.Equal(test, "root@example.com", repos.Users.(*mockUserRepository).users[admin.UUID].Email)
+}
+
+func TestShouldLetAdminsDoEverything(test *testing.T) {
+	// given
+	_, userService := setupTest()
+	users := AuthorizeUsers(userService, userPrincipal("admin-uuid", "admin"))
+
+	// when
+	created, createErr := users.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	deleteErr := users.DeleteUser(created.UUID)
+
+	// then
+	assert.NoError(test, createErr)
+	assert.NoError(test, deleteErr)
+}
+
+func TestShouldRejectBatchContainingForbiddenOperation(test *testing.T) {
+	// given
+	mockRepo, userService := setupTest()
+	self, _ := userService.CreateUser(&model.CreateUserRequest{Username: "self", Email: "self@example.com"})
+	users := AuthorizeUsers(userService, userPrincipal(self.UUID))
+	request := &model.BatchRequest{Operations: []model.BatchOperation{
+		{Op: model.BatchOpUpdate, UUID: self.UUID, Data: []byte(`{"full_name":"Self"}`)},
+		{Op: model.BatchOpDelete, UUID: self.UUID},
+	}}
+
+	// when
+	results, err := users.ExecuteBatch(request)
+
+	// then
+	assert.ErrorIs(test, err, model.ErrForbidden)
+	assert.Nil(test, results)
+	assert.Empty(test, mockRepo.users[self.UUID].FullName)
+}
+
+func TestShouldLetOnlyAdminsManageWebhooks(test *testing.T) {
+	// given
+	request := &model.CreateWebhookRequest{URL: "not a url", Events: []string{"user.created"}}
+
+	// when
+	_, supportErr := AuthorizeWebhooks(NewWebhookService(nil), userPrincipal("support-uuid", "support")).CreateWebhook(request)
+	_, selfErr := AuthorizeWebhooks(NewWebhookService(nil), userPrincipal("self-uuid")).GetAllWebhooks()
+	_, anonymousErr := AuthorizeWebhooks(NewWebhookService(nil), nil).GetAllWebhooks()
+	_, adminErr := AuthorizeWebhooks(NewWebhookService(nil), userPrincipal("admin-uuid", "admin")).CreateWebhook(request)
+
+	// then
+	assert.ErrorIs(test, supportErr, model.ErrForbidden)
+	assert.ErrorIs(test, selfErr, model.ErrForbidden)
+	assert.ErrorIs(test, anonymousErr, model.ErrUnauthenticated)
+	assert.ErrorIs(test, adminErr, model.ErrInvalidWebhookURL)
+}
+
+func TestShouldRequireAuthenticatedPrincipal(test *testing.T) {
+	// given
+	_, userService := setupTest()
+	users := AuthorizeUsers(userService, nil)
+
+	// when
+	_, err := users.GetAllUsers(model.UserFilter{})
+
+	// then
+	assert.ErrorIs(test, err, model.ErrUnauthenticated)
+}
+
+func TestShouldAssignRolesAndRevokeSessionsOnDemotion(test *testing.T) {
+	// given
+	repos, userService, tokenService := setupTokenTest(test)
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	roleService := NewRoleService(&mockTransactor{repos: repos, users: repos.Users.(*mockUserRepository)})
+	admin := AuthorizeRoles(roleService, userPrincipal("admin-uuid", "admin"))
+	promoted, promoteErr := admin.SetRoles(user.UUID, []model.Role{model.RoleSupport, model.RoleSupport})
+	tokens, _ := tokenService.IssueTokens(user, model.SessionClient{})
+
+	// when
+	_, selfErr := AuthorizeRoles(roleService, userPrincipal(user.UUID, "support")).SetRoles(user.UUID, []model.Role{model.RoleAdmin})
+	_, invalidErr := admin.SetRoles(user.UUID, []model.Role{model.RoleSelf})
+	demoted, demoteErr := admin.SetRoles(user.UUID, []model.Role{})
+	_, tokenErr := tokenService.Authenticate(tokens.AccessToken)
+
+	// then
+	assert.NoError(test, promoteErr)
+	assert.Equal(test, []model.Role{model.RoleSupport}, promoted.Roles)
+	assert.ErrorIs(test, selfErr, model.ErrForbidden)
+	assert.ErrorIs(test, invalidErr, model.ErrInvalidRole)
+	assert.NoError(test, demoteErr)
+	assert.Empty(test, demoted.Roles)
+	assert.ErrorIs(test, tokenErr, model.ErrInvalidToken)
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var errBatchItemFailed = errors.New("batch item failed")
+
+type batchExecutor func(fn func(repos *repository.Repository) error) error
+
+// ExecuteBatch runs create, update and delete operations in order. Atomic
+// batches run in a single transaction and roll back entirely on the first
+// failure; otherwise every operation commits or fails on its own. Runs of
+// consecutive creates are inserted with multi-row statements.
+func (userService *userService) ExecuteBatch(request *model.BatchRequest) ([]model.BatchItemResult, error) {
+	if len(request.Operations) == 0 {
+		return nil, fmt.Errorf("operations: %w", model.ErrEmptyField)
+	}
+	if len(request.Operations) > userService.config.MaxBatchSize {
+		return nil, fmt.Errorf("%w of %d operations", model.ErrBatchTooLarge, userService.config.MaxBatchSize)
+	}
+
+	results := make([]model.BatchItemResult, len(request.Operations))
+	for i, operation := range request.Operations {
+		results[i] = model.BatchItemResult{Index: i, Op: operation.Op}
+	}
+
+	var changes []userChange
+	if !request.Atomic {
+		userService.runBatch(userService.transactor.WithinTransaction, request.Operations, results, &changes, false)
+		userService.announce(changes...)
+		return results, nil
+	}
+
+	err := userService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		inTransaction := func(fn func(repos *repository.Repository) error) error { return fn(repos) }
+		return userService.runBatch(inTransaction, request.Operations, results, &changes, true)
+	})
+	if err != nil && !errors.Is(err, errBatchItemFailed) {
+		return nil, err
+	}
+	if err != nil {
+		for i := range results {
+			if results[i].Err == nil {
+				results[i].User = nil
+				results[i].RolledBack = true
+			}
+		}
+		return results, nil
+	}
+
+	userService.announce(changes...)
+	return results, nil
+}
+
+// runBatch adds the changes of every successful operation to changes; they
+// are committed once execute returns, or with the whole batch if atomic.
+func (userService *userService) runBatch(execute batchExecutor, operations []model.BatchOperation, results []model.BatchItemResult, changes *[]userChange, atomic bool) error {
+	for i := 0; i < len(operations); {
+		if operations[i].Op == model.BatchOpCreate {
+			end := i
+			for end < len(operations) && operations[end].Op == model.BatchOpCreate {
+				end++
+			}
+			if !userService.runBatchCreates(execute, operations[i:end], results[i:end], changes, atomic) && atomic {
+				return errBatchItemFailed
+			}
+			i = end
+			continue
+		}
+
+		var user *model.User
+		var change *userChange
+		err := execute(func(repos *repository.Repository) error {
+			var err error
+			user, change, err = userService.runBatchOperation(repos, operations[i])
+			return err
+		})
+		results[i].User = user
+		results[i].Err = err
+		if err != nil && atomic {
+			return errBatchItemFailed
+		}
+		if err == nil && change != nil {
+			*changes = append(*changes, *change)
+		}
+		i++
+	}
+	return nil
+}
+
+// runBatchOperation also returns the change to announce, if any.
+func (userService *userService) runBatchOperation(repos *repository.Repository, operation model.BatchOperation) (*model.User, *userChange, error) {
+	switch operation.Op {
+	case model.BatchOpUpdate:
+		var request model.UpdateUserRequest
+		if err := decodeBatchData(operation.Data, &request); err != nil {
+			return nil, nil, err
+		}
+		return userService.updateUser(repos, operation.UUID, &request)
+	case model.BatchOpDelete:
+		deleted, err := userService.deleteUser(repos, operation.UUID)
+		if err != nil {
+			return nil, nil, err
+		}
+		change := userDeleted(deleted)
+		return nil, &change, nil
+	default:
+		return nil, nil, fmt.Errorf("%q: %w", operation.Op, model.ErrUnknownBatchOp)
+	}
+}
+
+// runBatchCreates validates a run of creates and inserts the valid ones with
+// CreateMany. Outside of atomic mode a failed bulk insert falls back to one
+// insert per user so a single conflict does not fail its neighbours.
+func (userService *userService) runBatchCreates(execute batchExecutor, operations []model.BatchOperation, results []model.BatchItemResult, changes *[]userChange, atomic bool) bool {
+	var users []*model.User
+	var pending []int
+	for i, operation := range operations {
+		var request model.CreateUserRequest
+		err := decodeBatchData(operation.Data, &request)
+		if err == nil {
+			results[i].User, err = userService.newUser(&request)
+		}
+		if err != nil {
+			results[i].Err = err
+			if atomic {
+				return false
+			}
+			continue
+		}
+		users = append(users, results[i].User)
+		pending = append(pending, i)
+	}
+	if len(users) == 0 {
+		return true
+	}
+
+	err := execute(func(repos *repository.Repository) error {
+		if err := repos.Users.CreateMany(users); err != nil {
+			return err
+		}
+		for _, user := range users {
+			if err := repos.Outbox.Append(model.UserCreated{User: *user}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		for _, user := range users {
+			*changes = append(*changes, userCreated(user))
+		}
+		return true
+	}
+
+	if atomic {
+		for _, i := range pending {
+			results[i].User = nil
+			results[i].Err = err
+		}
+		return false
+	}
+
+	for _, i := range pending {
+		user := results[i].User
+		results[i].Err = execute(func(repos *repository.Repository) error {
+			if err := repos.Users.Create(user); err != nil {
+				return err
+			}
+			return repos.Outbox.Append(model.UserCreated{User: *user})
+		})
+		if results[i].Err != nil {
+			results[i].User = nil
+			continue
+		}
+		*changes = append(*changes, userCreated(user))
+	}
+	return true
+}
+
+func decodeBatchData(data json.RawMessage, target any) error {
+	if len(data) == 0 {
+		return fmt.Errorf("data: %w", model.ErrEmptyField)
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("%w: %v", model.ErrInvalidBatchData, err)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createOperation(username, email string) model.BatchOperation {
+	data, _ := json.Marshal(model.CreateUserRequest{Username: username, Email: email})
+	return model.BatchOperation{Op: model.BatchOpCreate, Data: data}
+}
+
+func TestShouldCreateConsecutiveUsersWithSingleBulkInsert(test *testing.T) {
+	// given
+	mockRepo, mockOutbox, userService := setupTestWithOutbox()
+	request := &model.BatchRequest{Operations: []model.BatchOperation{
+		createOperation("user1", "user1@example.com"),
+		createOperation("user2", "user2@example.com"),
+		createOperation("user3", "user3@example.com"),
+	}}
+
+	// when
+	results, err := userService.ExecuteBatch(request)
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, 1, mockRepo.bulkInserts)
+	assert.Len(test, mockRepo.users, 3)
+	assert.Len(test, mockOutbox.events, 3)
+	for _, result := range results {
+		assert.NoError(test, result.Err)
+		assert.NotEmpty(test, result.User.UUID)
+	}
+}
+
+func TestShouldReportPerItemErrorsInBestEffortBatch(test *testing.T) {
+	// given
+	mockRepo, _, userService := setupTestWithOutbox()
+	existing := &model.User{ID: 99, UUID: generateMockUUID(99), Username: "taken", Email: "taken@example.com"}
+	mockRepo.users[existing.UUID] = existing
+	request := &model.BatchRequest{Operations: []model.BatchOperation{
+		createOperation("fresh", "fresh@example.com"),
+		createOperation("taken", "other@example.com"),
+		createOperation("bad", "not-an-email"),
+		{Op: model.BatchOpUpdate, UUID: existing.UUID, Data: json.RawMessage(`{"full_name":"Taken User"}`)},
+		{Op: model.BatchOpDelete, UUID: "nonexistent-uuid"},
+		{Op: "rename"},
+	}}
+
+	// when
+	results, err := userService.ExecuteBatch(request)
+
+	// then
+	assert.NoError(test, err)
+	assert.NoError(test, results[0].Err)
+	assert.ErrorIs(test, results[1].Err, model.ErrUserAlreadyExists)
+	assert.ErrorIs(test, results[2].Err, model.ErrInvalidEmail)
+	assert.NoError(test, results[3].Err)
+	assert.Equal(test, "Taken User", results[3].User.FullName)
+	assert.ErrorIs(test, results[4].Err, model.ErrUserNotFound)
+	assert.ErrorIs(test, results[5].Err, model.ErrUnknownBatchOp)
+	assert.True(test, mockRepo.usernameTaken("fresh"))
+}
+
+func TestShouldRollBackAtomicBatchOnFirstFailure(test *testing.T) {
+	// given
+	mockRepo, _, userService := setupTestWithOutbox()
+	request := &model.BatchRequest{Atomic: true, Operations: []model.BatchOperation{
+		createOperation("user1", "user1@example.com"),
+		{Op: model.BatchOpDelete, UUID: "nonexistent-uuid"},
+		createOperation("user2", "user2@example.com"),
+	}}
+
+	// when
+	results, err := userService.ExecuteBatch(request)
+
+	// then
+	assert.NoError(test, err)
+	assert.True(test, results[0].RolledBack)
+	assert.Nil(test, results[0].User)
+	assert.ErrorIs(test, results[1].Err, model.ErrUserNotFound)
+	assert.True(test, results[2].RolledBack)
+	assert.Empty(test, mockRepo.users)
+}
+
+func TestShouldRejectBatchLargerThanConfiguredMaximum(test *testing.T) {
+	// given
+	mockRepo, mockOutbox, _ := setupTestWithOutbox()
+	config := DefaultConfig()
+	config.MaxBatchSize = 1
+	transactor := &mockTransactor{repos: newMockRepos(mockRepo, mockOutbox), users: mockRepo}
+	userService := NewUserService(mockRepo, transactor, config)
+	request := &model.BatchRequest{Operations: []model.BatchOperation{
+		createOperation("user1", "user1@example.com"),
+		createOperation("user2", "user2@example.com"),
+	}}
+
+	// when
+	results, err := userService.ExecuteBatch(request)
+
+	// then
+	assert.ErrorIs(test, err, model.ErrBatchTooLarge)
+	assert.Nil(test, results)
+	assert.Empty(test, mockRepo.users)
+}
+
+func TestShouldSendVerificationForBatchCreatesAndEmailChanges(test *testing.T) {
+	// given
+	_, mailer, userService, verifications := setupVerificationTest()
+	existing, err := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	require.NoError(test, err)
+	_, err = verifications.VerifyEmail(sentToken(test, verificationMails(mailer)[0]))
+	require.NoError(test, err)
+	request := &model.BatchRequest{Operations: []model.BatchOperation{
+		createOperation("user1", "user1@example.com"),
+		createOperation("user2", "user2@example.com"),
+		{Op: model.BatchOpUpdate, UUID: existing.UUID, Data: json.RawMessage(`{"email":"john@example.com"}`)},
+		{Op: model.BatchOpUpdate, UUID: existing.UUID, Data: json.RawMessage(`{"full_name":"John Doe"}`)},
+	}}
+
+	// when
+	results, err := userService.ExecuteBatch(request)
+
+	// then
+	require.NoError(test, err)
+	for _, result := range results {
+		require.NoError(test, result.Err)
+	}
+	messages := verificationMails(mailer)[1:]
+	require.Len(test, messages, 3)
+	assert.ElementsMatch(test, []string{"user1@example.com", "user2@example.com", "john@example.com"},
+		[]string{messages[0].To, messages[1].To, messages[2].To})
+	_, verifyErr := verifications.VerifyEmail(sentToken(test, messages[2]))
+	assert.NoError(test, verifyErr)
+}
+
+func TestShouldNotSendVerificationForRolledBackAtomicBatch(test *testing.T) {
+	// given
+	_, mailer, userService, _ := setupVerificationTest()
+	request := &model.BatchRequest{Atomic: true, Operations: []model.BatchOperation{
+		createOperation("user1", "user1@example.com"),
+		{Op: model.BatchOpDelete, UUID: "nonexistent-uuid"},
+	}}
+
+	// when
+	_, err := userService.ExecuteBatch(request)
+
+	// then
+	require.NoError(test, err)
+	assert.Empty(test, verificationMails(mailer))
+}
+
+func TestShouldNotifyUsersChangedInBatch(test *testing.T) {
+	// given
+	_, mailer, userService, _ := setupVerificationTest()
+	renamed, err := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	require.NoError(test, err)
+	leaving, err := userService.CreateUser(&model.CreateUserRequest{Username: "asmith", Email: "asmith@example.com"})
+	require.NoError(test, err)
+	request := &model.BatchRequest{Operations: []model.BatchOperation{
+		createOperation("user1", "user1@example.com"),
+		{Op: model.BatchOpUpdate, UUID: renamed.UUID, Data: json.RawMessage(`{"email":"john@example.com"}`)},
+		{Op: model.BatchOpDelete, UUID: leaving.UUID},
+	}}
+
+	// when
+	_, err = userService.ExecuteBatch(request)
+
+	// then
+	require.NoError(test, err)
+	welcomes := mailsWithSubject(mailer, "Welcome, user1")
+	require.Len(test, welcomes, 1)
+	assert.Equal(test, "user1@example.com", welcomes[0].To)
+	changes := mailsWithSubject(mailer, "Your email address was changed")
+	require.Len(test, changes, 2)
+	assert.ElementsMatch(test, []string{"jdoe@example.com", "john@example.com"}, []string{changes[0].To, changes[1].To})
+	deletions := mailsWithSubject(mailer, "Your account has been deleted")
+	require.Len(test, deletions, 1)
+	assert.Equal(test, "asmith@example.com", deletions[0].To)
+}
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+type GroupService interface {
+	ListGroups(page model.Page) (*model.GroupPage, error)
+	GetGroup(id string) (*model.Group, error)
+	CreateGroup(request *model.CreateGroupRequest) (*model.Group, error)
+	UpdateGroup(id string, request *model.UpdateGroupRequest) (*model.Group, error)
+	DeleteGroup(id string) error
+
+	ListMembers(groupID string, page model.Page) (*model.GroupMemberPage, error)
+	// GetMemberRole returns the user's direct role in the group, or "" if
+	// they are not a member.
+	GetMemberRole(groupID, userUUID string) (model.GroupRole, error)
+	// SetMember adds the user to the group or changes their role. The last
+	// owner cannot be demoted.
+	SetMember(groupID, userUUID string, role model.GroupRole) (*model.GroupMember, error)
+	// RemoveMember removes the user from the group. The last owner cannot
+	// be removed.
+	RemoveMember(groupID, userUUID string) error
+	ListUserGroups(userUUID string, page model.Page) (*model.UserGroupPage, error)
+
+	ListSubgroups(groupID string, page model.Page) (*model.GroupPage, error)
+	// AddSubgroup nests childID in parentID, making the child's members
+	// members of the parent. Nesting that would form a cycle fails with
+	// ErrGroupCycle.
+	AddSubgroup(parentID, childID string) error
+	RemoveSubgroup(parentID, childID string) error
+}
+
+type groupService struct {
+	transactor repository.Transactor
+}
+
+func NewGroupService(transactor repository.Transactor) GroupService {
+	return &groupService{transactor: transactor}
+}
+
+func normalizePage(page model.Page) model.Page {
+	if page.Limit <= 0 {
+		page.Limit = defaultPageLimit
+	}
+	page.Limit = min(page.Limit, maxPageLimit)
+	page.Offset = max(page.Offset, 0)
+	return page
+}
+
+func (groupService *groupService) ListGroups(page model.Page) (*model.GroupPage, error) {
+	page = normalizePage(page)
+	var groups []model.Group
+	total := 0
+	err := groupService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		var err error
+		groups, total, err = repos.Groups.List(page)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.GroupPage{Total: total, Limit: page.Limit, Offset: page.Offset, Groups: groups}, nil
+}
+
+func (groupService *groupService) GetGroup(id string) (*model.Group, error) {
+	var group *model.Group
+	err := groupService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		var err error
+		group, err = requireGroup(repos, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (groupService *groupService) CreateGroup(request *model.CreateGroupRequest) (*model.Group, error) {
+	group := &model.Group{Name: strings.TrimSpace(request.Name), Description: request.Description}
+	if group.Name == "" {
+		return nil, fmt.Errorf("name: %w", model.ErrEmptyField)
+	}
+
+	err := groupService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if request.ParentID != "" {
+			if _, err := requireGroup(repos, request.ParentID); err != nil {
+				return err
+			}
+		}
+		if request.OwnerUUID != "" {
+			if _, err := requireUser(repos, request.OwnerUUID); err != nil {
+				return err
+			}
+		}
+
+		if err := repos.Groups.Create(group); err != nil {
+			return err
+		}
+		if request.ParentID != "" {
+			if err := repos.Groups.AddSubgroup(request.ParentID, group.ID); err != nil {
+				return err
+			}
+		}
+		if request.OwnerUUID != "" {
+			return repos.Groups.SetMember(group.ID, request.OwnerUUID, model.GroupRoleOwner)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (groupService *groupService) UpdateGroup(id string, request *model.UpdateGroupRequest) (*model.Group, error) {
+	var group *model.Group
+	err := groupService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		var err error
+		group, err = requireGroup(repos, id)
+		if err != nil {
+			return err
+		}
+
+		if name := strings.TrimSpace(request.Name); name != "" {
+			group.Name = name
+		}
+		if request.Description != "" {
+			group.Description = request.Description
+		}
+		return repos.Groups.Update(group)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (groupService *groupService) DeleteGroup(id string) error {
+	return groupService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if _, err := requireGroup(repos, id); err != nil {
+			return err
+		}
+		return repos.Groups.Delete(id)
+	})
+}
+
+func (groupService *groupService) ListMembers(groupID string, page model.Page) (*model.GroupMemberPage, error) {
+	page = normalizePage(page)
+	var members []model.GroupMember
+	total := 0
+	err := groupService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if _, err := requireGroup(repos, groupID); err != nil {
+			return err
+		}
+		var err error
+		members, total, err = repos.Groups.ListMembers(groupID, page)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.GroupMemberPage{Total: total, Limit: page.Limit, Offset: page.Offset, Members: members}, nil
+}
+
+func (groupService *groupService) GetMemberRole(groupID, userUUID string) (model.GroupRole, error) {
+	var role model.GroupRole
+	err := groupService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		member, err := repos.Groups.GetMember(groupID, userUUID)
+		if member != nil {
+			role = member.Role
+		}
+		return err
+	})
+	return role, err
+}
+
+func (groupService *groupService) SetMember(groupID, userUUID string, role model.GroupRole) (*model.GroupMember, error) {
+	if !role.IsValid() {
+		return nil, fmt.Errorf("%w: %q", model.ErrInvalidGroupRole, role)
+	}
+
+	var member *model.GroupMember
+	err := groupService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if _, err := requireGroup(repos, groupID); err != nil {
+			return err
+		}
+		if _, err := requireUser(repos, userUUID); err != nil {
+			return err
+		}
+		if role != model.GroupRoleOwner {
+			if err := requireOtherOwner(repos, groupID, userUUID); err != nil {
+				return err
+			}
+		}
+
+		if err := repos.Groups.SetMember(groupID, userUUID, role); err != nil {
+			return err
+		}
+		var err error
+		member, err = repos.Groups.GetMember(groupID, userUUID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+func (groupService *groupService) RemoveMember(groupID, userUUID string) error {
+	return groupService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if _, err := requireGroup(repos, groupID); err != nil {
+			return err
+		}
+		member, err := repos.Groups.GetMember(groupID, userUUID)
+		if err != nil {
+			return err
+		}
+		if member == nil {
+			return model.ErrMemberNotFound
+		}
+		if err := requireOtherOwner(repos, groupID, userUUID); err != nil {
+			return err
+		}
+		return repos.Groups.RemoveMember(groupID, userUUID)
+	})
+}
+
+func (groupService *groupService) ListUserGroups(userUUID string, page model.Page) (*model.UserGroupPage, error) {
+	page = normalizePage(page)
+	var groups []model.UserGroup
+	total := 0
+	err := groupService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if _, err := requireUser(repos, userUUID); err != nil {
+			return err
+		}
+		var err error
+		groups, total, err = repos.Groups.ListUserGroups(userUUID, page)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.UserGroupPage{Total: total, Limit: page.Limit, Offset: page.Offset, Groups: groups}, nil
+}
+
+func (groupService *groupService) ListSubgroups(groupID string, page model.Page) (*model.GroupPage, error) {
+	page = normalizePage(page)
+	var groups []model.Group
+	total := 0
+	err := groupService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if _, err := requireGroup(repos, groupID); err != nil {
+			return err
+		}
+		var err error
+		groups, total, err = repos.Groups.ListSubgroups(groupID, page)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.GroupPage{Total: total, Limit: page.Limit, Offset: page.Offset, Groups: groups}, nil
+}
+
+func (groupService *groupService) AddSubgroup(parentID, childID string) error {
+	if parentID == childID {
+		return model.ErrGroupCycle
+	}
+
+	return groupService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if _, err := requireGroup(repos, parentID); err != nil {
+			return err
+		}
+		if _, err := requireGroup(repos, childID); err != nil {
+			return err
+		}
+
+		if err := repos.Groups.LockHierarchy(); err != nil {
+			return err
+		}
+		ancestors, err := repos.Groups.GetAncestorIDs(parentID)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(ancestors, childID) {
+			return model.ErrGroupCycle
+		}
+		return repos.Groups.AddSubgroup(parentID, childID)
+	})
+}
+
+func (groupService *groupService) RemoveSubgroup(parentID, childID string) error {
+	return groupService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		removed, err := repos.Groups.RemoveSubgroup(parentID, childID)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return model.ErrSubgroupNotFound
+		}
+		return nil
+	})
+}
+
+func requireGroup(repos *repository.Repository, id string) (*model.Group, error) {
+	group, err := repos.Groups.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, model.ErrGroupNotFound
+	}
+	return group, nil
+}
+
+// requireOtherOwner fails with ErrLastGroupOwner when userUUID is the only
+// owner of the group.
+func requireOtherOwner(repos *repository.Repository, groupID, userUUID string) error {
+	member, err := repos.Groups.GetMember(groupID, userUUID)
+	if err != nil || member == nil || member.Role != model.GroupRoleOwner {
+		return err
+	}
+	owners, err := repos.Groups.CountOwners(groupID)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return model.ErrLastGroupOwner
+	}
+	return nil
+}
@@ -0,0 +1,423 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockGroupRepository struct {
+	users     *mockUserRepository
+	groups    map[string]*model.Group
+	members   map[string][]model.GroupMember
+	subgroups map[string][]string
+	nextID    int
+}
+
+func newMockGroupRepository(users *mockUserRepository) *mockGroupRepository {
+	return &mockGroupRepository{
+		users:     users,
+		groups:    make(map[string]*model.Group),
+		members:   make(map[string][]model.GroupMember),
+		subgroups: make(map[string][]string),
+	}
+}
+
+func (groupRepository *mockGroupRepository) Create(group *model.Group) error {
+	for _, existing := range groupRepository.groups {
+		if existing.Name == group.Name {
+			return model.ErrGroupExists
+		}
+	}
+	groupRepository.nextID++
+	group.ID = fmt.Sprintf("group-%d", groupRepository.nextID)
+	stored := *group
+	groupRepository.groups[group.ID] = &stored
+	return nil
+}
+
+func (groupRepository *mockGroupRepository) GetByID(id string) (*model.Group, error) {
+	group, ok := groupRepository.groups[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *group
+	return &copied, nil
+}
+
+func (groupRepository *mockGroupRepository) List(page model.Page) ([]model.Group, int, error) {
+	groups := []model.Group{}
+	for _, group := range groupRepository.groups {
+		groups = append(groups, *group)
+	}
+	slices.SortFunc(groups, func(a, b model.Group) int { return compareStrings(a.Name, b.Name) })
+	return paginate(groups, page), len(groups), nil
+}
+
+func (groupRepository *mockGroupRepository) Update(group *model.Group) error {
+	stored := *group
+	groupRepository.groups[group.ID] = &stored
+	return nil
+}
+
+func (groupRepository *mockGroupRepository) Delete(id string) error {
+	delete(groupRepository.groups, id)
+	delete(groupRepository.members, id)
+	delete(groupRepository.subgroups, id)
+	return nil
+}
+
+func (groupRepository *mockGroupRepository) GetMember(groupID, userUUID string) (*model.GroupMember, error) {
+	for _, member := range groupRepository.members[groupID] {
+		if member.User.UUID == userUUID {
+			return &member, nil
+		}
+	}
+	return nil, nil
+}
+
+func (groupRepository *mockGroupRepository) ListMembers(groupID string, page model.Page) ([]model.GroupMember, int, error) {
+	members := groupRepository.members[groupID]
+	return paginate(members, page), len(members), nil
+}
+
+func (groupRepository *mockGroupRepository) SetMember(groupID, userUUID string, role model.GroupRole) error {
+	for i, member := range groupRepository.members[groupID] {
+		if member.User.UUID == userUUID {
+			groupRepository.members[groupID][i].Role = role
+			return nil
+		}
+	}
+	user := groupRepository.users.users[userUUID]
+	groupRepository.members[groupID] = append(groupRepository.members[groupID], model.GroupMember{User: *user, Role: role})
+	return nil
+}
+
+func (groupRepository *mockGroupRepository) RemoveMember(groupID, userUUID string) error {
+	groupRepository.members[groupID] = slices.DeleteFunc(groupRepository.members[groupID], func(member model.GroupMember) bool {
+		return member.User.UUID == userUUID
+	})
+	return nil
+}
+
+func (groupRepository *mockGroupRepository) CountOwners(groupID string) (int, error) {
+	owners := 0
+	for _, member := range groupRepository.members[groupID] {
+		if member.Role == model.GroupRoleOwner {
+			owners++
+		}
+	}
+	return owners, nil
+}
+
+func (groupRepository *mockGroupRepository) ListUserGroups(userUUID string, page model.Page) ([]model.UserGroup, int, error) {
+	groups := []model.UserGroup{}
+	for groupID := range groupRepository.members {
+		if member, _ := groupRepository.GetMember(groupID, userUUID); member != nil {
+			groups = append(groups, model.UserGroup{Group: *groupRepository.groups[groupID], Role: member.Role})
+		}
+	}
+	return paginate(groups, page), len(groups), nil
+}
+
+func (groupRepository *mockGroupRepository) RemoveUser(userUUID string) error {
+	for groupID, members := range groupRepository.members {
+		member, _ := groupRepository.GetMember(groupID, userUUID)
+		if member == nil {
+			continue
+		}
+		_ = groupRepository.RemoveMember(groupID, userUUID)
+		if owners, _ := groupRepository.CountOwners(groupID); member.Role == model.GroupRoleOwner && owners == 0 && len(members) > 1 {
+			groupRepository.members[groupID][0].Role = model.GroupRoleOwner
+		}
+	}
+	return nil
+}
+
+func (groupRepository *mockGroupRepository) ListSubgroups(groupID string, page model.Page) ([]model.Group, int, error) {
+	groups := []model.Group{}
+	for _, childID := range groupRepository.subgroups[groupID] {
+		groups = append(groups, *groupRepository.groups[childID])
+	}
+	return paginate(groups, page), len(groups), nil
+}
+
+func (groupRepository *mockGroupRepository) GetAncestorIDs(groupID string) ([]string, error) {
+	var ancestors []string
+	pending := []string{groupID}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+		for parentID, children := range groupRepository.subgroups {
+			if slices.Contains(children, current) && !slices.Contains(ancestors, parentID) {
+				ancestors = append(ancestors, parentID)
+				pending = append(pending, parentID)
+			}
+		}
+	}
+	return ancestors, nil
+}
+
+func (groupRepository *mockGroupRepository) LockHierarchy() error {
+	return nil
+}
+
+func (groupRepository *mockGroupRepository) AddSubgroup(parentID, childID string) error {
+	if !slices.Contains(groupRepository.subgroups[parentID], childID) {
+		groupRepository.subgroups[parentID] = append(groupRepository.subgroups[parentID], childID)
+	}
+	return nil
+}
+
+func (groupRepository *mockGroupRepository) RemoveSubgroup(parentID, childID string) (bool, error) {
+	children := groupRepository.subgroups[parentID]
+	if !slices.Contains(children, childID) {
+		return false, nil
+	}
+	groupRepository.subgroups[parentID] = slices.DeleteFunc(children, func(id string) bool { return id == childID })
+	return true, nil
+}
+
+var _ repository.GroupRepository = (*mockGroupRepository)(nil)
+
+func compareStrings(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func paginate[T any](items []T, page model.Page) []T {
+	start := min(page.Offset, len(items))
+	end := min(start+page.Limit, len(items))
+	return append([]T{}, items[start:end]...)
+}
+
+func setupGroupTest() (*repository.Repository, UserService, GroupService) {
+	mockRepo := newMockUserRepository()
+	repos := newMockRepos(mockRepo, &mockOutboxRepository{})
+	transactor := &mockTransactor{repos: repos, users: mockRepo}
+	return repos, NewUserService(mockRepo, transactor, DefaultConfig()), NewGroupService(transactor)
+}
+
+func TestShouldCreateGroupWithOwnerAndParent(test *testing.T) {
+	// given
+	_, userService, groupService := setupGroupTest()
+	owner, _ := userService.CreateUser(&model.CreateUserRequest{Username: "owner", Email: "owner@example.com"})
+	parent, _ := groupService.CreateGroup(&model.CreateGroupRequest{Name: "engineering"})
+
+	// when
+	group, err := groupService.CreateGroup(&model.CreateGroupRequest{Name: " backend ", ParentID: parent.ID, OwnerUUID: owner.UUID})
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, "backend", group.Name)
+	role, _ := groupService.GetMemberRole(group.ID, owner.UUID)
+	assert.Equal(test, model.GroupRoleOwner, role)
+	subgroups, _ := groupService.ListSubgroups(parent.ID, model.Page{})
+	assert.Equal(test, 1, subgroups.Total)
+	assert.Equal(test, group.ID, subgroups.Groups[0].ID)
+}
+
+func TestShouldRejectInvalidGroups(test *testing.T) {
+	// given
+	_, _, groupService := setupGroupTest()
+	_, _ = groupService.CreateGroup(&model.CreateGroupRequest{Name: "ops"})
+
+	// when
+	_, emptyErr := groupService.CreateGroup(&model.CreateGroupRequest{Name: "  "})
+	_, duplicateErr := groupService.CreateGroup(&model.CreateGroupRequest{Name: "ops"})
+	_, parentErr := groupService.CreateGroup(&model.CreateGroupRequest{Name: "dev", ParentID: "missing"})
+	_, ownerErr := groupService.CreateGroup(&model.CreateGroupRequest{Name: "qa", OwnerUUID: "missing"})
+
+	// then
+	assert.ErrorIs(test, emptyErr, model.ErrEmptyField)
+	assert.ErrorIs(test, duplicateErr, model.ErrGroupExists)
+	assert.ErrorIs(test, parentErr, model.ErrGroupNotFound)
+	assert.ErrorIs(test, ownerErr, model.ErrUserNotFound)
+}
+
+func TestShouldPaginateGroupMembers(test *testing.T) {
+	// given
+	_, userService, groupService := setupGroupTest()
+	group, _ := groupService.CreateGroup(&model.CreateGroupRequest{Name: "ops"})
+	for i := range 3 {
+		user, _ := userService.CreateUser(&model.CreateUserRequest{Username: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@example.com", i)})
+		_, _ = groupService.SetMember(group.ID, user.UUID, model.GroupRoleMember)
+	}
+
+	// when
+	page, err := groupService.ListMembers(group.ID, model.Page{Limit: 2, Offset: 2})
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, 3, page.Total)
+	assert.Equal(test, 2, page.Limit)
+	assert.Len(test, page.Members, 1)
+}
+
+func TestShouldNormalizeGroupPages(test *testing.T) {
+	// given
+	_, _, groupService := setupGroupTest()
+
+	// when
+	defaulted, _ := groupService.ListGroups(model.Page{Limit: 0, Offset: -5})
+	capped, _ := groupService.ListGroups(model.Page{Limit: 1000})
+
+	// then
+	assert.Equal(test, defaultPageLimit, defaulted.Limit)
+	assert.Equal(test, 0, defaulted.Offset)
+	assert.Equal(test, maxPageLimit, capped.Limit)
+}
+
+func TestShouldRejectInvalidMemberships(test *testing.T) {
+	// given
+	_, userService, groupService := setupGroupTest()
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	group, _ := groupService.CreateGroup(&model.CreateGroupRequest{Name: "ops"})
+
+	// when
+	_, roleErr := groupService.SetMember(group.ID, user.UUID, "admin")
+	_, userErr := groupService.SetMember(group.ID, "missing", model.GroupRoleMember)
+	_, groupErr := groupService.SetMember("missing", user.UUID, model.GroupRoleMember)
+	removeErr := groupService.RemoveMember(group.ID, user.UUID)
+
+	// then
+	assert.ErrorIs(test, roleErr, model.ErrInvalidGroupRole)
+	assert.ErrorIs(test, userErr, model.ErrUserNotFound)
+	assert.ErrorIs(test, groupErr, model.ErrGroupNotFound)
+	assert.ErrorIs(test, removeErr, model.ErrMemberNotFound)
+}
+
+func TestShouldKeepLastGroupOwner(test *testing.T) {
+	// given
+	_, userService, groupService := setupGroupTest()
+	owner, _ := userService.CreateUser(&model.CreateUserRequest{Username: "owner", Email: "owner@example.com"})
+	other, _ := userService.CreateUser(&model.CreateUserRequest{Username: "other", Email: "other@example.com"})
+	group, _ := groupService.CreateGroup(&model.CreateGroupRequest{Name: "ops", OwnerUUID: owner.UUID})
+
+	// when
+	_, demoteErr := groupService.SetMember(group.ID, owner.UUID, model.GroupRoleMember)
+	removeErr := groupService.RemoveMember(group.ID, owner.UUID)
+	_, _ = groupService.SetMember(group.ID, other.UUID, model.GroupRoleOwner)
+	removeAfterHandoverErr := groupService.RemoveMember(group.ID, owner.UUID)
+
+	// then
+	assert.ErrorIs(test, demoteErr, model.ErrLastGroupOwner)
+	assert.ErrorIs(test, removeErr, model.ErrLastGroupOwner)
+	assert.NoError(test, removeAfterHandoverErr)
+}
+
+func TestShouldRejectGroupCycles(test *testing.T) {
+	// given
+	_, _, groupService := setupGroupTest()
+	top, _ := groupService.CreateGroup(&model.CreateGroupRequest{Name: "top"})
+	middle, _ := groupService.CreateGroup(&model.CreateGroupRequest{Name: "middle", ParentID: top.ID})
+	bottom, _ := groupService.CreateGroup(&model.CreateGroupRequest{Name: "bottom", ParentID: middle.ID})
+
+	// when
+	selfErr := groupService.AddSubgroup(top.ID, top.ID)
+	directErr := groupService.AddSubgroup(middle.ID, top.ID)
+	transitiveErr := groupService.AddSubgroup(bottom.ID, top.ID)
+	shortcutErr := groupService.AddSubgroup(top.ID, bottom.ID)
+
+	// then
+	assert.ErrorIs(test, selfErr, model.ErrGroupCycle)
+	assert.ErrorIs(test, directErr, model.ErrGroupCycle)
+	assert.ErrorIs(test, transitiveErr, model.ErrGroupCycle)
+	assert.NoError(test, shortcutErr)
+}
+
+func TestShouldRemoveSubgroups(test *testing.T) {
+	// given
+	_, _, groupService := setupGroupTest()
+	parent, _ := groupService.CreateGroup(&model.CreateGroupRequest{Name: "parent"})
+	child, _ := groupService.CreateGroup(&model.CreateGroupRequest{Name: "child", ParentID: parent.ID})
+
+	// when
+	err := groupService.RemoveSubgroup(parent.ID, child.ID)
+	againErr := groupService.RemoveSubgroup(parent.ID, child.ID)
+
+	// then
+	assert.NoError(test, err)
+	assert.ErrorIs(test, againErr, model.ErrSubgroupNotFound)
+}
+
+func TestShouldRemoveDeletedUsersFromGroups(test *testing.T) {
+	// given
+	_, userService, groupService := setupGroupTest()
+	owner, _ := userService.CreateUser(&model.CreateUserRequest{Username: "owner", Email: "owner@example.com"})
+	member, _ := userService.CreateUser(&model.CreateUserRequest{Username: "member", Email: "member@example.com"})
+	group, _ := groupService.CreateGroup(&model.CreateGroupRequest{Name: "ops", OwnerUUID: owner.UUID})
+	_, _ = groupService.SetMember(group.ID, member.UUID, model.GroupRoleMember)
+
+	// when
+	err := userService.DeleteUser(owner.UUID)
+
+	// then
+	assert.NoError(test, err)
+	page, _ := groupService.ListMembers(group.ID, model.Page{})
+	assert.Equal(test, 1, page.Total)
+	assert.Equal(test, member.UUID, page.Members[0].User.UUID)
+	assert.Equal(test, model.GroupRoleOwner, page.Members[0].Role)
+}
+
+func TestShouldLetGroupOwnersManageOnlyTheirGroups(test *testing.T) {
+	// given
+	_, userService, groupService := setupGroupTest()
+	owner, _ := userService.CreateUser(&model.CreateUserRequest{Username: "owner", Email: "owner@example.com"})
+	member, _ := userService.CreateUser(&model.CreateUserRequest{Username: "member", Email: "member@example.com"})
+	owned, _ := groupService.CreateGroup(&model.CreateGroupRequest{Name: "owned", OwnerUUID: owner.UUID})
+	other, _ := groupService.CreateGroup(&model.CreateGroupRequest{Name: "other"})
+	groups := AuthorizeGroups(groupService, userPrincipal(owner.UUID))
+
+	// when
+	_, addErr := groups.SetMember(owned.ID, member.UUID, model.GroupRoleMember)
+	_, readErr := groups.ListMembers(owned.ID, model.Page{})
+	_, otherErr := groups.SetMember(other.ID, member.UUID, model.GroupRoleMember)
+	_, readOtherErr := groups.GetGroup(other.ID)
+	_, readMissingErr := groups.GetGroup("missing")
+	_, createErr := groups.CreateGroup(&model.CreateGroupRequest{Name: "new"})
+	_, listErr := groups.ListGroups(model.Page{})
+	_, ownGroupsErr := groups.ListUserGroups(owner.UUID, model.Page{})
+	_, otherGroupsErr := groups.ListUserGroups(member.UUID, model.Page{})
+	nestErr := groups.AddSubgroup(owned.ID, other.ID)
+
+	// then
+	assert.NoError(test, addErr)
+	assert.NoError(test, readErr)
+	assert.ErrorIs(test, otherErr, model.ErrForbidden)
+	assert.ErrorIs(test, readOtherErr, model.ErrForbidden)
+	assert.ErrorIs(test, readMissingErr, model.ErrForbidden)
+	assert.ErrorIs(test, createErr, model.ErrForbidden)
+	assert.ErrorIs(test, listErr, model.ErrForbidden)
+	assert.NoError(test, ownGroupsErr)
+	assert.ErrorIs(test, otherGroupsErr, model.ErrForbidden)
+	assert.ErrorIs(test, nestErr, model.ErrForbidden)
+}
+
+func TestShouldLetGroupMembersReadButNotManage(test *testing.T) {
+	// given
+	_, userService, groupService := setupGroupTest()
+	member, _ := userService.CreateUser(&model.CreateUserRequest{Username: "member", Email: "member@example.com"})
+	group, _ := groupService.CreateGroup(&model.CreateGroupRequest{Name: "ops"})
+	_, _ = groupService.SetMember(group.ID, member.UUID, model.GroupRoleMember)
+	groups := AuthorizeGroups(groupService, userPrincipal(member.UUID))
+
+	// when
+	_, readErr := groups.ListMembers(group.ID, model.Page{})
+	_, updateErr := groups.UpdateGroup(group.ID, &model.UpdateGroupRequest{Name: "renamed"})
+	deleteErr := groups.DeleteGroup(group.ID)
+
+	// then
+	assert.NoError(test, readErr)
+	assert.ErrorIs(test, updateErr, model.ErrForbidden)
+	assert.ErrorIs(test, deleteErr, model.ErrForbidden)
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"fmt"
+)
+
+// ImportUser validates a single imported row and creates the user, or updates
+// the user matched by options.UpsertBy. With options.DryRun nothing is
+// written and the returned action describes what would have happened.
+func (userService *userService) ImportUser(request *model.CreateUserRequest, options model.ImportOptions) (model.ImportAction, *model.User, error) {
+	if err := userService.validateCreateRequest(request); err != nil {
+		return model.ImportActionFailed, nil, err
+	}
+
+	action := model.ImportActionFailed
+	var user *model.User
+	var change *userChange
+	err := userService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		existing, err := userService.findImportMatch(repos, request, options.UpsertBy)
+		if err != nil {
+			return err
+		}
+
+		if existing == nil {
+			if options.DryRun {
+				action, user = model.ImportActionCreated, &model.User{Username: request.Username, Email: request.Email, FullName: request.FullName}
+				return userService.checkUnique(repos, request)
+			}
+			action = model.ImportActionCreated
+			if user, err = userService.createUser(repos, request); err != nil {
+				return err
+			}
+			created := userCreated(user)
+			change = &created
+			return nil
+		}
+
+		update := &model.UpdateUserRequest{Username: request.Username, Email: request.Email, FullName: request.FullName}
+		updated := *existing
+		userService.updateFieldIfProvided(&updated.Username, update.Username)
+		userService.updateFieldIfProvided(&updated.Email, update.Email)
+		userService.updateFieldIfProvided(&updated.FullName, update.FullName)
+		if len(changedUserFields(existing, &updated)) == 0 {
+			action, user = model.ImportActionUnchanged, existing
+			return nil
+		}
+
+		action = model.ImportActionUpdated
+		if options.DryRun {
+			user = &updated
+			return nil
+		}
+		user, change, err = userService.updateUser(repos, existing.UUID, update)
+		return err
+	})
+	if err != nil {
+		return model.ImportActionFailed, nil, err
+	}
+	if change != nil {
+		userService.announce(*change)
+	}
+
+	return action, user, nil
+}
+
+func (userService *userService) findImportMatch(repos *repository.Repository, request *model.CreateUserRequest, upsertBy string) (*model.User, error) {
+	switch upsertBy {
+	case "":
+		return nil, nil
+	case model.UpsertByEmail:
+		return repos.Users.GetByEmail(request.Email)
+	case model.UpsertByUsername:
+		return repos.Users.GetByUsername(request.Username)
+	default:
+		return nil, model.ErrInvalidUpsertKey
+	}
+}
+
+func (userService *userService) checkUnique(repos *repository.Repository, request *model.CreateUserRequest) error {
+	byUsername, err := repos.Users.GetByUsername(request.Username)
+	if err != nil {
+		return err
+	}
+	if byUsername != nil {
+		return fmt.Errorf("username: %w", model.ErrUserAlreadyExists)
+	}
+
+	byEmail, err := repos.Users.GetByEmail(request.Email)
+	if err != nil {
+		return err
+	}
+	if byEmail != nil {
+		return fmt.Errorf("email: %w", model.ErrUserAlreadyExists)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldNotWriteDuringDryRunImport(test *testing.T) {
+	// given
+	mockRepo, mockOutbox, userService := setupTestWithOutbox()
+	request := &model.CreateUserRequest{Username: "newuser", Email: "newuser@example.com"}
+
+	// when
+	action, user, err := userService.ImportUser(request, model.ImportOptions{DryRun: true})
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, model.ImportActionCreated, action)
+	assert.Equal(test, "newuser", user.Username)
+	assert.Empty(test, mockRepo.users)
+	assert.Empty(test, mockOutbox.events)
+}
+
+func TestShouldReportConflictDuringDryRunImportWithoutUpsert(test *testing.T) {
+	// given
+	mockRepo, _, userService := setupTestWithOutbox()
+	existing := &model.User{ID: 1, UUID: generateMockUUID(1), Username: "jdoe", Email: "jdoe@example.com"}
+	mockRepo.users[existing.UUID] = existing
+
+	// when
+	action, _, err := userService.ImportUser(&model.CreateUserRequest{Username: "john", Email: "jdoe@example.com"}, model.ImportOptions{DryRun: true})
+
+	// then
+	assert.ErrorIs(test, err, model.ErrUserAlreadyExists)
+	assert.Equal(test, model.ImportActionFailed, action)
+}
+
+func TestShouldUpsertImportedUserByEmail(test *testing.T) {
+	// given
+	mockRepo, mockOutbox, userService := setupTestWithOutbox()
+	existing := &model.User{ID: 1, UUID: generateMockUUID(1), Username: "jdoe", Email: "jdoe@example.com", FullName: "J Doe"}
+	mockRepo.users[existing.UUID] = existing
+	request := &model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"}
+
+	// when
+	action, user, err := userService.ImportUser(request, model.ImportOptions{UpsertBy: model.UpsertByEmail})
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, model.ImportActionUpdated, action)
+	assert.Equal(test, existing.UUID, user.UUID)
+	assert.Equal(test, "John Doe", mockRepo.users[existing.UUID].FullName)
+	assert.Len(test, mockOutbox.events, 1)
+
+	// when
+	action, _, err = userService.ImportUser(request, model.ImportOptions{UpsertBy: model.UpsertByEmail})
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, model.ImportActionUnchanged, action)
+	assert.Len(test, mockOutbox.events, 1)
+}
+
+func TestShouldSendVerificationForImportedUsers(test *testing.T) {
+	// given
+	_, mailer, userService, verifications := setupVerificationTest()
+	options := model.ImportOptions{UpsertBy: model.UpsertByUsername}
+
+	// when
+	_, _, createErr := userService.ImportUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"}, options)
+	_, _, unchangedErr := userService.ImportUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"}, options)
+	_, _, changeErr := userService.ImportUser(&model.CreateUserRequest{Username: "jdoe", Email: "john@example.com"}, options)
+	_, _, dryRunErr := userService.ImportUser(&model.CreateUserRequest{Username: "asmith", Email: "asmith@example.com"}, model.ImportOptions{DryRun: true})
+
+	// then
+	require.NoError(test, createErr)
+	require.NoError(test, unchangedErr)
+	require.NoError(test, changeErr)
+	require.NoError(test, dryRunErr)
+	messages := verificationMails(mailer)
+	require.Len(test, messages, 2)
+	assert.Equal(test, "jdoe@example.com", messages[0].To)
+	assert.Equal(test, "john@example.com", messages[1].To)
+	_, verifyErr := verifications.VerifyEmail(sentToken(test, messages[1]))
+	assert.NoError(test, verifyErr)
+}
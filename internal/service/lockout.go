@@ -0,0 +1,227 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	userThrottlePrefix  = "user:"
+	loginThrottlePrefix = "login:"
+	ipThrottlePrefix    = "ip:"
+	// throttleRetention is how long idle throttles are kept. It has to
+	// exceed every Window and LockDuration.
+	throttleRetention = 24 * time.Hour
+	systemActor       = "system"
+)
+
+type loginAttempt struct {
+	user       *model.User
+	accountKey string
+	ipAddress  string
+	// accountFailures and ipFailures include this attempt once reserved.
+	accountFailures int
+	ipFailures      int
+}
+
+// accountThrottleKey throttles known users by UUID, whichever login they
+// use, and unknown logins by tenant and a hash of the normalized login, so
+// failures in one tenant cannot lock the same login out of another.
+func accountThrottleKey(tenantID string, user *model.User, login string) string {
+	if user != nil {
+		return userThrottlePrefix + user.UUID
+	}
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(login))))
+	return loginThrottlePrefix + tenantID + ":" + hex.EncodeToString(sum[:])
+}
+
+// check returns a LoginThrottledError if throttle blocks attempts at now.
+// Failures include reserved attempts still being verified.
+func (policy LoginThrottlePolicy) check(throttle *model.LoginThrottle, now time.Time) error {
+	if throttle == nil {
+		return nil
+	}
+	if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+		return &model.LoginThrottledError{RetryAfter: throttle.LockedUntil.Sub(now), Locked: true}
+	}
+	inWindow := !throttle.LastFailedAt.Before(now.Add(-policy.Window))
+	if inWindow && policy.reached(throttle.Failures) {
+		// The attempt that reached the limit is still being verified and
+		// most likely locks the key.
+		return &model.LoginThrottledError{RetryAfter: policy.LockDuration, Locked: true}
+	}
+	if !inWindow || policy.DelayAfter <= 0 || throttle.Failures < policy.DelayAfter {
+		return nil
+	}
+	next := throttle.LastFailedAt.Add(policy.delay(throttle.Failures))
+	if now.Before(next) {
+		return &model.LoginThrottledError{RetryAfter: next.Sub(now)}
+	}
+	return nil
+}
+
+// delay is the wait after the given number of failures.
+func (policy LoginThrottlePolicy) delay(failures int) time.Duration {
+	delay := policy.BaseDelay
+	for range failures - policy.DelayAfter {
+		if delay >= policy.MaxDelay {
+			break
+		}
+		delay *= 2
+	}
+	return min(delay, policy.MaxDelay)
+}
+
+// reserveAttempt counts the attempt as failed before its password is
+// verified, so concurrent guesses see each other and cannot get past the
+// throttles together. Login takes the reservation back unless the password
+// turns out to be wrong.
+func (authService *authService) reserveAttempt(attempt *loginAttempt) error {
+	return authService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		now := authService.now()
+		if err := repos.LoginThrottles.Prune(now.Add(-throttleRetention)); err != nil {
+			return err
+		}
+		ipKey := ipThrottlePrefix + attempt.ipAddress
+		if attempt.ipAddress != "" {
+			if err := acquire(repos, ipKey, authService.config.IPThrottle, now); err != nil {
+				return err
+			}
+		}
+		if err := acquire(repos, attempt.accountKey, authService.config.AccountThrottle, now); err != nil {
+			return err
+		}
+
+		var err error
+		if attempt.ipAddress != "" {
+			if attempt.ipFailures, err = countFailure(repos, ipKey, authService.config.IPThrottle, now); err != nil {
+				return err
+			}
+		}
+		attempt.accountFailures, err = countFailure(repos, attempt.accountKey, authService.config.AccountThrottle, now)
+		return err
+	})
+}
+
+// acquire locks the throttle of key and checks that it lets the attempt
+// through.
+func acquire(repos *repository.Repository, key string, policy LoginThrottlePolicy, now time.Time) error {
+	throttle, err := repos.LoginThrottles.Acquire(key, now)
+	if err != nil {
+		return err
+	}
+	return policy.check(throttle, now)
+}
+
+// countFailure returns the failures of key including this one.
+func countFailure(repos *repository.Repository, key string, policy LoginThrottlePolicy, now time.Time) (int, error) {
+	throttle, err := repos.LoginThrottles.RecordFailure(key, now, now.Add(-policy.Window))
+	if err != nil {
+		return 0, err
+	}
+	return throttle.Failures, nil
+}
+
+// recordFailure locks the account and the client IP once the failed
+// attempt brought either to its limit.
+func (authService *authService) recordFailure(attempt *loginAttempt) error {
+	return authService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		now := authService.now()
+		policy := authService.config.AccountThrottle
+		if policy.reached(attempt.accountFailures) {
+			if err := repos.LoginThrottles.Lock(attempt.accountKey, now.Add(policy.LockDuration)); err != nil {
+				return err
+			}
+			if attempt.user != nil {
+				err := repos.Audit.Record(&model.AuditEvent{
+					UserUUID:  attempt.user.UUID,
+					Action:    model.AuditLoginLocked,
+					Detail:    fmt.Sprintf("%d failed logins; locked for %s", policy.LockAfter, policy.LockDuration),
+					Actor:     systemActor,
+					IPAddress: attempt.ipAddress,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		policy = authService.config.IPThrottle
+		if attempt.ipAddress == "" || !policy.reached(attempt.ipFailures) {
+			return nil
+		}
+		if err := repos.LoginThrottles.Lock(ipThrottlePrefix+attempt.ipAddress, now.Add(policy.LockDuration)); err != nil {
+			return err
+		}
+		return repos.Audit.Record(&model.AuditEvent{
+			Action:    model.AuditIPLocked,
+			Detail:    fmt.Sprintf("%d failed logins; locked for %s", policy.LockAfter, policy.LockDuration),
+			Actor:     systemActor,
+			IPAddress: attempt.ipAddress,
+		})
+	})
+}
+
+// reached reports whether failures lock the key.
+func (policy LoginThrottlePolicy) reached(failures int) bool {
+	return policy.LockAfter > 0 && failures >= policy.LockAfter
+}
+
+// releaseAttempt takes back the reservation of an attempt that failed for
+// another reason than a wrong password.
+func (authService *authService) releaseAttempt(attempt *loginAttempt) error {
+	return authService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if attempt.ipAddress != "" {
+			if err := repos.LoginThrottles.ReleaseFailure(ipThrottlePrefix + attempt.ipAddress); err != nil {
+				return err
+			}
+		}
+		return repos.LoginThrottles.ReleaseFailure(attempt.accountKey)
+	})
+}
+
+// resetThrottle forgets the account's failures after a successful login.
+// Those of the IP stay, as one address may try many accounts; only the
+// reservation of this attempt is taken back.
+func (authService *authService) resetThrottle(attempt *loginAttempt) error {
+	return authService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if attempt.ipAddress != "" {
+			if err := repos.LoginThrottles.ReleaseFailure(ipThrottlePrefix + attempt.ipAddress); err != nil {
+				return err
+			}
+		}
+		return repos.LoginThrottles.Reset(attempt.accountKey)
+	})
+}
+
+func (authService *authService) Unlock(uuid, actor string) error {
+	return authService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		user, err := requireUser(repos, uuid)
+		if err != nil {
+			return err
+		}
+		key := accountThrottleKey(authService.tenantID, user, "")
+		throttle, err := repos.LoginThrottles.Get(key)
+		if err != nil || throttle == nil {
+			return err
+		}
+		if err := repos.LoginThrottles.Reset(key); err != nil {
+			return err
+		}
+		detail := fmt.Sprintf("%d failed logins cleared", throttle.Failures)
+		if throttle.LockedUntil != nil && authService.now().Before(*throttle.LockedUntil) {
+			detail = "lockout lifted"
+		}
+		return repos.Audit.Record(&model.AuditEvent{
+			UserUUID: user.UUID,
+			Action:   model.AuditLoginUnlocked,
+			Detail:   detail,
+			Actor:    actor,
+		})
+	})
+}
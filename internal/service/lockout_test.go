@@ -0,0 +1,338 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockLoginThrottleRepository struct {
+	throttles map[string]*model.LoginThrottle
+}
+
+func newMockLoginThrottleRepository() *mockLoginThrottleRepository {
+	return &mockLoginThrottleRepository{throttles: make(map[string]*model.LoginThrottle)}
+}
+
+func (loginThrottleRepository *mockLoginThrottleRepository) Get(key string) (*model.LoginThrottle, error) {
+	throttle, ok := loginThrottleRepository.throttles[key]
+	if !ok {
+		return nil, nil
+	}
+	copied := *throttle
+	return &copied, nil
+}
+
+func (loginThrottleRepository *mockLoginThrottleRepository) Acquire(key string, now time.Time) (*model.LoginThrottle, error) {
+	if _, ok := loginThrottleRepository.throttles[key]; !ok {
+		loginThrottleRepository.throttles[key] = &model.LoginThrottle{Key: key, LastFailedAt: now}
+	}
+	return loginThrottleRepository.Get(key)
+}
+
+func (loginThrottleRepository *mockLoginThrottleRepository) RecordFailure(key string, now, windowStart time.Time) (*model.LoginThrottle, error) {
+	throttle, ok := loginThrottleRepository.throttles[key]
+	if !ok {
+		throttle = &model.LoginThrottle{Key: key}
+		loginThrottleRepository.throttles[key] = throttle
+	}
+	if throttle.LastFailedAt.Before(windowStart) {
+		throttle.Failures = 0
+	}
+	throttle.Failures++
+	throttle.LastFailedAt = now
+	copied := *throttle
+	return &copied, nil
+}
+
+func (loginThrottleRepository *mockLoginThrottleRepository) ReleaseFailure(key string) error {
+	if throttle, ok := loginThrottleRepository.throttles[key]; ok && throttle.Failures > 0 {
+		throttle.Failures--
+	}
+	return nil
+}
+
+func (loginThrottleRepository *mockLoginThrottleRepository) Lock(key string, until time.Time) error {
+	if throttle, ok := loginThrottleRepository.throttles[key]; ok {
+		throttle.Failures = 0
+		throttle.LockedUntil = &until
+	}
+	return nil
+}
+
+func (loginThrottleRepository *mockLoginThrottleRepository) Reset(key string) error {
+	delete(loginThrottleRepository.throttles, key)
+	return nil
+}
+
+func (loginThrottleRepository *mockLoginThrottleRepository) Prune(before time.Time) error {
+	for key, throttle := range loginThrottleRepository.throttles {
+		if throttle.LastFailedAt.Before(before) && (throttle.LockedUntil == nil || throttle.LockedUntil.Before(before)) {
+			delete(loginThrottleRepository.throttles, key)
+		}
+	}
+	return nil
+}
+
+var _ repository.LoginThrottleRepository = (*mockLoginThrottleRepository)(nil)
+
+type mockAuditRepository struct {
+	events []model.AuditEvent
+}
+
+func (auditRepository *mockAuditRepository) Record(event *model.AuditEvent) error {
+	event.ID = int64(len(auditRepository.events) + 1)
+	auditRepository.events = append(auditRepository.events, *event)
+	return nil
+}
+
+func (auditRepository *mockAuditRepository) GetByUser(uuid string) ([]model.AuditEvent, error) {
+	events := []model.AuditEvent{}
+	for _, event := range auditRepository.events {
+		if event.UserUUID == uuid {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+var _ repository.AuditRepository = (*mockAuditRepository)(nil)
+
+const testIP = "192.0.2.1"
+
+func setupLockoutTest(test *testing.T) (*repository.Repository, *model.User, UserService, *authService, *time.Time) {
+	mockRepo := newMockUserRepository()
+	repos := newMockRepos(mockRepo, &mockOutboxRepository{})
+	transactor := &mockTransactor{repos: repos, users: mockRepo}
+	config := testPasswordConfig()
+	userService := NewUserService(mockRepo, transactor, config)
+	user, err := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", Password: "correct horse battery"})
+	require.NoError(test, err)
+
+	clock := time.Date(2025, 11, 22, 9, 0, 0, 0, time.UTC)
+	auth := NewAuthService(mockRepo, transactor, config).(*authService)
+	auth.now = func() time.Time { return clock }
+	return repos, user, userService, auth, &clock
+}
+
+func login(auth *authService, login, password string) error {
+	_, err := auth.Login(&model.LoginRequest{Login: login, Password: password}, testIP)
+	return err
+}
+
+// failLogins fails count logins, waiting out each delay first.
+func failLogins(test *testing.T, auth *authService, clock *time.Time, loginName string, count int) {
+	for range count {
+		*clock = clock.Add(auth.config.AccountThrottle.MaxDelay)
+		require.ErrorIs(test, login(auth, loginName, "wrong horse battery"), model.ErrInvalidCredentials)
+	}
+}
+
+func TestShouldDelayLoginsProgressively(test *testing.T) {
+	// given
+	_, _, _, auth, clock := setupLockoutTest(test)
+	failLogins(test, auth, clock, "jdoe", auth.config.AccountThrottle.DelayAfter)
+
+	// when
+	correctErr := login(auth, "jdoe", "correct horse battery")
+	*clock = clock.Add(time.Second)
+	afterDelayErr := login(auth, "jdoe", "wrong horse battery")
+	*clock = clock.Add(time.Second)
+	doubledErr := login(auth, "jdoe", "wrong horse battery")
+
+	// then
+	var throttled *model.LoginThrottledError
+	require.True(test, errors.As(correctErr, &throttled))
+	assert.ErrorIs(test, correctErr, model.ErrLoginThrottled)
+	assert.Equal(test, time.Second, throttled.RetryAfter)
+	assert.ErrorIs(test, afterDelayErr, model.ErrInvalidCredentials)
+	require.True(test, errors.As(doubledErr, &throttled))
+	assert.Equal(test, time.Second, throttled.RetryAfter)
+}
+
+func TestShouldLockAccountAndUnlockAfterDuration(test *testing.T) {
+	// given
+	repos, user, _, auth, clock := setupLockoutTest(test)
+	failLogins(test, auth, clock, "jdoe", auth.config.AccountThrottle.LockAfter)
+
+	// when
+	lockedErr := login(auth, "jdoe@example.com", "correct horse battery")
+	*clock = clock.Add(auth.config.AccountThrottle.LockDuration - time.Second)
+	stillLockedErr := login(auth, "jdoe", "correct horse battery")
+	*clock = clock.Add(time.Second)
+	unlockedErr := login(auth, "jdoe", "correct horse battery")
+
+	// then
+	assert.ErrorIs(test, lockedErr, model.ErrAccountLocked)
+	assert.ErrorIs(test, stillLockedErr, model.ErrAccountLocked)
+	assert.NoError(test, unlockedErr)
+	events, _ := repos.Audit.GetByUser(user.UUID)
+	require.Len(test, events, 1)
+	assert.Equal(test, model.AuditLoginLocked, events[0].Action)
+	assert.Equal(test, testIP, events[0].IPAddress)
+}
+
+func TestShouldForgetFailuresAfterSuccessfulLogin(test *testing.T) {
+	// given
+	_, _, _, auth, clock := setupLockoutTest(test)
+	failLogins(test, auth, clock, "jdoe", auth.config.AccountThrottle.DelayAfter-1)
+	*clock = clock.Add(time.Second)
+	require.NoError(test, login(auth, "jdoe", "correct horse battery"))
+
+	// when
+	failLogins(test, auth, clock, "jdoe", auth.config.AccountThrottle.DelayAfter-1)
+	err := login(auth, "jdoe", "correct horse battery")
+
+	// then
+	assert.NoError(test, err)
+}
+
+func TestShouldThrottleUnknownLoginsLikeAccounts(test *testing.T) {
+	// given
+	_, _, _, auth, clock := setupLockoutTest(test)
+	failLogins(test, auth, clock, "ghost", auth.config.AccountThrottle.LockAfter)
+
+	// when
+	err := login(auth, "Ghost", "wrong horse battery")
+
+	// then
+	assert.ErrorIs(test, err, model.ErrAccountLocked)
+}
+
+func TestShouldThrottleUnknownLoginsPerTenant(test *testing.T) {
+	// given
+	_, _, _, auth, clock := setupLockoutTest(test)
+	auth.tenantID = "tenant-a"
+	failLogins(test, auth, clock, "ghost", auth.config.AccountThrottle.LockAfter)
+	otherTenant := newAuthService("tenant-b", auth.userRepository, auth.transactor, auth.config)
+	otherTenant.now = auth.now
+
+	// when
+	lockedErr := login(auth, "ghost", "wrong horse battery")
+	_, otherTenantErr := otherTenant.Login(&model.LoginRequest{Login: "ghost", Password: "wrong horse battery"}, "198.51.100.7")
+
+	// then
+	assert.ErrorIs(test, lockedErr, model.ErrAccountLocked)
+	assert.ErrorIs(test, otherTenantErr, model.ErrInvalidCredentials)
+}
+
+func TestShouldLockClientIPAcrossAccounts(test *testing.T) {
+	// given
+	repos, _, _, auth, clock := setupLockoutTest(test)
+	auth.config.IPThrottle = LoginThrottlePolicy{LockAfter: 3, LockDuration: time.Hour, Window: time.Hour}
+	for _, name := range []string{"alice", "bob", "carol"} {
+		require.ErrorIs(test, login(auth, name, "wrong horse battery"), model.ErrInvalidCredentials)
+	}
+
+	// when
+	lockedErr := login(auth, "jdoe", "correct horse battery")
+	_, otherIPErr := auth.Login(&model.LoginRequest{Login: "jdoe", Password: "correct horse battery"}, "198.51.100.7")
+	*clock = clock.Add(time.Hour)
+	unlockedErr := login(auth, "jdoe", "correct horse battery")
+
+	// then
+	assert.ErrorIs(test, lockedErr, model.ErrAccountLocked)
+	assert.NoError(test, otherIPErr)
+	assert.NoError(test, unlockedErr)
+	audit := repos.Audit.(*mockAuditRepository)
+	require.Len(test, audit.events, 1)
+	assert.Equal(test, model.AuditIPLocked, audit.events[0].Action)
+}
+
+func TestShouldLetAdminsUnlockAccounts(test *testing.T) {
+	// given
+	_, user, userService, auth, clock := setupLockoutTest(test)
+	failLogins(test, auth, clock, "jdoe", auth.config.AccountThrottle.LockAfter)
+
+	// when
+	selfErr := AuthorizeAuth(auth, userPrincipal(user.UUID)).Unlock(user.UUID, "user:"+user.UUID)
+	unlockErr := AuthorizeAuth(auth, userPrincipal(generateMockUUID(99), string(model.RoleAdmin))).Unlock(user.UUID, "user:admin")
+	loginErr := login(auth, "jdoe", "correct horse battery")
+
+	// then
+	assert.ErrorIs(test, selfErr, model.ErrForbidden)
+	assert.NoError(test, unlockErr)
+	assert.NoError(test, loginErr)
+	events, err := userService.GetAuditEvents(user.UUID)
+	require.NoError(test, err)
+	require.Len(test, events, 2)
+	assert.Equal(test, model.AuditLoginUnlocked, events[1].Action)
+	assert.Equal(test, "user:admin", events[1].Actor)
+	assert.Equal(test, "lockout lifted", events[1].Detail)
+}
+
+// guessConcurrently fails count logins in parallel and returns how many
+// got as far as the password check.
+func guessConcurrently(test *testing.T, auth *authService, count int) int {
+	errs := make(chan error, count)
+	var wait sync.WaitGroup
+	for range count {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			errs <- login(auth, "jdoe", "wrong horse battery")
+		}()
+	}
+	wait.Wait()
+	close(errs)
+
+	verified := 0
+	for err := range errs {
+		var throttled *model.LoginThrottledError
+		if errors.Is(err, model.ErrInvalidCredentials) {
+			verified++
+		} else {
+			require.True(test, errors.As(err, &throttled), err)
+		}
+	}
+	return verified
+}
+
+func TestShouldThrottleConcurrentGuesses(test *testing.T) {
+	// given
+	_, _, _, auth, _ := setupLockoutTest(test)
+
+	// when
+	verified := guessConcurrently(test, auth, 20)
+
+	// then
+	assert.Equal(test, auth.config.AccountThrottle.DelayAfter, verified)
+}
+
+func TestShouldLockOnceWhenConcurrentGuessesReachLimit(test *testing.T) {
+	// given
+	repos, user, _, auth, _ := setupLockoutTest(test)
+	auth.config.AccountThrottle = LoginThrottlePolicy{LockAfter: 5, LockDuration: time.Hour, Window: time.Hour}
+
+	// when
+	verified := guessConcurrently(test, auth, 20)
+	err := login(auth, "jdoe", "correct horse battery")
+
+	// then
+	assert.Equal(test, 5, verified)
+	assert.ErrorIs(test, err, model.ErrAccountLocked)
+	events, _ := repos.Audit.GetByUser(user.UUID)
+	require.Len(test, events, 1)
+	assert.Equal(test, model.AuditLoginLocked, events[0].Action)
+}
+
+func TestShouldNotCountSuccessfulLoginsAgainstClientIP(test *testing.T) {
+	// given
+	repos, _, _, auth, _ := setupLockoutTest(test)
+
+	// when
+	for range auth.config.IPThrottle.DelayAfter + 1 {
+		require.NoError(test, login(auth, "jdoe", "correct horse battery"))
+	}
+
+	// then
+	throttle, err := repos.LoginThrottles.Get(ipThrottlePrefix + testIP)
+	require.NoError(test, err)
+	assert.Zero(test, throttle.Failures)
+}
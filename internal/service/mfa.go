@@ -0,0 +1,432 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/qr"
+	"cruder/internal/repository"
+	"cruder/internal/token"
+	"cruder/internal/totp"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	mfaTokenPrefix = "mfa_"
+	// mfaSkew accepts codes of one time step either side of the current one.
+	mfaSkew           = 1
+	recoveryCodeCount = 10
+	recoveryCodeBytes = 10
+)
+
+type MFAService interface {
+	GetStatus(uuid string) (*model.MFAStatus, error)
+	// Enroll starts a new enrollment, replacing a pending one. It takes
+	// effect once confirmed with a first code.
+	Enroll(uuid string) (*model.MFAEnrollment, error)
+	ConfirmEnrollment(uuid, code string) error
+	// RegenerateRecoveryCodes replaces all recovery codes, used or not.
+	RegenerateRecoveryCodes(uuid, code string) (*model.RecoveryCodes, error)
+	Disable(uuid, code string) error
+	// Reset removes the second factor without a code, for users who lost
+	// their device.
+	Reset(uuid string) error
+
+	// Challenge starts the second step of a login for user, whose password
+	// was checked. It returns nil if the user needs no second factor.
+	Challenge(user *model.User) (*model.MFAChallengeResponse, error)
+	// EnrollForChallenge enrolls the user of a challenge that requires
+	// enrollment. The first code then both confirms it and completes the
+	// login.
+	EnrollForChallenge(mfaToken string) (*model.MFAEnrollment, error)
+	// CompleteChallenge checks the second factor and returns the user to
+	// issue tokens for. Challenges allow MFAChallengeAttempts wrong codes.
+	CompleteChallenge(request *model.MFALoginRequest) (*model.User, error)
+}
+
+type mfaService struct {
+	transactor repository.Transactor
+	config     Config
+	now        func() time.Time
+}
+
+func NewMFAService(transactor repository.Transactor, config Config) MFAService {
+	return &mfaService{transactor: transactor, config: config, now: time.Now}
+}
+
+func (mfaService *mfaService) GetStatus(uuid string) (*model.MFAStatus, error) {
+	var status *model.MFAStatus
+	err := mfaService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		user, err := requireUser(repos, uuid)
+		if err != nil {
+			return err
+		}
+		required, err := mfaService.required(repos, user.UUID)
+		if err != nil {
+			return err
+		}
+		status = &model.MFAStatus{Required: required}
+
+		mfa, err := repos.MFA.Get(user.UUID)
+		if err != nil || mfa == nil || mfa.EnabledAt == nil {
+			return err
+		}
+		status.Enabled = true
+		status.EnabledAt = mfa.EnabledAt
+		status.RecoveryCodesRemaining, err = repos.MFA.CountRecoveryCodes(user.UUID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (mfaService *mfaService) Enroll(uuid string) (*model.MFAEnrollment, error) {
+	var enrollment *model.MFAEnrollment
+	err := mfaService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		user, err := requireUser(repos, uuid)
+		if err != nil {
+			return err
+		}
+		enrollment, err = mfaService.enroll(repos, user)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+func (mfaService *mfaService) ConfirmEnrollment(uuid, code string) error {
+	return mfaService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		user, err := requireUser(repos, uuid)
+		if err != nil {
+			return err
+		}
+		mfa, err := repos.MFA.Get(user.UUID)
+		if err != nil {
+			return err
+		}
+		if mfa == nil {
+			return model.ErrMFANotEnrolled
+		}
+		if mfa.EnabledAt != nil {
+			return model.ErrMFAAlreadyEnabled
+		}
+		valid, err := mfaService.verifyCode(repos, mfa, code)
+		if err != nil {
+			return err
+		}
+		if !valid {
+			return model.ErrInvalidMFACode
+		}
+		return repos.MFA.Enable(user.UUID)
+	})
+}
+
+func (mfaService *mfaService) RegenerateRecoveryCodes(uuid, code string) (*model.RecoveryCodes, error) {
+	var codes []string
+	err := mfaService.withEnabled(uuid, code, func(repos *repository.Repository, mfa *model.UserMFA) error {
+		var err error
+		codes, err = replaceRecoveryCodes(repos, mfa.UserUUID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.RecoveryCodes{RecoveryCodes: codes}, nil
+}
+
+func (mfaService *mfaService) Disable(uuid, code string) error {
+	return mfaService.withEnabled(uuid, code, func(repos *repository.Repository, mfa *model.UserMFA) error {
+		return repos.MFA.Delete(mfa.UserUUID)
+	})
+}
+
+func (mfaService *mfaService) Reset(uuid string) error {
+	return mfaService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		user, err := requireUser(repos, uuid)
+		if err != nil {
+			return err
+		}
+		mfa, err := repos.MFA.Get(user.UUID)
+		if err != nil {
+			return err
+		}
+		if mfa == nil {
+			return model.ErrMFANotEnrolled
+		}
+		return repos.MFA.Delete(user.UUID)
+	})
+}
+
+// withEnabled runs fn for a user with enabled MFA after checking code.
+func (mfaService *mfaService) withEnabled(uuid, code string, fn func(repos *repository.Repository, mfa *model.UserMFA) error) error {
+	return mfaService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		user, err := requireUser(repos, uuid)
+		if err != nil {
+			return err
+		}
+		mfa, err := repos.MFA.Get(user.UUID)
+		if err != nil {
+			return err
+		}
+		if mfa == nil || mfa.EnabledAt == nil {
+			return model.ErrMFANotEnrolled
+		}
+		valid, err := mfaService.verifyCode(repos, mfa, code)
+		if err != nil {
+			return err
+		}
+		if !valid {
+			return model.ErrInvalidMFACode
+		}
+		return fn(repos, mfa)
+	})
+}
+
+func (mfaService *mfaService) Challenge(user *model.User) (*model.MFAChallengeResponse, error) {
+	var response *model.MFAChallengeResponse
+	err := mfaService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		mfa, err := repos.MFA.Get(user.UUID)
+		if err != nil {
+			return err
+		}
+		enabled := mfa != nil && mfa.EnabledAt != nil
+		required, err := mfaService.required(repos, user.UUID)
+		if err != nil {
+			return err
+		}
+		if !enabled && !required {
+			return nil
+		}
+		// Without the key no code can be checked, so such users cannot sign
+		// in rather than skipping their second factor.
+		if mfaService.config.MFASecrets == nil {
+			return model.ErrMFAUnavailable
+		}
+		if err := repos.MFA.DeleteExpiredChallenges(mfaService.now()); err != nil {
+			return err
+		}
+
+		rawToken, hash, err := token.NewOpaque(mfaTokenPrefix)
+		if err != nil {
+			return err
+		}
+		challenge := &model.MFAChallenge{UserUUID: user.UUID, ExpiresAt: mfaService.now().Add(mfaService.config.MFAChallengeTTL)}
+		if err := repos.MFA.CreateChallenge(challenge, hash); err != nil {
+			return err
+		}
+		response = &model.MFAChallengeResponse{
+			MFARequired:        true,
+			MFAToken:           rawToken,
+			EnrollmentRequired: !enabled,
+			ExpiresIn:          int(mfaService.config.MFAChallengeTTL.Seconds()),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (mfaService *mfaService) EnrollForChallenge(mfaToken string) (*model.MFAEnrollment, error) {
+	var enrollment *model.MFAEnrollment
+	err := mfaService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		_, user, err := mfaService.openChallenge(repos, mfaToken)
+		if err != nil {
+			return err
+		}
+		enrollment, err = mfaService.enroll(repos, user)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+func (mfaService *mfaService) CompleteChallenge(request *model.MFALoginRequest) (*model.User, error) {
+	var user *model.User
+	failed := false
+	err := mfaService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		challenge, challenged, err := mfaService.openChallenge(repos, request.MFAToken)
+		if err != nil {
+			return err
+		}
+		mfa, err := repos.MFA.Get(challenged.UUID)
+		if err != nil {
+			return err
+		}
+		if mfa == nil {
+			return model.ErrMFANotEnrolled
+		}
+
+		valid := false
+		if request.RecoveryCode != "" && mfa.EnabledAt != nil {
+			valid, err = repos.MFA.UseRecoveryCode(mfa.UserUUID, hashRecoveryCode(request.RecoveryCode))
+		} else if request.Code != "" {
+			valid, err = mfaService.verifyCode(repos, mfa, request.Code)
+		}
+		if err != nil {
+			return err
+		}
+		if !valid {
+			// Commit the failed attempt so it counts against the limit.
+			failed = true
+			return repos.MFA.RecordChallengeFailure(challenge.ID)
+		}
+
+		consumed, err := repos.MFA.ConsumeChallenge(challenge.ID)
+		if err != nil {
+			return err
+		}
+		if !consumed {
+			return model.ErrInvalidToken
+		}
+		if mfa.EnabledAt == nil {
+			if err := repos.MFA.Enable(mfa.UserUUID); err != nil {
+				return err
+			}
+		}
+		user = challenged
+		return nil
+	})
+	if err == nil && failed {
+		return nil, model.ErrInvalidMFACode
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// openChallenge returns a usable challenge and the user it was issued to.
+func (mfaService *mfaService) openChallenge(repos *repository.Repository, mfaToken string) (*model.MFAChallenge, *model.User, error) {
+	challenge, err := repos.MFA.GetChallenge(token.HashOpaque(mfaToken))
+	if err != nil {
+		return nil, nil, err
+	}
+	if challenge == nil || !challenge.Usable(mfaService.now(), mfaService.config.MFAChallengeAttempts) {
+		return nil, nil, model.ErrInvalidToken
+	}
+	user, err := repos.Users.GetByUUID(challenge.UserUUID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil || user.Status == model.UserStatusSuspended || user.Status == model.UserStatusDeactivated {
+		return nil, nil, model.ErrInvalidToken
+	}
+	return challenge, user, nil
+}
+
+func (mfaService *mfaService) enroll(repos *repository.Repository, user *model.User) (*model.MFAEnrollment, error) {
+	if mfaService.config.MFASecrets == nil {
+		return nil, model.ErrMFAUnavailable
+	}
+	existing, err := repos.MFA.Get(user.UUID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.EnabledAt != nil {
+		return nil, model.ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := mfaService.config.MFASecrets.Seal(secret, []byte(user.UUID))
+	if err != nil {
+		return nil, err
+	}
+	if err := repos.MFA.SavePending(&model.UserMFA{UserUUID: user.UUID, Secret: sealed}); err != nil {
+		return nil, err
+	}
+	codes, err := replaceRecoveryCodes(repos, user.UUID)
+	if err != nil {
+		return nil, err
+	}
+
+	uri := totp.URI(mfaService.config.TokenIssuer, user.Username, secret)
+	code, err := qr.Encode([]byte(uri))
+	if err != nil {
+		return nil, err
+	}
+	png, err := code.PNG(8)
+	if err != nil {
+		return nil, err
+	}
+	return &model.MFAEnrollment{
+		Secret:        totp.EncodeSecret(secret),
+		URI:           uri,
+		QRCode:        "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// verifyCode checks a TOTP code and records its time step, so the same
+// code is rejected when presented again.
+func (mfaService *mfaService) verifyCode(repos *repository.Repository, mfa *model.UserMFA, code string) (bool, error) {
+	if mfaService.config.MFASecrets == nil {
+		return false, model.ErrMFAUnavailable
+	}
+	secret, err := mfaService.config.MFASecrets.Open(mfa.Secret, []byte(mfa.UserUUID))
+	if err != nil {
+		return false, err
+	}
+	step, ok := totp.Verify(secret, strings.ReplaceAll(code, " ", ""), mfaService.now(), mfaSkew)
+	if !ok {
+		return false, nil
+	}
+	return repos.MFA.UseCounter(mfa.UserUUID, step)
+}
+
+// required reports whether one of the user's roles requires MFA.
+func (mfaService *mfaService) required(repos *repository.Repository, uuid string) (bool, error) {
+	if len(mfaService.config.MFARequiredRoles) == 0 {
+		return false, nil
+	}
+	roles, err := repos.Roles.GetByUser(uuid)
+	if err != nil {
+		return false, err
+	}
+	for _, role := range roles {
+		if slices.Contains(mfaService.config.MFARequiredRoles, role) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// replaceRecoveryCodes generates new recovery codes formatted as
+// XXXX-XXXX-XXXX-XXXX and stores their hashes.
+func replaceRecoveryCodes(repos *repository.Repository, uuid string) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([][]byte, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		random := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(random); err != nil {
+			return nil, err
+		}
+		encoded := base32.StdEncoding.EncodeToString(random)
+		codes = append(codes, encoded[0:4]+"-"+encoded[4:8]+"-"+encoded[8:12]+"-"+encoded[12:16])
+		hashes = append(hashes, hashRecoveryCode(encoded))
+	}
+	if err := repos.MFA.ReplaceRecoveryCodes(uuid, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode ignores case, dashes and spaces, which users may type
+// differently.
+func hashRecoveryCode(code string) []byte {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return token.HashOpaque(normalized)
+}
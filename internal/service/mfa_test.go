@@ -0,0 +1,379 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"cruder/internal/secretbox"
+	"cruder/internal/totp"
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockRecoveryCode struct {
+	hash string
+	used bool
+}
+
+type mockMFARepository struct {
+	mfa           map[string]*model.UserMFA
+	recoveryCodes map[string][]*mockRecoveryCode
+	challenges    map[string]*model.MFAChallenge
+	nextID        int64
+}
+
+func newMockMFARepository() *mockMFARepository {
+	return &mockMFARepository{
+		mfa:           make(map[string]*model.UserMFA),
+		recoveryCodes: make(map[string][]*mockRecoveryCode),
+		challenges:    make(map[string]*model.MFAChallenge),
+	}
+}
+
+func (mfaRepository *mockMFARepository) Get(userUUID string) (*model.UserMFA, error) {
+	mfa, ok := mfaRepository.mfa[userUUID]
+	if !ok {
+		return nil, nil
+	}
+	copied := *mfa
+	return &copied, nil
+}
+
+func (mfaRepository *mockMFARepository) SavePending(mfa *model.UserMFA) error {
+	mfa.CreatedAt = time.Now()
+	mfa.EnabledAt = nil
+	mfa.LastCounter = nil
+	stored := *mfa
+	mfaRepository.mfa[mfa.UserUUID] = &stored
+	return nil
+}
+
+func (mfaRepository *mockMFARepository) Enable(userUUID string) error {
+	if mfa, ok := mfaRepository.mfa[userUUID]; ok && mfa.EnabledAt == nil {
+		enabledAt := time.Now()
+		mfa.EnabledAt = &enabledAt
+	}
+	return nil
+}
+
+func (mfaRepository *mockMFARepository) Delete(userUUID string) error {
+	delete(mfaRepository.mfa, userUUID)
+	delete(mfaRepository.recoveryCodes, userUUID)
+	return nil
+}
+
+func (mfaRepository *mockMFARepository) UseCounter(userUUID string, counter int64) (bool, error) {
+	mfa, ok := mfaRepository.mfa[userUUID]
+	if !ok || (mfa.LastCounter != nil && *mfa.LastCounter >= counter) {
+		return false, nil
+	}
+	mfa.LastCounter = &counter
+	return true, nil
+}
+
+func (mfaRepository *mockMFARepository) ReplaceRecoveryCodes(userUUID string, hashes [][]byte) error {
+	codes := make([]*mockRecoveryCode, 0, len(hashes))
+	for _, hash := range hashes {
+		codes = append(codes, &mockRecoveryCode{hash: string(hash)})
+	}
+	mfaRepository.recoveryCodes[userUUID] = codes
+	return nil
+}
+
+func (mfaRepository *mockMFARepository) UseRecoveryCode(userUUID string, hash []byte) (bool, error) {
+	for _, code := range mfaRepository.recoveryCodes[userUUID] {
+		if code.hash == string(hash) && !code.used {
+			code.used = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (mfaRepository *mockMFARepository) CountRecoveryCodes(userUUID string) (int, error) {
+	count := 0
+	for _, code := range mfaRepository.recoveryCodes[userUUID] {
+		if !code.used {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (mfaRepository *mockMFARepository) CreateChallenge(challenge *model.MFAChallenge, hash []byte) error {
+	mfaRepository.nextID++
+	challenge.ID = mfaRepository.nextID
+	stored := *challenge
+	mfaRepository.challenges[string(hash)] = &stored
+	return nil
+}
+
+func (mfaRepository *mockMFARepository) GetChallenge(hash []byte) (*model.MFAChallenge, error) {
+	challenge, ok := mfaRepository.challenges[string(hash)]
+	if !ok {
+		return nil, nil
+	}
+	copied := *challenge
+	return &copied, nil
+}
+
+func (mfaRepository *mockMFARepository) challenge(id int64) *model.MFAChallenge {
+	for _, challenge := range mfaRepository.challenges {
+		if challenge.ID == id {
+			return challenge
+		}
+	}
+	return nil
+}
+
+func (mfaRepository *mockMFARepository) RecordChallengeFailure(id int64) error {
+	if challenge := mfaRepository.challenge(id); challenge != nil {
+		challenge.Attempts++
+	}
+	return nil
+}
+
+func (mfaRepository *mockMFARepository) ConsumeChallenge(id int64) (bool, error) {
+	challenge := mfaRepository.challenge(id)
+	if challenge == nil || challenge.UsedAt != nil {
+		return false, nil
+	}
+	usedAt := time.Now()
+	challenge.UsedAt = &usedAt
+	return true, nil
+}
+
+func (mfaRepository *mockMFARepository) DeleteExpiredChallenges(before time.Time) error {
+	for hash, challenge := range mfaRepository.challenges {
+		if challenge.ExpiresAt.Before(before) {
+			delete(mfaRepository.challenges, hash)
+		}
+	}
+	return nil
+}
+
+var _ repository.MFARepository = (*mockMFARepository)(nil)
+
+func setupMFATest(test *testing.T, requiredRoles ...string) (*repository.Repository, *model.User, *mfaService, *time.Time) {
+	mockRepo := newMockUserRepository()
+	repos := newMockRepos(mockRepo, &mockOutboxRepository{})
+	transactor := &mockTransactor{repos: repos, users: mockRepo}
+	user := &model.User{ID: 1, UUID: generateMockUUID(1), Username: "jdoe", Email: "jdoe@example.com", Status: model.UserStatusActive}
+	mockRepo.users[user.UUID] = user
+
+	key := make([]byte, secretbox.KeySize)
+	box, err := secretbox.New(key)
+	require.NoError(test, err)
+	config := DefaultConfig()
+	config.MFASecrets = box
+	config.MFARequiredRoles = requiredRoles
+
+	clock := time.Date(2025, 11, 21, 9, 0, 0, 0, time.UTC)
+	mfa := NewMFAService(transactor, config).(*mfaService)
+	mfa.now = func() time.Time { return clock }
+	return repos, user, mfa, &clock
+}
+
+func currentCode(test *testing.T, enrollment *model.MFAEnrollment, now time.Time) string {
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.Secret)
+	require.NoError(test, err)
+	return totp.Code(secret, totp.Counter(now))
+}
+
+// enrolled enrolls the user and confirms the enrollment one step before
+// the clock, so the code of the current step is still unused.
+func enrolled(test *testing.T, user *model.User, mfa *mfaService, clock *time.Time) *model.MFAEnrollment {
+	enrollment, err := mfa.Enroll(user.UUID)
+	require.NoError(test, err)
+	require.NoError(test, mfa.ConfirmEnrollment(user.UUID, currentCode(test, enrollment, clock.Add(-totp.Period))))
+	return enrollment
+}
+
+func TestShouldEnrollAndConfirmMFA(test *testing.T) {
+	// given
+	_, user, mfa, clock := setupMFATest(test)
+
+	// when
+	enrollment, err := mfa.Enroll(user.UUID)
+	require.NoError(test, err)
+	pending, pendingErr := mfa.GetStatus(user.UUID)
+	wrongErr := mfa.ConfirmEnrollment(user.UUID, "000000")
+	confirmErr := mfa.ConfirmEnrollment(user.UUID, currentCode(test, enrollment, *clock))
+	status, statusErr := mfa.GetStatus(user.UUID)
+
+	// then
+	assert.True(test, strings.HasPrefix(enrollment.URI, "otpauth://totp/cruder:jdoe?"))
+	assert.True(test, strings.HasPrefix(enrollment.QRCode, "data:image/png;base64,"))
+	assert.Len(test, enrollment.RecoveryCodes, 10)
+	assert.NoError(test, pendingErr)
+	assert.False(test, pending.Enabled)
+	assert.ErrorIs(test, wrongErr, model.ErrInvalidMFACode)
+	assert.NoError(test, confirmErr)
+	assert.NoError(test, statusErr)
+	assert.True(test, status.Enabled)
+	assert.Equal(test, 10, status.RecoveryCodesRemaining)
+}
+
+func TestShouldStoreMFASecretEncrypted(test *testing.T) {
+	// given
+	repos, user, mfa, _ := setupMFATest(test)
+
+	// when
+	enrollment, err := mfa.Enroll(user.UUID)
+
+	// then
+	require.NoError(test, err)
+	stored, _ := repos.MFA.Get(user.UUID)
+	secret, _ := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.Secret)
+	assert.NotContains(test, string(stored.Secret), string(secret))
+}
+
+func TestShouldRejectEnrollmentWithoutEncryptionKey(test *testing.T) {
+	// given
+	_, user, mfa, _ := setupMFATest(test)
+	mfa.config.MFASecrets = nil
+
+	// when
+	_, err := mfa.Enroll(user.UUID)
+
+	// then
+	assert.ErrorIs(test, err, model.ErrMFAUnavailable)
+}
+
+func TestShouldRejectReplayedCode(test *testing.T) {
+	// given
+	_, user, mfa, clock := setupMFATest(test)
+	enrollment, _ := mfa.Enroll(user.UUID)
+	code := currentCode(test, enrollment, *clock)
+	require.NoError(test, mfa.ConfirmEnrollment(user.UUID, code))
+
+	// when
+	_, replayErr := mfa.RegenerateRecoveryCodes(user.UUID, code)
+	*clock = clock.Add(totp.Period)
+	codes, nextErr := mfa.RegenerateRecoveryCodes(user.UUID, currentCode(test, enrollment, *clock))
+
+	// then
+	assert.ErrorIs(test, replayErr, model.ErrInvalidMFACode)
+	assert.NoError(test, nextErr)
+	assert.Len(test, codes.RecoveryCodes, 10)
+}
+
+func TestShouldCompleteLoginChallengeWithCode(test *testing.T) {
+	// given
+	_, user, mfa, clock := setupMFATest(test)
+	enrollment := enrolled(test, user, mfa, clock)
+
+	// when
+	challenge, err := mfa.Challenge(user)
+	require.NoError(test, err)
+	_, wrongErr := mfa.CompleteChallenge(&model.MFALoginRequest{MFAToken: challenge.MFAToken, Code: "000000"})
+	loggedIn, loginErr := mfa.CompleteChallenge(&model.MFALoginRequest{MFAToken: challenge.MFAToken,
+		Code: currentCode(test, enrollment, *clock)})
+	_, reuseErr := mfa.CompleteChallenge(&model.MFALoginRequest{MFAToken: challenge.MFAToken,
+		Code: currentCode(test, enrollment, *clock)})
+
+	// then
+	assert.True(test, challenge.MFARequired)
+	assert.False(test, challenge.EnrollmentRequired)
+	assert.ErrorIs(test, wrongErr, model.ErrInvalidMFACode)
+	assert.NoError(test, loginErr)
+	assert.Equal(test, user.UUID, loggedIn.UUID)
+	assert.ErrorIs(test, reuseErr, model.ErrInvalidToken)
+}
+
+func TestShouldAcceptRecoveryCodeOnlyOnce(test *testing.T) {
+	// given
+	_, user, mfa, clock := setupMFATest(test)
+	enrollment := enrolled(test, user, mfa, clock)
+	recoveryCode := strings.ToLower(enrollment.RecoveryCodes[0])
+	first, _ := mfa.Challenge(user)
+	second, _ := mfa.Challenge(user)
+
+	// when
+	_, firstErr := mfa.CompleteChallenge(&model.MFALoginRequest{MFAToken: first.MFAToken, RecoveryCode: recoveryCode})
+	_, secondErr := mfa.CompleteChallenge(&model.MFALoginRequest{MFAToken: second.MFAToken, RecoveryCode: recoveryCode})
+
+	// then
+	assert.NoError(test, firstErr)
+	assert.ErrorIs(test, secondErr, model.ErrInvalidMFACode)
+	status, _ := mfa.GetStatus(user.UUID)
+	assert.Equal(test, 9, status.RecoveryCodesRemaining)
+}
+
+func TestShouldExpireChallengeAfterTooManyAttempts(test *testing.T) {
+	// given
+	_, user, mfa, clock := setupMFATest(test)
+	enrollment := enrolled(test, user, mfa, clock)
+	challenge, _ := mfa.Challenge(user)
+
+	// when
+	for range mfa.config.MFAChallengeAttempts {
+		_, _ = mfa.CompleteChallenge(&model.MFALoginRequest{MFAToken: challenge.MFAToken, Code: "000000"})
+	}
+	_, err := mfa.CompleteChallenge(&model.MFALoginRequest{MFAToken: challenge.MFAToken,
+		Code: currentCode(test, enrollment, *clock)})
+
+	// then
+	assert.ErrorIs(test, err, model.ErrInvalidToken)
+}
+
+func TestShouldNotChallengeUsersWithoutMFA(test *testing.T) {
+	// given
+	_, user, mfa, _ := setupMFATest(test, string(model.RoleAdmin))
+	_, _ = mfa.Enroll(user.UUID)
+
+	// when
+	challenge, err := mfa.Challenge(user)
+
+	// then
+	assert.NoError(test, err)
+	assert.Nil(test, challenge)
+}
+
+func TestShouldEnrollDuringLoginWhenRoleRequiresMFA(test *testing.T) {
+	// given
+	repos, user, mfa, clock := setupMFATest(test, string(model.RoleAdmin))
+	require.NoError(test, repos.Roles.Replace(user.UUID, []string{string(model.RoleAdmin)}))
+
+	// when
+	challenge, err := mfa.Challenge(user)
+	require.NoError(test, err)
+	_, notEnrolledErr := mfa.CompleteChallenge(&model.MFALoginRequest{MFAToken: challenge.MFAToken, Code: "000000"})
+	enrollment, enrollErr := mfa.EnrollForChallenge(challenge.MFAToken)
+	require.NoError(test, enrollErr)
+	_, loginErr := mfa.CompleteChallenge(&model.MFALoginRequest{MFAToken: challenge.MFAToken,
+		Code: currentCode(test, enrollment, *clock)})
+
+	// then
+	assert.True(test, challenge.EnrollmentRequired)
+	assert.ErrorIs(test, notEnrolledErr, model.ErrMFANotEnrolled)
+	assert.NoError(test, loginErr)
+	status, _ := mfa.GetStatus(user.UUID)
+	assert.True(test, status.Enabled)
+	assert.True(test, status.Required)
+}
+
+func TestShouldLetOnlyUsersEnrollThemselves(test *testing.T) {
+	// given
+	repos, user, mfa, clock := setupMFATest(test)
+	enrolled(test, user, mfa, clock)
+	admin := userPrincipal(generateMockUUID(2), string(model.RoleAdmin))
+
+	// when
+	_, enrollErr := AuthorizeMFA(mfa, admin).Enroll(user.UUID)
+	_, selfStatusErr := AuthorizeMFA(mfa, userPrincipal(user.UUID)).GetStatus(user.UUID)
+	selfResetErr := AuthorizeMFA(mfa, userPrincipal(user.UUID)).Reset(user.UUID)
+	resetErr := AuthorizeMFA(mfa, admin).Reset(user.UUID)
+
+	// then
+	assert.ErrorIs(test, enrollErr, model.ErrForbidden)
+	assert.NoError(test, selfStatusErr)
+	assert.ErrorIs(test, selfResetErr, model.ErrForbidden)
+	assert.NoError(test, resetErr)
+	stored, _ := repos.MFA.Get(user.UUID)
+	assert.Nil(test, stored)
+}
@@ -0,0 +1,380 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"cruder/internal/token"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	oidcClientIDPrefix     = "oidc_"
+	oidcClientSecretPrefix = "ocs_"
+	oidcCodePrefix         = "oc_"
+	oidcAccessTokenPrefix  = "oat_"
+	// oidcExpiredRetention keeps expired codes around for a while, so a
+	// late replay is still recognised as one.
+	oidcExpiredRetention = time.Hour
+)
+
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+var supportedScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+// pkceValue matches code challenges and verifiers: 43 to 128 characters of
+// the unreserved set (RFC 7636). S256 challenges are always 43 long.
+var pkceValue = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+type OIDCService interface {
+	Discovery() model.OIDCDiscovery
+	// CreateClient registers a client for the users of tenantID.
+	// Confidential clients get a secret, which is returned once.
+	CreateClient(tenantID, name string, redirectURIs []string, confidential bool) (string, *model.OIDCClient, error)
+	// ValidateAuthorization checks an authorization request. On
+	// ErrInvalidClient and ErrInvalidRedirectURI the client is nil and the
+	// error must not be sent to the redirect URI; on any other error the
+	// client is returned.
+	ValidateAuthorization(request *model.AuthorizationRequest) (*model.OIDCClient, error)
+	// Authorize issues an authorization code for user, who logged in to the
+	// request's client and consented.
+	Authorize(request *model.AuthorizationRequest, user *model.User) (string, error)
+	// Exchange redeems an authorization code for an access and an ID token.
+	// Redeeming a code twice revokes the access token of the first time.
+	Exchange(request *model.OIDCTokenRequest) (*model.OIDCTokenResponse, error)
+	UserInfo(accessToken string) (*model.UserInfo, error)
+}
+
+type oidcService struct {
+	transactor repository.Transactor
+	config     Config
+	idTokens   *token.Issuer
+	now        func() time.Time
+}
+
+func NewOIDCService(transactor repository.Transactor, config Config) OIDCService {
+	keys := config.SigningKeys
+	if keys == nil {
+		keys = token.NewKeySet()
+	}
+	return &oidcService{
+		transactor: transactor,
+		config:     config,
+		idTokens:   token.NewIssuer(keys, config.OIDCIssuer, config.AccessTokenTTL),
+		now:        time.Now,
+	}
+}
+
+func (oidcService *oidcService) Discovery() model.OIDCDiscovery {
+	issuer := oidcService.config.OIDCIssuer
+	return model.OIDCDiscovery{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth2/authorize",
+		TokenEndpoint:                     issuer + "/oauth2/token",
+		UserInfoEndpoint:                  issuer + "/oauth2/userinfo",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   supportedScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{"EdDSA"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce",
+			"preferred_username", "name", "email", "email_verified",
+		},
+	}
+}
+
+func (oidcService *oidcService) CreateClient(tenantID, name string, redirectURIs []string, confidential bool) (string, *model.OIDCClient, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", nil, errors.New("client name is required")
+	}
+	if len(redirectURIs) == 0 {
+		return "", nil, errors.New("at least one redirect uri is required")
+	}
+	for _, redirectURI := range redirectURIs {
+		parsed, err := url.Parse(redirectURI)
+		if err != nil || !parsed.IsAbs() || parsed.Fragment != "" {
+			return "", nil, fmt.Errorf("invalid redirect uri %q: must be absolute and without fragment", redirectURI)
+		}
+	}
+
+	clientID, _, err := token.NewOpaque(oidcClientIDPrefix)
+	if err != nil {
+		return "", nil, err
+	}
+	client := &model.OIDCClient{ID: clientID, TenantID: tenantID, Name: name, RedirectURIs: redirectURIs}
+	secret := ""
+	if confidential {
+		if secret, client.SecretHash, err = token.NewOpaque(oidcClientSecretPrefix); err != nil {
+			return "", nil, err
+		}
+	}
+
+	err = oidcService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		return repos.OIDC.CreateClient(client)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return secret, client, nil
+}
+
+func (oidcService *oidcService) ValidateAuthorization(request *model.AuthorizationRequest) (*model.OIDCClient, error) {
+	var client *model.OIDCClient
+	err := oidcService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		var err error
+		client, err = repos.OIDC.GetClient(request.ClientID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, model.ErrInvalidClient
+	}
+	if !client.AllowsRedirect(request.RedirectURI) {
+		return nil, model.ErrInvalidRedirectURI
+	}
+
+	if request.ResponseType != "code" {
+		return client, model.ErrUnsupportedResponseType
+	}
+	if !slices.Contains(grantedScopes(request.Scope), ScopeOpenID) {
+		return client, model.ErrInvalidScope
+	}
+	if request.CodeChallengeMethod != "S256" || !pkceValue.MatchString(request.CodeChallenge) {
+		return client, fmt.Errorf("%w: PKCE with code_challenge_method S256 is required", model.ErrInvalidOAuthRequest)
+	}
+	return client, nil
+}
+
+func (oidcService *oidcService) Authorize(request *model.AuthorizationRequest, user *model.User) (string, error) {
+	client, err := oidcService.ValidateAuthorization(request)
+	if err != nil {
+		return "", err
+	}
+	if user.TenantID != client.TenantID {
+		return "", model.ErrInvalidCredentials
+	}
+
+	raw, hash, err := token.NewOpaque(oidcCodePrefix)
+	if err != nil {
+		return "", err
+	}
+	now := oidcService.now()
+	code := &model.AuthorizationCode{
+		ClientID:      client.ID,
+		UserUUID:      user.UUID,
+		RedirectURI:   request.RedirectURI,
+		Scope:         strings.Join(grantedScopes(request.Scope), " "),
+		Nonce:         request.Nonce,
+		CodeChallenge: request.CodeChallenge,
+		AuthTime:      now,
+		ExpiresAt:     now.Add(oidcService.config.OIDCCodeTTL),
+	}
+	err = oidcService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if err := repos.OIDC.DeleteExpired(now.Add(-oidcExpiredRetention)); err != nil {
+			return err
+		}
+		return repos.OIDC.CreateCode(code, hash)
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+func (oidcService *oidcService) Exchange(request *model.OIDCTokenRequest) (*model.OIDCTokenResponse, error) {
+	if request.GrantType != "authorization_code" {
+		return nil, model.ErrUnsupportedGrantType
+	}
+
+	var response *model.OIDCTokenResponse
+	replayed := false
+	err := oidcService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		client, err := authenticateClient(repos, request)
+		if err != nil {
+			return err
+		}
+
+		code, err := repos.OIDC.GetCode(token.HashOpaque(request.Code))
+		if err != nil {
+			return err
+		}
+		if code == nil || code.ClientID != client.ID || code.RedirectURI != request.RedirectURI ||
+			!verifyCodeChallenge(code.CodeChallenge, request.CodeVerifier) {
+			return model.ErrInvalidGrant
+		}
+		fresh, err := repos.OIDC.UseCode(code.ID)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			// As with refresh tokens, the revocation has to commit.
+			replayed = true
+			log.Printf("authorization code replay detected for client %s, revoking its tokens", client.ID)
+			return repos.OIDC.RevokeAccessTokens(code.ID)
+		}
+		if !oidcService.now().Before(code.ExpiresAt) {
+			return model.ErrInvalidGrant
+		}
+
+		user, err := repos.Users.GetByUUID(code.UserUUID)
+		if err != nil {
+			return err
+		}
+		if user == nil || user.Status == model.UserStatusSuspended || user.Status == model.UserStatusDeactivated {
+			return model.ErrInvalidGrant
+		}
+
+		response, err = oidcService.issue(repos, code, user)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if replayed {
+		return nil, model.ErrInvalidGrant
+	}
+	return response, nil
+}
+
+func (oidcService *oidcService) issue(repos *repository.Repository, code *model.AuthorizationCode, user *model.User) (*model.OIDCTokenResponse, error) {
+	raw, hash, err := token.NewOpaque(oidcAccessTokenPrefix)
+	if err != nil {
+		return nil, err
+	}
+	ttl := oidcService.config.AccessTokenTTL
+	accessToken := &model.OIDCAccessToken{
+		CodeID:    code.ID,
+		ClientID:  code.ClientID,
+		UserUUID:  user.UUID,
+		Scope:     code.Scope,
+		ExpiresAt: oidcService.now().Add(ttl),
+	}
+	if err := repos.OIDC.CreateAccessToken(accessToken, hash); err != nil {
+		return nil, err
+	}
+
+	info := userInfo(user, code.Scope)
+	idToken, _, err := oidcService.idTokens.IssueIDToken(token.IDClaims{
+		Subject:           info.Subject,
+		Audience:          code.ClientID,
+		AuthTime:          code.AuthTime.Unix(),
+		Nonce:             code.Nonce,
+		PreferredUsername: info.PreferredUsername,
+		Name:              info.Name,
+		Email:             info.Email,
+		EmailVerified:     info.EmailVerified,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.OIDCTokenResponse{
+		AccessToken: raw,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(ttl.Seconds()),
+		IDToken:     idToken,
+		Scope:       code.Scope,
+	}, nil
+}
+
+func (oidcService *oidcService) UserInfo(accessToken string) (*model.UserInfo, error) {
+	var info *model.UserInfo
+	err := oidcService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		stored, err := repos.OIDC.GetAccessToken(token.HashOpaque(accessToken))
+		if err != nil {
+			return err
+		}
+		if stored == nil || !oidcService.now().Before(stored.ExpiresAt) {
+			return model.ErrInvalidToken
+		}
+
+		user, err := repos.Users.GetByUUID(stored.UserUUID)
+		if err != nil {
+			return err
+		}
+		if user == nil || user.Status == model.UserStatusSuspended || user.Status == model.UserStatusDeactivated {
+			return model.ErrInvalidToken
+		}
+		info = userInfo(user, stored.Scope)
+		return nil
+	})
+	return info, err
+}
+
+// authenticateClient checks the client credentials of a token request.
+// Public clients must not send a secret.
+func authenticateClient(repos *repository.Repository, request *model.OIDCTokenRequest) (*model.OIDCClient, error) {
+	client, err := repos.OIDC.GetClient(request.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, model.ErrInvalidClient
+	}
+	if client.Public() {
+		if request.ClientSecret != "" {
+			return nil, model.ErrInvalidClient
+		}
+		return client, nil
+	}
+	if subtle.ConstantTimeCompare(token.HashOpaque(request.ClientSecret), client.SecretHash) != 1 {
+		return nil, model.ErrInvalidClient
+	}
+	return client, nil
+}
+
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if !pkceValue.MatchString(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// grantedScopes returns the supported scopes among the requested ones.
+// Unknown scopes are ignored.
+func grantedScopes(scope string) []string {
+	var granted []string
+	for _, requested := range strings.Fields(scope) {
+		if slices.Contains(supportedScopes, requested) && !slices.Contains(granted, requested) {
+			granted = append(granted, requested)
+		}
+	}
+	return granted
+}
+
+// userInfo maps user to the claims released by scope.
+func userInfo(user *model.User, scope string) *model.UserInfo {
+	scopes := strings.Fields(scope)
+	info := &model.UserInfo{Subject: user.UUID}
+	if slices.Contains(scopes, ScopeProfile) {
+		info.PreferredUsername = user.Username
+		info.Name = user.FullName
+	}
+	if slices.Contains(scopes, ScopeEmail) {
+		verified := user.EmailVerifiedAt != nil
+		info.Email = user.Email
+		info.EmailVerified = &verified
+	}
+	return info
+}
@@ -0,0 +1,409 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"cruder/internal/token"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockOIDCRepository struct {
+	clients      map[string]*model.OIDCClient
+	codes        map[string]*model.AuthorizationCode
+	accessTokens map[string]*model.OIDCAccessToken
+	nextID       int64
+}
+
+func newMockOIDCRepository() *mockOIDCRepository {
+	return &mockOIDCRepository{
+		clients:      make(map[string]*model.OIDCClient),
+		codes:        make(map[string]*model.AuthorizationCode),
+		accessTokens: make(map[string]*model.OIDCAccessToken),
+	}
+}
+
+func (oidcRepository *mockOIDCRepository) CreateClient(client *model.OIDCClient) error {
+	client.CreatedAt = time.Now().UTC()
+	stored := *client
+	oidcRepository.clients[client.ID] = &stored
+	return nil
+}
+
+func (oidcRepository *mockOIDCRepository) GetClient(id string) (*model.OIDCClient, error) {
+	if client, ok := oidcRepository.clients[id]; ok {
+		copied := *client
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (oidcRepository *mockOIDCRepository) CreateCode(code *model.AuthorizationCode, hash []byte) error {
+	oidcRepository.nextID++
+	code.ID = oidcRepository.nextID
+	stored := *code
+	oidcRepository.codes[string(hash)] = &stored
+	return nil
+}
+
+func (oidcRepository *mockOIDCRepository) GetCode(hash []byte) (*model.AuthorizationCode, error) {
+	if code, ok := oidcRepository.codes[string(hash)]; ok {
+		copied := *code
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (oidcRepository *mockOIDCRepository) UseCode(id int64) (bool, error) {
+	for _, code := range oidcRepository.codes {
+		if code.ID == id && code.UsedAt == nil {
+			now := time.Now().UTC()
+			code.UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (oidcRepository *mockOIDCRepository) CreateAccessToken(accessToken *model.OIDCAccessToken, hash []byte) error {
+	stored := *accessToken
+	oidcRepository.accessTokens[string(hash)] = &stored
+	return nil
+}
+
+func (oidcRepository *mockOIDCRepository) GetAccessToken(hash []byte) (*model.OIDCAccessToken, error) {
+	if accessToken, ok := oidcRepository.accessTokens[string(hash)]; ok {
+		copied := *accessToken
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (oidcRepository *mockOIDCRepository) RevokeAccessTokens(codeID int64) error {
+	for hash, accessToken := range oidcRepository.accessTokens {
+		if accessToken.CodeID == codeID {
+			delete(oidcRepository.accessTokens, hash)
+		}
+	}
+	return nil
+}
+
+func (oidcRepository *mockOIDCRepository) DeleteExpired(before time.Time) error {
+	for hash, code := range oidcRepository.codes {
+		if code.ExpiresAt.Before(before) {
+			delete(oidcRepository.codes, hash)
+		}
+	}
+	for hash, accessToken := range oidcRepository.accessTokens {
+		if accessToken.ExpiresAt.Before(before) {
+			delete(oidcRepository.accessTokens, hash)
+		}
+	}
+	return nil
+}
+
+var _ repository.OIDCRepository = (*mockOIDCRepository)(nil)
+
+const (
+	testRedirectURI = "https://app.example.com/callback"
+	// testCodeChallenge is the S256 challenge of testCodeVerifier.
+	testCodeVerifier  = "dBjftJeZ4CVP-mJ0kIAmYQlKpCA8cjFQ8nOIDNKbPV0"
+	testCodeChallenge = "EVPl7o3zcwexCrqMZggBVBJRq-BtwWd_f-Qq9YrAuPM"
+)
+
+func setupOIDCTest(test *testing.T) (UserService, *oidcService, *token.Issuer) {
+	mockRepo := newMockUserRepository()
+	repos := newMockRepos(mockRepo, &mockOutboxRepository{})
+	transactor := &mockTransactor{repos: repos, users: mockRepo}
+
+	key, err := token.GenerateKey()
+	require.NoError(test, err)
+	config := testPasswordConfig()
+	config.SigningKeys = token.NewKeySet(key)
+	config.OIDCIssuer = "https://id.example.com"
+	relyingParty := token.NewIssuer(config.SigningKeys, config.OIDCIssuer, config.AccessTokenTTL)
+	return NewUserService(mockRepo, transactor, config), NewOIDCService(transactor, config).(*oidcService), relyingParty
+}
+
+func authorizationRequest(clientID string) *model.AuthorizationRequest {
+	return &model.AuthorizationRequest{
+		ResponseType:        "code",
+		ClientID:            clientID,
+		RedirectURI:         testRedirectURI,
+		Scope:               "openid profile email",
+		State:               "af0ifjsldkj",
+		Nonce:               "n-0S6_WzA2Mj",
+		CodeChallenge:       testCodeChallenge,
+		CodeChallengeMethod: "S256",
+	}
+}
+
+func tokenRequest(clientID, clientSecret, code string) *model.OIDCTokenRequest {
+	return &model.OIDCTokenRequest{
+		GrantType:    "authorization_code",
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		CodeVerifier: testCodeVerifier,
+	}
+}
+
+func TestShouldVerifyS256CodeChallenge(test *testing.T) {
+	// given
+	sum := sha256.Sum256([]byte(testCodeVerifier))
+
+	// when
+	matches := verifyCodeChallenge(testCodeChallenge, testCodeVerifier)
+	short := verifyCodeChallenge(testCodeChallenge, "too-short")
+
+	// then
+	assert.Equal(test, testCodeChallenge, base64.RawURLEncoding.EncodeToString(sum[:]))
+	assert.True(test, matches)
+	assert.False(test, short)
+}
+
+func TestShouldRejectAuthorizationRequestsBeforeRedirecting(test *testing.T) {
+	// given
+	_, oidc, _ := setupOIDCTest(test)
+	_, client, err := oidc.CreateClient("", "Wiki", []string{testRedirectURI}, true)
+	require.NoError(test, err)
+	unknownClient := authorizationRequest("oidc_unknown")
+	otherRedirect := authorizationRequest(client.ID)
+	otherRedirect.RedirectURI = "https://evil.example.com/callback"
+
+	// when
+	unknownResult, unknownErr := oidc.ValidateAuthorization(unknownClient)
+	redirectResult, redirectErr := oidc.ValidateAuthorization(otherRedirect)
+
+	// then
+	assert.ErrorIs(test, unknownErr, model.ErrInvalidClient)
+	assert.Nil(test, unknownResult)
+	assert.ErrorIs(test, redirectErr, model.ErrInvalidRedirectURI)
+	assert.Nil(test, redirectResult)
+}
+
+func TestShouldReportInvalidAuthorizationRequestsToClient(test *testing.T) {
+	// given
+	_, oidc, _ := setupOIDCTest(test)
+	_, client, _ := oidc.CreateClient("", "Wiki", []string{testRedirectURI}, true)
+	implicit := authorizationRequest(client.ID)
+	implicit.ResponseType = "token"
+	withoutOpenID := authorizationRequest(client.ID)
+	withoutOpenID.Scope = "profile email"
+	withoutPKCE := authorizationRequest(client.ID)
+	withoutPKCE.CodeChallenge, withoutPKCE.CodeChallengeMethod = "", ""
+	plainPKCE := authorizationRequest(client.ID)
+	plainPKCE.CodeChallenge, plainPKCE.CodeChallengeMethod = testCodeVerifier, "plain"
+
+	// when
+	implicitResult, implicitErr := oidc.ValidateAuthorization(implicit)
+	_, scopeErr := oidc.ValidateAuthorization(withoutOpenID)
+	_, pkceErr := oidc.ValidateAuthorization(withoutPKCE)
+	_, plainErr := oidc.ValidateAuthorization(plainPKCE)
+
+	// then
+	assert.ErrorIs(test, implicitErr, model.ErrUnsupportedResponseType)
+	assert.Equal(test, client.ID, implicitResult.ID)
+	assert.ErrorIs(test, scopeErr, model.ErrInvalidScope)
+	assert.ErrorIs(test, pkceErr, model.ErrInvalidOAuthRequest)
+	assert.ErrorIs(test, plainErr, model.ErrInvalidOAuthRequest)
+}
+
+func TestShouldExchangeCodeForIDTokenWithUserClaims(test *testing.T) {
+	// given
+	userService, oidc, relyingParty := setupOIDCTest(test)
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "Jane Doe"})
+	secret, client, _ := oidc.CreateClient("", "Wiki", []string{testRedirectURI}, true)
+	code, err := oidc.Authorize(authorizationRequest(client.ID), user)
+	require.NoError(test, err)
+
+	// when
+	response, err := oidc.Exchange(tokenRequest(client.ID, secret, code))
+
+	// then
+	require.NoError(test, err)
+	assert.True(test, strings.HasPrefix(code, oidcCodePrefix))
+	assert.Equal(test, "Bearer", response.TokenType)
+	assert.Equal(test, "openid profile email", response.Scope)
+	claims, err := relyingParty.VerifyIDToken(response.IDToken, client.ID)
+	require.NoError(test, err)
+	assert.Equal(test, "https://id.example.com", claims.Issuer)
+	assert.Equal(test, user.UUID, claims.Subject)
+	assert.Equal(test, "n-0S6_WzA2Mj", claims.Nonce)
+	assert.Equal(test, "jdoe", claims.PreferredUsername)
+	assert.Equal(test, "Jane Doe", claims.Name)
+	assert.Equal(test, "jdoe@example.com", claims.Email)
+	assert.False(test, *claims.EmailVerified)
+
+	info, err := oidc.UserInfo(response.AccessToken)
+	require.NoError(test, err)
+	assert.Equal(test, &model.UserInfo{
+		Subject: user.UUID, PreferredUsername: "jdoe", Name: "Jane Doe",
+		Email: "jdoe@example.com", EmailVerified: claims.EmailVerified,
+	}, info)
+}
+
+func TestShouldReleaseOnlyClaimsOfGrantedScopes(test *testing.T) {
+	// given
+	userService, oidc, relyingParty := setupOIDCTest(test)
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "Jane Doe"})
+	_, client, _ := oidc.CreateClient("", "Dashboard", []string{testRedirectURI}, false)
+	request := authorizationRequest(client.ID)
+	request.Scope = "openid offline_access openid"
+	code, _ := oidc.Authorize(request, user)
+
+	// when
+	response, err := oidc.Exchange(tokenRequest(client.ID, "", code))
+
+	// then
+	require.NoError(test, err)
+	assert.Equal(test, "openid", response.Scope)
+	claims, _ := relyingParty.VerifyIDToken(response.IDToken, client.ID)
+	assert.Equal(test, "", claims.PreferredUsername)
+	assert.Equal(test, "", claims.Email)
+	info, _ := oidc.UserInfo(response.AccessToken)
+	assert.Equal(test, &model.UserInfo{Subject: user.UUID}, info)
+}
+
+func TestShouldRejectCodeWithWrongVerifierRedirectOrClient(test *testing.T) {
+	// given
+	userService, oidc, _ := setupOIDCTest(test)
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	secret, client, _ := oidc.CreateClient("", "Wiki", []string{testRedirectURI, "https://app.example.com/other"}, true)
+	otherSecret, otherClient, _ := oidc.CreateClient("", "Chat", []string{testRedirectURI}, true)
+	code, _ := oidc.Authorize(authorizationRequest(client.ID), user)
+	wrongVerifier := tokenRequest(client.ID, secret, code)
+	wrongVerifier.CodeVerifier = strings.Repeat("a", 43)
+	wrongRedirect := tokenRequest(client.ID, secret, code)
+	wrongRedirect.RedirectURI = "https://app.example.com/other"
+
+	// when
+	_, verifierErr := oidc.Exchange(wrongVerifier)
+	_, redirectErr := oidc.Exchange(wrongRedirect)
+	_, clientErr := oidc.Exchange(tokenRequest(otherClient.ID, otherSecret, code))
+	_, secretErr := oidc.Exchange(tokenRequest(client.ID, "ocs_wrong", code))
+	_, grantErr := oidc.Exchange(&model.OIDCTokenRequest{GrantType: "password"})
+	_, validErr := oidc.Exchange(tokenRequest(client.ID, secret, code))
+
+	// then
+	assert.ErrorIs(test, verifierErr, model.ErrInvalidGrant)
+	assert.ErrorIs(test, redirectErr, model.ErrInvalidGrant)
+	assert.ErrorIs(test, clientErr, model.ErrInvalidGrant)
+	assert.ErrorIs(test, secretErr, model.ErrInvalidClient)
+	assert.ErrorIs(test, grantErr, model.ErrUnsupportedGrantType)
+	assert.NoError(test, validErr)
+}
+
+func TestShouldRequireSecretOfConfidentialClientsOnly(test *testing.T) {
+	// given
+	userService, oidc, _ := setupOIDCTest(test)
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	secret, confidential, _ := oidc.CreateClient("", "Wiki", []string{testRedirectURI}, true)
+	noSecret, public, _ := oidc.CreateClient("", "Dashboard", []string{testRedirectURI}, false)
+	confidentialCode, _ := oidc.Authorize(authorizationRequest(confidential.ID), user)
+	publicCode, _ := oidc.Authorize(authorizationRequest(public.ID), user)
+
+	// when
+	_, missingErr := oidc.Exchange(tokenRequest(confidential.ID, "", confidentialCode))
+	_, inventedErr := oidc.Exchange(tokenRequest(public.ID, secret, publicCode))
+	_, publicErr := oidc.Exchange(tokenRequest(public.ID, "", publicCode))
+
+	// then
+	assert.True(test, strings.HasPrefix(secret, oidcClientSecretPrefix))
+	assert.Empty(test, noSecret)
+	assert.True(test, public.Public())
+	assert.ErrorIs(test, missingErr, model.ErrInvalidClient)
+	assert.ErrorIs(test, inventedErr, model.ErrInvalidClient)
+	assert.NoError(test, publicErr)
+}
+
+func TestShouldRevokeTokensWhenCodeIsReplayed(test *testing.T) {
+	// given
+	userService, oidc, _ := setupOIDCTest(test)
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	secret, client, _ := oidc.CreateClient("", "Wiki", []string{testRedirectURI}, true)
+	code, _ := oidc.Authorize(authorizationRequest(client.ID), user)
+	first, err := oidc.Exchange(tokenRequest(client.ID, secret, code))
+	require.NoError(test, err)
+
+	// when
+	_, replayErr := oidc.Exchange(tokenRequest(client.ID, secret, code))
+
+	// then
+	assert.ErrorIs(test, replayErr, model.ErrInvalidGrant)
+	_, userInfoErr := oidc.UserInfo(first.AccessToken)
+	assert.ErrorIs(test, userInfoErr, model.ErrInvalidToken)
+}
+
+func TestShouldRejectExpiredCodesAndAccessTokens(test *testing.T) {
+	// given
+	userService, oidc, _ := setupOIDCTest(test)
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	secret, client, _ := oidc.CreateClient("", "Wiki", []string{testRedirectURI}, true)
+	expiredCode, _ := oidc.Authorize(authorizationRequest(client.ID), user)
+	code, _ := oidc.Authorize(authorizationRequest(client.ID), user)
+	response, _ := oidc.Exchange(tokenRequest(client.ID, secret, code))
+	oidc.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+
+	// when
+	_, codeErr := oidc.Exchange(tokenRequest(client.ID, secret, expiredCode))
+	_, userInfoErr := oidc.UserInfo(response.AccessToken)
+
+	// then
+	assert.ErrorIs(test, codeErr, model.ErrInvalidGrant)
+	assert.ErrorIs(test, userInfoErr, model.ErrInvalidToken)
+}
+
+func TestShouldStopReleasingClaimsOfSuspendedUsers(test *testing.T) {
+	// given
+	userService, oidc, _ := setupOIDCTest(test)
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	_, _ = userService.TransitionUserStatus(user.UUID, model.UserStatusActive, "verified", "admin")
+	secret, client, _ := oidc.CreateClient("", "Wiki", []string{testRedirectURI}, true)
+	code, _ := oidc.Authorize(authorizationRequest(client.ID), user)
+	response, _ := oidc.Exchange(tokenRequest(client.ID, secret, code))
+
+	// when
+	_, _ = userService.TransitionUserStatus(user.UUID, model.UserStatusSuspended, "abuse report", "admin")
+	_, err := oidc.UserInfo(response.AccessToken)
+
+	// then
+	assert.ErrorIs(test, err, model.ErrInvalidToken)
+}
+
+func TestShouldNotIssueCodesForUsersOfOtherTenants(test *testing.T) {
+	// given
+	_, oidc, _ := setupOIDCTest(test)
+	_, client, _ := oidc.CreateClient("tenant-a", "Wiki", []string{testRedirectURI}, true)
+	user := &model.User{UUID: generateMockUUID(1), TenantID: "tenant-b"}
+
+	// when
+	_, err := oidc.Authorize(authorizationRequest(client.ID), user)
+
+	// then
+	assert.ErrorIs(test, err, model.ErrInvalidCredentials)
+}
+
+func TestShouldRejectClientsWithoutUsableRedirectURIs(test *testing.T) {
+	// given
+	_, oidc, _ := setupOIDCTest(test)
+
+	// when
+	_, _, missingErr := oidc.CreateClient("", "Wiki", nil, true)
+	_, _, relativeErr := oidc.CreateClient("", "Wiki", []string{"/callback"}, true)
+	_, _, fragmentErr := oidc.CreateClient("", "Wiki", []string{"https://app.example.com/#callback"}, true)
+	_, _, nameErr := oidc.CreateClient("", " ", []string{testRedirectURI}, true)
+
+	// then
+	assert.Error(test, missingErr)
+	assert.Error(test, relativeErr)
+	assert.Error(test, fragmentErr)
+	assert.Error(test, nameErr)
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"cruder/internal/model"
+	"cruder/internal/notify"
+	"cruder/internal/password"
+	"cruder/internal/repository"
+	"cruder/internal/token"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const passwordResetTokenPrefix = "pr_"
+
+type PasswordResetService interface {
+	// RequestReset mails a reset link if email belongs to a user who may
+	// sign in. It succeeds either way, so callers cannot probe for accounts.
+	// Requests beyond the hourly limits for the email or ipAddress fail with
+	// ErrRateLimited, counted the same for known and unknown emails.
+	RequestReset(email, ipAddress string) error
+	// ResetPassword consumes a token, sets newPassword and revokes all of
+	// the user's sessions.
+	ResetPassword(rawToken, newPassword string) error
+}
+
+type passwordResetService struct {
+	transactor repository.Transactor
+	config     Config
+	passwords  *password.Hasher
+	now        func() time.Time
+}
+
+func NewPasswordResetService(transactor repository.Transactor, config Config) PasswordResetService {
+	return &passwordResetService{
+		transactor: transactor,
+		config:     config,
+		passwords:  password.NewHasher(config.PasswordParams, config.PasswordPolicy),
+		now:        time.Now,
+	}
+}
+
+func (passwordResetService *passwordResetService) RequestReset(email, ipAddress string) error {
+	email = strings.TrimSpace(email)
+	var user *model.User
+	var rawToken string
+	err := passwordResetService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if err := passwordResetService.checkRequestLimit(repos, strings.ToLower(email), ipAddress); err != nil {
+			return err
+		}
+
+		found, err := repos.Users.GetByEmail(email)
+		if err != nil {
+			return err
+		}
+		if found == nil || found.Status == model.UserStatusSuspended || found.Status == model.UserStatusDeactivated {
+			return nil
+		}
+		if passwordResetService.config.Notifier == nil {
+			return nil
+		}
+		user = found
+		rawToken, err = passwordResetService.issue(repos, user)
+		return err
+	})
+	if err != nil || rawToken == "" {
+		return err
+	}
+	return passwordResetService.send(user, rawToken)
+}
+
+// checkRequestLimit records the request unless it exceeds a limit.
+func (passwordResetService *passwordResetService) checkRequestLimit(repos *repository.Repository, email, ipAddress string) error {
+	now := passwordResetService.now()
+	if err := repos.PasswordResets.PruneRequests(now.Add(-24 * time.Hour)); err != nil {
+		return err
+	}
+	byEmail, byIP, err := repos.PasswordResets.CountRequestsSince(email, ipAddress, now.Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	if byEmail >= passwordResetService.config.PasswordResetEmailLimit || byIP >= passwordResetService.config.PasswordResetIPLimit {
+		return model.ErrRateLimited
+	}
+	return repos.PasswordResets.RecordRequest(email, ipAddress)
+}
+
+func (passwordResetService *passwordResetService) ResetPassword(rawToken, newPassword string) error {
+	if err := passwordResetService.passwords.Validate(newPassword); err != nil {
+		return err
+	}
+
+	return passwordResetService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		stored, err := repos.PasswordResets.GetByHash(token.HashOpaque(rawToken))
+		if err != nil {
+			return err
+		}
+		if stored == nil || !stored.Usable(passwordResetService.now()) {
+			return model.ErrInvalidToken
+		}
+		used, err := repos.PasswordResets.MarkUsed(stored.ID)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return model.ErrInvalidToken
+		}
+
+		user, err := repos.Users.GetByUUID(stored.UserUUID)
+		if err != nil {
+			return err
+		}
+		if user == nil || user.Status == model.UserStatusSuspended || user.Status == model.UserStatusDeactivated {
+			return model.ErrInvalidToken
+		}
+
+		hash, err := passwordResetService.passwords.Hash(newPassword)
+		if err != nil {
+			return err
+		}
+		if err := repos.Users.UpdatePasswordHash(user.UUID, hash); err != nil {
+			return err
+		}
+		if err := repos.PasswordResets.InvalidateForUser(user.UUID); err != nil {
+			return err
+		}
+		// Whoever knew the old password may still hold a session.
+		return repos.Sessions.RevokeAllForUser(user.UUID, "password reset")
+	})
+}
+
+func (passwordResetService *passwordResetService) issue(repos *repository.Repository, user *model.User) (string, error) {
+	if err := repos.PasswordResets.InvalidateForUser(user.UUID); err != nil {
+		return "", err
+	}
+	rawToken, hash, err := token.NewOpaque(passwordResetTokenPrefix)
+	if err != nil {
+		return "", err
+	}
+	reset := &model.PasswordResetToken{
+		UserUUID:  user.UUID,
+		ExpiresAt: passwordResetService.now().Add(passwordResetService.config.PasswordResetTokenTTL),
+	}
+	if err := repos.PasswordResets.Create(reset, hash); err != nil {
+		return "", err
+	}
+	return rawToken, nil
+}
+
+func (passwordResetService *passwordResetService) send(user *model.User, rawToken string) error {
+	link, err := url.Parse(passwordResetService.config.PasswordResetURL)
+	if err != nil {
+		return fmt.Errorf("invalid password reset url: %w", err)
+	}
+	query := link.Query()
+	query.Set("token", rawToken)
+	link.RawQuery = query.Encode()
+
+	return passwordResetService.config.Notifier.Notify(context.Background(), notify.KindPasswordReset, user.Email, notify.Data{
+		User:      *user,
+		Link:      link.String(),
+		ExpiresIn: passwordResetService.config.PasswordResetTokenTTL,
+	})
+}
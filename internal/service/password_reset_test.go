@@ -0,0 +1,266 @@
+package service
+
+import (
+	"cruder/internal/mail"
+	"cruder/internal/model"
+	"cruder/internal/notify"
+	"cruder/internal/repository"
+	"cruder/internal/token"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockResetRequest struct {
+	email     string
+	ipAddress string
+	at        time.Time
+}
+
+type mockPasswordResetRepository struct {
+	tokens   map[string]*model.PasswordResetToken
+	requests []mockResetRequest
+	nextID   int64
+	now      func() time.Time
+}
+
+func newMockPasswordResetRepository() *mockPasswordResetRepository {
+	return &mockPasswordResetRepository{tokens: make(map[string]*model.PasswordResetToken), now: time.Now}
+}
+
+func (passwordResetRepository *mockPasswordResetRepository) Create(token *model.PasswordResetToken, hash []byte) error {
+	passwordResetRepository.nextID++
+	token.ID = passwordResetRepository.nextID
+	token.CreatedAt = passwordResetRepository.now()
+	stored := *token
+	passwordResetRepository.tokens[string(hash)] = &stored
+	return nil
+}
+
+func (passwordResetRepository *mockPasswordResetRepository) GetByHash(hash []byte) (*model.PasswordResetToken, error) {
+	token, ok := passwordResetRepository.tokens[string(hash)]
+	if !ok {
+		return nil, nil
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (passwordResetRepository *mockPasswordResetRepository) MarkUsed(id int64) (bool, error) {
+	for _, token := range passwordResetRepository.tokens {
+		if token.ID == id && token.UsedAt == nil {
+			usedAt := passwordResetRepository.now()
+			token.UsedAt = &usedAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (passwordResetRepository *mockPasswordResetRepository) InvalidateForUser(userUUID string) error {
+	for _, token := range passwordResetRepository.tokens {
+		if token.UserUUID == userUUID && token.UsedAt == nil {
+			usedAt := passwordResetRepository.now()
+			token.UsedAt = &usedAt
+		}
+	}
+	return nil
+}
+
+func (passwordResetRepository *mockPasswordResetRepository) RecordRequest(email, ipAddress string) error {
+	passwordResetRepository.requests = append(passwordResetRepository.requests,
+		mockResetRequest{email: email, ipAddress: ipAddress, at: passwordResetRepository.now()})
+	return nil
+}
+
+func (passwordResetRepository *mockPasswordResetRepository) CountRequestsSince(email, ipAddress string, since time.Time) (int, int, error) {
+	byEmail, byIP := 0, 0
+	for _, request := range passwordResetRepository.requests {
+		if !request.at.After(since) {
+			continue
+		}
+		if request.email == email {
+			byEmail++
+		}
+		if request.ipAddress == ipAddress {
+			byIP++
+		}
+	}
+	return byEmail, byIP, nil
+}
+
+func (passwordResetRepository *mockPasswordResetRepository) PruneRequests(before time.Time) error {
+	var kept []mockResetRequest
+	for _, request := range passwordResetRepository.requests {
+		if !request.at.Before(before) {
+			kept = append(kept, request)
+		}
+	}
+	passwordResetRepository.requests = kept
+	return nil
+}
+
+var _ repository.PasswordResetRepository = (*mockPasswordResetRepository)(nil)
+
+func setupPasswordResetTest(test *testing.T) (*repository.Repository, *mail.MemorySender, UserService, TokenService, *passwordResetService) {
+	mockRepo := newMockUserRepository()
+	repos := newMockRepos(mockRepo, &mockOutboxRepository{})
+	transactor := &mockTransactor{repos: repos, users: mockRepo}
+
+	mailer := mail.NewMemorySender()
+	notifier, err := notify.NewNotifier(mailer, notify.DefaultLocale)
+	require.NoError(test, err)
+	key, err := token.GenerateKey()
+	require.NoError(test, err)
+	config := testPasswordConfig()
+	config.Notifier = notifier
+	config.SigningKeys = token.NewKeySet(key)
+
+	resets := NewPasswordResetService(transactor, config).(*passwordResetService)
+	return repos, mailer, NewUserService(mockRepo, transactor, config), NewTokenService(repos, transactor, config), resets
+}
+
+func resetMails(mailer *mail.MemorySender) []mail.Message {
+	return mailsWithSubject(mailer, "Reset your password")
+}
+
+func TestShouldResetPasswordWithEmailedTokenOnce(test *testing.T) {
+	// given
+	repos, mailer, userService, _, resets := setupPasswordResetTest(test)
+	_, _ = userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", Password: "correct horse battery"})
+	authService := NewAuthService(repos.Users, &mockTransactor{repos: repos, users: repos.Users.(*mockUserRepository)},
+		testPasswordConfig())
+
+	// when
+	requestErr := resets.RequestReset("jdoe@example.com", "192.0.2.1")
+	messages := resetMails(mailer)
+	require.Len(test, messages, 1)
+	rawToken := sentToken(test, messages[0])
+	resetErr := resets.ResetPassword(rawToken, "a brand new passphrase")
+	againErr := resets.ResetPassword(rawToken, "yet another passphrase")
+
+	// then
+	assert.NoError(test, requestErr)
+	assert.True(test, strings.HasPrefix(rawToken, passwordResetTokenPrefix))
+	assert.Equal(test, "jdoe@example.com", messages[0].To)
+	assert.NoError(test, resetErr)
+	assert.ErrorIs(test, againErr, model.ErrInvalidToken)
+	_, oldErr := authService.Login(&model.LoginRequest{Login: "jdoe", Password: "correct horse battery"}, "")
+	assert.ErrorIs(test, oldErr, model.ErrInvalidCredentials)
+	_, newErr := authService.Login(&model.LoginRequest{Login: "jdoe", Password: "a brand new passphrase"}, "")
+	assert.NoError(test, newErr)
+}
+
+func TestShouldRevokeSessionsOnPasswordReset(test *testing.T) {
+	// given
+	_, mailer, userService, tokenService, resets := setupPasswordResetTest(test)
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", Password: "correct horse battery"})
+	tokens, err := tokenService.IssueTokens(user, model.SessionClient{})
+	require.NoError(test, err)
+	_ = resets.RequestReset("jdoe@example.com", "192.0.2.1")
+
+	// when
+	err = resets.ResetPassword(sentToken(test, resetMails(mailer)[0]), "a brand new passphrase")
+
+	// then
+	assert.NoError(test, err)
+	_, refreshErr := tokenService.Refresh(tokens.RefreshToken)
+	assert.ErrorIs(test, refreshErr, model.ErrInvalidToken)
+	_, accessErr := tokenService.Authenticate(tokens.AccessToken)
+	assert.ErrorIs(test, accessErr, model.ErrInvalidToken)
+}
+
+func TestShouldNotRevealWhetherResetEmailExists(test *testing.T) {
+	// given
+	_, mailer, userService, _, resets := setupPasswordResetTest(test)
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	_, _ = userService.TransitionUserStatus(user.UUID, model.UserStatusActive, "verified", "admin")
+	_, _ = userService.TransitionUserStatus(user.UUID, model.UserStatusSuspended, "abuse report", "moderator")
+
+	// when
+	unknownErr := resets.RequestReset("nobody@example.com", "192.0.2.1")
+	suspendedErr := resets.RequestReset("jdoe@example.com", "192.0.2.1")
+
+	// then
+	assert.NoError(test, unknownErr)
+	assert.NoError(test, suspendedErr)
+	assert.Empty(test, resetMails(mailer))
+}
+
+func TestShouldKeepResetTokenWhenNewPasswordIsWeak(test *testing.T) {
+	// given
+	_, mailer, userService, _, resets := setupPasswordResetTest(test)
+	_, _ = userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	_ = resets.RequestReset("jdoe@example.com", "192.0.2.1")
+	rawToken := sentToken(test, resetMails(mailer)[0])
+
+	// when
+	weakErr := resets.ResetPassword(rawToken, "short")
+	err := resets.ResetPassword(rawToken, "a brand new passphrase")
+
+	// then
+	assert.ErrorIs(test, weakErr, model.ErrWeakPassword)
+	assert.NoError(test, err)
+}
+
+func TestShouldRejectExpiredAndReplacedResetTokens(test *testing.T) {
+	// given
+	_, mailer, userService, _, resets := setupPasswordResetTest(test)
+	_, _ = userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	_ = resets.RequestReset("jdoe@example.com", "192.0.2.1")
+	_ = resets.RequestReset("jdoe@example.com", "192.0.2.1")
+	messages := resetMails(mailer)
+	require.Len(test, messages, 2)
+	resets.now = func() time.Time { return time.Now().Add(resets.config.PasswordResetTokenTTL + time.Minute) }
+
+	// when
+	replacedErr := resets.ResetPassword(sentToken(test, messages[0]), "a brand new passphrase")
+	expiredErr := resets.ResetPassword(sentToken(test, messages[1]), "a brand new passphrase")
+
+	// then
+	assert.ErrorIs(test, replacedErr, model.ErrInvalidToken)
+	assert.ErrorIs(test, expiredErr, model.ErrInvalidToken)
+}
+
+func TestShouldRateLimitResetRequestsPerEmail(test *testing.T) {
+	// given
+	repos, _, _, _, resets := setupPasswordResetTest(test)
+	clock := time.Now()
+	resets.now = func() time.Time { return clock }
+	repos.PasswordResets.(*mockPasswordResetRepository).now = func() time.Time { return clock }
+
+	// when
+	var errs []error
+	for attempt := range resets.config.PasswordResetEmailLimit + 1 {
+		errs = append(errs, resets.RequestReset("Nobody@example.com", fmt.Sprintf("192.0.2.%d", attempt)))
+	}
+	clock = clock.Add(time.Hour)
+	laterErr := resets.RequestReset("nobody@example.com", "192.0.2.1")
+
+	// then
+	for _, err := range errs[:len(errs)-1] {
+		assert.NoError(test, err)
+	}
+	assert.ErrorIs(test, errs[len(errs)-1], model.ErrRateLimited)
+	assert.NoError(test, laterErr)
+}
+
+func TestShouldRateLimitResetRequestsPerIP(test *testing.T) {
+	// given
+	_, _, _, _, resets := setupPasswordResetTest(test)
+
+	// when
+	var err error
+	for attempt := range resets.config.PasswordResetIPLimit + 1 {
+		err = resets.RequestReset(fmt.Sprintf("user%d@example.com", attempt), "192.0.2.1")
+	}
+	otherIPErr := resets.RequestReset("user0@example.com", "198.51.100.1")
+
+	// then
+	assert.ErrorIs(test, err, model.ErrRateLimited)
+	assert.NoError(test, otherIPErr)
+}
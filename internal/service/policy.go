@@ -0,0 +1,46 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"fmt"
+	"slices"
+)
+
+var rolePermissions = map[model.Role][]model.Permission{
+	model.RoleAdmin: {
+		model.PermissionReadUsers, model.PermissionCreateUsers, model.PermissionUpdateUsers,
+		model.PermissionDeleteUsers, model.PermissionChangeStatus, model.PermissionChangePassword,
+		model.PermissionManageRoles, model.PermissionReadGroups, model.PermissionManageGroups,
+		model.PermissionResetMFA, model.PermissionUnlockLogins, model.PermissionManageSessions,
+		model.PermissionManageWebhooks, model.PermissionReadMetrics,
+	},
+	// Support cannot change other users: changing an admin's email and then
+	// resetting the password would take the account over.
+	model.RoleSupport: {
+		model.PermissionReadUsers, model.PermissionReadGroups,
+	},
+	model.RoleSelf: {
+		model.PermissionReadUsers, model.PermissionUpdateUsers, model.PermissionChangePassword,
+		model.PermissionReadGroups, model.PermissionEnrollMFA, model.PermissionManageSessions,
+	},
+}
+
+// Authorize checks that principal may exercise permission on the user with
+// targetUUID, or on every user when targetUUID is empty. Users additionally
+// hold the self role towards their own record.
+func Authorize(principal *model.Principal, permission model.Permission, targetUUID string) error {
+	if principal == nil {
+		return model.ErrUnauthenticated
+	}
+
+	for _, role := range principal.Roles {
+		if slices.Contains(rolePermissions[model.Role(role)], permission) {
+			return nil
+		}
+	}
+	if targetUUID != "" && principal.Kind == model.PrincipalUser && principal.ID == targetUUID &&
+		slices.Contains(rolePermissions[model.RoleSelf], permission) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s requires %s", model.ErrForbidden, principal, permission)
+}
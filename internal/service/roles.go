@@ -0,0 +1,84 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"fmt"
+	"slices"
+)
+
+type RoleService interface {
+	GetRoles(uuid string) (*model.UserRoles, error)
+	// SetRoles replaces the roles of a user. Removing a role revokes the
+	// user's sessions, since their access tokens still carry it.
+	SetRoles(uuid string, roles []model.Role) (*model.UserRoles, error)
+}
+
+type roleService struct {
+	transactor repository.Transactor
+}
+
+func NewRoleService(transactor repository.Transactor) RoleService {
+	return &roleService{transactor: transactor}
+}
+
+func (roleService *roleService) GetRoles(uuid string) (*model.UserRoles, error) {
+	var userRoles *model.UserRoles
+	err := roleService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if _, err := requireUser(repos, uuid); err != nil {
+			return err
+		}
+		roles, err := repos.Roles.GetByUser(uuid)
+		userRoles = newUserRoles(uuid, roles)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return userRoles, nil
+}
+
+func (roleService *roleService) SetRoles(uuid string, roles []model.Role) (*model.UserRoles, error) {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if !role.IsAssignable() {
+			return nil, fmt.Errorf("%w: %q", model.ErrInvalidRole, role)
+		}
+		if !slices.Contains(names, string(role)) {
+			names = append(names, string(role))
+		}
+	}
+	slices.Sort(names)
+
+	err := roleService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if _, err := requireUser(repos, uuid); err != nil {
+			return err
+		}
+		previous, err := repos.Roles.GetByUser(uuid)
+		if err != nil {
+			return err
+		}
+		if err := repos.Roles.Replace(uuid, names); err != nil {
+			return err
+		}
+
+		for _, role := range previous {
+			if !slices.Contains(names, role) {
+				return repos.Sessions.RevokeAllForUser(uuid, "roles changed")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newUserRoles(uuid, names), nil
+}
+
+func newUserRoles(uuid string, names []string) *model.UserRoles {
+	roles := make([]model.Role, len(names))
+	for i, name := range names {
+		roles[i] = model.Role(name)
+	}
+	return &model.UserRoles{UserUUID: uuid, Roles: roles}
+}
@@ -0,0 +1,518 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// SCIMMaxResults is the page size of list requests without count and the
+	// largest one allowed.
+	SCIMMaxResults = 200
+
+	scimStatusReason = "SCIM provisioning"
+)
+
+var (
+	scimID = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	// scimComparison matches the comparison at the start of a filter.
+	scimComparison = regexp.MustCompile(`^([A-Za-z][\w.:\-]*)\s+([A-Za-z]{2})\s+("(?:[^"\\]|\\.)*"|\S+)`)
+	scimAnd        = regexp.MustCompile(`^\s+(?i:and)\s+`)
+	// scimPath matches lower-cased attribute paths such as
+	// emails[type eq "work"].value.
+	scimPath           = regexp.MustCompile(`^[a-z][\w.\-]*(\[[^\]]*\])?(\.[a-z]\w*)?$`)
+	scimEmailValuePath = regexp.MustCompile(`^emails(\[[^\]]*\])?\.value$`)
+)
+
+// SCIMService provisions users through SCIM 2.0 on top of UserService, so
+// the caller's permissions apply as they do in the REST API. Status changes
+// are recorded with actor.
+type SCIMService interface {
+	ServiceProviderConfig() model.SCIMServiceProviderConfig
+	Schemas() []model.SCIMSchema
+	ResourceTypes() []model.SCIMResourceType
+
+	GetUser(id string) (*model.SCIMUser, error)
+	ListUsers(query model.SCIMListQuery) (*model.SCIMListResponse, error)
+	CreateUser(resource *model.SCIMUser, actor string) (*model.SCIMUser, error)
+	ReplaceUser(id string, resource *model.SCIMUser, actor string) (*model.SCIMUser, error)
+	PatchUser(id string, patch *model.SCIMPatchRequest, actor string) (*model.SCIMUser, error)
+	DeleteUser(id string) error
+}
+
+type scimService struct {
+	users  UserService
+	config Config
+}
+
+func NewSCIMService(users UserService, config Config) SCIMService {
+	return &scimService{users: users, config: config}
+}
+
+func (scimService *scimService) location(parts ...string) string {
+	return strings.Join(append([]string{scimService.config.SCIMBaseURL}, parts...), "/")
+}
+
+func (scimService *scimService) ServiceProviderConfig() model.SCIMServiceProviderConfig {
+	return model.SCIMServiceProviderConfig{
+		Schemas:        []string{model.SCIMSchemaServiceProviderConfig},
+		Patch:          model.SCIMSupported{Supported: true},
+		Filter:         model.SCIMFilterSupport{Supported: true, MaxResults: SCIMMaxResults},
+		ChangePassword: model.SCIMSupported{Supported: false},
+		AuthenticationSchemes: []model.SCIMAuthenticationScheme{{
+			Type:        "oauthbearertoken",
+			Name:        "OAuth Bearer Token",
+			Description: "An API key or access token sent as Authorization: Bearer",
+			Primary:     true,
+		}},
+		Meta: &model.SCIMMeta{ResourceType: "ServiceProviderConfig", Location: scimService.location("ServiceProviderConfig")},
+	}
+}
+
+func (scimService *scimService) ResourceTypes() []model.SCIMResourceType {
+	return []model.SCIMResourceType{{
+		Schemas:     []string{model.SCIMSchemaResourceType},
+		ID:          "User",
+		Name:        "User",
+		Endpoint:    "/Users",
+		Description: "User Account",
+		Schema:      model.SCIMSchemaUser,
+		Meta:        &model.SCIMMeta{ResourceType: "ResourceType", Location: scimService.location("ResourceTypes", "User")},
+	}}
+}
+
+func scimStringAttribute(name, description string) model.SCIMAttribute {
+	return model.SCIMAttribute{
+		Name:        name,
+		Type:        "string",
+		Description: description,
+		Mutability:  "readWrite",
+		Returned:    "default",
+		Uniqueness:  "none",
+	}
+}
+
+// Schemas describes the User attributes that are stored; others are
+// ignored.
+func (scimService *scimService) Schemas() []model.SCIMSchema {
+	userName := scimStringAttribute("userName", "Unique identifier for the user within the organization.")
+	userName.Required, userName.CaseExact, userName.Uniqueness = true, true, "server"
+
+	name := scimStringAttribute("name", "The user's name, stored as one full name.")
+	name.Type = "complex"
+	name.SubAttributes = []model.SCIMAttribute{
+		scimStringAttribute("formatted", "The full name."),
+		scimStringAttribute("familyName", "Used for the full name when formatted and displayName are missing."),
+		scimStringAttribute("givenName", "Used for the full name when formatted and displayName are missing."),
+	}
+
+	emailValue := scimStringAttribute("value", "The email address.")
+	emailValue.Required, emailValue.Uniqueness = true, "server"
+	emailPrimary := scimStringAttribute("primary", "Whether this is the email to store.")
+	emailPrimary.Type = "boolean"
+	emails := scimStringAttribute("emails", "Only the primary email, or else the first, is stored.")
+	emails.Type, emails.MultiValued, emails.Required = "complex", true, true
+	emails.SubAttributes = []model.SCIMAttribute{emailValue, scimStringAttribute("type", "Reported as work."), emailPrimary}
+
+	active := scimStringAttribute("active", "True for active users. False suspends an active user.")
+	active.Type = "boolean"
+
+	password := scimStringAttribute("password", "The initial password. It can only be set on creation.")
+	password.Mutability, password.Returned = "writeOnly", "never"
+
+	return []model.SCIMSchema{{
+		Schemas:     []string{model.SCIMSchemaSchema},
+		ID:          model.SCIMSchemaUser,
+		Name:        "User",
+		Description: "User Account",
+		Attributes: []model.SCIMAttribute{
+			userName,
+			name,
+			scimStringAttribute("displayName", "The full name."),
+			emails,
+			active,
+			password,
+		},
+		Meta: &model.SCIMMeta{ResourceType: "Schema", Location: scimService.location("Schemas", model.SCIMSchemaUser)},
+	}}
+}
+
+// resource maps user onto the User schema.
+func (scimService *scimService) resource(user *model.User) *model.SCIMUser {
+	active := user.Status == model.UserStatusActive
+	created, lastModified := user.CreatedAt, user.UpdatedAt
+	resource := &model.SCIMUser{
+		Schemas:     []string{model.SCIMSchemaUser},
+		ID:          user.UUID,
+		UserName:    user.Username,
+		DisplayName: user.FullName,
+		Emails:      []model.SCIMEmail{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta: &model.SCIMMeta{
+			ResourceType: "User",
+			Created:      &created,
+			LastModified: &lastModified,
+			Location:     scimService.location("Users", user.UUID),
+		},
+	}
+	if user.FullName != "" {
+		resource.Name = &model.SCIMName{Formatted: user.FullName}
+	}
+	return resource
+}
+
+// lookup finds a user by SCIM id, which is the UUID.
+func (scimService *scimService) lookup(id string) (*model.User, error) {
+	if !scimID.MatchString(id) {
+		return nil, model.ErrUserNotFound
+	}
+	return scimService.users.GetUserByUUID(id)
+}
+
+func (scimService *scimService) GetUser(id string) (*model.SCIMUser, error) {
+	user, err := scimService.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	return scimService.resource(user), nil
+}
+
+func (scimService *scimService) ListUsers(query model.SCIMListQuery) (*model.SCIMListResponse, error) {
+	filter, err := parseSCIMFilter(query.Filter)
+	if err != nil {
+		return nil, err
+	}
+	startIndex := max(query.StartIndex, 1)
+	count := min(max(query.Count, 0), SCIMMaxResults)
+	users, total, err := scimService.users.GetUserPage(filter, startIndex-1, count)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]model.SCIMUser, 0, len(users))
+	for _, user := range users {
+		resources = append(resources, *scimService.resource(&user))
+	}
+	return &model.SCIMListResponse{
+		Schemas:      []string{model.SCIMSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}, nil
+}
+
+// CreateUser creates a pending user and activates it when active is true.
+func (scimService *scimService) CreateUser(resource *model.SCIMUser, actor string) (*model.SCIMUser, error) {
+	if err := requireSchema(resource.Schemas, model.SCIMSchemaUser); err != nil {
+		return nil, err
+	}
+	attributes := attributesOf(resource)
+	user, err := scimService.users.CreateUser(&model.CreateUserRequest{
+		Username: attributes.userName,
+		Email:    attributes.email,
+		FullName: attributes.fullName,
+		Password: attributes.password,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if attributes.active != nil && *attributes.active {
+		if user, err = scimService.users.TransitionUserStatus(user.UUID, model.UserStatusActive, scimStatusReason, actor); err != nil {
+			return nil, err
+		}
+	}
+	return scimService.resource(user), nil
+}
+
+func (scimService *scimService) ReplaceUser(id string, resource *model.SCIMUser, actor string) (*model.SCIMUser, error) {
+	if err := requireSchema(resource.Schemas, model.SCIMSchemaUser); err != nil {
+		return nil, err
+	}
+	user, err := scimService.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	return scimService.save(user, attributesOf(resource), actor)
+}
+
+// PatchUser applies the operations to the user's current attributes and
+// saves the result like a replace. Attributes that are not stored are
+// ignored.
+func (scimService *scimService) PatchUser(id string, patch *model.SCIMPatchRequest, actor string) (*model.SCIMUser, error) {
+	if err := requireSchema(patch.Schemas, model.SCIMSchemaPatchOp); err != nil {
+		return nil, err
+	}
+	if len(patch.Operations) == 0 {
+		return nil, fmt.Errorf("%w: Operations must not be empty", model.ErrInvalidSCIMSyntax)
+	}
+	user, err := scimService.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	active := user.Status == model.UserStatusActive
+	attributes := scimAttributes{userName: user.Username, email: user.Email, fullName: user.FullName, active: &active}
+	for _, operation := range patch.Operations {
+		if err := attributes.apply(operation); err != nil {
+			return nil, err
+		}
+	}
+	return scimService.save(user, attributes, actor)
+}
+
+func (scimService *scimService) DeleteUser(id string) error {
+	user, err := scimService.lookup(id)
+	if err != nil {
+		return err
+	}
+	return scimService.users.DeleteUser(user.UUID)
+}
+
+// save writes attributes over user, then suspends or activates it when
+// active asks for it. A deactivated user cannot be made active again.
+func (scimService *scimService) save(user *model.User, attributes scimAttributes, actor string) (*model.SCIMUser, error) {
+	if strings.TrimSpace(attributes.userName) == "" {
+		return nil, fmt.Errorf("userName: %w", model.ErrEmptyField)
+	}
+	if strings.TrimSpace(attributes.email) == "" {
+		return nil, fmt.Errorf("emails: %w", model.ErrEmptyField)
+	}
+	if attributes.password != "" {
+		return nil, fmt.Errorf("%w: password can only be set on creation", model.ErrSCIMMutability)
+	}
+
+	updated, err := scimService.users.UpdateUser(user.UUID, &model.UpdateUserRequest{
+		Username: attributes.userName,
+		Email:    attributes.email,
+		FullName: attributes.fullName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	target := model.UserStatus("")
+	switch {
+	case attributes.active == nil:
+	case *attributes.active && updated.Status != model.UserStatusActive:
+		target = model.UserStatusActive
+	case !*attributes.active && updated.Status == model.UserStatusActive:
+		target = model.UserStatusSuspended
+	}
+	if target != "" {
+		if updated, err = scimService.users.TransitionUserStatus(updated.UUID, target, scimStatusReason, actor); err != nil {
+			return nil, err
+		}
+	}
+	return scimService.resource(updated), nil
+}
+
+func requireSchema(schemas []string, schema string) error {
+	if !slices.Contains(schemas, schema) {
+		return fmt.Errorf("%w: schemas must contain %s", model.ErrInvalidSCIMSyntax, schema)
+	}
+	return nil
+}
+
+// scimAttributes are the attributes of a SCIM user that map onto User. A nil
+// active leaves the status alone.
+type scimAttributes struct {
+	userName string
+	email    string
+	fullName string
+	active   *bool
+	password string
+}
+
+func attributesOf(resource *model.SCIMUser) scimAttributes {
+	return scimAttributes{
+		userName: resource.UserName,
+		email:    primaryEmail(resource.Emails),
+		fullName: fullNameOf(resource.Name, resource.DisplayName),
+		active:   resource.Active,
+		password: resource.Password,
+	}
+}
+
+// fullNameOf prefers name.formatted, then displayName, then the given and
+// family name.
+func fullNameOf(name *model.SCIMName, displayName string) string {
+	if name != nil && strings.TrimSpace(name.Formatted) != "" {
+		return strings.TrimSpace(name.Formatted)
+	}
+	if strings.TrimSpace(displayName) != "" {
+		return strings.TrimSpace(displayName)
+	}
+	if name != nil {
+		return strings.TrimSpace(name.GivenName + " " + name.FamilyName)
+	}
+	return ""
+}
+
+func primaryEmail(emails []model.SCIMEmail) string {
+	for _, email := range emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	if len(emails) > 0 {
+		return emails[0].Value
+	}
+	return ""
+}
+
+// normalizePath lower-cases an attribute path, as attribute names are case
+// insensitive, and strips the User schema URI.
+func normalizePath(path string) string {
+	path = strings.ToLower(strings.TrimSpace(path))
+	return strings.TrimPrefix(path, strings.ToLower(model.SCIMSchemaUser)+":")
+}
+
+func (attributes *scimAttributes) apply(operation model.SCIMPatchOperation) error {
+	path := normalizePath(operation.Path)
+	if path != "" && !scimPath.MatchString(path) {
+		return fmt.Errorf("%w: %q", model.ErrInvalidSCIMPath, operation.Path)
+	}
+
+	switch op := strings.ToLower(operation.Op); op {
+	case "add", "replace":
+		if path != "" {
+			return attributes.set(path, operation.Value, op == "add")
+		}
+		// Without a path the value holds the attributes to set.
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &values); err != nil {
+			return fmt.Errorf("%w: value must be an object when path is omitted", model.ErrInvalidSCIMValue)
+		}
+		for _, key := range slices.Sorted(maps.Keys(values)) {
+			if err := attributes.set(normalizePath(key), values[key], op == "add"); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "remove":
+		if path == "" {
+			return fmt.Errorf("%w: remove needs a path", model.ErrSCIMNoTarget)
+		}
+		return attributes.remove(path)
+	default:
+		return fmt.Errorf("%w: unknown op %q", model.ErrInvalidSCIMSyntax, operation.Op)
+	}
+}
+
+// set sets the attribute at path. Only one email is stored, so adding
+// emails only changes it when one of them is primary.
+func (attributes *scimAttributes) set(path string, value json.RawMessage, adding bool) error {
+	switch {
+	case path == "username":
+		return decodeSCIMValue(path, value, &attributes.userName)
+	case path == "displayname", path == "name.formatted":
+		return decodeSCIMValue(path, value, &attributes.fullName)
+	case path == "name":
+		var name model.SCIMName
+		if err := decodeSCIMValue(path, value, &name); err != nil {
+			return err
+		}
+		attributes.fullName = fullNameOf(&name, "")
+	case path == "emails":
+		var emails []model.SCIMEmail
+		if err := decodeSCIMValue(path, value, &emails); err != nil {
+			return err
+		}
+		if !adding || slices.ContainsFunc(emails, func(email model.SCIMEmail) bool { return email.Primary }) {
+			attributes.email = primaryEmail(emails)
+		}
+	case scimEmailValuePath.MatchString(path):
+		return decodeSCIMValue(path, value, &attributes.email)
+	case path == "active":
+		active, err := decodeSCIMBool(value)
+		if err != nil {
+			return err
+		}
+		attributes.active = &active
+	case path == "password":
+		return decodeSCIMValue(path, value, &attributes.password)
+	}
+	return nil
+}
+
+func (attributes *scimAttributes) remove(path string) error {
+	switch {
+	case path == "username", path == "active", path == "password", strings.HasPrefix(path, "emails"):
+		return fmt.Errorf("%w: %s cannot be removed", model.ErrSCIMMutability, path)
+	case path == "displayname", path == "name", path == "name.formatted":
+		return fmt.Errorf("%w: the full name can be replaced but not removed", model.ErrSCIMMutability)
+	}
+	return nil
+}
+
+func decodeSCIMValue(path string, value json.RawMessage, target any) error {
+	if err := json.Unmarshal(value, target); err != nil {
+		return fmt.Errorf("%w: %s", model.ErrInvalidSCIMValue, path)
+	}
+	return nil
+}
+
+// decodeSCIMBool also accepts "True" and "False" as strings, which some
+// identity providers send.
+func decodeSCIMBool(value json.RawMessage) (bool, error) {
+	var active bool
+	if err := json.Unmarshal(value, &active); err == nil {
+		return active, nil
+	}
+	var text string
+	if err := json.Unmarshal(value, &text); err == nil {
+		switch strings.ToLower(text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("%w: active must be a boolean", model.ErrInvalidSCIMValue)
+}
+
+// parseSCIMFilter supports eq comparisons of userName and emails joined by
+// and, which is what identity providers send to find a user.
+func parseSCIMFilter(expression string) (model.UserFilter, error) {
+	var filter model.UserFilter
+	rest := strings.TrimSpace(expression)
+	for rest != "" {
+		match := scimComparison.FindStringSubmatch(rest)
+		if match == nil {
+			return model.UserFilter{}, fmt.Errorf("%w: cannot parse %q", model.ErrInvalidFilter, rest)
+		}
+		if !strings.EqualFold(match[2], "eq") {
+			return model.UserFilter{}, fmt.Errorf("%w: only the eq operator is supported", model.ErrInvalidFilter)
+		}
+		var value string
+		if err := json.Unmarshal([]byte(match[3]), &value); err != nil {
+			return model.UserFilter{}, fmt.Errorf("%w: %s must be compared to a string", model.ErrInvalidFilter, match[1])
+		}
+		switch normalizePath(match[1]) {
+		case "username":
+			filter.Username = value
+		case "emails", "emails.value":
+			filter.Email = value
+		default:
+			return model.UserFilter{}, fmt.Errorf("%w: filtering by %s is not supported", model.ErrInvalidFilter, match[1])
+		}
+
+		rest = rest[len(match[0]):]
+		if rest == "" {
+			break
+		}
+		separator := scimAnd.FindString(rest)
+		if separator == "" {
+			return model.UserFilter{}, fmt.Errorf("%w: comparisons can only be joined with and", model.ErrInvalidFilter)
+		}
+		rest = rest[len(separator):]
+	}
+	return filter, nil
+}
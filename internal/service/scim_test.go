@@ -0,0 +1,435 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const scimActor = "api_key:okta"
+
+// rfcCreateUser is the creation example of RFC 7644 section 3.3 with the
+// work email of the full User example in RFC 7643 section 8.2, as email is
+// required here.
+const rfcCreateUser = `{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+	"userName": "bjensen",
+	"externalId": "bjensen",
+	"name": {
+		"formatted": "Ms. Barbara J Jensen III",
+		"familyName": "Jensen",
+		"givenName": "Barbara"
+	},
+	"emails": [{"value": "bjensen@example.com", "type": "work", "primary": true}],
+	"active": true
+}`
+
+func setupSCIMTest() (*mockUserRepository, SCIMService) {
+	repo, userService := setupTest()
+	users := AuthorizeUsers(userService, userPrincipal("admin-uuid", "admin"))
+	return repo, NewSCIMService(users, DefaultConfig())
+}
+
+func decodeSCIM[T any](test *testing.T, body string) *T {
+	var target T
+	require.NoError(test, json.Unmarshal([]byte(body), &target))
+	return &target
+}
+
+func createSCIMUser(test *testing.T, scim SCIMService) *model.SCIMUser {
+	created, err := scim.CreateUser(decodeSCIM[model.SCIMUser](test, rfcCreateUser), scimActor)
+	require.NoError(test, err)
+	return created
+}
+
+func TestShouldCreateSCIMUserFromRFCExample(test *testing.T) {
+	// given
+	repo, scim := setupSCIMTest()
+
+	// when
+	created, err := scim.CreateUser(decodeSCIM[model.SCIMUser](test, rfcCreateUser), scimActor)
+
+	// then
+	assert.NoError(test, err)
+	stored := repo.users[created.ID]
+	assert.Equal(test, "bjensen", stored.Username)
+	assert.Equal(test, "bjensen@example.com", stored.Email)
+	assert.Equal(test, "Ms. Barbara J Jensen III", stored.FullName)
+	assert.Equal(test, model.UserStatusActive, stored.Status)
+
+	assert.Equal(test, []string{model.SCIMSchemaUser}, created.Schemas)
+	assert.Equal(test, "Ms. Barbara J Jensen III", created.Name.Formatted)
+	assert.True(test, *created.Active)
+	assert.Equal(test, "User", created.Meta.ResourceType)
+	assert.Equal(test, "http://localhost:8080/scim/v2/Users/"+created.ID, created.Meta.Location)
+	assert.Empty(test, created.ExternalID)
+}
+
+func TestShouldRejectSCIMUserWithoutSchemaOrEmail(test *testing.T) {
+	// given
+	_, scim := setupSCIMTest()
+	withoutSchema := decodeSCIM[model.SCIMUser](test, `{"userName": "bjensen", "emails": [{"value": "bjensen@example.com"}]}`)
+	withoutEmail := decodeSCIM[model.SCIMUser](test, `{"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"], "userName": "bjensen"}`)
+
+	// when
+	_, schemaErr := scim.CreateUser(withoutSchema, scimActor)
+	_, emailErr := scim.CreateUser(withoutEmail, scimActor)
+
+	// then
+	assert.ErrorIs(test, schemaErr, model.ErrInvalidSCIMSyntax)
+	assert.ErrorIs(test, emailErr, model.ErrEmptyField)
+}
+
+func TestShouldRejectDuplicateSCIMUserName(test *testing.T) {
+	// given
+	_, scim := setupSCIMTest()
+	createSCIMUser(test, scim)
+
+	// when
+	_, err := scim.CreateUser(decodeSCIM[model.SCIMUser](test, rfcCreateUser), scimActor)
+
+	// then
+	assert.ErrorIs(test, err, model.ErrUserAlreadyExists)
+}
+
+func TestShouldFilterSCIMUsersByUserName(test *testing.T) {
+	// given
+	_, scim := setupSCIMTest()
+	created := createSCIMUser(test, scim)
+	_, err := scim.CreateUser(decodeSCIM[model.SCIMUser](test, `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "jsmith",
+		"emails": [{"value": "jsmith@example.com"}]
+	}`), scimActor)
+	require.NoError(test, err)
+
+	// when
+	list, err := scim.ListUsers(model.SCIMListQuery{Filter: `userName Eq "bjensen"`, Count: SCIMMaxResults})
+	missing, _ := scim.ListUsers(model.SCIMListQuery{Filter: `userName eq "nobody"`, Count: SCIMMaxResults})
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, []string{model.SCIMSchemaListResponse}, list.Schemas)
+	assert.Equal(test, 1, list.TotalResults)
+	assert.Equal(test, 1, list.StartIndex)
+	assert.Equal(test, created.ID, list.Resources.([]model.SCIMUser)[0].ID)
+	assert.Equal(test, 0, missing.TotalResults)
+	assert.Empty(test, missing.Resources)
+}
+
+func TestShouldPageSCIMUsers(test *testing.T) {
+	// given
+	_, userService := setupTest()
+	for _, username := range []string{"one", "two", "three"} {
+		_, err := userService.CreateUser(&model.CreateUserRequest{Username: username, Email: username + "@example.com"})
+		require.NoError(test, err)
+	}
+	scim := NewSCIMService(userService, DefaultConfig())
+
+	// when
+	page, err := scim.ListUsers(model.SCIMListQuery{StartIndex: 2, Count: 1})
+	empty, _ := scim.ListUsers(model.SCIMListQuery{StartIndex: 1, Count: 0})
+	beyond, _ := scim.ListUsers(model.SCIMListQuery{StartIndex: 10, Count: 5})
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, 3, page.TotalResults)
+	assert.Equal(test, 2, page.StartIndex)
+	assert.Equal(test, 1, page.ItemsPerPage)
+	assert.Equal(test, "two", page.Resources.([]model.SCIMUser)[0].UserName)
+	assert.Equal(test, 3, empty.TotalResults)
+	assert.Equal(test, 0, empty.ItemsPerPage)
+	assert.Equal(test, 0, beyond.ItemsPerPage)
+}
+
+func TestShouldParseSupportedSCIMFilters(test *testing.T) {
+	// given
+	filters := map[string]model.UserFilter{
+		`userName eq "bjensen"`: {Username: "bjensen"},
+		`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "bjensen"`: {Username: "bjensen"},
+		`emails.value eq "bjensen@example.com"`:                            {Email: "bjensen@example.com"},
+		`userName eq "b \"jensen\"" AND emails eq "bjensen@example.com"`:   {Username: `b "jensen"`, Email: "bjensen@example.com"},
+		``: {},
+	}
+
+	for expression, expected := range filters {
+		// when
+		filter, err := parseSCIMFilter(expression)
+
+		// then
+		assert.NoError(test, err, expression)
+		assert.Equal(test, expected, filter, expression)
+	}
+}
+
+func TestShouldRejectUnsupportedSCIMFilters(test *testing.T) {
+	// given
+	filters := []string{
+		`userName co "jen"`,
+		`title pr`,
+		`userName eq "bjensen" or userName eq "jsmith"`,
+		`userName eq "bjensen" and`,
+		`externalId eq "bjensen"`,
+		`userName eq true`,
+		`(userName eq "bjensen")`,
+	}
+
+	for _, expression := range filters {
+		// when
+		_, err := parseSCIMFilter(expression)
+
+		// then
+		assert.ErrorIs(test, err, model.ErrInvalidFilter, expression)
+	}
+}
+
+func TestShouldReplaceSCIMUserFromRFCExample(test *testing.T) {
+	// given
+	repo, scim := setupSCIMTest()
+	created := createSCIMUser(test, scim)
+	// RFC 7644 section 3.5.1 with a new work email. No email is marked
+	// primary, so the first is stored.
+	replacement := decodeSCIM[model.SCIMUser](test, `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"id": "`+created.ID+`",
+		"userName": "bjensen",
+		"externalId": "bjensen",
+		"name": {
+			"formatted": "Ms. Barbara J Jensen III",
+			"familyName": "Jensen",
+			"givenName": "Barbara",
+			"middleName": "Jane"
+		},
+		"roles": [],
+		"emails": [
+			{"value": "bjensen@example.org"},
+			{"value": "babs@jensen.org"}
+		]
+	}`)
+
+	// when
+	replaced, err := scim.ReplaceUser(created.ID, replacement, scimActor)
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, "bjensen@example.org", repo.users[created.ID].Email)
+	assert.Equal(test, "bjensen@example.org", replaced.Emails[0].Value)
+	assert.True(test, *replaced.Active, "active was omitted and stays as it was")
+}
+
+func TestShouldRejectSCIMReplaceWithoutUserNameOrWithPassword(test *testing.T) {
+	// given
+	_, scim := setupSCIMTest()
+	created := createSCIMUser(test, scim)
+	withoutUserName := decodeSCIM[model.SCIMUser](test, `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"emails": [{"value": "bjensen@example.com"}]
+	}`)
+	withPassword := decodeSCIM[model.SCIMUser](test, rfcCreateUser)
+	withPassword.Password = "t1meMa$heen machine"
+
+	// when
+	_, userNameErr := scim.ReplaceUser(created.ID, withoutUserName, scimActor)
+	_, passwordErr := scim.ReplaceUser(created.ID, withPassword, scimActor)
+	_, missingErr := scim.ReplaceUser("2819c223-7f76-453a-919d-413861904646", decodeSCIM[model.SCIMUser](test, rfcCreateUser), scimActor)
+
+	// then
+	assert.ErrorIs(test, userNameErr, model.ErrEmptyField)
+	assert.ErrorIs(test, passwordErr, model.ErrSCIMMutability)
+	assert.ErrorIs(test, missingErr, model.ErrUserNotFound)
+}
+
+func TestShouldPatchSCIMUserFromRFCExamples(test *testing.T) {
+	// given
+	repo, scim := setupSCIMTest()
+	created := createSCIMUser(test, scim)
+	// RFC 7644 section 3.5.2.1: adding a non-primary email keeps the one stored.
+	add := decodeSCIM[model.SCIMPatchRequest](test, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{
+			"op": "add",
+			"value": {
+				"emails": [{"value": "babs@jensen.org", "type": "home"}],
+				"nickName": "Babs"
+			}
+		}]
+	}`)
+	// RFC 7644 section 3.5.2.3 with a new primary email.
+	replace := decodeSCIM[model.SCIMPatchRequest](test, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{
+			"op": "replace",
+			"value": {
+				"emails": [
+					{"value": "bjensen@example.org", "type": "work", "primary": true},
+					{"value": "babs@jensen.org", "type": "home"}
+				],
+				"nickName": "Babs"
+			}
+		}]
+	}`)
+
+	// when
+	added, addErr := scim.PatchUser(created.ID, add, scimActor)
+	replaced, replaceErr := scim.PatchUser(created.ID, replace, scimActor)
+
+	// then
+	assert.NoError(test, addErr)
+	assert.Equal(test, "bjensen@example.com", added.Emails[0].Value)
+	assert.NoError(test, replaceErr)
+	assert.Equal(test, "bjensen@example.org", replaced.Emails[0].Value)
+	assert.Equal(test, "bjensen@example.org", repo.users[created.ID].Email)
+}
+
+func TestShouldPatchSCIMUserAttributesByPath(test *testing.T) {
+	// given
+	repo, scim := setupSCIMTest()
+	created := createSCIMUser(test, scim)
+	patch := decodeSCIM[model.SCIMPatchRequest](test, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "path": "userName", "value": "babs"},
+			{"op": "Replace", "path": "emails[type eq \"work\"].value", "value": "babs@example.com"},
+			{"op": "Replace", "path": "displayName", "value": "Babs Jensen"},
+			{"op": "Add", "path": "name.givenName", "value": "Barbara"},
+			{"op": "Replace", "path": "urn:ietf:params:scim:schemas:core:2.0:User:active", "value": "False"}
+		]
+	}`)
+
+	// when
+	patched, err := scim.PatchUser(created.ID, patch, scimActor)
+
+	// then
+	assert.NoError(test, err)
+	stored := repo.users[created.ID]
+	assert.Equal(test, "babs", stored.Username)
+	assert.Equal(test, "babs@example.com", stored.Email)
+	assert.Equal(test, "Babs Jensen", stored.FullName)
+	assert.Equal(test, model.UserStatusSuspended, stored.Status)
+	assert.False(test, *patched.Active)
+}
+
+func TestShouldReactivateSuspendedSCIMUser(test *testing.T) {
+	// given
+	repo, scim := setupSCIMTest()
+	created := createSCIMUser(test, scim)
+	deactivate := decodeSCIM[model.SCIMPatchRequest](test, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "replace", "value": {"active": false}}]
+	}`)
+	activate := decodeSCIM[model.SCIMPatchRequest](test, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "replace", "path": "active", "value": true}]
+	}`)
+	_, err := scim.PatchUser(created.ID, deactivate, scimActor)
+	require.NoError(test, err)
+
+	// when
+	activated, err := scim.PatchUser(created.ID, activate, scimActor)
+
+	// then
+	assert.NoError(test, err)
+	assert.True(test, *activated.Active)
+	assert.Equal(test, model.UserStatusActive, repo.users[created.ID].Status)
+}
+
+func TestShouldNotReactivateDeactivatedSCIMUser(test *testing.T) {
+	// given
+	repo, scim := setupSCIMTest()
+	created := createSCIMUser(test, scim)
+	repo.users[created.ID].Status = model.UserStatusDeactivated
+	activate := decodeSCIM[model.SCIMPatchRequest](test, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "replace", "path": "active", "value": true}]
+	}`)
+
+	// when
+	_, err := scim.PatchUser(created.ID, activate, scimActor)
+
+	// then
+	assert.ErrorIs(test, err, model.ErrIllegalStatusTransition)
+}
+
+func TestShouldRejectInvalidSCIMPatches(test *testing.T) {
+	// given
+	_, scim := setupSCIMTest()
+	created := createSCIMUser(test, scim)
+	patches := map[string]error{
+		// RFC 7644 section 3.5.2.2 removes emails, which are required here.
+		`{"op": "remove", "path": "emails[type eq \"work\" and value ew \"example.com\"]"}`: model.ErrSCIMMutability,
+		`{"op": "remove"}`: model.ErrSCIMNoTarget,
+		`{"op": "replace", "path": "userName", "value": 42}`:        model.ErrInvalidSCIMValue,
+		`{"op": "replace", "path": "active", "value": "maybe"}`:     model.ErrInvalidSCIMValue,
+		`{"op": "replace", "path": "emails[type eq", "value": "x"}`: model.ErrInvalidSCIMPath,
+		`{"op": "replace", "value": "bjensen"}`:                     model.ErrInvalidSCIMValue,
+		`{"op": "move", "path": "userName", "value": "babs"}`:       model.ErrInvalidSCIMSyntax,
+		`{"op": "replace", "path": "password", "value": "secret"}`:  model.ErrSCIMMutability,
+	}
+
+	for operation, expected := range patches {
+		patch := decodeSCIM[model.SCIMPatchRequest](test,
+			`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [`+operation+`]}`)
+
+		// when
+		_, err := scim.PatchUser(created.ID, patch, scimActor)
+
+		// then
+		assert.ErrorIs(test, err, expected, operation)
+	}
+	_, err := scim.PatchUser(created.ID, &model.SCIMPatchRequest{Schemas: []string{model.SCIMSchemaPatchOp}}, scimActor)
+	assert.ErrorIs(test, err, model.ErrInvalidSCIMSyntax)
+}
+
+func TestShouldDeleteSCIMUser(test *testing.T) {
+	// given
+	repo, scim := setupSCIMTest()
+	created := createSCIMUser(test, scim)
+
+	// when
+	err := scim.DeleteUser(created.ID)
+	_, getErr := scim.GetUser(created.ID)
+	_, malformedErr := scim.GetUser("not-a-uuid")
+
+	// then
+	assert.NoError(test, err)
+	assert.NotContains(test, repo.users, created.ID)
+	assert.ErrorIs(test, getErr, model.ErrUserNotFound)
+	assert.ErrorIs(test, malformedErr, model.ErrUserNotFound)
+}
+
+func TestShouldApplyCallerPermissionsToSCIM(test *testing.T) {
+	// given
+	_, userService := setupTest()
+	scim := NewSCIMService(AuthorizeUsers(userService, userPrincipal("support-uuid", "support")), DefaultConfig())
+
+	// when
+	_, createErr := scim.CreateUser(decodeSCIM[model.SCIMUser](test, rfcCreateUser), scimActor)
+	_, listErr := scim.ListUsers(model.SCIMListQuery{Count: SCIMMaxResults})
+
+	// then
+	assert.ErrorIs(test, createErr, model.ErrForbidden)
+	assert.NoError(test, listErr)
+}
+
+func TestShouldDescribeSCIMServiceProvider(test *testing.T) {
+	// given
+	_, scim := setupSCIMTest()
+
+	// when
+	config := scim.ServiceProviderConfig()
+	schemas := scim.Schemas()
+	resourceTypes := scim.ResourceTypes()
+
+	// then
+	assert.True(test, config.Patch.Supported)
+	assert.True(test, config.Filter.Supported)
+	assert.False(test, config.Bulk.Supported)
+	assert.Equal(test, "oauthbearertoken", config.AuthenticationSchemes[0].Type)
+	assert.Equal(test, model.SCIMSchemaUser, schemas[0].ID)
+	assert.Equal(test, "userName", schemas[0].Attributes[0].Name)
+	assert.Equal(test, "/Users", resourceTypes[0].Endpoint)
+	assert.Equal(test, model.SCIMSchemaUser, resourceTypes[0].Schema)
+}
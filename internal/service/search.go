@@ -0,0 +1,54 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/search"
+	"fmt"
+	"strings"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// SearchUsers ranks users matching query.Query and highlights the matched
+// fragments of each searchable field.
+func (userService *userService) SearchUsers(query model.SearchQuery) (*model.SearchPage, error) {
+	query.Query = strings.TrimSpace(query.Query)
+	if query.Query == "" {
+		return nil, fmt.Errorf("q: %w", model.ErrEmptyField)
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultSearchLimit
+	}
+	query.Limit = min(query.Limit, maxSearchLimit)
+	query.Offset = max(query.Offset, 0)
+
+	results, total, err := userService.userRepository.Search(query)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		results[i].Highlights = highlightUser(&results[i].User, query.Query)
+	}
+
+	return &model.SearchPage{
+		Query:   query.Query,
+		Total:   total,
+		Limit:   query.Limit,
+		Offset:  query.Offset,
+		Results: results,
+	}, nil
+}
+
+func highlightUser(user *model.User, query string) map[string]string {
+	highlights := make(map[string]string)
+	for field, value := range map[string]string{"username": user.Username, "email": user.Email, "full_name": user.FullName} {
+		if highlighted, ok := search.Highlight(value, query); ok {
+			highlights[field] = highlighted
+		}
+	}
+	return highlights
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldSearchUsersWithHighlights(test *testing.T) {
+	// given
+	mockRepo, userService := setupTest()
+	user := &model.User{ID: 1, UUID: generateMockUUID(1), Username: "asmith", Email: "asmith@example.com", FullName: "Alice Smith"}
+	other := &model.User{ID: 2, UUID: generateMockUUID(2), Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"}
+	mockRepo.users[user.UUID] = user
+	mockRepo.users[other.UUID] = other
+
+	// when
+	page, err := userService.SearchUsers(model.SearchQuery{Query: " Smith "})
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, 1, page.Total)
+	assert.Equal(test, defaultSearchLimit, page.Limit)
+	assert.Equal(test, "asmith", page.Results[0].User.Username)
+	assert.Equal(test, "Alice <mark>Smith</mark>", page.Results[0].Highlights["full_name"])
+	assert.Equal(test, "a<mark>smith</mark>", page.Results[0].Highlights["username"])
+}
+
+func TestShouldRejectEmptySearchQuery(test *testing.T) {
+	// given
+	_, userService := setupTest()
+
+	// when
+	page, err := userService.SearchUsers(model.SearchQuery{Query: "   "})
+
+	// then
+	assert.ErrorIs(test, err, model.ErrEmptyField)
+	assert.Nil(test, page)
+}
+
+func TestShouldCapSearchPageSize(test *testing.T) {
+	// given
+	_, userService := setupTest()
+
+	// when
+	page, err := userService.SearchUsers(model.SearchQuery{Query: "anyone", Limit: 1000, Offset: -5})
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, maxSearchLimit, page.Limit)
+	assert.Equal(test, 0, page.Offset)
+}
@@ -1,13 +1,203 @@
 package service
 
-import "cruder/internal/repository"
+import (
+	"cruder/internal/model"
+	"cruder/internal/notify"
+	"cruder/internal/password"
+	"cruder/internal/repository"
+	"cruder/internal/secretbox"
+	"cruder/internal/token"
+	"fmt"
+	"time"
+)
+
+// LoginThrottlePolicy limits failed logins for one account or client IP.
+// From DelayAfter failures on, each attempt has to wait BaseDelay, doubling
+// with every further failure up to MaxDelay. LockAfter failures lock logins
+// for LockDuration. Failures are forgotten after Window without one. Zero
+// DelayAfter or LockAfter disables that stage.
+type LoginThrottlePolicy struct {
+	DelayAfter   int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	LockAfter    int
+	LockDuration time.Duration
+	Window       time.Duration
+}
+
+type Config struct {
+	MaxBatchSize   int
+	PasswordParams password.Params
+	PasswordPolicy password.Policy
+
+	// SigningKeys signs access tokens. Without keys, token issuance fails.
+	SigningKeys     *token.KeySet
+	TokenIssuer     string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// Notifier emails users about their account. Without one, no emails
+	// are sent.
+	Notifier        *notify.Notifier
+	VerificationURL string
+
+	VerificationTokenTTL       time.Duration
+	VerificationResendInterval time.Duration
+	VerificationResendLimit    int
+
+	PasswordResetURL      string
+	PasswordResetTokenTTL time.Duration
+	// PasswordResetEmailLimit and PasswordResetIPLimit cap the reset
+	// requests per email address and per client IP within an hour.
+	PasswordResetEmailLimit int
+	PasswordResetIPLimit    int
+
+	// MFASecrets encrypts TOTP secrets. Without it, users cannot enroll.
+	MFASecrets *secretbox.Box
+	// MFARequiredRoles lists the roles that have to sign in with a second
+	// factor. Users holding one enroll during their next login.
+	MFARequiredRoles     []string
+	MFAChallengeTTL      time.Duration
+	MFAChallengeAttempts int
+
+	AccountThrottle LoginThrottlePolicy
+	IPThrottle      LoginThrottlePolicy
+
+	// SessionRetention is how long ended sessions are kept before the
+	// cleanup job, running every SessionCleanupInterval, deletes them.
+	SessionRetention       time.Duration
+	SessionCleanupInterval time.Duration
+
+	// OIDCIssuer is the public base URL of the OpenID Connect provider and
+	// the issuer of its ID tokens. Its access and ID tokens live for
+	// AccessTokenTTL.
+	OIDCIssuer  string
+	OIDCCodeTTL time.Duration
+
+	// SCIMBaseURL is the public URL of the SCIM endpoints, used for the
+	// location of resources.
+	SCIMBaseURL string
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MaxBatchSize:   1000,
+		PasswordParams: password.DefaultParams(),
+		PasswordPolicy: password.DefaultPolicy(),
+
+		TokenIssuer:     "cruder",
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 30 * 24 * time.Hour,
+
+		VerificationURL:            "http://localhost:8080/verify-email",
+		VerificationTokenTTL:       48 * time.Hour,
+		VerificationResendInterval: time.Minute,
+		VerificationResendLimit:    5,
+
+		PasswordResetURL:        "http://localhost:8080/reset-password",
+		PasswordResetTokenTTL:   30 * time.Minute,
+		PasswordResetEmailLimit: 5,
+		PasswordResetIPLimit:    20,
+
+		MFAChallengeTTL:      5 * time.Minute,
+		MFAChallengeAttempts: 5,
+
+		AccountThrottle: LoginThrottlePolicy{
+			DelayAfter: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second,
+			LockAfter: 10, LockDuration: 15 * time.Minute, Window: 15 * time.Minute,
+		},
+		IPThrottle: LoginThrottlePolicy{
+			DelayAfter: 20, BaseDelay: time.Second, MaxDelay: 30 * time.Second,
+			LockAfter: 100, LockDuration: 15 * time.Minute, Window: 15 * time.Minute,
+		},
+
+		SessionRetention:       7 * 24 * time.Hour,
+		SessionCleanupInterval: time.Hour,
+
+		OIDCIssuer:  "http://localhost:8080",
+		OIDCCodeTTL: time.Minute,
+
+		SCIMBaseURL: "http://localhost:8080/scim/v2",
+	}
+}
 
 type Service struct {
-	Users UserService
+	Users    UserService
+	Webhooks WebhookService
+	Auth     AuthService
+	Tokens   TokenService
+	Sessions SessionService
+	Roles    RoleService
+	Groups   GroupService
+
+	Verifications  EmailVerificationService
+	PasswordResets PasswordResetService
+	MFA            MFAService
+	OIDC           OIDCService
+	SCIM           SCIMService
+
+	repos  *repository.Repository
+	config Config
 }
 
-func NewService(repos *repository.Repository) *Service {
+func NewService(repos *repository.Repository, config Config) *Service {
+	users := NewUserService(repos.Users, repos, config)
 	return &Service{
-		Users: NewUserService(repos.Users),
+		repos:    repos,
+		config:   config,
+		Users:    users,
+		Webhooks: NewWebhookService(repos),
+		Auth:     newAuthService(repos.TenantID(), repos.Users, repos, config),
+		Tokens:   NewTokenService(repos, repos, config),
+		Sessions: NewSessionService(repos, config),
+		Roles:    NewRoleService(repos),
+		Groups:   NewGroupService(repos),
+
+		Verifications:  NewEmailVerificationService(repos, config),
+		PasswordResets: NewPasswordResetService(repos, config),
+		MFA:            NewMFAService(repos, config),
+		OIDC:           NewOIDCService(repos, config),
+		SCIM:           NewSCIMService(users, config),
+	}
+}
+
+// ForTenant returns the services with user access limited to tenantID.
+func (service *Service) ForTenant(tenantID string) *Service {
+	return NewService(service.repos.ForTenant(tenantID), service.config)
+}
+
+// ForOrganization resolves an organization slug, DefaultOrganizationSlug if
+// empty, to the services of that tenant.
+func (service *Service) ForOrganization(slug string) (*Service, error) {
+	if slug == "" {
+		slug = model.DefaultOrganizationSlug
+	}
+	organization, err := service.repos.Organizations.GetBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+	if organization == nil {
+		return nil, fmt.Errorf("%w: %s", model.ErrOrganizationNotFound, slug)
+	}
+	return service.ForTenant(organization.ID), nil
+}
+
+// As returns the services acting for principal: scoped to its tenant and
+// limited to what its roles allow. A nil principal sees no users.
+func (service *Service) As(principal *model.Principal) *Service {
+	tenantID := ""
+	if principal != nil {
+		tenantID = principal.TenantID
 	}
+	scoped := service.ForTenant(tenantID)
+	scoped.Users = AuthorizeUsers(scoped.Users, principal)
+	scoped.Auth = AuthorizeAuth(scoped.Auth, principal)
+	scoped.Sessions = AuthorizeSessions(scoped.Sessions, principal)
+	scoped.Roles = AuthorizeRoles(scoped.Roles, principal)
+	scoped.Groups = AuthorizeGroups(scoped.Groups, principal)
+	scoped.Verifications = AuthorizeVerifications(scoped.Verifications, principal)
+	scoped.MFA = AuthorizeMFA(scoped.MFA, principal)
+	scoped.Webhooks = AuthorizeWebhooks(scoped.Webhooks, principal)
+	scoped.SCIM = NewSCIMService(scoped.Users, scoped.config)
+	return scoped
 }
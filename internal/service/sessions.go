@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"log"
+	"time"
+	"unicode/utf8"
+)
+
+const maxUserAgentLength = 512
+
+type SessionService interface {
+	// ListSessions returns the user's active sessions and marks currentID,
+	// the session of the caller, as current.
+	ListSessions(uuid, currentID string) ([]model.Session, error)
+	RevokeSession(uuid, sessionID, actor string) error
+	// RevokeOtherSessions revokes all of the user's sessions except keepID,
+	// which may be empty to revoke all.
+	RevokeOtherSessions(uuid, keepID, actor string) error
+	// DeleteEndedSessions removes sessions that ended SessionRetention ago.
+	DeleteEndedSessions() (int64, error)
+	// RunCleanup calls DeleteEndedSessions every SessionCleanupInterval
+	// until ctx is done.
+	RunCleanup(ctx context.Context)
+}
+
+type sessionService struct {
+	transactor repository.Transactor
+	config     Config
+	now        func() time.Time
+}
+
+func NewSessionService(transactor repository.Transactor, config Config) SessionService {
+	return &sessionService{transactor: transactor, config: config, now: time.Now}
+}
+
+func (sessionService *sessionService) ListSessions(uuid, currentID string) ([]model.Session, error) {
+	var sessions []model.Session
+	err := sessionService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if _, err := requireUser(repos, uuid); err != nil {
+			return err
+		}
+		var err error
+		sessions, err = repos.Sessions.ListActiveForUser(uuid, sessionService.now())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for index := range sessions {
+		sessions[index].Current = currentID != "" && sessions[index].ID == currentID
+	}
+	return sessions, nil
+}
+
+func (sessionService *sessionService) RevokeSession(uuid, sessionID, actor string) error {
+	return sessionService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if _, err := requireUser(repos, uuid); err != nil {
+			return err
+		}
+		session, err := repos.Sessions.Get(sessionID)
+		if err != nil {
+			return err
+		}
+		if session == nil || session.UserUUID != uuid || !session.Active(sessionService.now()) {
+			return model.ErrSessionNotFound
+		}
+		return repos.Sessions.Revoke(sessionID, "revoked by "+actor)
+	})
+}
+
+func (sessionService *sessionService) RevokeOtherSessions(uuid, keepID, actor string) error {
+	return sessionService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if _, err := requireUser(repos, uuid); err != nil {
+			return err
+		}
+		return repos.Sessions.RevokeOthersForUser(uuid, keepID, "revoked by "+actor)
+	})
+}
+
+func (sessionService *sessionService) DeleteEndedSessions() (int64, error) {
+	var deleted int64
+	err := sessionService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		var err error
+		deleted, err = repos.Sessions.DeleteEnded(sessionService.now().Add(-sessionService.config.SessionRetention))
+		return err
+	})
+	return deleted, err
+}
+
+func (sessionService *sessionService) RunCleanup(ctx context.Context) {
+	ticker := time.NewTicker(sessionService.config.SessionCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := sessionService.DeleteEndedSessions(); err != nil {
+			log.Printf("session cleanup: %v", err)
+		} else if deleted > 0 {
+			log.Printf("session cleanup: deleted %d ended sessions", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// truncate shortens value to at most length bytes without splitting a rune.
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+	value = value[:length]
+	for !utf8.ValidString(value) {
+		value = value[:len(value)-1]
+	}
+	return value
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"cruder/internal/token"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupSessionTest(test *testing.T) (*repository.Repository, UserService, TokenService, *sessionService) {
+	mockRepo := newMockUserRepository()
+	repos := newMockRepos(mockRepo, &mockOutboxRepository{})
+	transactor := &mockTransactor{repos: repos, users: mockRepo}
+
+	key, err := token.GenerateKey()
+	require.NoError(test, err)
+	config := testPasswordConfig()
+	config.SigningKeys = token.NewKeySet(key)
+	sessions := NewSessionService(transactor, config).(*sessionService)
+	return repos, NewUserService(mockRepo, transactor, config), NewTokenService(repos, transactor, config), sessions
+}
+
+func TestShouldListActiveSessionsMarkingCurrent(test *testing.T) {
+	// given
+	_, userService, tokenService, sessions := setupSessionTest(test)
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	laptop, _ := tokenService.IssueTokens(user, model.SessionClient{UserAgent: "Firefox", IPAddress: "192.0.2.1"})
+	_, _ = tokenService.IssueTokens(user, model.SessionClient{UserAgent: "curl", IPAddress: "192.0.2.2"})
+	loggedOut, _ := tokenService.IssueTokens(user, model.SessionClient{})
+	require.NoError(test, tokenService.Logout(loggedOut.RefreshToken, ""))
+	principal, _ := tokenService.Authenticate(laptop.AccessToken)
+
+	// when
+	listed, err := sessions.ListSessions(user.UUID, principal.SessionID)
+
+	// then
+	assert.NoError(test, err)
+	require.Len(test, listed, 2)
+	assert.Equal(test, "Firefox", listed[0].UserAgent)
+	assert.Equal(test, "192.0.2.1", listed[0].IPAddress)
+	assert.True(test, listed[0].Current)
+	assert.Equal(test, "curl", listed[1].UserAgent)
+	assert.False(test, listed[1].Current)
+}
+
+func TestShouldRevokeSingleSession(test *testing.T) {
+	// given
+	_, userService, tokenService, sessions := setupSessionTest(test)
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	other, _ := userService.CreateUser(&model.CreateUserRequest{Username: "other", Email: "other@example.com"})
+	kept, _ := tokenService.IssueTokens(user, model.SessionClient{})
+	revoked, _ := tokenService.IssueTokens(user, model.SessionClient{})
+	revokedPrincipal, _ := tokenService.Authenticate(revoked.AccessToken)
+
+	// when
+	err := sessions.RevokeSession(user.UUID, revokedPrincipal.SessionID, "user:"+user.UUID)
+	againErr := sessions.RevokeSession(user.UUID, revokedPrincipal.SessionID, "user:"+user.UUID)
+	otherErr := sessions.RevokeSession(other.UUID, revokedPrincipal.SessionID, "user:"+other.UUID)
+
+	// then
+	assert.NoError(test, err)
+	assert.ErrorIs(test, againErr, model.ErrSessionNotFound)
+	assert.ErrorIs(test, otherErr, model.ErrSessionNotFound)
+	_, revokedErr := tokenService.Authenticate(revoked.AccessToken)
+	assert.ErrorIs(test, revokedErr, model.ErrInvalidToken)
+	_, keptErr := tokenService.Authenticate(kept.AccessToken)
+	assert.NoError(test, keptErr)
+}
+
+func TestShouldRevokeAllOtherSessions(test *testing.T) {
+	// given
+	_, userService, tokenService, sessions := setupSessionTest(test)
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	current, _ := tokenService.IssueTokens(user, model.SessionClient{})
+	first, _ := tokenService.IssueTokens(user, model.SessionClient{})
+	second, _ := tokenService.IssueTokens(user, model.SessionClient{})
+	principal, _ := tokenService.Authenticate(current.AccessToken)
+
+	// when
+	err := sessions.RevokeOtherSessions(user.UUID, principal.SessionID, principal.String())
+
+	// then
+	assert.NoError(test, err)
+	_, currentErr := tokenService.Authenticate(current.AccessToken)
+	assert.NoError(test, currentErr)
+	_, firstErr := tokenService.Refresh(first.RefreshToken)
+	assert.ErrorIs(test, firstErr, model.ErrInvalidToken)
+	_, secondErr := tokenService.Authenticate(second.AccessToken)
+	assert.ErrorIs(test, secondErr, model.ErrInvalidToken)
+}
+
+func TestShouldRevokeSessionsWhenUserIsSuspended(test *testing.T) {
+	// given
+	_, userService, tokenService, sessions := setupSessionTest(test)
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	_, _ = userService.TransitionUserStatus(user.UUID, model.UserStatusActive, "verified", "admin")
+	tokens, _ := tokenService.IssueTokens(user, model.SessionClient{})
+
+	// when
+	_, err := userService.TransitionUserStatus(user.UUID, model.UserStatusSuspended, "abuse report", "admin")
+
+	// then
+	assert.NoError(test, err)
+	_, accessErr := tokenService.Authenticate(tokens.AccessToken)
+	assert.ErrorIs(test, accessErr, model.ErrInvalidToken)
+	listed, _ := sessions.ListSessions(user.UUID, "")
+	assert.Empty(test, listed)
+}
+
+func TestShouldDeleteSessionsEndedBeforeRetention(test *testing.T) {
+	// given
+	repos, userService, tokenService, sessions := setupSessionTest(test)
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	_, _ = tokenService.IssueTokens(user, model.SessionClient{})
+	_, _ = tokenService.IssueTokens(user, model.SessionClient{})
+	stored := repos.Sessions.(*mockSessionRepository).sessions
+	stored["session-1"].ExpiresAt = time.Now().Add(-8 * 24 * time.Hour)
+	sessions.config.SessionRetention = 7 * 24 * time.Hour
+
+	// when
+	deleted, err := sessions.DeleteEndedSessions()
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, int64(1), deleted)
+	assert.NotContains(test, stored, "session-1")
+	assert.Contains(test, stored, "session-2")
+}
+
+func TestShouldLetUsersManageOnlyTheirOwnSessions(test *testing.T) {
+	// given
+	_, userService, tokenService, sessions := setupSessionTest(test)
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	other, _ := userService.CreateUser(&model.CreateUserRequest{Username: "other", Email: "other@example.com"})
+	_, _ = tokenService.IssueTokens(other, model.SessionClient{})
+	asUser := AuthorizeSessions(sessions, userPrincipal(user.UUID))
+	asAdmin := AuthorizeSessions(sessions, userPrincipal(user.UUID, "admin"))
+
+	// when
+	_, ownErr := asUser.ListSessions(user.UUID, "")
+	_, otherErr := asUser.ListSessions(other.UUID, "")
+	revokeErr := asUser.RevokeOtherSessions(other.UUID, "", "user:"+user.UUID)
+	adminListed, adminErr := asAdmin.ListSessions(other.UUID, "")
+
+	// then
+	assert.NoError(test, ownErr)
+	assert.ErrorIs(test, otherErr, model.ErrForbidden)
+	assert.ErrorIs(test, revokeErr, model.ErrForbidden)
+	assert.NoError(test, adminErr)
+	assert.Len(test, adminListed, 1)
+}
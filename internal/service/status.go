@@ -0,0 +1,107 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/notify"
+	"cruder/internal/repository"
+	"fmt"
+)
+
+// TransitionUserStatus moves a user along the account lifecycle and records
+// the reason and actor. Illegal moves fail with ErrIllegalStatusTransition.
+func (userService *userService) TransitionUserStatus(uuid string, target model.UserStatus, reason, actor string) (*model.User, error) {
+	if err := userService.validateNonEmpty(uuid); err != nil {
+		return nil, err
+	}
+	if err := userService.validateRequiredField("reason", reason); err != nil {
+		return nil, err
+	}
+	if err := userService.validateRequiredField("actor", actor); err != nil {
+		return nil, err
+	}
+
+	var user *model.User
+	err := userService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		existing, err := userService.validateUserExists(repos.Users.GetByUUID(uuid))
+		if err != nil {
+			return err
+		}
+		from := existing.Status
+		if !from.CanTransitionTo(target) {
+			return fmt.Errorf("%w: cannot change status from %s to %s", model.ErrIllegalStatusTransition, from, target)
+		}
+
+		updated := *existing
+		updated.Status = target
+		if err := repos.Users.UpdateStatus(uuid, &updated); err != nil {
+			return err
+		}
+		transition := &model.StatusTransition{UserUUID: uuid, From: from, To: target, Reason: reason, Actor: actor}
+		if err := repos.StatusTransitions.Record(transition); err != nil {
+			return err
+		}
+		if err := repos.Outbox.Append(model.UserUpdated{User: updated, ChangedFields: []string{"status"}}); err != nil {
+			return err
+		}
+		// Access tokens are checked against their session, so this signs
+		// the user out right away.
+		if target == model.UserStatusSuspended || target == model.UserStatusDeactivated {
+			if err := repos.Sessions.RevokeAllForUser(uuid, "account "+string(target)); err != nil {
+				return err
+			}
+		}
+
+		user = &updated
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if target == model.UserStatusSuspended {
+		userService.notify(notify.KindSuspended, user.Email, notify.Data{User: *user, Reason: reason})
+	}
+	return user, nil
+}
+
+func (userService *userService) GetStatusTransitions(uuid string) ([]model.StatusTransition, error) {
+	if err := userService.validateNonEmpty(uuid); err != nil {
+		return nil, err
+	}
+
+	var transitions []model.StatusTransition
+	err := userService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if _, err := userService.validateUserExists(repos.Users.GetByUUID(uuid)); err != nil {
+			return err
+		}
+
+		var err error
+		transitions, err = repos.StatusTransitions.GetByUser(uuid)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transitions, nil
+}
+
+func (userService *userService) GetAuditEvents(uuid string) ([]model.AuditEvent, error) {
+	if err := userService.validateNonEmpty(uuid); err != nil {
+		return nil, err
+	}
+
+	var events []model.AuditEvent
+	err := userService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if _, err := userService.validateUserExists(repos.Users.GetByUUID(uuid)); err != nil {
+			return err
+		}
+
+		var err error
+		events, err = repos.Audit.GetByUser(uuid)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
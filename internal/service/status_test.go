@@ -0,0 +1,123 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockStatusTransitionRepository struct {
+	transitions []model.StatusTransition
+}
+
+func (statusTransitionRepository *mockStatusTransitionRepository) Record(transition *model.StatusTransition) error {
+	transition.ID = int64(len(statusTransitionRepository.transitions) + 1)
+	statusTransitionRepository.transitions = append(statusTransitionRepository.transitions, *transition)
+	return nil
+}
+
+func (statusTransitionRepository *mockStatusTransitionRepository) GetByUser(uuid string) ([]model.StatusTransition, error) {
+	transitions := []model.StatusTransition{}
+	for _, transition := range statusTransitionRepository.transitions {
+		if transition.UserUUID == uuid {
+			transitions = append(transitions, transition)
+		}
+	}
+	return transitions, nil
+}
+
+var _ repository.StatusTransitionRepository = (*mockStatusTransitionRepository)(nil)
+
+func TestShouldWalkUserThroughStatusLifecycle(test *testing.T) {
+	// given
+	_, mockOutbox, userService := setupTestWithOutbox()
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	initialStatus := user.Status
+
+	// when
+	activated, activateErr := userService.TransitionUserStatus(user.UUID, model.UserStatusActive, "verified", "admin")
+	suspended, suspendErr := userService.TransitionUserStatus(user.UUID, model.UserStatusSuspended, "abuse report", "moderator")
+	reactivated, _ := userService.TransitionUserStatus(user.UUID, model.UserStatusActive, "appeal accepted", "moderator")
+	deactivated, _ := userService.TransitionUserStatus(user.UUID, model.UserStatusDeactivated, "account closed", "jdoe")
+	transitions, historyErr := userService.GetStatusTransitions(user.UUID)
+
+	// then
+	assert.Equal(test, model.UserStatusPending, initialStatus)
+	assert.NoError(test, activateErr)
+	assert.NoError(test, suspendErr)
+	assert.NoError(test, historyErr)
+	assert.Equal(test, model.UserStatusActive, activated.Status)
+	assert.Equal(test, model.UserStatusSuspended, suspended.Status)
+	assert.Equal(test, model.UserStatusActive, reactivated.Status)
+	assert.Equal(test, model.UserStatusDeactivated, deactivated.Status)
+	assert.Len(test, transitions, 4)
+	assert.Equal(test, model.StatusTransition{
+		ID: 2, UserUUID: user.UUID, From: model.UserStatusActive, To: model.UserStatusSuspended,
+		Reason: "abuse report", Actor: "moderator",
+	}, transitions[1])
+	lastEvent := mockOutbox.events[len(mockOutbox.events)-1].(model.UserUpdated)
+	assert.Equal(test, []string{"status"}, lastEvent.ChangedFields)
+}
+
+func TestShouldNotifySuspendedUserWithReason(test *testing.T) {
+	// given
+	_, mailer, userService, _ := setupVerificationTest()
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	_, _ = userService.TransitionUserStatus(user.UUID, model.UserStatusActive, "verified", "admin")
+
+	// when
+	_, err := userService.TransitionUserStatus(user.UUID, model.UserStatusSuspended, "abuse report", "moderator")
+
+	// then
+	assert.NoError(test, err)
+	suspensions := mailsWithSubject(mailer, "Your account has been suspended")
+	assert.Len(test, suspensions, 1)
+	assert.Equal(test, "jdoe@example.com", suspensions[0].To)
+	assert.Contains(test, suspensions[0].Text, "Reason: abuse report")
+	assert.Len(test, mailer.Messages(), 3)
+}
+
+func TestShouldRejectIllegalStatusTransition(test *testing.T) {
+	// given
+	mockRepo, userService := setupTest()
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+
+	// when
+	result, err := userService.TransitionUserStatus(user.UUID, model.UserStatusSuspended, "spam", "admin")
+
+	// then
+	assert.ErrorIs(test, err, model.ErrIllegalStatusTransition)
+	assert.EqualError(test, err, "illegal status transition: cannot change status from pending to suspended")
+	assert.Nil(test, result)
+	assert.Equal(test, model.UserStatusPending, mockRepo.users[user.UUID].Status)
+}
+
+func TestShouldRequireReasonForStatusTransition(test *testing.T) {
+	// given
+	_, userService := setupTest()
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+
+	// when
+	_, err := userService.TransitionUserStatus(user.UUID, model.UserStatusActive, " ", "admin")
+
+	// then
+	assert.ErrorIs(test, err, model.ErrEmptyField)
+}
+
+func TestShouldFilterUsersByStatus(test *testing.T) {
+	// given
+	_, userService := setupTest()
+	pending, _ := userService.CreateUser(&model.CreateUserRequest{Username: "pending", Email: "pending@example.com"})
+	active, _ := userService.CreateUser(&model.CreateUserRequest{Username: "active", Email: "active@example.com"})
+	_, _ = userService.TransitionUserStatus(active.UUID, model.UserStatusActive, "verified", "admin")
+
+	// when
+	users, err := userService.GetAllUsers(model.UserFilter{Status: model.UserStatusPending})
+
+	// then
+	assert.NoError(test, err)
+	assert.Len(test, users, 1)
+	assert.Equal(test, pending.UUID, users[0].UUID)
+}
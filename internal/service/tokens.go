@@ -0,0 +1,258 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"cruder/internal/token"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	refreshTokenPrefix = "rt_"
+	APIKeyPrefix       = "ck_"
+	apiKeyDisplayChars = 8
+)
+
+type TokenService interface {
+	// IssueTokens starts a new session for user on the client's device.
+	IssueTokens(user *model.User, client model.SessionClient) (*model.TokenResponse, error)
+	// Refresh rotates refreshToken. Presenting an already rotated token
+	// revokes its whole session.
+	Refresh(refreshToken string) (*model.TokenResponse, error)
+	// Logout revokes the session of refreshToken, of sessionID, or both.
+	Logout(refreshToken string, sessionID string) error
+	Authenticate(accessToken string) (*model.Principal, error)
+	AuthenticateAPIKey(key string) (*model.Principal, error)
+	CreateAPIKey(tenantID, name string, roles []string) (string, *model.APIKey, error)
+	JWKS() token.JWKS
+}
+
+type tokenService struct {
+	repos      *repository.Repository
+	transactor repository.Transactor
+	keys       *token.KeySet
+	issuer     *token.Issuer
+	refreshTTL time.Duration
+	now        func() time.Time
+}
+
+func NewTokenService(repos *repository.Repository, transactor repository.Transactor, config Config) TokenService {
+	keys := config.SigningKeys
+	if keys == nil {
+		keys = token.NewKeySet()
+	}
+	return &tokenService{
+		repos:      repos,
+		transactor: transactor,
+		keys:       keys,
+		issuer:     token.NewIssuer(keys, config.TokenIssuer, config.AccessTokenTTL),
+		refreshTTL: config.RefreshTokenTTL,
+		now:        time.Now,
+	}
+}
+
+func (tokenService *tokenService) IssueTokens(user *model.User, client model.SessionClient) (*model.TokenResponse, error) {
+	var response *model.TokenResponse
+	err := tokenService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		session := &model.Session{
+			UserUUID:  user.UUID,
+			TenantID:  user.TenantID,
+			UserAgent: truncate(client.UserAgent, maxUserAgentLength),
+			IPAddress: client.IPAddress,
+			ExpiresAt: tokenService.now().Add(tokenService.refreshTTL),
+		}
+		if err := repos.Sessions.Create(session); err != nil {
+			return fmt.Errorf("failed to create session: %w", err)
+		}
+
+		var err error
+		response, err = tokenService.issue(repos, session)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	response.User = user
+	return response, nil
+}
+
+func (tokenService *tokenService) Refresh(refreshToken string) (*model.TokenResponse, error) {
+	var response *model.TokenResponse
+	reused := false
+	err := tokenService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		stored, err := repos.Sessions.GetRefreshToken(token.HashOpaque(refreshToken))
+		if err != nil {
+			return err
+		}
+		if stored == nil {
+			return model.ErrInvalidToken
+		}
+
+		session, err := repos.Sessions.Get(stored.SessionID)
+		if err != nil {
+			return err
+		}
+		if session == nil || !session.Active(tokenService.now()) {
+			return model.ErrInvalidToken
+		}
+
+		fresh, err := repos.Sessions.MarkRefreshTokenUsed(stored.ID)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			// The revocation has to commit, so this returns nil and the
+			// error is reported once the transaction is done.
+			reused = true
+			log.Printf("refresh token reuse detected for session %s, revoking it", session.ID)
+			return repos.Sessions.Revoke(session.ID, "refresh token reuse")
+		}
+		if !tokenService.now().Before(stored.ExpiresAt) {
+			return model.ErrInvalidToken
+		}
+
+		user, err := repos.Users.GetByUUID(session.UserUUID)
+		if err != nil {
+			return err
+		}
+		if user == nil || user.Status == model.UserStatusSuspended || user.Status == model.UserStatusDeactivated {
+			return model.ErrInvalidToken
+		}
+
+		if err := repos.Sessions.Extend(session.ID, tokenService.now().Add(tokenService.refreshTTL)); err != nil {
+			return err
+		}
+		response, err = tokenService.issue(repos, session)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, model.ErrTokenReused
+	}
+	return response, nil
+}
+
+func (tokenService *tokenService) Logout(refreshToken string, sessionID string) error {
+	return tokenService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if refreshToken != "" {
+			stored, err := repos.Sessions.GetRefreshToken(token.HashOpaque(refreshToken))
+			if err != nil {
+				return err
+			}
+			if stored == nil {
+				return model.ErrInvalidToken
+			}
+			if sessionID != "" && stored.SessionID != sessionID {
+				if err := repos.Sessions.Revoke(stored.SessionID, "logout"); err != nil {
+					return err
+				}
+			} else {
+				sessionID = stored.SessionID
+			}
+		}
+		if sessionID == "" {
+			return model.ErrInvalidToken
+		}
+		return repos.Sessions.Revoke(sessionID, "logout")
+	})
+}
+
+// Authenticate verifies the signature and expiry of accessToken and that its
+// session has not been revoked, so logout takes effect immediately.
+func (tokenService *tokenService) Authenticate(accessToken string) (*model.Principal, error) {
+	claims, err := tokenService.issuer.Verify(accessToken)
+	if err != nil {
+		if errors.Is(err, token.ErrInvalidToken) || errors.Is(err, token.ErrTokenExpired) {
+			return nil, fmt.Errorf("%w: %v", model.ErrInvalidToken, err)
+		}
+		return nil, err
+	}
+
+	if claims.SessionID != "" {
+		session, err := tokenService.repos.Sessions.Get(claims.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		if session == nil || session.RevokedAt != nil {
+			return nil, model.ErrInvalidToken
+		}
+	}
+	return &model.Principal{
+		Kind:      model.PrincipalUser,
+		ID:        claims.Subject,
+		TenantID:  claims.TenantID,
+		SessionID: claims.SessionID,
+		Roles:     claims.Roles,
+	}, nil
+}
+
+func (tokenService *tokenService) AuthenticateAPIKey(key string) (*model.Principal, error) {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return nil, model.ErrInvalidToken
+	}
+	apiKey, err := tokenService.repos.APIKeys.GetByHash(token.HashOpaque(key))
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == nil {
+		return nil, model.ErrInvalidToken
+	}
+	if err := tokenService.repos.APIKeys.Touch(apiKey.ID); err != nil {
+		log.Printf("failed to record use of API key %s: %v", apiKey.ID, err)
+	}
+	return &model.Principal{Kind: model.PrincipalAPIKey, ID: apiKey.ID, TenantID: apiKey.TenantID, Roles: apiKey.Roles}, nil
+}
+
+// CreateAPIKey returns the raw key, which is not stored and cannot be
+// recovered later.
+func (tokenService *tokenService) CreateAPIKey(tenantID, name string, roles []string) (string, *model.APIKey, error) {
+	raw, hash, err := token.NewOpaque(APIKeyPrefix)
+	if err != nil {
+		return "", nil, err
+	}
+	if roles == nil {
+		roles = []string{}
+	}
+	apiKey := &model.APIKey{Name: name, TenantID: tenantID, Prefix: raw[:len(APIKeyPrefix)+apiKeyDisplayChars], Roles: roles}
+	if err := tokenService.repos.APIKeys.Create(apiKey, hash); err != nil {
+		return "", nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+	return raw, apiKey, nil
+}
+
+func (tokenService *tokenService) JWKS() token.JWKS {
+	return tokenService.keys.JWKS()
+}
+
+func (tokenService *tokenService) issue(repos *repository.Repository, session *model.Session) (*model.TokenResponse, error) {
+	roles, err := repos.Roles.GetByUser(session.UserUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, hash, err := token.NewOpaque(refreshTokenPrefix)
+	if err != nil {
+		return nil, err
+	}
+	stored := &model.RefreshToken{SessionID: session.ID, Hash: hash, ExpiresAt: tokenService.now().Add(tokenService.refreshTTL)}
+	if err := repos.Sessions.AddRefreshToken(stored); err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	accessToken, _, err := tokenService.issuer.Issue(session.UserUUID, session.TenantID, session.ID, roles)
+	if err != nil {
+		return nil, err
+	}
+	return &model.TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(tokenService.issuer.TTL().Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
@@ -0,0 +1,280 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"cruder/internal/token"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSessionRepository struct {
+	sessions      map[string]*model.Session
+	refreshTokens []*model.RefreshToken
+}
+
+func newMockSessionRepository() *mockSessionRepository {
+	return &mockSessionRepository{sessions: make(map[string]*model.Session)}
+}
+
+func (sessionRepository *mockSessionRepository) Create(session *model.Session) error {
+	session.ID = fmt.Sprintf("session-%d", len(sessionRepository.sessions)+1)
+	session.CreatedAt = time.Now().UTC()
+	session.LastUsedAt = session.CreatedAt
+	stored := *session
+	sessionRepository.sessions[session.ID] = &stored
+	return nil
+}
+
+func (sessionRepository *mockSessionRepository) Get(id string) (*model.Session, error) {
+	if session, ok := sessionRepository.sessions[id]; ok {
+		copied := *session
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (sessionRepository *mockSessionRepository) Extend(id string, expiresAt time.Time) error {
+	sessionRepository.sessions[id].ExpiresAt = expiresAt
+	return nil
+}
+
+func (sessionRepository *mockSessionRepository) Revoke(id string, reason string) error {
+	if session, ok := sessionRepository.sessions[id]; ok && session.RevokedAt == nil {
+		now := time.Now().UTC()
+		session.RevokedAt = &now
+	}
+	return nil
+}
+
+func (sessionRepository *mockSessionRepository) RevokeAllForUser(userUUID string, reason string) error {
+	for id, session := range sessionRepository.sessions {
+		if session.UserUUID == userUUID {
+			_ = sessionRepository.Revoke(id, reason)
+		}
+	}
+	return nil
+}
+
+func (sessionRepository *mockSessionRepository) RevokeOthersForUser(userUUID, keepID, reason string) error {
+	for id, session := range sessionRepository.sessions {
+		if session.UserUUID == userUUID && id != keepID {
+			_ = sessionRepository.Revoke(id, reason)
+		}
+	}
+	return nil
+}
+
+func (sessionRepository *mockSessionRepository) ListActiveForUser(userUUID string, now time.Time) ([]model.Session, error) {
+	sessions := []model.Session{}
+	for _, session := range sessionRepository.sessions {
+		if session.UserUUID == userUUID && session.Active(now) {
+			sessions = append(sessions, *session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	return sessions, nil
+}
+
+func (sessionRepository *mockSessionRepository) DeleteEnded(before time.Time) (int64, error) {
+	var deleted int64
+	for id, session := range sessionRepository.sessions {
+		ended := session.ExpiresAt
+		if session.RevokedAt != nil && session.RevokedAt.Before(ended) {
+			ended = *session.RevokedAt
+		}
+		if ended.Before(before) {
+			delete(sessionRepository.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (sessionRepository *mockSessionRepository) AddRefreshToken(refreshToken *model.RefreshToken) error {
+	refreshToken.ID = int64(len(sessionRepository.refreshTokens) + 1)
+	stored := *refreshToken
+	sessionRepository.refreshTokens = append(sessionRepository.refreshTokens, &stored)
+	return nil
+}
+
+func (sessionRepository *mockSessionRepository) GetRefreshToken(hash []byte) (*model.RefreshToken, error) {
+	for _, refreshToken := range sessionRepository.refreshTokens {
+		if string(refreshToken.Hash) == string(hash) {
+			copied := *refreshToken
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (sessionRepository *mockSessionRepository) MarkRefreshTokenUsed(id int64) (bool, error) {
+	refreshToken := sessionRepository.refreshTokens[id-1]
+	if refreshToken.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now().UTC()
+	refreshToken.UsedAt = &now
+	return true, nil
+}
+
+var _ repository.SessionRepository = (*mockSessionRepository)(nil)
+
+type mockAPIKeyRepository struct {
+	keys   map[string]*model.APIKey
+	hashes map[string]string
+}
+
+func newMockAPIKeyRepository() *mockAPIKeyRepository {
+	return &mockAPIKeyRepository{keys: make(map[string]*model.APIKey), hashes: make(map[string]string)}
+}
+
+func (apiKeyRepository *mockAPIKeyRepository) Create(key *model.APIKey, hash []byte) error {
+	key.ID = fmt.Sprintf("key-%d", len(apiKeyRepository.keys)+1)
+	apiKeyRepository.keys[key.ID] = key
+	apiKeyRepository.hashes[string(hash)] = key.ID
+	return nil
+}
+
+func (apiKeyRepository *mockAPIKeyRepository) GetByHash(hash []byte) (*model.APIKey, error) {
+	if id, ok := apiKeyRepository.hashes[string(hash)]; ok && apiKeyRepository.keys[id].RevokedAt == nil {
+		return apiKeyRepository.keys[id], nil
+	}
+	return nil, nil
+}
+
+func (apiKeyRepository *mockAPIKeyRepository) Touch(id string) error {
+	now := time.Now().UTC()
+	apiKeyRepository.keys[id].LastUsedAt = &now
+	return nil
+}
+
+var _ repository.APIKeyRepository = (*mockAPIKeyRepository)(nil)
+
+type mockRoleRepository struct {
+	roles map[string][]string
+}
+
+func (roleRepository *mockRoleRepository) GetByUser(uuid string) ([]string, error) {
+	return append([]string{}, roleRepository.roles[uuid]...), nil
+}
+
+func (roleRepository *mockRoleRepository) Replace(uuid string, roles []string) error {
+	roleRepository.roles[uuid] = append([]string{}, roles...)
+	return nil
+}
+
+var _ repository.RoleRepository = (*mockRoleRepository)(nil)
+
+func setupTokenTest(test *testing.T) (*repository.Repository, UserService, TokenService) {
+	mockRepo := newMockUserRepository()
+	repos := newMockRepos(mockRepo, &mockOutboxRepository{})
+	transactor := &mockTransactor{repos: repos, users: mockRepo}
+
+	key, err := token.GenerateKey()
+	assert.NoError(test, err)
+	config := testPasswordConfig()
+	config.SigningKeys = token.NewKeySet(key)
+	return repos, NewUserService(mockRepo, transactor, config), NewTokenService(repos, transactor, config)
+}
+
+func TestShouldIssueTokensCarryingRoles(test *testing.T) {
+	// given
+	repos, userService, tokenService := setupTokenTest(test)
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	repos.Roles.(*mockRoleRepository).roles[user.UUID] = []string{"admin"}
+	user.TenantID = "tenant-a"
+
+	// when
+	tokens, err := tokenService.IssueTokens(user, model.SessionClient{})
+	principal, authErr := tokenService.Authenticate(tokens.AccessToken)
+
+	// then
+	assert.NoError(test, err)
+	assert.NoError(test, authErr)
+	assert.Equal(test, "Bearer", tokens.TokenType)
+	assert.True(test, strings.HasPrefix(tokens.RefreshToken, "rt_"))
+	assert.Equal(test, model.PrincipalUser, principal.Kind)
+	assert.Equal(test, user.UUID, principal.ID)
+	assert.Equal(test, "tenant-a", principal.TenantID)
+	assert.Equal(test, []string{"admin"}, principal.Roles)
+}
+
+func TestShouldRotateRefreshToken(test *testing.T) {
+	// given
+	_, userService, tokenService := setupTokenTest(test)
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	issued, _ := tokenService.IssueTokens(user, model.SessionClient{})
+
+	// when
+	rotated, err := tokenService.Refresh(issued.RefreshToken)
+
+	// then
+	assert.NoError(test, err)
+	assert.NotEqual(test, issued.RefreshToken, rotated.RefreshToken)
+	_, authErr := tokenService.Authenticate(rotated.AccessToken)
+	assert.NoError(test, authErr)
+}
+
+func TestShouldRevokeTokenFamilyOnRefreshTokenReuse(test *testing.T) {
+	// given
+	_, userService, tokenService := setupTokenTest(test)
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	issued, _ := tokenService.IssueTokens(user, model.SessionClient{})
+	rotated, _ := tokenService.Refresh(issued.RefreshToken)
+
+	// when
+	_, reuseErr := tokenService.Refresh(issued.RefreshToken)
+	_, rotatedErr := tokenService.Refresh(rotated.RefreshToken)
+	_, accessErr := tokenService.Authenticate(rotated.AccessToken)
+
+	// then
+	assert.ErrorIs(test, reuseErr, model.ErrTokenReused)
+	assert.ErrorIs(test, rotatedErr, model.ErrInvalidToken)
+	assert.ErrorIs(test, accessErr, model.ErrInvalidToken)
+}
+
+func TestShouldRevokeSessionOnLogout(test *testing.T) {
+	// given
+	_, userService, tokenService := setupTokenTest(test)
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	issued, _ := tokenService.IssueTokens(user, model.SessionClient{})
+	other, _ := tokenService.IssueTokens(user, model.SessionClient{})
+
+	// when
+	err := tokenService.Logout(issued.RefreshToken, "")
+	_, refreshErr := tokenService.Refresh(issued.RefreshToken)
+	_, accessErr := tokenService.Authenticate(issued.AccessToken)
+	_, otherErr := tokenService.Authenticate(other.AccessToken)
+
+	// then
+	assert.NoError(test, err)
+	assert.ErrorIs(test, refreshErr, model.ErrInvalidToken)
+	assert.ErrorIs(test, accessErr, model.ErrInvalidToken)
+	assert.NoError(test, otherErr)
+}
+
+func TestShouldAuthenticateAPIKey(test *testing.T) {
+	// given
+	_, _, tokenService := setupTokenTest(test)
+	raw, created, createErr := tokenService.CreateAPIKey("tenant-a", "ci", []string{"support"})
+
+	// when
+	principal, err := tokenService.AuthenticateAPIKey(raw)
+	_, unknownErr := tokenService.AuthenticateAPIKey(APIKeyPrefix + "unknown")
+
+	// then
+	assert.NoError(test, createErr)
+	assert.NoError(test, err)
+	assert.Equal(test, model.PrincipalAPIKey, principal.Kind)
+	assert.Equal(test, created.ID, principal.ID)
+	assert.Equal(test, "tenant-a", principal.TenantID)
+	assert.Equal(test, []string{"support"}, principal.Roles)
+	assert.True(test, strings.HasPrefix(raw, created.Prefix))
+	assert.ErrorIs(test, unknownErr, model.ErrInvalidToken)
+}
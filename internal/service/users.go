@@ -1,36 +1,80 @@
 package service
 
 import (
+	"context"
 	"cruder/internal/model"
+	"cruder/internal/notify"
+	"cruder/internal/password"
 	"cruder/internal/repository"
 	"fmt"
+	"log"
 	"net/mail"
 	"strings"
 )
 
 type UserService interface {
-	GetAllUsers() ([]model.User, error)
+	GetAllUsers(filter model.UserFilter) ([]model.User, error)
+	// GetUserPage returns up to limit matching users after skipping offset
+	// of them, and how many match in total.
+	GetUserPage(filter model.UserFilter, offset, limit int) ([]model.User, int, error)
+	ExportUsers(filter model.UserFilter, fn func(user *model.User) error) error
+	SearchUsers(query model.SearchQuery) (*model.SearchPage, error)
 	GetUserByUsername(username string) (*model.User, error)
 	GetUserByID(id int64) (*model.User, error)
 	GetUserByUUID(uuid string) (*model.User, error)
 	CreateUser(request *model.CreateUserRequest) (*model.User, error)
 	UpdateUser(uuid string, request *model.UpdateUserRequest) (*model.User, error)
 	DeleteUser(uuid string) error
+	TransitionUserStatus(uuid string, target model.UserStatus, reason, actor string) (*model.User, error)
+	GetStatusTransitions(uuid string) ([]model.StatusTransition, error)
+	GetAuditEvents(uuid string) ([]model.AuditEvent, error)
+	ExecuteBatch(request *model.BatchRequest) ([]model.BatchItemResult, error)
+	ImportUser(request *model.CreateUserRequest, options model.ImportOptions) (model.ImportAction, *model.User, error)
 }
 
 type userService struct {
 	userRepository repository.UserRepository
+	transactor     repository.Transactor
+	config         Config
+	passwords      *password.Hasher
+	verifications  EmailVerificationService
 }
 
-func NewUserService(userRepository repository.UserRepository) UserService {
-	return &userService{userRepository: userRepository}
+func NewUserService(userRepository repository.UserRepository, transactor repository.Transactor, config Config) UserService {
+	return &userService{
+		userRepository: userRepository,
+		transactor:     transactor,
+		config:         config,
+		passwords:      password.NewHasher(config.PasswordParams, config.PasswordPolicy),
+		verifications:  NewEmailVerificationService(transactor, config),
+	}
+}
+
+func (userService *userService) GetAllUsers(filter model.UserFilter) ([]model.User, error) {
+	return userService.userRepository.GetAll(filter)
 }
 
-func (userService *userService) GetAllUsers() ([]model.User, error) {
-	return userService.userRepository.GetAll()
+func (userService *userService) GetUserPage(filter model.UserFilter, offset, limit int) ([]model.User, int, error) {
+	return userService.userRepository.GetPage(filter, offset, limit)
+}
+
+func (userService *userService) ExportUsers(filter model.UserFilter, fn func(user *model.User) error) error {
+	return userService.userRepository.Stream(filter, fn)
 }
 
 func (userService *userService) validateUserExists(user *model.User, err error) (*model.User, error) {
+	return existingUser(user, err)
+}
+
+// requireUser loads the user with the given UUID or fails with
+// ErrUserNotFound.
+func requireUser(repos *repository.Repository, uuid string) (*model.User, error) {
+	return existingUser(repos.Users.GetByUUID(uuid))
+}
+
+// existingUser turns the result of a user lookup that found nothing into
+// ErrUserNotFound.
+func existingUser(user *model.User, err error) (*model.User, error) {
 	if err != nil {
 		return nil, err
 	}
@@ -66,6 +110,101 @@ func (userService *userService) GetUserByUUID(uuid string) (*model.User, error)
 }
 
 func (userService *userService) CreateUser(request *model.CreateUserRequest) (*model.User, error) {
+	var user *model.User
+	err := userService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		created, err := userService.createUser(repos, request)
+		user = created
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	userService.announce(userCreated(user))
+	return user, nil
+}
+
+func (userService *userService) UpdateUser(uuid string, request *model.UpdateUserRequest) (*model.User, error) {
+	var user *model.User
+	var change *userChange
+	err := userService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		var err error
+		user, change, err = userService.updateUser(repos, uuid, request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if change != nil {
+		userService.announce(*change)
+	}
+	return user, nil
+}
+
+// userChange is a committed change the user is emailed about: a created
+// user, a new address or a deleted user.
+type userChange struct {
+	kind          notify.Kind
+	user          model.User
+	previousEmail string
+}
+
+func userCreated(user *model.User) userChange {
+	return userChange{kind: notify.KindWelcome, user: *user}
+}
+
+func userDeleted(user *model.User) userChange {
+	return userChange{kind: notify.KindDeleted, user: *user}
+}
+
+// announce notifies users of committed changes and sends the verification
+// link every new address needs. The changes are already committed, so
+// failures are only logged; the user can ask for another link.
+func (userService *userService) announce(changes ...userChange) {
+	for _, change := range changes {
+		data := notify.Data{User: change.user, PreviousEmail: change.previousEmail}
+		if change.previousEmail != "" {
+			// Telling the old address too lets the owner notice a takeover.
+			userService.notify(change.kind, change.previousEmail, data)
+		}
+		userService.notify(change.kind, change.user.Email, data)
+		if change.kind == notify.KindDeleted {
+			continue
+		}
+		if err := userService.verifications.SendVerification(&change.user); err != nil {
+			log.Printf("failed to send verification email to user %s: %v", change.user.UUID, err)
+		}
+	}
+}
+
+// notify emails a user after a committed change. Delivery is queued, so
+// failures here are rare and only logged.
+func (userService *userService) notify(kind notify.Kind, to string, data notify.Data) {
+	if userService.config.Notifier == nil {
+		return
+	}
+	if err := userService.config.Notifier.Notify(context.Background(), kind, to, data); err != nil {
+		log.Printf("failed to send %s notification to user %s: %v", kind, data.User.UUID, err)
+	}
+}
+
+func (userService *userService) DeleteUser(uuid string) error {
+	var deleted *model.User
+	err := userService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		var err error
+		deleted, err = userService.deleteUser(repos, uuid)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	userService.announce(userDeleted(deleted))
+	return nil
+}
+
+func (userService *userService) newUser(request *model.CreateUserRequest) (*model.User, error) {
 	if err := userService.validateCreateRequest(request); err != nil {
 		return nil, err
 	}
@@ -75,49 +214,109 @@ func (userService *userService) CreateUser(request *model.CreateUserRequest) (*m
 		Email:    request.Email,
 		FullName: request.FullName,
 	}
+	if request.Password != "" {
+		if err := userService.passwords.Validate(request.Password); err != nil {
+			return nil, err
+		}
+		hash, err := userService.passwords.Hash(request.Password)
+		if err != nil {
+			return nil, err
+		}
+		user.PasswordHash = hash
+	}
+	return user, nil
+}
+
+func (userService *userService) createUser(repos *repository.Repository, request *model.CreateUserRequest) (*model.User, error) {
+	user, err := userService.newUser(request)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := userService.userRepository.Create(user); err != nil {
+	if err := repos.Users.Create(user); err != nil {
+		return nil, err
+	}
+	if err := repos.Outbox.Append(model.UserCreated{User: *user}); err != nil {
 		return nil, err
 	}
 
 	return user, nil
 }
 
-func (userService *userService) UpdateUser(uuid string, request *model.UpdateUserRequest) (*model.User, error) {
+// updateUser also returns the change of email address, if any.
+func (userService *userService) updateUser(repos *repository.Repository, uuid string, request *model.UpdateUserRequest) (*model.User, *userChange, error) {
 	if err := userService.validateNonEmpty(uuid); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	existing, err := userService.validateUserExists(userService.userRepository.GetByUUID(uuid))
+	existing, err := userService.validateUserExists(repos.Users.GetByUUID(uuid))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if err := userService.validateUpdateRequest(request); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	userService.updateFieldIfProvided(&existing.Username, request.Username)
-	userService.updateFieldIfProvided(&existing.Email, request.Email)
-	userService.updateFieldIfProvided(&existing.FullName, request.FullName)
+	updated := *existing
+	userService.updateFieldIfProvided(&updated.Username, request.Username)
+	userService.updateFieldIfProvided(&updated.Email, request.Email)
+	userService.updateFieldIfProvided(&updated.FullName, request.FullName)
 
-	if err := userService.userRepository.Update(uuid, existing); err != nil {
-		return nil, err
+	changedFields := changedUserFields(existing, &updated)
+	if len(changedFields) == 0 {
+		return existing, nil, nil
 	}
+	previousEmail := existing.Email
 
-	return existing, nil
+	if err := repos.Users.Update(uuid, &updated); err != nil {
+		return nil, nil, err
+	}
+	if err := repos.Outbox.Append(model.UserUpdated{User: updated, ChangedFields: changedFields}); err != nil {
+		return nil, nil, err
+	}
+
+	if updated.Email == previousEmail {
+		return &updated, nil, nil
+	}
+	return &updated, &userChange{kind: notify.KindEmailChanged, user: updated, previousEmail: previousEmail}, nil
 }
 
-func (userService *userService) DeleteUser(uuid string) error {
+// deleteUser returns the user as it was before deletion.
+func (userService *userService) deleteUser(repos *repository.Repository, uuid string) (*model.User, error) {
 	if err := userService.validateNonEmpty(uuid); err != nil {
-		return err
+		return nil, err
 	}
 
-	if _, err := userService.validateUserExists(userService.userRepository.GetByUUID(uuid)); err != nil {
-		return err
+	existing, err := userService.validateUserExists(repos.Users.GetByUUID(uuid))
+	if err != nil {
+		return nil, err
 	}
 
-	return userService.userRepository.Delete(uuid)
+	if err := repos.Groups.RemoveUser(uuid); err != nil {
+		return nil, err
+	}
+	if err := repos.Users.Delete(uuid); err != nil {
+		return nil, err
+	}
+	if err := repos.Outbox.Append(model.UserDeleted{UUID: uuid, TenantID: existing.TenantID}); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+func changedUserFields(before, after *model.User) []string {
+	var changed []string
+	if before.Username != after.Username {
+		changed = append(changed, "username")
+	}
+	if before.Email != after.Email {
+		changed = append(changed, "email")
+	}
+	if before.FullName != after.FullName {
+		changed = append(changed, "full_name")
+	}
+	return changed
 }
 
 func (userService *userService) validateRequiredField(fieldName, value string) error {
@@ -1,39 +1,131 @@
 package service
 
 import (
+	"cruder/internal/mail"
 	"cruder/internal/model"
 	"cruder/internal/repository"
+	"cruder/internal/search"
 	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 type mockUserRepository struct {
-	users      map[string]*model.User
-	nextID     int
-	shouldFail bool
+	users          map[string]*model.User
+	passwordHashes map[string]string
+	nextID         int
+	shouldFail     bool
+	bulkInserts    int
+	clock          time.Time
 }
 
 func newMockUserRepository() *mockUserRepository {
 	return &mockUserRepository{
-		users:  make(map[string]*model.User),
-		nextID: 1,
+		users:          make(map[string]*model.User),
+		passwordHashes: make(map[string]string),
+		nextID:         1,
+		clock:          time.Date(2025, 11, 13, 9, 0, 0, 0, time.UTC),
 	}
 }
 
-func (userRepository *mockUserRepository) GetAll() ([]model.User, error) {
-	if userRepository.shouldFail {
-		return nil, assert.AnError
+// now advances the mock clock by a second per write, standing in for the
+// column defaults and the updated_at trigger.
+func (userRepository *mockUserRepository) now() time.Time {
+	userRepository.clock = userRepository.clock.Add(time.Second)
+	return userRepository.clock
+}
+
+func (userRepository *mockUserRepository) matches(user *model.User, filter model.UserFilter) bool {
+	return (filter.Username == "" || user.Username == filter.Username) &&
+		(filter.Email == "" || user.Email == filter.Email) &&
+		(filter.FullName == "" || strings.Contains(strings.ToLower(user.FullName), strings.ToLower(filter.FullName))) &&
+		(filter.Status == "" || user.Status == filter.Status) &&
+		(filter.CreatedAfter.IsZero() || user.CreatedAt.After(filter.CreatedAfter)) &&
+		(filter.CreatedBefore.IsZero() || user.CreatedAt.Before(filter.CreatedBefore)) &&
+		(filter.UpdatedAfter.IsZero() || user.UpdatedAt.After(filter.UpdatedAfter)) &&
+		(filter.UpdatedBefore.IsZero() || user.UpdatedAt.Before(filter.UpdatedBefore))
+}
+
+func (userRepository *mockUserRepository) less(left, right *model.User, sort model.UserSort) bool {
+	var comparison int
+	switch sort.Field {
+	case "username":
+		comparison = strings.Compare(left.Username, right.Username)
+	case "created_at":
+		comparison = left.CreatedAt.Compare(right.CreatedAt)
+	case "updated_at":
+		comparison = left.UpdatedAt.Compare(right.UpdatedAt)
+	}
+	if comparison == 0 {
+		comparison = left.ID - right.ID
 	}
+	if sort.Descending {
+		return comparison > 0
+	}
+	return comparison < 0
+}
 
+func (userRepository *mockUserRepository) GetAll(filter model.UserFilter) ([]model.User, error) {
 	users := make([]model.User, 0, len(userRepository.users))
-	for _, user := range userRepository.users {
+	err := userRepository.Stream(filter, func(user *model.User) error {
 		users = append(users, *user)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	return users, nil
 }
 
+func (userRepository *mockUserRepository) GetPage(filter model.UserFilter, offset, limit int) ([]model.User, int, error) {
+	users, err := userRepository.GetAll(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	if offset >= len(users) {
+		return nil, len(users), nil
+	}
+	return users[offset:min(offset+limit, len(users))], len(users), nil
+}
+
+func (userRepository *mockUserRepository) Stream(filter model.UserFilter, fn func(user *model.User) error) error {
+	if userRepository.shouldFail {
+		return assert.AnError
+	}
+
+	users := make([]*model.User, 0, len(userRepository.users))
+	for _, user := range userRepository.users {
+		if userRepository.matches(user, filter) {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return userRepository.less(users[i], users[j], filter.Sort) })
+
+	for _, user := range users {
+		copied := *user
+		if err := fn(&copied); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (userRepository *mockUserRepository) Search(query model.SearchQuery) ([]model.SearchResult, int, error) {
+	if userRepository.shouldFail {
+		return nil, 0, assert.AnError
+	}
+
+	return search.Match(query, func(fn func(user *model.User) error) error {
+		return userRepository.Stream(model.UserFilter{}, fn)
+	})
+}
+
 func (userRepository *mockUserRepository) GetByUsername(username string) (*model.User, error) {
 	if userRepository.shouldFail {
 		return nil, assert.AnError
@@ -47,6 +139,19 @@ func (userRepository *mockUserRepository) GetByUsername(username string) (*model
 	return nil, nil
 }
 
+func (userRepository *mockUserRepository) GetByEmail(email string) (*model.User, error) {
+	if userRepository.shouldFail {
+		return nil, assert.AnError
+	}
+
+	for _, user := range userRepository.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
 func (userRepository *mockUserRepository) GetByID(id int64) (*model.User, error) {
 	if userRepository.shouldFail {
 		return nil, assert.AnError
@@ -72,15 +177,57 @@ func (userRepository *mockUserRepository) GetByUUID(uuid string) (*model.User, e
 	return user, nil
 }
 
+func (userRepository *mockUserRepository) usernameTaken(username string) bool {
+	for _, user := range userRepository.users {
+		if user.Username == username {
+			return true
+		}
+	}
+	return false
+}
+
 func (userRepository *mockUserRepository) Create(user *model.User) error {
 	if userRepository.shouldFail {
 		return assert.AnError
 	}
+	if userRepository.usernameTaken(user.Username) {
+		return model.ErrUserAlreadyExists
+	}
 
 	user.ID = userRepository.nextID
 	user.UUID = generateMockUUID(userRepository.nextID)
+	if user.Status == "" {
+		user.Status = model.UserStatusPending
+	}
+	user.CreatedAt = userRepository.now()
+	user.UpdatedAt = user.CreatedAt
 	userRepository.nextID++
 	userRepository.users[user.UUID] = user
+	if user.PasswordHash != "" {
+		userRepository.passwordHashes[user.UUID] = user.PasswordHash
+	}
+	return nil
+}
+
+func (userRepository *mockUserRepository) CreateMany(users []*model.User) error {
+	if userRepository.shouldFail {
+		return assert.AnError
+	}
+
+	seen := make(map[string]bool)
+	for _, user := range users {
+		if seen[user.Username] || userRepository.usernameTaken(user.Username) {
+			return model.ErrUserAlreadyExists
+		}
+		seen[user.Username] = true
+	}
+
+	userRepository.bulkInserts++
+	for _, user := range users {
+		if err := userRepository.Create(user); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -94,12 +241,66 @@ func (userRepository *mockUserRepository) Update(uuid string, user *model.User)
 		return nil
 	}
 
+	if existing.Username != user.Username || existing.Email != user.Email || existing.FullName != user.FullName {
+		existing.UpdatedAt = userRepository.now()
+	}
+	if existing.Email != user.Email {
+		existing.EmailVerifiedAt = nil
+	}
 	existing.Username = user.Username
 	existing.Email = user.Email
 	existing.FullName = user.FullName
+	user.UpdatedAt = existing.UpdatedAt
+	user.EmailVerifiedAt = existing.EmailVerifiedAt
 	return nil
 }
 
+func (userRepository *mockUserRepository) UpdateStatus(uuid string, user *model.User) error {
+	if userRepository.shouldFail {
+		return assert.AnError
+	}
+
+	existing, exists := userRepository.users[uuid]
+	if !exists {
+		return nil
+	}
+
+	existing.Status = user.Status
+	existing.UpdatedAt = userRepository.now()
+	user.UpdatedAt = existing.UpdatedAt
+	return nil
+}
+
+func (userRepository *mockUserRepository) GetPasswordHash(uuid string) (string, error) {
+	if userRepository.shouldFail {
+		return "", assert.AnError
+	}
+	return userRepository.passwordHashes[uuid], nil
+}
+
+func (userRepository *mockUserRepository) UpdatePasswordHash(uuid string, hash string) error {
+	if userRepository.shouldFail {
+		return assert.AnError
+	}
+	if _, exists := userRepository.users[uuid]; exists {
+		userRepository.passwordHashes[uuid] = hash
+	}
+	return nil
+}
+
+func (userRepository *mockUserRepository) MarkEmailVerified(uuid string, email string) (bool, error) {
+	if userRepository.shouldFail {
+		return false, assert.AnError
+	}
+	existing, exists := userRepository.users[uuid]
+	if !exists || existing.Email != email {
+		return false, nil
+	}
+	verifiedAt := userRepository.now()
+	existing.EmailVerifiedAt = &verifiedAt
+	return true, nil
+}
+
 func (userRepository *mockUserRepository) Delete(uuid string) error {
 	if userRepository.shouldFail {
 		return assert.AnError
@@ -109,16 +310,109 @@ func (userRepository *mockUserRepository) Delete(uuid string) error {
 	return nil
 }
 
+type mockOutboxRepository struct {
+	events     []model.DomainEvent
+	shouldFail bool
+}
+
+func (outboxRepository *mockOutboxRepository) Append(event model.DomainEvent) error {
+	if outboxRepository.shouldFail {
+		return assert.AnError
+	}
+
+	outboxRepository.events = append(outboxRepository.events, event)
+	return nil
+}
+
+func (outboxRepository *mockOutboxRepository) ClaimPending(limit int) ([]model.OutboxMessage, error) {
+	return nil, nil
+}
+
+func (outboxRepository *mockOutboxRepository) MarkPublished(id int64) error {
+	return nil
+}
+
+func (outboxRepository *mockOutboxRepository) MarkRetry(id int64, attempts int, availableAt time.Time, lastError string) error {
+	return nil
+}
+
+func (outboxRepository *mockOutboxRepository) MarkDead(id int64, attempts int, lastError string) error {
+	return nil
+}
+
+type mockTransactor struct {
+	repos *repository.Repository
+	users *mockUserRepository
+	mutex sync.Mutex
+}
+
+// WithinTransaction runs one transaction at a time, like row locks on the
+// same rows would, and restores the mock users when fn fails, mimicking a
+// rollback.
+func (transactor *mockTransactor) WithinTransaction(fn func(repos *repository.Repository) error) error {
+	transactor.mutex.Lock()
+	defer transactor.mutex.Unlock()
+
+	snapshot := make(map[string]model.User, len(transactor.users.users))
+	for uuid, user := range transactor.users.users {
+		snapshot[uuid] = *user
+	}
+
+	err := fn(transactor.repos)
+	if err != nil {
+		// Only changed users are written back, so reads outside
+		// transactions do not race with rollbacks that changed nothing.
+		for uuid := range transactor.users.users {
+			if _, ok := snapshot[uuid]; !ok {
+				delete(transactor.users.users, uuid)
+			}
+		}
+		for uuid, user := range snapshot {
+			if current, ok := transactor.users.users[uuid]; !ok || !reflect.DeepEqual(*current, user) {
+				restored := user
+				transactor.users.users[uuid] = &restored
+			}
+		}
+	}
+	return err
+}
+
 func generateMockUUID(id int) string {
 	return fmt.Sprintf("123e4567-e89b-12d3-a456-42661417%04d", id)
 }
 
 func setupTest() (*mockUserRepository, UserService) {
-	mockRepo := newMockUserRepository()
-	userService := NewUserService(mockRepo)
+	mockRepo, _, userService := setupTestWithOutbox()
 	return mockRepo, userService
 }
 
+func newMockRepos(mockRepo *mockUserRepository, mockOutbox *mockOutboxRepository) *repository.Repository {
+	return &repository.Repository{
+		Users:             mockRepo,
+		Outbox:            mockOutbox,
+		StatusTransitions: &mockStatusTransitionRepository{},
+		Sessions:          newMockSessionRepository(),
+		APIKeys:           newMockAPIKeyRepository(),
+		Roles:             &mockRoleRepository{roles: make(map[string][]string)},
+		Groups:            newMockGroupRepository(mockRepo),
+
+		EmailVerifications: newMockEmailVerificationRepository(),
+		PasswordResets:     newMockPasswordResetRepository(),
+		MFA:                newMockMFARepository(),
+		LoginThrottles:     newMockLoginThrottleRepository(),
+		Audit:              &mockAuditRepository{},
+		OIDC:               newMockOIDCRepository(),
+	}
+}
+
+func setupTestWithOutbox() (*mockUserRepository, *mockOutboxRepository, UserService) {
+	mockRepo := newMockUserRepository()
+	mockOutbox := &mockOutboxRepository{}
+	transactor := &mockTransactor{repos: newMockRepos(mockRepo, mockOutbox), users: mockRepo}
+	userService := NewUserService(mockRepo, transactor, DefaultConfig())
+	return mockRepo, mockOutbox, userService
+}
+
 func TestShouldGetAllUsers(t *testing.T) {
 	// given
 	repo, svc := setupTest()
@@ -128,13 +422,45 @@ func TestShouldGetAllUsers(t *testing.T) {
 	repo.users[user2.UUID] = user2
 
 	// when
-	result, err := svc.GetAllUsers()
+	result, err := svc.GetAllUsers(model.UserFilter{})
 
 	// then
 	assert.NoError(t, err)
 	assert.Len(t, result, 2)
 }
 
+func TestShouldFilterAndSortUsersByTimestamps(test *testing.T) {
+	// given
+	_, userService := setupTest()
+	first, _ := userService.CreateUser(&model.CreateUserRequest{Username: "first", Email: "first@example.com"})
+	second, _ := userService.CreateUser(&model.CreateUserRequest{Username: "second", Email: "second@example.com"})
+	third, _ := userService.CreateUser(&model.CreateUserRequest{Username: "third", Email: "third@example.com"})
+	updated, _ := userService.UpdateUser(first.UUID, &model.UpdateUserRequest{FullName: "First User"})
+
+	// when
+	createdAfterFirst, err := userService.GetAllUsers(model.UserFilter{CreatedAfter: first.CreatedAt})
+	byUpdatedDesc, _ := userService.GetAllUsers(model.UserFilter{Sort: model.UserSort{Field: "updated_at", Descending: true}})
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, []string{"second", "third"}, []string{createdAfterFirst[0].Username, createdAfterFirst[1].Username})
+	assert.Equal(test, []int{first.ID, third.ID, second.ID}, []int{byUpdatedDesc[0].ID, byUpdatedDesc[1].ID, byUpdatedDesc[2].ID})
+	assert.Equal(test, first.CreatedAt, updated.CreatedAt)
+	assert.True(test, updated.UpdatedAt.After(third.CreatedAt))
+	assert.Equal(test, time.UTC, updated.UpdatedAt.Location())
+}
+
+func TestShouldRejectUnknownSortField(test *testing.T) {
+	// when
+	_, err := model.ParseUserSort("-password")
+	sort, validErr := model.ParseUserSort("-created_at")
+
+	// then
+	assert.ErrorIs(test, err, model.ErrInvalidFilter)
+	assert.NoError(test, validErr)
+	assert.Equal(test, model.UserSort{Field: "created_at", Descending: true}, sort)
+}
+
 func TestShouldGetUserByUsername(test *testing.T) {
 	// given
 	mockRepo, userService := setupTest()
@@ -463,4 +789,158 @@ func TestShouldReturnErrorWhenDeleteUserWithEmptyUUID(test *testing.T) {
 	assert.ErrorIs(test, err, model.ErrEmptyField)
 }
 
+func TestShouldRecordUserCreatedEvent(test *testing.T) {
+	// given
+	_, mockOutbox, userService := setupTestWithOutbox()
+	request := &model.CreateUserRequest{
+		Username: "newuser",
+		Email:    "newuser@example.com",
+	}
+
+	// when
+	result, err := userService.CreateUser(request)
+
+	// then
+	assert.NoError(test, err)
+	assert.Len(test, mockOutbox.events, 1)
+	assert.Equal(test, model.UserCreated{User: *result}, mockOutbox.events[0])
+}
+
+func TestShouldRecordChangedFieldsInUserUpdatedEvent(test *testing.T) {
+	// given
+	mockRepo, mockOutbox, userService := setupTestWithOutbox()
+	user := &model.User{
+		ID:       1,
+		UUID:     "123e4567-e89b-12d3-a456-426614174000",
+		Username: "test_user",
+		Email:    "test@example.com",
+		FullName: "Test User",
+	}
+	mockRepo.users[user.UUID] = user
+
+	request := &model.UpdateUserRequest{
+		Username: "test_user",
+		Email:    "changed@example.com",
+	}
+
+	// when
+	_, err := userService.UpdateUser(user.UUID, request)
+
+	// then
+	assert.NoError(test, err)
+	assert.Len(test, mockOutbox.events, 1)
+	event, ok := mockOutbox.events[0].(model.UserUpdated)
+	assert.True(test, ok)
+	assert.Equal(test, []string{"email"}, event.ChangedFields)
+}
+
+func TestShouldNotRecordEventWhenUpdateChangesNothing(test *testing.T) {
+	// given
+	mockRepo, mockOutbox, userService := setupTestWithOutbox()
+	user := &model.User{
+		ID:       1,
+		UUID:     "123e4567-e89b-12d3-a456-426614174000",
+		Username: "test_user",
+		Email:    "test@example.com",
+	}
+	mockRepo.users[user.UUID] = user
+
+	// when
+	result, err := userService.UpdateUser(user.UUID, &model.UpdateUserRequest{Username: "test_user"})
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, "test_user", result.Username)
+	assert.Empty(test, mockOutbox.events)
+}
+
+func TestShouldRecordUserDeletedEvent(test *testing.T) {
+	// given
+	mockRepo, mockOutbox, userService := setupTestWithOutbox()
+	user := &model.User{ID: 1, UUID: generateMockUUID(1), Username: "test_user", Email: "test@example.com"}
+	mockRepo.users[user.UUID] = user
+
+	// when
+	err := userService.DeleteUser(user.UUID)
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, []model.DomainEvent{model.UserDeleted{UUID: user.UUID}}, mockOutbox.events)
+}
+
+func TestShouldFailCreateWhenOutboxAppendFails(test *testing.T) {
+	// given
+	_, mockOutbox, userService := setupTestWithOutbox()
+	mockOutbox.shouldFail = true
+
+	// when
+	result, err := userService.CreateUser(&model.CreateUserRequest{Username: "newuser", Email: "newuser@example.com"})
+
+	// then
+	assert.ErrorIs(test, err, assert.AnError)
+	assert.Nil(test, result)
+}
+
+// mailsWithSubject returns the sent emails with the given subject.
+func mailsWithSubject(mailer *mail.MemorySender, subject string) []mail.Message {
+	var messages []mail.Message
+	for _, message := range mailer.Messages() {
+		if message.Subject == subject {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+func TestShouldWelcomeCreatedUser(test *testing.T) {
+	// given
+	_, mailer, userService, _ := setupVerificationTest()
+
+	// when
+	_, err := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+
+	// then
+	assert.NoError(test, err)
+	welcomes := mailsWithSubject(mailer, "Welcome, jdoe")
+	assert.Len(test, welcomes, 1)
+	assert.Equal(test, "jdoe@example.com", welcomes[0].To)
+	assert.Contains(test, welcomes[0].HTML, "<strong>jdoe</strong>")
+}
+
+func TestShouldNotifyOldAndNewAddressOnEmailChange(test *testing.T) {
+	// given
+	_, mailer, userService, _ := setupVerificationTest()
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+
+	// when
+	_, renameErr := userService.UpdateUser(user.UUID, &model.UpdateUserRequest{FullName: "John Doe"})
+	_, err := userService.UpdateUser(user.UUID, &model.UpdateUserRequest{Email: "john@example.com"})
+
+	// then
+	assert.NoError(test, renameErr)
+	assert.NoError(test, err)
+	changes := mailsWithSubject(mailer, "Your email address was changed")
+	assert.Len(test, changes, 2)
+	for _, message := range changes {
+		assert.Contains(test, message.Text, "from jdoe@example.com to john@example.com")
+	}
+	assert.ElementsMatch(test, []string{"jdoe@example.com", "john@example.com"}, []string{changes[0].To, changes[1].To})
+}
+
+func TestShouldNotifyDeletedUser(test *testing.T) {
+	// given
+	_, mailer, userService, _ := setupVerificationTest()
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+
+	// when
+	err := userService.DeleteUser(user.UUID)
+
+	// then
+	assert.NoError(test, err)
+	deletions := mailsWithSubject(mailer, "Your account has been deleted")
+	assert.Len(test, deletions, 1)
+	assert.Equal(test, "jdoe@example.com", deletions[0].To)
+}
+
 var _ repository.UserRepository = (*mockUserRepository)(nil)
+var _ repository.OutboxRepository = (*mockOutboxRepository)(nil)
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"cruder/internal/model"
+	"cruder/internal/notify"
+	"cruder/internal/repository"
+	"cruder/internal/token"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const verificationTokenPrefix = "ev_"
+
+type EmailVerificationService interface {
+	// SendVerification mails the user a link to verify their current email.
+	// Links sent earlier stop working.
+	SendVerification(user *model.User) error
+	// ResendVerification sends a new link on request, at most once per
+	// VerificationResendInterval and VerificationResendLimit times per hour.
+	ResendVerification(uuid string) error
+	// VerifyEmail consumes a token and marks the email it was sent to as
+	// verified, provided it is still the user's email.
+	VerifyEmail(rawToken string) (*model.User, error)
+}
+
+type emailVerificationService struct {
+	transactor repository.Transactor
+	config     Config
+	now        func() time.Time
+}
+
+func NewEmailVerificationService(transactor repository.Transactor, config Config) EmailVerificationService {
+	return &emailVerificationService{transactor: transactor, config: config, now: time.Now}
+}
+
+func (emailVerificationService *emailVerificationService) SendVerification(user *model.User) error {
+	if emailVerificationService.config.Notifier == nil {
+		return nil
+	}
+
+	var rawToken string
+	err := emailVerificationService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		var err error
+		rawToken, err = emailVerificationService.issue(repos, user)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return emailVerificationService.send(user, rawToken)
+}
+
+func (emailVerificationService *emailVerificationService) ResendVerification(uuid string) error {
+	var user *model.User
+	var rawToken string
+	err := emailVerificationService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		var err error
+		user, err = requireUser(repos, uuid)
+		if err != nil {
+			return err
+		}
+		if user.EmailVerifiedAt != nil {
+			return model.ErrEmailAlreadyVerified
+		}
+		if err := emailVerificationService.checkResendLimit(repos, uuid); err != nil {
+			return err
+		}
+		if emailVerificationService.config.Notifier == nil {
+			return nil
+		}
+		rawToken, err = emailVerificationService.issue(repos, user)
+		return err
+	})
+	if err != nil || rawToken == "" {
+		return err
+	}
+	return emailVerificationService.send(user, rawToken)
+}
+
+func (emailVerificationService *emailVerificationService) checkResendLimit(repos *repository.Repository, uuid string) error {
+	now := emailVerificationService.now()
+	recent, err := repos.EmailVerifications.CountSince(uuid, now.Add(-emailVerificationService.config.VerificationResendInterval))
+	if err != nil {
+		return err
+	}
+	hourly, err := repos.EmailVerifications.CountSince(uuid, now.Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	if recent > 0 || hourly >= emailVerificationService.config.VerificationResendLimit {
+		return model.ErrRateLimited
+	}
+	return nil
+}
+
+func (emailVerificationService *emailVerificationService) VerifyEmail(rawToken string) (*model.User, error) {
+	hash := token.HashOpaque(rawToken)
+	var user *model.User
+	err := emailVerificationService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		stored, err := repos.EmailVerifications.GetByHash(hash)
+		if err != nil {
+			return err
+		}
+		if stored == nil || !stored.Usable(emailVerificationService.now()) {
+			return model.ErrInvalidToken
+		}
+		used, err := repos.EmailVerifications.MarkUsed(stored.ID)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return model.ErrInvalidToken
+		}
+		// A token sent to an address the user has since changed proves
+		// nothing about their current email.
+		verified, err := repos.Users.MarkEmailVerified(stored.UserUUID, stored.Email)
+		if err != nil {
+			return err
+		}
+		if !verified {
+			return model.ErrInvalidToken
+		}
+
+		user, err = repos.Users.GetByUUID(stored.UserUUID)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return model.ErrInvalidToken
+		}
+		return repos.Outbox.Append(model.UserUpdated{User: *user, ChangedFields: []string{"email_verified_at"}})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (emailVerificationService *emailVerificationService) issue(repos *repository.Repository, user *model.User) (string, error) {
+	if err := repos.EmailVerifications.InvalidateForUser(user.UUID); err != nil {
+		return "", err
+	}
+	rawToken, hash, err := token.NewOpaque(verificationTokenPrefix)
+	if err != nil {
+		return "", err
+	}
+	verification := &model.EmailVerificationToken{
+		UserUUID:  user.UUID,
+		Email:     user.Email,
+		ExpiresAt: emailVerificationService.now().Add(emailVerificationService.config.VerificationTokenTTL),
+	}
+	if err := repos.EmailVerifications.Create(verification, hash); err != nil {
+		return "", err
+	}
+	return rawToken, nil
+}
+
+func (emailVerificationService *emailVerificationService) send(user *model.User, rawToken string) error {
+	link, err := url.Parse(emailVerificationService.config.VerificationURL)
+	if err != nil {
+		return fmt.Errorf("invalid verification url: %w", err)
+	}
+	query := link.Query()
+	query.Set("token", rawToken)
+	link.RawQuery = query.Encode()
+
+	return emailVerificationService.config.Notifier.Notify(context.Background(), notify.KindVerifyEmail, user.Email, notify.Data{
+		User:      *user,
+		Link:      link.String(),
+		ExpiresIn: emailVerificationService.config.VerificationTokenTTL,
+	})
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"cruder/internal/mail"
+	"cruder/internal/model"
+	"cruder/internal/notify"
+	"cruder/internal/repository"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockEmailVerificationRepository struct {
+	tokens map[string]*model.EmailVerificationToken
+	nextID int64
+	now    func() time.Time
+}
+
+func newMockEmailVerificationRepository() *mockEmailVerificationRepository {
+	return &mockEmailVerificationRepository{tokens: make(map[string]*model.EmailVerificationToken), now: time.Now}
+}
+
+func (verificationRepository *mockEmailVerificationRepository) Create(token *model.EmailVerificationToken, hash []byte) error {
+	verificationRepository.nextID++
+	token.ID = verificationRepository.nextID
+	token.CreatedAt = verificationRepository.now()
+	stored := *token
+	verificationRepository.tokens[string(hash)] = &stored
+	return nil
+}
+
+func (verificationRepository *mockEmailVerificationRepository) GetByHash(hash []byte) (*model.EmailVerificationToken, error) {
+	token, ok := verificationRepository.tokens[string(hash)]
+	if !ok {
+		return nil, nil
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (verificationRepository *mockEmailVerificationRepository) MarkUsed(id int64) (bool, error) {
+	for _, token := range verificationRepository.tokens {
+		if token.ID == id && token.UsedAt == nil {
+			usedAt := verificationRepository.now()
+			token.UsedAt = &usedAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (verificationRepository *mockEmailVerificationRepository) InvalidateForUser(userUUID string) error {
+	for _, token := range verificationRepository.tokens {
+		if token.UserUUID == userUUID && token.UsedAt == nil {
+			usedAt := verificationRepository.now()
+			token.UsedAt = &usedAt
+		}
+	}
+	return nil
+}
+
+func (verificationRepository *mockEmailVerificationRepository) CountSince(userUUID string, since time.Time) (int, error) {
+	count := 0
+	for _, token := range verificationRepository.tokens {
+		if token.UserUUID == userUUID && token.CreatedAt.After(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+var _ repository.EmailVerificationRepository = (*mockEmailVerificationRepository)(nil)
+
+func setupVerificationTest() (*repository.Repository, *mail.MemorySender, UserService, *emailVerificationService) {
+	mockRepo := newMockUserRepository()
+	repos := newMockRepos(mockRepo, &mockOutboxRepository{})
+	transactor := &mockTransactor{repos: repos, users: mockRepo}
+	mailer := mail.NewMemorySender()
+	notifier, _ := notify.NewNotifier(mailer, notify.DefaultLocale)
+	config := DefaultConfig()
+	config.Notifier = notifier
+	verifications := NewEmailVerificationService(transactor, config).(*emailVerificationService)
+	return repos, mailer, NewUserService(mockRepo, transactor, config), verifications
+}
+
+// verificationMails returns the verification emails among those sent.
+func verificationMails(mailer *mail.MemorySender) []mail.Message {
+	var messages []mail.Message
+	for _, message := range mailer.Messages() {
+		if strings.Contains(message.Text, "?token=") {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// sentToken extracts the token from the link in a verification email.
+func sentToken(test *testing.T, message mail.Message) string {
+	start := strings.Index(message.Text, "http")
+	require.GreaterOrEqual(test, start, 0)
+	link, err := url.Parse(strings.Fields(message.Text[start:])[0])
+	require.NoError(test, err)
+	return link.Query().Get("token")
+}
+
+func TestShouldSendVerificationEmailOnCreate(test *testing.T) {
+	// given
+	_, mailer, userService, _ := setupVerificationTest()
+
+	// when
+	user, err := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+
+	// then
+	assert.NoError(test, err)
+	assert.Nil(test, user.EmailVerifiedAt)
+	messages := verificationMails(mailer)
+	require.Len(test, messages, 1)
+	assert.Equal(test, "jdoe@example.com", messages[0].To)
+	assert.True(test, strings.HasPrefix(sentToken(test, messages[0]), verificationTokenPrefix))
+}
+
+func TestShouldVerifyEmailOnlyOnce(test *testing.T) {
+	// given
+	repos, mailer, userService, verifications := setupVerificationTest()
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	rawToken := sentToken(test, verificationMails(mailer)[0])
+
+	// when
+	verified, err := verifications.VerifyEmail(rawToken)
+	_, againErr := verifications.VerifyEmail(rawToken)
+
+	// then
+	assert.NoError(test, err)
+	assert.NotNil(test, verified.EmailVerifiedAt)
+	assert.ErrorIs(test, againErr, model.ErrInvalidToken)
+	stored, _ := repos.Users.GetByUUID(user.UUID)
+	assert.NotNil(test, stored.EmailVerifiedAt)
+}
+
+func TestShouldRejectExpiredAndUnknownVerificationTokens(test *testing.T) {
+	// given
+	_, mailer, userService, verifications := setupVerificationTest()
+	_, _ = userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	rawToken := sentToken(test, verificationMails(mailer)[0])
+	verifications.now = func() time.Time { return time.Now().Add(verifications.config.VerificationTokenTTL + time.Minute) }
+
+	// when
+	_, expiredErr := verifications.VerifyEmail(rawToken)
+	_, unknownErr := verifications.VerifyEmail("ev_unknown")
+
+	// then
+	assert.ErrorIs(test, expiredErr, model.ErrInvalidToken)
+	assert.ErrorIs(test, unknownErr, model.ErrInvalidToken)
+}
+
+func TestShouldResetVerificationWhenEmailChanges(test *testing.T) {
+	// given
+	_, mailer, userService, verifications := setupVerificationTest()
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	oldToken := sentToken(test, verificationMails(mailer)[0])
+	_, _ = verifications.VerifyEmail(oldToken)
+
+	// when
+	renamed, renameErr := userService.UpdateUser(user.UUID, &model.UpdateUserRequest{FullName: "John Doe"})
+	updated, err := userService.UpdateUser(user.UUID, &model.UpdateUserRequest{Email: "john@example.com"})
+
+	// then
+	assert.NoError(test, renameErr)
+	assert.NotNil(test, renamed.EmailVerifiedAt)
+	assert.NoError(test, err)
+	assert.Nil(test, updated.EmailVerifiedAt)
+	messages := verificationMails(mailer)
+	require.Len(test, messages, 2)
+	assert.Equal(test, "john@example.com", messages[1].To)
+	_, verifyErr := verifications.VerifyEmail(sentToken(test, messages[1]))
+	assert.NoError(test, verifyErr)
+}
+
+func TestShouldRejectTokenSentToPreviousEmail(test *testing.T) {
+	// given
+	repos, mailer, userService, verifications := setupVerificationTest()
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	oldToken := sentToken(test, verificationMails(mailer)[0])
+	stored, _ := repos.Users.GetByUUID(user.UUID)
+	stored.Email = "john@example.com"
+
+	// when
+	_, err := verifications.VerifyEmail(oldToken)
+
+	// then
+	assert.ErrorIs(test, err, model.ErrInvalidToken)
+}
+
+func TestShouldRateLimitVerificationResends(test *testing.T) {
+	// given
+	_, mailer, userService, verifications := setupVerificationTest()
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	clock := time.Now()
+	verifications.now = func() time.Time { return clock }
+
+	// when
+	tooSoonErr := verifications.ResendVerification(user.UUID)
+	clock = clock.Add(2 * time.Minute)
+	resendErr := verifications.ResendVerification(user.UUID)
+
+	// then
+	assert.ErrorIs(test, tooSoonErr, model.ErrRateLimited)
+	assert.NoError(test, resendErr)
+	assert.Len(test, verificationMails(mailer), 2)
+}
+
+func TestShouldCapVerificationResendsPerHour(test *testing.T) {
+	// given
+	repos, _, userService, verifications := setupVerificationTest()
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	clock := time.Now()
+	verifications.now = func() time.Time { return clock }
+	repos.EmailVerifications.(*mockEmailVerificationRepository).now = func() time.Time { return clock }
+
+	// when
+	var err error
+	for range verifications.config.VerificationResendLimit {
+		clock = clock.Add(2 * time.Minute)
+		err = verifications.ResendVerification(user.UUID)
+	}
+
+	// then
+	assert.ErrorIs(test, err, model.ErrRateLimited)
+}
+
+func TestShouldNotResendToVerifiedEmail(test *testing.T) {
+	// given
+	_, mailer, userService, verifications := setupVerificationTest()
+	user, _ := userService.CreateUser(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com"})
+	_, _ = verifications.VerifyEmail(sentToken(test, verificationMails(mailer)[0]))
+
+	// when
+	err := verifications.ResendVerification(user.UUID)
+
+	// then
+	assert.ErrorIs(test, err, model.ErrEmailAlreadyVerified)
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const defaultDeliveryLimit = 50
+
+type WebhookService interface {
+	GetAllWebhooks() ([]model.Webhook, error)
+	GetWebhook(id int64) (*model.Webhook, error)
+	CreateWebhook(request *model.CreateWebhookRequest) (*model.Webhook, error)
+	EnableWebhook(id int64) (*model.Webhook, error)
+	DeleteWebhook(id int64) error
+	GetDeliveries(webhookID int64) ([]model.WebhookDelivery, error)
+	Redeliver(webhookID, deliveryID int64) (*model.WebhookDelivery, error)
+}
+
+type webhookService struct {
+	transactor repository.Transactor
+}
+
+// NewWebhookService manages the webhooks of the tenant transactor is scoped
+// to.
+func NewWebhookService(transactor repository.Transactor) WebhookService {
+	return &webhookService{transactor: transactor}
+}
+
+func (webhookService *webhookService) GetAllWebhooks() ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+	err := webhookService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		var err error
+		webhooks, err = repos.Webhooks.GetAll()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+func (webhookService *webhookService) GetWebhook(id int64) (*model.Webhook, error) {
+	var webhook *model.Webhook
+	err := webhookService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		var err error
+		webhook, err = requireWebhook(repos, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+func requireWebhook(repos *repository.Repository, id int64) (*model.Webhook, error) {
+	webhook, err := repos.Webhooks.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil {
+		return nil, model.ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+// CreateWebhook registers a subscription. The secret is only ever returned
+// here, so a generated one must be stored by the caller.
+func (webhookService *webhookService) CreateWebhook(request *model.CreateWebhookRequest) (*model.Webhook, error) {
+	if err := webhookService.validateCreateRequest(request); err != nil {
+		return nil, err
+	}
+
+	secret := request.Secret
+	if secret == "" {
+		generated, err := generateSecret()
+		if err != nil {
+			return nil, err
+		}
+		secret = generated
+	}
+
+	webhook := &model.Webhook{
+		URL:    request.URL,
+		Events: request.Events,
+		Secret: secret,
+		Active: true,
+	}
+	err := webhookService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		return repos.Webhooks.Create(webhook)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+func (webhookService *webhookService) EnableWebhook(id int64) (*model.Webhook, error) {
+	var webhook *model.Webhook
+	err := webhookService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		var err error
+		webhook, err = requireWebhook(repos, id)
+		if err != nil {
+			return err
+		}
+
+		webhook.Active = true
+		webhook.ConsecutiveFailures = 0
+		return repos.Webhooks.UpdateHealth(id, webhook.ConsecutiveFailures, webhook.Active)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+func (webhookService *webhookService) DeleteWebhook(id int64) error {
+	return webhookService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if _, err := requireWebhook(repos, id); err != nil {
+			return err
+		}
+		return repos.Webhooks.Delete(id)
+	})
+}
+
+func (webhookService *webhookService) GetDeliveries(webhookID int64) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := webhookService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		if _, err := requireWebhook(repos, webhookID); err != nil {
+			return err
+		}
+		var err error
+		deliveries, err = repos.Webhooks.GetDeliveries(webhookID, defaultDeliveryLimit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Redeliver queues a fresh copy of a past delivery so the original attempt
+// history stays intact in the delivery log.
+func (webhookService *webhookService) Redeliver(webhookID, deliveryID int64) (*model.WebhookDelivery, error) {
+	var delivery *model.WebhookDelivery
+	err := webhookService.transactor.WithinTransaction(func(repos *repository.Repository) error {
+		webhook, err := requireWebhook(repos, webhookID)
+		if err != nil {
+			return err
+		}
+
+		original, err := repos.Webhooks.GetDelivery(deliveryID)
+		if err != nil {
+			return err
+		}
+		if original == nil || original.WebhookID != webhookID {
+			return model.ErrDeliveryNotFound
+		}
+
+		delivery = &model.WebhookDelivery{
+			WebhookID:     webhookID,
+			TenantID:      webhook.TenantID,
+			EventType:     original.EventType,
+			Payload:       original.Payload,
+			Status:        model.DeliveryStatusPending,
+			NextAttemptAt: time.Now(),
+		}
+		return repos.Webhooks.CreateDelivery(delivery)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+func (webhookService *webhookService) validateCreateRequest(request *model.CreateWebhookRequest) error {
+	parsed, err := url.Parse(request.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return model.ErrInvalidWebhookURL
+	}
+
+	if len(request.Events) == 0 {
+		return fmt.Errorf("events: %w", model.ErrEmptyField)
+	}
+	for _, event := range request.Events {
+		if event != model.WebhookEventWildcard && !model.EventType(event).IsValid() {
+			return fmt.Errorf("%q: %w", event, model.ErrUnknownEventType)
+		}
+	}
+	return nil
+}
+
+func generateSecret() (string, error) {
+	buffer := make([]byte, 32)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(buffer), nil
+}
@@ -0,0 +1,129 @@
+package stream
+
+import (
+	"cruder/internal/model"
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+)
+
+const subscriberBuffer = 64
+
+type Event struct {
+	ID        int64           `json:"id"`
+	Type      model.EventType `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+	// TenantID is the tenant of the user the event is about.
+	TenantID string `json:"-"`
+}
+
+type Filter map[model.EventType]bool
+
+func (filter Filter) Matches(eventType model.EventType) bool {
+	return len(filter) == 0 || filter[eventType]
+}
+
+// Subscription receives live events. Events is closed when the subscriber
+// falls too far behind, so the client reconnects and resumes from its last ID.
+type Subscription struct {
+	Events <-chan Event
+	events chan Event
+	filter Filter
+}
+
+// Broker fans events out to local subscribers and keeps a bounded log of the
+// most recent events so reconnecting clients can resume via Last-Event-ID.
+// The log is in arrival order: outbox IDs are assigned before commit, so
+// events can arrive out of ID order.
+type Broker struct {
+	mutex       sync.Mutex
+	log         []Event
+	logged      map[int64]struct{}
+	capacity    int
+	subscribers map[*Subscription]struct{}
+}
+
+func NewBroker(capacity int) *Broker {
+	return &Broker{
+		capacity:    capacity,
+		logged:      make(map[int64]struct{}),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish logs event and sends it to the subscribers, dropping it as a
+// duplicate if its ID is still in the log.
+func (broker *Broker) Publish(event Event) {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	if _, ok := broker.logged[event.ID]; ok {
+		return
+	}
+
+	broker.log = append(broker.log, event)
+	broker.logged[event.ID] = struct{}{}
+	if len(broker.log) > broker.capacity {
+		evicted := len(broker.log) - broker.capacity
+		for _, old := range broker.log[:evicted] {
+			delete(broker.logged, old.ID)
+		}
+		broker.log = broker.log[evicted:]
+	}
+
+	for subscription := range broker.subscribers {
+		if !subscription.filter.Matches(event.Type) {
+			continue
+		}
+		select {
+		case subscription.events <- event:
+		default:
+			broker.remove(subscription)
+		}
+	}
+}
+
+// Subscribe registers a subscriber and returns the logged events that arrived
+// after lastEventID and match the filter. Replay and registration happen under
+// the same lock so no event is lost or duplicated between the two. complete is
+// false when lastEventID is no longer in the log: events may have been missed
+// and the client has to reload instead.
+func (broker *Broker) Subscribe(lastEventID int64, filter Filter) (*Subscription, []Event, bool) {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	var replay []Event
+	complete := true
+	if lastEventID > 0 {
+		position := slices.IndexFunc(broker.log, func(event Event) bool { return event.ID == lastEventID })
+		complete = position >= 0
+		if complete {
+			for _, event := range broker.log[position+1:] {
+				if filter.Matches(event.Type) {
+					replay = append(replay, event)
+				}
+			}
+		}
+	}
+
+	events := make(chan Event, subscriberBuffer)
+	subscription := &Subscription{Events: events, events: events, filter: filter}
+	broker.subscribers[subscription] = struct{}{}
+	return subscription, replay, complete
+}
+
+func (broker *Broker) Unsubscribe(subscription *Subscription) {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	broker.remove(subscription)
+}
+
+func (broker *Broker) remove(subscription *Subscription) {
+	if _, ok := broker.subscribers[subscription]; ok {
+		delete(broker.subscribers, subscription)
+		close(subscription.events)
+	}
+}
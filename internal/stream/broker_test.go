@@ -0,0 +1,144 @@
+package stream
+
+import (
+	"cruder/internal/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func publishEvents(broker *Broker, types ...model.EventType) {
+	for i, eventType := range types {
+		broker.Publish(Event{ID: int64(i + 1), Type: eventType, Data: []byte(`{}`)})
+	}
+}
+
+func eventIDs(events []Event) []int64 {
+	ids := make([]int64, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func TestShouldReplayEventsAfterLastEventID(test *testing.T) {
+	// given
+	broker := NewBroker(10)
+	publishEvents(broker, model.EventUserCreated, model.EventUserUpdated, model.EventUserDeleted)
+
+	// when
+	_, replay, complete := broker.Subscribe(1, nil)
+
+	// then
+	assert.Equal(test, []int64{2, 3}, eventIDs(replay))
+	assert.True(test, complete)
+}
+
+func TestShouldNotReplayWithoutLastEventID(test *testing.T) {
+	// given
+	broker := NewBroker(10)
+	publishEvents(broker, model.EventUserCreated)
+
+	// when
+	_, replay, complete := broker.Subscribe(0, nil)
+
+	// then
+	assert.Empty(test, replay)
+	assert.True(test, complete)
+}
+
+func TestShouldKeepOnlyMostRecentEventsInLog(test *testing.T) {
+	// given
+	broker := NewBroker(2)
+	publishEvents(broker, model.EventUserCreated, model.EventUserUpdated, model.EventUserUpdated, model.EventUserDeleted)
+
+	// when
+	_, replay, complete := broker.Subscribe(3, nil)
+	_, evictedReplay, evictedComplete := broker.Subscribe(1, nil)
+
+	// then
+	assert.Equal(test, []int64{4}, eventIDs(replay))
+	assert.True(test, complete)
+	assert.Empty(test, evictedReplay)
+	assert.False(test, evictedComplete)
+}
+
+func TestShouldKeepEventsArrivingOutOfIDOrder(test *testing.T) {
+	// given
+	broker := NewBroker(10)
+	subscription, _, _ := broker.Subscribe(0, nil)
+
+	// when
+	broker.Publish(Event{ID: 2, Type: model.EventUserUpdated})
+	broker.Publish(Event{ID: 1, Type: model.EventUserCreated})
+	_, replay, complete := broker.Subscribe(2, nil)
+
+	// then
+	assert.Equal(test, int64(2), (<-subscription.Events).ID)
+	assert.Equal(test, int64(1), (<-subscription.Events).ID)
+	assert.Equal(test, []int64{1}, eventIDs(replay))
+	assert.True(test, complete)
+}
+
+func TestShouldFilterReplayedAndLiveEventsByType(test *testing.T) {
+	// given
+	broker := NewBroker(10)
+	publishEvents(broker, model.EventUserCreated, model.EventUserDeleted)
+	filter := Filter{model.EventUserDeleted: true}
+
+	// when
+	subscription, replay, _ := broker.Subscribe(1, filter)
+	broker.Publish(Event{ID: 3, Type: model.EventUserUpdated})
+	broker.Publish(Event{ID: 4, Type: model.EventUserDeleted})
+
+	// then
+	assert.Equal(test, []int64{2}, eventIDs(replay))
+	live := <-subscription.Events
+	assert.Equal(test, int64(4), live.ID)
+	assert.Len(test, subscription.Events, 0)
+}
+
+func TestShouldIgnoreDuplicateNotifications(test *testing.T) {
+	// given
+	broker := NewBroker(10)
+	subscription, _, _ := broker.Subscribe(0, nil)
+
+	// when
+	broker.Publish(Event{ID: 1, Type: model.EventUserCreated})
+	broker.Publish(Event{ID: 1, Type: model.EventUserCreated})
+
+	// then
+	assert.Len(test, subscription.Events, 1)
+}
+
+func TestShouldDropSubscriberThatFallsBehind(test *testing.T) {
+	// given
+	broker := NewBroker(10)
+	subscription, _, _ := broker.Subscribe(0, nil)
+
+	// when
+	for i := 1; i <= subscriberBuffer+1; i++ {
+		broker.Publish(Event{ID: int64(i), Type: model.EventUserUpdated})
+	}
+
+	// then
+	received := 0
+	for range subscription.Events {
+		received++
+	}
+	assert.Equal(test, subscriberBuffer, received)
+}
+
+func TestShouldDecodeNotificationPayload(test *testing.T) {
+	// given
+	payload := `{"id":42,"type":"user.updated","created_at":"2025-11-07T11:00:00.000000Z","data":{"changed_fields":["email"]}}`
+
+	// when
+	event, err := decodeNotification(payload)
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, int64(42), event.ID)
+	assert.Equal(test, model.EventUserUpdated, event.Type)
+	assert.JSONEq(test, `{"changed_fields":["email"]}`, string(event.Data))
+}
@@ -0,0 +1,74 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Channel is the Postgres notification channel fed by the outbox insert
+// trigger, so every replica sees every committed user change.
+const Channel = "user_events"
+
+// Listen subscribes to Channel and publishes each notification to the broker
+// until ctx is cancelled. Notifications sent while the connection is being
+// re-established are not replayed.
+func Listen(ctx context.Context, dsn string, broker *Broker) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("event listener: %v", err)
+		}
+	})
+	if err := listener.Listen(Channel); err != nil {
+		_ = listener.Close()
+		return fmt.Errorf("failed to listen on %s: %w", Channel, err)
+	}
+
+	go func() {
+		defer listener.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case notification := <-listener.Notify:
+				if notification == nil {
+					continue
+				}
+				event, err := decodeNotification(notification.Extra)
+				if err != nil {
+					log.Printf("event listener: %v", err)
+					continue
+				}
+				broker.Publish(event)
+			}
+		}
+	}()
+	return nil
+}
+
+func decodeNotification(payload string) (Event, error) {
+	var event Event
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return Event{}, fmt.Errorf("failed to decode notification: %w", err)
+	}
+
+	// Created and updated events carry the user, deleted events the tenant.
+	var scope struct {
+		TenantID string `json:"tenant_id"`
+		User     struct {
+			TenantID string `json:"tenant_id"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(event.Data, &scope); err != nil {
+		return Event{}, fmt.Errorf("failed to decode event data: %w", err)
+	}
+	event.TenantID = scope.TenantID
+	if event.TenantID == "" {
+		event.TenantID = scope.User.TenantID
+	}
+	return event, nil
+}
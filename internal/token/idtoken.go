@@ -0,0 +1,49 @@
+package token
+
+// IDClaims are the claims of an OpenID Connect ID token. The profile claims
+// are only set for the scopes the client was granted.
+type IDClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	Audience  string `json:"aud"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	AuthTime  int64  `json:"auth_time"`
+	Nonce     string `json:"nonce,omitempty"`
+
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Name              string `json:"name,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+}
+
+// IssueIDToken signs claims as an ID token after setting the issuer, issue
+// time and expiry.
+func (issuer *Issuer) IssueIDToken(claims IDClaims) (string, IDClaims, error) {
+	now := issuer.now()
+	claims.Issuer = issuer.issuer
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = now.Add(issuer.ttl).Unix()
+
+	raw, err := issuer.sign(claims)
+	if err != nil {
+		return "", IDClaims{}, err
+	}
+	return raw, claims, nil
+}
+
+// VerifyIDToken checks an ID token the way a relying party registered as
+// audience would.
+func (issuer *Issuer) VerifyIDToken(raw, audience string) (*IDClaims, error) {
+	var claims IDClaims
+	if err := issuer.verifySignature(raw, &claims); err != nil {
+		return nil, err
+	}
+	if claims.Issuer != issuer.issuer || claims.Subject == "" || claims.Audience != audience {
+		return nil, ErrInvalidToken
+	}
+	if issuer.expired(claims.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+	return &claims, nil
+}
@@ -0,0 +1,46 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldIssueAndVerifyIDToken(test *testing.T) {
+	// given
+	issuer, _ := newTestIssuer(test)
+	verified := true
+
+	// when
+	raw, issued, issueErr := issuer.IssueIDToken(IDClaims{
+		Subject: "user-uuid", Audience: "client-id", AuthTime: 1700000000, Nonce: "n-0S6_WzA2Mj",
+		PreferredUsername: "jdoe", Email: "jdoe@example.com", EmailVerified: &verified,
+	})
+	claims, verifyErr := issuer.VerifyIDToken(raw, "client-id")
+
+	// then
+	assert.NoError(test, issueErr)
+	assert.NoError(test, verifyErr)
+	assert.Equal(test, issued, *claims)
+	assert.Equal(test, "cruder", claims.Issuer)
+	assert.Equal(test, "n-0S6_WzA2Mj", claims.Nonce)
+	assert.Equal(test, int64(15*60), claims.ExpiresAt-claims.IssuedAt)
+}
+
+func TestShouldRejectIDTokenForOtherAudience(test *testing.T) {
+	// given
+	issuer, _ := newTestIssuer(test)
+	raw, _, _ := issuer.IssueIDToken(IDClaims{Subject: "user-uuid", Audience: "client-id"})
+
+	// when
+	_, audienceErr := issuer.VerifyIDToken(raw, "other-client")
+	_, accessErr := issuer.Verify(raw)
+	issuer.now = func() time.Time { return time.Now().Add(16 * time.Minute) }
+	_, expiredErr := issuer.VerifyIDToken(raw, "client-id")
+
+	// then
+	assert.ErrorIs(test, audienceErr, ErrInvalidToken)
+	assert.ErrorIs(test, accessErr, ErrInvalidToken)
+	assert.ErrorIs(test, expiredErr, ErrTokenExpired)
+}
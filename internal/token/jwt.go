@@ -0,0 +1,165 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const algorithm = "EdDSA"
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+)
+
+type Claims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	TenantID  string   `json:"tid"`
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+	ID        string   `json:"jti"`
+	SessionID string   `json:"sid,omitempty"`
+	Roles     []string `json:"roles"`
+	// Audience is only set on ID tokens, which must never pass as access
+	// tokens.
+	Audience string `json:"aud,omitempty"`
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid"`
+}
+
+// Issuer signs and verifies EdDSA access tokens.
+type Issuer struct {
+	keys   *KeySet
+	issuer string
+	ttl    time.Duration
+	leeway time.Duration
+	now    func() time.Time
+}
+
+func NewIssuer(keys *KeySet, issuer string, ttl time.Duration) *Issuer {
+	return &Issuer{keys: keys, issuer: issuer, ttl: ttl, leeway: 30 * time.Second, now: time.Now}
+}
+
+func (issuer *Issuer) TTL() time.Duration {
+	return issuer.ttl
+}
+
+func (issuer *Issuer) Issue(subject, tenantID, sessionID string, roles []string) (string, Claims, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", Claims{}, fmt.Errorf("failed to generate token id: %w", err)
+	}
+
+	now := issuer.now()
+	if roles == nil {
+		roles = []string{}
+	}
+	claims := Claims{
+		Issuer:    issuer.issuer,
+		Subject:   subject,
+		TenantID:  tenantID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(issuer.ttl).Unix(),
+		ID:        hex.EncodeToString(id),
+		SessionID: sessionID,
+		Roles:     roles,
+	}
+
+	raw, err := issuer.sign(claims)
+	if err != nil {
+		return "", Claims{}, err
+	}
+	return raw, claims, nil
+}
+
+// Verify checks the signature against the key named in the header, then the
+// issuer and expiry.
+func (issuer *Issuer) Verify(raw string) (*Claims, error) {
+	var claims Claims
+	if err := issuer.verifySignature(raw, &claims); err != nil {
+		return nil, err
+	}
+	if claims.Issuer != issuer.issuer || claims.Subject == "" || claims.Audience != "" {
+		return nil, ErrInvalidToken
+	}
+	if issuer.expired(claims.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+	return &claims, nil
+}
+
+func (issuer *Issuer) sign(claims any) (string, error) {
+	key, err := issuer.keys.signingKey()
+	if err != nil {
+		return "", err
+	}
+
+	signingInput, err := encodeSegments(header{Algorithm: algorithm, Type: "JWT", KeyID: key.ID}, claims)
+	if err != nil {
+		return "", err
+	}
+	signature := ed25519.Sign(key.PrivateKey, []byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// verifySignature checks raw against the key named in its header and
+// decodes its payload into claims.
+func (issuer *Issuer) verifySignature(raw string, claims any) error {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return ErrInvalidToken
+	}
+
+	var tokenHeader header
+	if err := decodeSegment(parts[0], &tokenHeader); err != nil || tokenHeader.Algorithm != algorithm {
+		return ErrInvalidToken
+	}
+	publicKey, ok := issuer.keys.publicKey(tokenHeader.KeyID)
+	if !ok {
+		return ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !ed25519.Verify(publicKey, []byte(parts[0]+"."+parts[1]), signature) {
+		return ErrInvalidToken
+	}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func (issuer *Issuer) expired(expiresAt int64) bool {
+	return issuer.now().Add(-issuer.leeway).Unix() >= expiresAt
+}
+
+func encodeSegments(tokenHeader header, claims any) (string, error) {
+	headerJSON, err := json.Marshal(tokenHeader)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON), nil
+}
+
+func decodeSegment(segment string, target any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
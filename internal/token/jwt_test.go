@@ -0,0 +1,115 @@
+package token
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestIssuer(test *testing.T, keys ...Key) (*Issuer, *KeySet) {
+	if len(keys) == 0 {
+		key, err := GenerateKey()
+		assert.NoError(test, err)
+		keys = []Key{key}
+	}
+	keySet := NewKeySet(keys...)
+	return NewIssuer(keySet, "cruder", 15*time.Minute), keySet
+}
+
+func TestShouldIssueAndVerifyToken(test *testing.T) {
+	// given
+	issuer, _ := newTestIssuer(test)
+
+	// when
+	raw, issued, issueErr := issuer.Issue("user-uuid", "tenant-id", "session-id", []string{"admin"})
+	claims, verifyErr := issuer.Verify(raw)
+
+	// then
+	assert.NoError(test, issueErr)
+	assert.NoError(test, verifyErr)
+	assert.Equal(test, issued, *claims)
+	assert.Equal(test, "user-uuid", claims.Subject)
+	assert.Equal(test, "tenant-id", claims.TenantID)
+	assert.Equal(test, "session-id", claims.SessionID)
+	assert.Equal(test, []string{"admin"}, claims.Roles)
+}
+
+func TestShouldRejectTamperedToken(test *testing.T) {
+	// given
+	issuer, _ := newTestIssuer(test)
+	raw, _, _ := issuer.Issue("user-uuid", "tenant-id", "", nil)
+	other, _, _ := issuer.Issue("other-uuid", "tenant-id", "", []string{"admin"})
+	parts := strings.Split(raw, ".")
+	otherParts := strings.Split(other, ".")
+
+	// when
+	_, err := issuer.Verify(parts[0] + "." + otherParts[1] + "." + parts[2])
+
+	// then
+	assert.ErrorIs(test, err, ErrInvalidToken)
+}
+
+func TestShouldRejectExpiredToken(test *testing.T) {
+	// given
+	issuer, _ := newTestIssuer(test)
+	raw, _, _ := issuer.Issue("user-uuid", "tenant-id", "", nil)
+	issuer.now = func() time.Time { return time.Now().Add(16 * time.Minute) }
+
+	// when
+	_, err := issuer.Verify(raw)
+
+	// then
+	assert.ErrorIs(test, err, ErrTokenExpired)
+}
+
+func TestShouldVerifyTokensOfRetiredKeyAfterRotation(test *testing.T) {
+	// given
+	oldKey, _ := GenerateKey()
+	oldKey.ID = "20250101T000000Z"
+	issuer, keySet := newTestIssuer(test, oldKey)
+	oldToken, _, _ := issuer.Issue("user-uuid", "tenant-id", "", nil)
+	newKey, _ := GenerateKey()
+	keySet.Replace([]Key{oldKey, newKey})
+
+	// when
+	newToken, _, _ := issuer.Issue("user-uuid", "tenant-id", "", nil)
+	_, oldErr := issuer.Verify(oldToken)
+	_, newErr := issuer.Verify(newToken)
+	keySet.Replace([]Key{newKey})
+	_, removedErr := issuer.Verify(oldToken)
+
+	// then
+	assert.NoError(test, oldErr)
+	assert.NoError(test, newErr)
+	assert.ErrorIs(test, removedErr, ErrInvalidToken)
+	assert.Len(test, keySet.JWKS().Keys, 1)
+	assert.Equal(test, newKey.ID, keySet.JWKS().Keys[0].KeyID)
+}
+
+func TestShouldLoadWrittenKeys(test *testing.T) {
+	// given
+	dir := test.TempDir()
+	key, _ := GenerateKey()
+	_, writeErr := WriteKeyFile(dir, key)
+
+	// when
+	keys, err := LoadKeyDir(dir)
+
+	// then
+	assert.NoError(test, writeErr)
+	assert.NoError(test, err)
+	assert.Equal(test, []Key{key}, keys)
+}
+
+func TestShouldFailWithoutSigningKey(test *testing.T) {
+	// given
+	issuer := NewIssuer(NewKeySet(), "cruder", time.Minute)
+
+	// when
+	_, _, err := issuer.Issue("user-uuid", "tenant-id", "", nil)
+
+	// then
+	assert.ErrorIs(test, err, ErrNoSigningKey)
+}
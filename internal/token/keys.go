@@ -0,0 +1,162 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNoSigningKey = errors.New("no signing key available")
+
+type Key struct {
+	ID         string
+	PrivateKey ed25519.PrivateKey
+}
+
+// GenerateKey creates an Ed25519 key whose id sorts after every key
+// generated before it, so a newly added key takes over signing.
+func GenerateKey() (Key, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return Key{}, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	return Key{ID: time.Now().UTC().Format("20060102T150405Z"), PrivateKey: privateKey}, nil
+}
+
+// WriteKeyFile stores key as a PKCS #8 PEM file named after its id.
+func WriteKeyFile(dir string, key Key) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode signing key: %w", err)
+	}
+
+	path := filepath.Join(dir, key.ID+".pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write signing key: %w", err)
+	}
+	return path, nil
+}
+
+// KeySet signs with its newest key and verifies with all of them, so keys
+// can be rotated by adding a new one and removing the old one only once
+// every token it signed has expired.
+type KeySet struct {
+	mutex sync.RWMutex
+	keys  []Key
+}
+
+func NewKeySet(keys ...Key) *KeySet {
+	keySet := &KeySet{}
+	keySet.Replace(keys)
+	return keySet
+}
+
+// LoadKeyDir reads every *.pem file in dir as an Ed25519 private key whose id
+// is the file name without extension.
+func LoadKeyDir(dir string) ([]Key, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]Key, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signing key: %w", err)
+		}
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("%s: no PEM data", path)
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		privateKey, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s: not an Ed25519 key", path)
+		}
+		keys = append(keys, Key{ID: strings.TrimSuffix(filepath.Base(path), ".pem"), PrivateKey: privateKey})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: %w", dir, ErrNoSigningKey)
+	}
+	return keys, nil
+}
+
+// Replace swaps in a new set of keys, e.g. after the key directory changed.
+func (keySet *KeySet) Replace(keys []Key) {
+	sorted := append([]Key(nil), keys...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	keySet.mutex.Lock()
+	defer keySet.mutex.Unlock()
+	keySet.keys = sorted
+}
+
+func (keySet *KeySet) signingKey() (Key, error) {
+	keySet.mutex.RLock()
+	defer keySet.mutex.RUnlock()
+
+	if len(keySet.keys) == 0 {
+		return Key{}, ErrNoSigningKey
+	}
+	return keySet.keys[len(keySet.keys)-1], nil
+}
+
+func (keySet *KeySet) publicKey(id string) (ed25519.PublicKey, bool) {
+	keySet.mutex.RLock()
+	defer keySet.mutex.RUnlock()
+
+	for _, key := range keySet.keys {
+		if key.ID == id {
+			return key.PrivateKey.Public().(ed25519.PublicKey), true
+		}
+	}
+	return nil, false
+}
+
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS publishes the public half of every key, newest first.
+func (keySet *KeySet) JWKS() JWKS {
+	keySet.mutex.RLock()
+	defer keySet.mutex.RUnlock()
+
+	jwks := JWKS{Keys: make([]JWK, 0, len(keySet.keys))}
+	for i := len(keySet.keys) - 1; i >= 0; i-- {
+		key := keySet.keys[i]
+		jwks.Keys = append(jwks.Keys, JWK{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(key.PrivateKey.Public().(ed25519.PublicKey)),
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: algorithm,
+		})
+	}
+	return jwks
+}
@@ -0,0 +1,24 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+// NewOpaque returns a random token with the given prefix and the hash to
+// store in its place. The 256 bits of entropy make a plain SHA-256 safe.
+func NewOpaque(prefix string) (string, []byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	raw := prefix + base64.RawURLEncoding.EncodeToString(secret)
+	return raw, HashOpaque(raw), nil
+}
+
+func HashOpaque(raw string) []byte {
+	sum := sha256.Sum256([]byte(raw))
+	return sum[:]
+}
@@ -0,0 +1,59 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 test key of RFC 6238 appendix B.
+var rfcSecret = []byte("12345678901234567890")
+
+func TestShouldMatchRFC6238TestVectors(test *testing.T) {
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for seconds, expected := range vectors {
+		assert.Equal(test, expected, Code(rfcSecret, Counter(time.Unix(seconds, 0))), "at %d", seconds)
+	}
+}
+
+func TestShouldAcceptCodesWithinSkew(test *testing.T) {
+	// given
+	now := time.Unix(1111111111, 0)
+	previous := Code(rfcSecret, Counter(now)-1)
+	stale := Code(rfcSecret, Counter(now)-2)
+
+	// when
+	step, ok := Verify(rfcSecret, previous, now, 1)
+	_, staleOK := Verify(rfcSecret, stale, now, 1)
+	_, malformedOK := Verify(rfcSecret, "12345", now, 1)
+
+	// then
+	assert.True(test, ok)
+	assert.Equal(test, Counter(now)-1, step)
+	assert.False(test, staleOK)
+	assert.False(test, malformedOK)
+}
+
+func TestShouldBuildOTPAuthURI(test *testing.T) {
+	// when
+	uri := URI("cruder", "jdoe@example.com", rfcSecret)
+
+	// then
+	parsed, err := url.Parse(uri)
+	require.NoError(test, err)
+	assert.Equal(test, "otpauth", parsed.Scheme)
+	assert.Equal(test, "totp", parsed.Host)
+	assert.Equal(test, "/cruder:jdoe@example.com", parsed.Path)
+	assert.Equal(test, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", parsed.Query().Get("secret"))
+	assert.Equal(test, "cruder", parsed.Query().Get("issuer"))
+}
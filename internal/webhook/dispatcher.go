@@ -0,0 +1,187 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"cruder/internal/model"
+	"cruder/internal/outbox"
+	"cruder/internal/repository"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const snippetLimit = 1024
+
+type Config struct {
+	PollInterval time.Duration
+	BatchSize    int
+	Timeout      time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	DisableAfter int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		PollInterval: time.Second,
+		BatchSize:    50,
+		Timeout:      10 * time.Second,
+		MaxAttempts:  8,
+		BaseBackoff:  10 * time.Second,
+		MaxBackoff:   time.Hour,
+		DisableAfter: 20,
+	}
+}
+
+// Dispatcher sends due webhook deliveries, retrying failures with exponential
+// backoff and disabling webhooks that keep failing.
+type Dispatcher struct {
+	webhooks repository.WebhookRepository
+	client   *http.Client
+	config   Config
+	now      func() time.Time
+}
+
+func NewDispatcher(webhooks repository.WebhookRepository, config Config) *Dispatcher {
+	return &Dispatcher{
+		webhooks: webhooks,
+		client:   &http.Client{Timeout: config.Timeout},
+		config:   config,
+		now:      time.Now,
+	}
+}
+
+func (dispatcher *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(dispatcher.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := dispatcher.ProcessDue(ctx); err != nil {
+			log.Printf("webhook dispatcher: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessDue attempts every delivery whose next attempt is due and returns how
+// many succeeded. The claim on a batch lasts until every delivery in it could
+// have timed out.
+func (dispatcher *Dispatcher) ProcessDue(ctx context.Context) (int, error) {
+	now := dispatcher.now()
+	leaseUntil := now.Add(time.Duration(dispatcher.config.BatchSize) * dispatcher.config.Timeout)
+	deliveries, err := dispatcher.webhooks.ClaimDueDeliveries(now, leaseUntil, dispatcher.config.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim due deliveries: %w", err)
+	}
+
+	succeeded := 0
+	webhooks := make(map[int64]*model.Webhook)
+	for i := range deliveries {
+		delivery := &deliveries[i]
+
+		webhook, ok := webhooks[delivery.WebhookID]
+		if !ok {
+			webhook, err = dispatcher.webhooks.GetByID(delivery.WebhookID)
+			if err != nil {
+				return succeeded, fmt.Errorf("failed to load webhook %d: %w", delivery.WebhookID, err)
+			}
+			webhooks[delivery.WebhookID] = webhook
+		}
+		if webhook == nil || !webhook.Active {
+			continue
+		}
+
+		if err := dispatcher.Deliver(ctx, webhook, delivery); err != nil {
+			return succeeded, err
+		}
+		if delivery.Status == model.DeliveryStatusSucceeded {
+			succeeded++
+		}
+	}
+
+	return succeeded, nil
+}
+
+// Deliver makes a single attempt at a delivery and persists its outcome along
+// with the webhook's health. Disabling the webhook fails its pending
+// deliveries. Only persistence failures are returned.
+func (dispatcher *Dispatcher) Deliver(ctx context.Context, webhook *model.Webhook, delivery *model.WebhookDelivery) error {
+	disabled := false
+	delivery.Attempts++
+	delivery.RequestSnippet = snippet(delivery.Payload)
+	delivery.ResponseStatus, delivery.ResponseSnippet, delivery.LastError = dispatcher.send(ctx, webhook, delivery)
+
+	if delivery.LastError == "" {
+		delivery.Status = model.DeliveryStatusSucceeded
+		webhook.ConsecutiveFailures = 0
+	} else {
+		webhook.ConsecutiveFailures++
+		if webhook.Active && webhook.ConsecutiveFailures >= dispatcher.config.DisableAfter {
+			webhook.Active = false
+			disabled = true
+		}
+
+		if delivery.Attempts >= dispatcher.config.MaxAttempts {
+			delivery.Status = model.DeliveryStatusFailed
+		} else {
+			delivery.NextAttemptAt = dispatcher.now().Add(outbox.Backoff(dispatcher.config.BaseBackoff, dispatcher.config.MaxBackoff, delivery.Attempts))
+		}
+	}
+
+	if err := dispatcher.webhooks.UpdateDelivery(delivery); err != nil {
+		return fmt.Errorf("failed to update delivery %d: %w", delivery.ID, err)
+	}
+	if err := dispatcher.webhooks.UpdateHealth(webhook.ID, webhook.ConsecutiveFailures, webhook.Active); err != nil {
+		return fmt.Errorf("failed to update webhook %d: %w", webhook.ID, err)
+	}
+	if disabled {
+		reason := fmt.Sprintf("webhook disabled after %d consecutive failures", webhook.ConsecutiveFailures)
+		if err := dispatcher.webhooks.FailPendingDeliveries(webhook.ID, reason); err != nil {
+			return fmt.Errorf("failed to fail pending deliveries of webhook %d: %w", webhook.ID, err)
+		}
+	}
+	return nil
+}
+
+func (dispatcher *Dispatcher) send(ctx context.Context, webhook *model.Webhook, delivery *model.WebhookDelivery) (int, string, string) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, "", err.Error()
+	}
+
+	timestamp := dispatcher.now().Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, string(delivery.EventType))
+	request.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	request.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, delivery.Payload))
+
+	response, err := dispatcher.client.Do(request)
+	if err != nil {
+		return 0, "", err.Error()
+	}
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, snippetLimit))
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, string(body), fmt.Sprintf("receiver responded with status %d", response.StatusCode)
+	}
+	return response.StatusCode, string(body), ""
+}
+
+func snippet(body []byte) string {
+	if len(body) > snippetLimit {
+		return string(body[:snippetLimit])
+	}
+	return string(body)
+}
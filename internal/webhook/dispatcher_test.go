@@ -0,0 +1,313 @@
+package webhook
+
+import (
+	"context"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryWebhookRepository struct {
+	webhooks   map[int64]*model.Webhook
+	deliveries []*model.WebhookDelivery
+}
+
+func newMemoryWebhookRepository() *memoryWebhookRepository {
+	return &memoryWebhookRepository{webhooks: make(map[int64]*model.Webhook)}
+}
+
+func (repository *memoryWebhookRepository) GetAll() ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+	for _, webhook := range repository.webhooks {
+		webhooks = append(webhooks, *webhook)
+	}
+	return webhooks, nil
+}
+
+func (repository *memoryWebhookRepository) GetByID(id int64) (*model.Webhook, error) {
+	webhook, ok := repository.webhooks[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *webhook
+	return &copied, nil
+}
+
+func (repository *memoryWebhookRepository) GetActiveForEvent(tenantID string, eventType model.EventType) ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+	for _, webhook := range repository.webhooks {
+		if webhook.TenantID == tenantID && webhook.Active && webhook.Subscribes(eventType) {
+			webhooks = append(webhooks, *webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+func (repository *memoryWebhookRepository) Create(webhook *model.Webhook) error {
+	webhook.ID = int64(len(repository.webhooks) + 1)
+	repository.webhooks[webhook.ID] = webhook
+	return nil
+}
+
+func (repository *memoryWebhookRepository) UpdateHealth(id int64, consecutiveFailures int, active bool) error {
+	repository.webhooks[id].ConsecutiveFailures = consecutiveFailures
+	repository.webhooks[id].Active = active
+	return nil
+}
+
+func (repository *memoryWebhookRepository) Delete(id int64) error {
+	delete(repository.webhooks, id)
+	return nil
+}
+
+func (repository *memoryWebhookRepository) CreateDelivery(delivery *model.WebhookDelivery) error {
+	for _, existing := range repository.deliveries {
+		if delivery.OutboxID != 0 && existing.WebhookID == delivery.WebhookID && existing.OutboxID == delivery.OutboxID {
+			return nil
+		}
+	}
+	delivery.ID = int64(len(repository.deliveries) + 1)
+	copied := *delivery
+	repository.deliveries = append(repository.deliveries, &copied)
+	return nil
+}
+
+func (repository *memoryWebhookRepository) UpdateDelivery(delivery *model.WebhookDelivery) error {
+	copied := *delivery
+	repository.deliveries[delivery.ID-1] = &copied
+	return nil
+}
+
+func (repository *memoryWebhookRepository) GetDelivery(id int64) (*model.WebhookDelivery, error) {
+	if id < 1 || int(id) > len(repository.deliveries) {
+		return nil, nil
+	}
+	copied := *repository.deliveries[id-1]
+	return &copied, nil
+}
+
+func (repository *memoryWebhookRepository) GetDeliveries(webhookID int64, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	for _, delivery := range repository.deliveries {
+		if delivery.WebhookID == webhookID && len(deliveries) < limit {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (repository *memoryWebhookRepository) FailPendingDeliveries(webhookID int64, reason string) error {
+	for _, delivery := range repository.deliveries {
+		if delivery.WebhookID == webhookID && delivery.Status == model.DeliveryStatusPending {
+			delivery.Status = model.DeliveryStatusFailed
+			delivery.LastError = reason
+		}
+	}
+	return nil
+}
+
+func (repository *memoryWebhookRepository) ClaimDueDeliveries(now, leaseUntil time.Time, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	for _, delivery := range repository.deliveries {
+		webhook := repository.webhooks[delivery.WebhookID]
+		if delivery.Status == model.DeliveryStatusPending && !delivery.NextAttemptAt.After(now) && webhook.Active && len(deliveries) < limit {
+			deliveries = append(deliveries, *delivery)
+			delivery.NextAttemptAt = leaseUntil
+		}
+	}
+	return deliveries, nil
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+type receiver struct {
+	mutex    sync.Mutex
+	status   int
+	requests []receivedRequest
+	server   *httptest.Server
+}
+
+func newReceiver(test *testing.T, status int) *receiver {
+	receiver := &receiver{status: status}
+	receiver.server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := io.ReadAll(request.Body)
+		receiver.mutex.Lock()
+		receiver.requests = append(receiver.requests, receivedRequest{header: request.Header.Clone(), body: body})
+		status := receiver.status
+		receiver.mutex.Unlock()
+		writer.WriteHeader(status)
+		_, _ = writer.Write([]byte(`{"ok":true}`))
+	}))
+	test.Cleanup(receiver.server.Close)
+	return receiver
+}
+
+func setupDispatcher(test *testing.T, status int) (*memoryWebhookRepository, *Dispatcher, *receiver, *time.Time) {
+	repository := newMemoryWebhookRepository()
+	receiver := newReceiver(test, status)
+	now := time.Date(2025, 11, 6, 10, 0, 0, 0, time.UTC)
+
+	config := DefaultConfig()
+	config.MaxAttempts = 3
+	config.DisableAfter = 2
+	dispatcher := NewDispatcher(repository, config)
+	dispatcher.now = func() time.Time { return now }
+
+	_ = repository.Create(&model.Webhook{TenantID: "tenant-a", URL: receiver.server.URL, Events: []string{"*"}, Secret: "s3cret", Active: true})
+	return repository, dispatcher, receiver, &now
+}
+
+func enqueue(test *testing.T, repository *memoryWebhookRepository, now time.Time) {
+	publish(test, repository, now, int64(len(repository.deliveries)+7), `{"user":{"username":"jdoe","tenant_id":"tenant-a"}}`)
+}
+
+func publish(test *testing.T, repository *memoryWebhookRepository, now time.Time, id int64, payload string) {
+	sink := NewSink(repository)
+	sink.now = func() time.Time { return now }
+	err := sink.Publish(context.Background(), model.OutboxMessage{
+		ID:        id,
+		EventType: model.EventUserCreated,
+		Payload:   []byte(payload),
+		CreatedAt: now,
+	})
+	assert.NoError(test, err)
+}
+
+func TestShouldDeliverSignedPayload(test *testing.T) {
+	// given
+	repository, dispatcher, receiver, now := setupDispatcher(test, http.StatusOK)
+	enqueue(test, repository, *now)
+
+	// when
+	succeeded, err := dispatcher.ProcessDue(context.Background())
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, 1, succeeded)
+	assert.Len(test, receiver.requests, 1)
+
+	request := receiver.requests[0]
+	assert.Equal(test, "user.created", request.header.Get(EventHeader))
+	assert.NoError(test, Verify("s3cret", request.header.Get(SignatureHeader), request.header.Get(TimestampHeader),
+		request.body, *now, 5*time.Minute))
+	assert.JSONEq(test, `{"id":7,"type":"user.created","created_at":"2025-11-06T10:00:00Z","data":{"user":{"username":"jdoe","tenant_id":"tenant-a"}}}`,
+		string(request.body))
+
+	delivery := repository.deliveries[0]
+	assert.Equal(test, model.DeliveryStatusSucceeded, delivery.Status)
+	assert.Equal(test, http.StatusOK, delivery.ResponseStatus)
+	assert.Equal(test, `{"ok":true}`, delivery.ResponseSnippet)
+}
+
+func TestShouldOnlyEnqueueDeliveriesForEventTenant(test *testing.T) {
+	// given
+	repository, _, _, now := setupDispatcher(test, http.StatusOK)
+
+	// when
+	publish(test, repository, *now, 1, `{"user":{"username":"jdoe","tenant_id":"tenant-b"}}`)
+	publish(test, repository, *now, 2, `{"uuid":"0b0e4b8e-1d53-4a52-9c8b-3f5d7f7f2a10","tenant_id":"tenant-b"}`)
+	publish(test, repository, *now, 3, `{"uuid":"0b0e4b8e-1d53-4a52-9c8b-3f5d7f7f2a10","tenant_id":"tenant-a"}`)
+
+	// then
+	assert.Len(test, repository.deliveries, 1)
+	assert.Equal(test, "tenant-a", repository.deliveries[0].TenantID)
+}
+
+func TestShouldNotDuplicateDeliveriesWhenEventIsPublishedAgain(test *testing.T) {
+	// given
+	repository, _, _, now := setupDispatcher(test, http.StatusOK)
+	_ = repository.Create(&model.Webhook{TenantID: "tenant-a", URL: "https://b.example.com", Events: []string{"*"}, Active: true})
+	publish(test, repository, *now, 7, `{"user":{"username":"jdoe","tenant_id":"tenant-a"}}`)
+	// The delivery for the second webhook failed to insert.
+	repository.deliveries = repository.deliveries[:1]
+
+	// when
+	publish(test, repository, *now, 7, `{"user":{"username":"jdoe","tenant_id":"tenant-a"}}`)
+
+	// then
+	assert.Len(test, repository.deliveries, 2)
+	assert.NotEqual(test, repository.deliveries[0].WebhookID, repository.deliveries[1].WebhookID)
+}
+
+func TestShouldRejectTamperedOrReplayedSignature(test *testing.T) {
+	// given
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":1}`)
+	signature := Sign("s3cret", now.Unix(), body)
+
+	// when
+	tampered := Verify("s3cret", signature, "1700000000", []byte(`{"id":2}`), now, time.Minute)
+	replayed := Verify("s3cret", signature, "1700000000", body, now.Add(time.Hour), time.Minute)
+
+	// then
+	assert.ErrorIs(test, tampered, ErrInvalidSignature)
+	assert.ErrorIs(test, replayed, ErrStaleTimestamp)
+}
+
+func TestShouldRetryFailedDeliveryWithBackoff(test *testing.T) {
+	// given
+	repository, dispatcher, receiver, now := setupDispatcher(test, http.StatusInternalServerError)
+	enqueue(test, repository, *now)
+
+	// when
+	succeeded, err := dispatcher.ProcessDue(context.Background())
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, 0, succeeded)
+	delivery := repository.deliveries[0]
+	assert.Equal(test, model.DeliveryStatusPending, delivery.Status)
+	assert.Equal(test, 1, delivery.Attempts)
+	assert.Equal(test, now.Add(dispatcher.config.BaseBackoff), delivery.NextAttemptAt)
+
+	// when
+	receiver.status = http.StatusNoContent
+	*now = now.Add(dispatcher.config.BaseBackoff)
+	succeeded, err = dispatcher.ProcessDue(context.Background())
+
+	// then
+	assert.NoError(test, err)
+	assert.Equal(test, 1, succeeded)
+	assert.Equal(test, model.DeliveryStatusSucceeded, repository.deliveries[0].Status)
+	assert.Equal(test, 0, repository.webhooks[1].ConsecutiveFailures)
+}
+
+func TestShouldDisableWebhookAfterRepeatedFailures(test *testing.T) {
+	// given
+	repository, dispatcher, receiver, now := setupDispatcher(test, http.StatusBadGateway)
+	enqueue(test, repository, *now)
+	enqueue(test, repository, *now)
+
+	// when
+	_, err := dispatcher.ProcessDue(context.Background())
+
+	// then
+	assert.NoError(test, err)
+	assert.Len(test, receiver.requests, 2)
+	assert.False(test, repository.webhooks[1].Active)
+	for _, delivery := range repository.deliveries {
+		assert.Equal(test, model.DeliveryStatusFailed, delivery.Status)
+		assert.Contains(test, delivery.LastError, "webhook disabled")
+	}
+
+	// when
+	repository.webhooks[1].Active = true
+	*now = now.Add(time.Hour)
+	_, err = dispatcher.ProcessDue(context.Background())
+
+	// then
+	assert.NoError(test, err)
+	assert.Len(test, receiver.requests, 2)
+}
+
+var _ repository.WebhookRepository = (*memoryWebhookRepository)(nil)
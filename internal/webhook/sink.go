@@ -0,0 +1,91 @@
+package webhook
+
+import (
+	"context"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type envelope struct {
+	ID        int64           `json:"id"`
+	Type      model.EventType `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Sink is an outbox sink that fans each event out into a pending delivery for
+// every active webhook of the event's tenant subscribed to it. Publishing an
+// event again only adds the deliveries that are still missing.
+type Sink struct {
+	webhooks repository.WebhookRepository
+	now      func() time.Time
+}
+
+func NewSink(webhooks repository.WebhookRepository) *Sink {
+	return &Sink{webhooks: webhooks, now: time.Now}
+}
+
+func (sink *Sink) Publish(_ context.Context, message model.OutboxMessage) error {
+	tenantID, err := eventTenant(message.Payload)
+	if err != nil {
+		return err
+	}
+	if tenantID == "" {
+		return nil
+	}
+
+	webhooks, err := sink.webhooks.GetActiveForEvent(tenantID, message.EventType)
+	if err != nil {
+		return fmt.Errorf("failed to load webhooks for %s: %w", message.EventType, err)
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(envelope{
+		ID:        message.ID,
+		Type:      message.EventType,
+		CreatedAt: message.CreatedAt.UTC(),
+		Data:      message.Payload,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	for _, webhook := range webhooks {
+		delivery := &model.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			TenantID:      webhook.TenantID,
+			OutboxID:      message.ID,
+			EventType:     message.EventType,
+			Payload:       payload,
+			Status:        model.DeliveryStatusPending,
+			NextAttemptAt: sink.now(),
+		}
+		if err := sink.webhooks.CreateDelivery(delivery); err != nil {
+			return fmt.Errorf("failed to enqueue delivery for webhook %d: %w", webhook.ID, err)
+		}
+	}
+	return nil
+}
+
+// eventTenant returns the tenant of the user an event is about. Created and
+// updated events carry the user, deleted events the tenant.
+func eventTenant(payload []byte) (string, error) {
+	var scope struct {
+		TenantID string `json:"tenant_id"`
+		User     struct {
+			TenantID string `json:"tenant_id"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(payload, &scope); err != nil {
+		return "", fmt.Errorf("failed to decode event payload: %w", err)
+	}
+	if scope.TenantID != "" {
+		return scope.TenantID, nil
+	}
+	return scope.User.TenantID, nil
+}
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION notify_user_cache_invalidation() RETURNS TRIGGER AS $$
+DECLARE
+    target users%ROWTYPE;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        target := OLD;
+    ELSE
+        target := NEW;
+    END IF;
+    PERFORM pg_notify('user_cache_invalidations', json_build_object(
+        'id', target.id,
+        'uuid', target.uuid,
+        'username', target.username
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_cache_invalidation AFTER INSERT OR UPDATE OR DELETE ON users
+    FOR EACH ROW EXECUTE FUNCTION notify_user_cache_invalidation();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS users_cache_invalidation ON users;
+DROP FUNCTION IF EXISTS notify_user_cache_invalidation();
+-- +goose StatementEnd
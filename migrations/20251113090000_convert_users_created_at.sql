@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- created_at was stored without a time zone by CURRENT_TIMESTAMP in the
+-- server's zone, which is UTC in every deployment of this service.
+ALTER TABLE users ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC';
+UPDATE users SET created_at = now() WHERE created_at IS NULL;
+ALTER TABLE users ALTER COLUMN created_at SET DEFAULT now(), ALTER COLUMN created_at SET NOT NULL;
+
+CREATE INDEX idx_users_created_at ON users (created_at, id);
+CREATE INDEX idx_users_updated_at ON users (updated_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_users_updated_at;
+DROP INDEX IF EXISTS idx_users_created_at;
+ALTER TABLE users ALTER COLUMN created_at DROP NOT NULL, ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE users ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC';
+-- +goose StatementEnd
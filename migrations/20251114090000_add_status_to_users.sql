@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Existing users are already in use, so they start out active; users created
+-- from now on start pending.
+ALTER TABLE users ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active'
+    CHECK (status IN ('pending', 'active', 'suspended', 'deactivated'));
+ALTER TABLE users ALTER COLUMN status SET DEFAULT 'pending';
+
+CREATE INDEX idx_users_status ON users (status);
+
+CREATE TABLE user_status_transitions (
+    id BIGSERIAL PRIMARY KEY,
+    user_uuid UUID NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    reason TEXT NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_user_status_transitions_user ON user_status_transitions (user_uuid, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_status_transitions;
+DROP INDEX IF EXISTS idx_users_status;
+ALTER TABLE users DROP COLUMN IF EXISTS status;
+-- +goose StatementEnd
@@ -0,0 +1,108 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE organizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    slug VARCHAR(63) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Existing users, sessions and API keys move to the default organization.
+INSERT INTO organizations (id, slug, name) VALUES ('00000000-0000-0000-0000-000000000001', 'default', 'Default');
+
+-- New rows take their tenant from the transaction's app.tenant_id, so an
+-- insert outside a tenant scope fails the NOT NULL constraint.
+ALTER TABLE users ADD COLUMN tenant_id UUID REFERENCES organizations (id);
+UPDATE users SET tenant_id = '00000000-0000-0000-0000-000000000001';
+ALTER TABLE users ALTER COLUMN tenant_id SET NOT NULL,
+    ALTER COLUMN tenant_id SET DEFAULT NULLIF(current_setting('app.tenant_id', true), '')::uuid;
+
+ALTER TABLE users DROP CONSTRAINT users_username_key, DROP CONSTRAINT users_email_key;
+ALTER TABLE users ADD CONSTRAINT users_tenant_username_key UNIQUE (tenant_id, username),
+    ADD CONSTRAINT users_tenant_email_key UNIQUE (tenant_id, email);
+
+ALTER TABLE auth_sessions ADD COLUMN tenant_id UUID NOT NULL
+    DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES organizations (id);
+ALTER TABLE auth_sessions ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE api_keys ADD COLUMN tenant_id UUID NOT NULL
+    DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES organizations (id);
+ALTER TABLE api_keys ALTER COLUMN tenant_id DROP DEFAULT;
+
+-- Tenant-scoped transactions switch to this role. It does not own the users
+-- table, so the policy below applies to it even when the application
+-- connects as the owner or a superuser.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'cruder_tenant') THEN
+        CREATE ROLE cruder_tenant NOLOGIN;
+    END IF;
+    EXECUTE format('GRANT cruder_tenant TO %I', current_user);
+END;
+$$;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO cruder_tenant;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO cruder_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO cruder_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO cruder_tenant;
+
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+CREATE POLICY users_tenant_isolation ON users TO cruder_tenant
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid)
+    WITH CHECK (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid);
+
+CREATE OR REPLACE FUNCTION notify_user_cache_invalidation() RETURNS TRIGGER AS $$
+DECLARE
+    target users%ROWTYPE;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        target := OLD;
+    ELSE
+        target := NEW;
+    END IF;
+    PERFORM pg_notify('user_cache_invalidations', json_build_object(
+        'id', target.id,
+        'uuid', target.uuid,
+        'username', target.username,
+        'tenant_id', target.tenant_id
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION notify_user_cache_invalidation() RETURNS TRIGGER AS $$
+DECLARE
+    target users%ROWTYPE;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        target := OLD;
+    ELSE
+        target := NEW;
+    END IF;
+    PERFORM pg_notify('user_cache_invalidations', json_build_object(
+        'id', target.id,
+        'uuid', target.uuid,
+        'username', target.username
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP POLICY IF EXISTS users_tenant_isolation ON users;
+ALTER TABLE users DISABLE ROW LEVEL SECURITY;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE ALL ON TABLES FROM cruder_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE ALL ON SEQUENCES FROM cruder_tenant;
+REVOKE ALL ON ALL TABLES IN SCHEMA public FROM cruder_tenant;
+REVOKE ALL ON ALL SEQUENCES IN SCHEMA public FROM cruder_tenant;
+DROP ROLE IF EXISTS cruder_tenant;
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE auth_sessions DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE users DROP CONSTRAINT users_tenant_username_key, DROP CONSTRAINT users_tenant_email_key;
+ALTER TABLE users ADD CONSTRAINT users_username_key UNIQUE (username),
+    ADD CONSTRAINT users_email_key UNIQUE (email);
+ALTER TABLE users DROP COLUMN IF EXISTS tenant_id;
+DROP TABLE IF EXISTS organizations;
+-- +goose StatementEnd
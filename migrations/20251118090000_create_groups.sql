@@ -0,0 +1,56 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE groups (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL DEFAULT NULLIF(current_setting('app.tenant_id', true), '')::uuid
+        REFERENCES organizations (id),
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT groups_tenant_name_key UNIQUE (tenant_id, name)
+);
+
+CREATE TABLE group_members (
+    group_id UUID NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    user_uuid UUID NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'member')),
+    joined_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (group_id, user_uuid)
+);
+
+CREATE INDEX idx_group_members_user ON group_members (user_uuid);
+
+-- Members of a subgroup are also members of its parents. The application
+-- keeps the graph acyclic.
+CREATE TABLE group_subgroups (
+    parent_id UUID NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    child_id UUID NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    PRIMARY KEY (parent_id, child_id),
+    CHECK (parent_id <> child_id)
+);
+
+CREATE INDEX idx_group_subgroups_child ON group_subgroups (child_id);
+
+ALTER TABLE groups ENABLE ROW LEVEL SECURITY;
+CREATE POLICY groups_tenant_isolation ON groups TO cruder_tenant
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid)
+    WITH CHECK (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid);
+
+-- Memberships and nesting are visible exactly when their groups are.
+ALTER TABLE group_members ENABLE ROW LEVEL SECURITY;
+CREATE POLICY group_members_tenant_isolation ON group_members TO cruder_tenant
+    USING (EXISTS (SELECT 1 FROM groups WHERE groups.id = group_id));
+
+ALTER TABLE group_subgroups ENABLE ROW LEVEL SECURITY;
+CREATE POLICY group_subgroups_tenant_isolation ON group_subgroups TO cruder_tenant
+    USING (EXISTS (SELECT 1 FROM groups WHERE groups.id = parent_id)
+        AND EXISTS (SELECT 1 FROM groups WHERE groups.id = child_id));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS group_subgroups;
+DROP TABLE IF EXISTS group_members;
+DROP TABLE IF EXISTS groups;
+-- +goose StatementEnd
@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The secret is encrypted by the application. last_counter is the time step
+-- of the last accepted code, so a code cannot be used twice.
+CREATE TABLE user_mfa (
+    user_uuid UUID PRIMARY KEY REFERENCES users (uuid) ON DELETE CASCADE,
+    secret BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    enabled_at TIMESTAMPTZ,
+    last_counter BIGINT
+);
+
+CREATE TABLE mfa_recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_uuid UUID NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+    code_hash BYTEA NOT NULL,
+    used_at TIMESTAMPTZ,
+    UNIQUE (user_uuid, code_hash)
+);
+
+-- Logins that passed the password check and wait for the second factor.
+CREATE TABLE mfa_challenges (
+    id BIGSERIAL PRIMARY KEY,
+    user_uuid UUID NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+    token_hash BYTEA NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    used_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_mfa_challenges_expires_at ON mfa_challenges (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS mfa_challenges;
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TABLE IF EXISTS user_mfa;
+-- +goose StatementEnd
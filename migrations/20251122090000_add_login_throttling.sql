@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Failed logins per key: a user UUID, an unknown login name or a client IP.
+-- Kept in Postgres so the limits hold across replicas.
+CREATE TABLE login_throttles (
+    key VARCHAR(320) PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ
+);
+
+CREATE INDEX idx_login_throttles_last_failed_at ON login_throttles (last_failed_at);
+
+-- Security-relevant events such as lockouts. Events about a client IP
+-- rather than a user have no user_uuid.
+CREATE TABLE audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_uuid UUID REFERENCES users (uuid) ON DELETE CASCADE,
+    action VARCHAR(50) NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    actor VARCHAR(255) NOT NULL,
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_audit_events_user ON audit_events (user_uuid, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_events;
+DROP TABLE IF EXISTS login_throttles;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The device a session was started from, shown to users listing their
+-- sessions. Existing sessions have none recorded.
+ALTER TABLE auth_sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+ALTER TABLE auth_sessions ADD COLUMN ip_address VARCHAR(45) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_auth_sessions_expires_at ON auth_sessions (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_auth_sessions_expires_at;
+ALTER TABLE auth_sessions DROP COLUMN IF EXISTS ip_address;
+ALTER TABLE auth_sessions DROP COLUMN IF EXISTS user_agent;
+-- +goose StatementEnd
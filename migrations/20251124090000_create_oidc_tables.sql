@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Applications signing in through the OpenID Connect provider. Public
+-- clients have no secret.
+CREATE TABLE oidc_clients (
+    id VARCHAR(64) PRIMARY KEY,
+    tenant_id UUID NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    secret_hash BYTEA,
+    redirect_uris TEXT[] NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE oidc_authorization_codes (
+    id BIGSERIAL PRIMARY KEY,
+    code_hash BYTEA NOT NULL UNIQUE,
+    client_id VARCHAR(64) NOT NULL REFERENCES oidc_clients (id) ON DELETE CASCADE,
+    user_uuid UUID NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scope TEXT NOT NULL,
+    nonce TEXT NOT NULL DEFAULT '',
+    code_challenge VARCHAR(128) NOT NULL,
+    auth_time TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ
+);
+
+-- code_id has no foreign key: tokens outlive the short-lived code they were
+-- exchanged for.
+CREATE TABLE oidc_access_tokens (
+    token_hash BYTEA PRIMARY KEY,
+    code_id BIGINT NOT NULL,
+    client_id VARCHAR(64) NOT NULL REFERENCES oidc_clients (id) ON DELETE CASCADE,
+    user_uuid UUID NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+    scope TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_oidc_authorization_codes_expires_at ON oidc_authorization_codes (expires_at);
+CREATE INDEX idx_oidc_access_tokens_code ON oidc_access_tokens (code_id);
+CREATE INDEX idx_oidc_access_tokens_expires_at ON oidc_access_tokens (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS oidc_access_tokens;
+DROP TABLE IF EXISTS oidc_authorization_codes;
+DROP TABLE IF EXISTS oidc_clients;
+-- +goose StatementEnd
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Existing webhooks received the events of every organization; they stay
+-- with the default organization.
+ALTER TABLE webhooks ADD COLUMN tenant_id UUID NOT NULL
+    DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES organizations (id);
+ALTER TABLE webhooks ALTER COLUMN tenant_id SET DEFAULT NULLIF(current_setting('app.tenant_id', true), '')::uuid;
+
+ALTER TABLE webhook_deliveries ADD COLUMN tenant_id UUID REFERENCES organizations (id);
+UPDATE webhook_deliveries SET tenant_id = webhooks.tenant_id FROM webhooks WHERE webhooks.id = webhook_deliveries.webhook_id;
+ALTER TABLE webhook_deliveries ALTER COLUMN tenant_id SET NOT NULL;
+
+CREATE INDEX idx_webhooks_tenant ON webhooks (tenant_id);
+
+ALTER TABLE webhooks ENABLE ROW LEVEL SECURITY;
+CREATE POLICY webhooks_tenant_isolation ON webhooks TO cruder_tenant
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid)
+    WITH CHECK (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid);
+
+ALTER TABLE webhook_deliveries ENABLE ROW LEVEL SECURITY;
+CREATE POLICY webhook_deliveries_tenant_isolation ON webhook_deliveries TO cruder_tenant
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid)
+    WITH CHECK (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP POLICY IF EXISTS webhook_deliveries_tenant_isolation ON webhook_deliveries;
+ALTER TABLE webhook_deliveries DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS webhooks_tenant_isolation ON webhooks;
+ALTER TABLE webhooks DISABLE ROW LEVEL SECURITY;
+DROP INDEX IF EXISTS idx_webhooks_tenant;
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS tenant_id;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Deliveries fanned out from the outbox remember their message, so a message
+-- published again after a partial failure is not delivered twice.
+-- Redeliveries have no outbox_id and never conflict.
+ALTER TABLE webhook_deliveries ADD COLUMN outbox_id BIGINT;
+CREATE UNIQUE INDEX idx_webhook_deliveries_outbox ON webhook_deliveries (webhook_id, outbox_id);
+
+-- Pending deliveries of disabled webhooks would all fire at once when the
+-- webhook is enabled again.
+UPDATE webhook_deliveries SET status = 'failed', last_error = 'webhook disabled'
+    FROM webhooks
+    WHERE webhooks.id = webhook_deliveries.webhook_id AND NOT webhooks.active AND webhook_deliveries.status = 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_webhook_deliveries_outbox;
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS outbox_id;
+-- +goose StatementEnd